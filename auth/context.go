@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ahummel25/user-auth-api/graphql/model"
+	"github.com/ahummel25/user-auth-api/service/token"
+)
+
+// Principal represents the authenticated caller of a request
+type Principal struct {
+	User   *model.User
+	Claims *token.JwtCustomClaim
+}
+
+// principalCtxKey is the context key for the Principal value stored in the context
+type principalCtxKey struct{}
+
+// NewContext returns a new context containing the authenticated principal
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalCtxKey{}, p)
+}
+
+// FromContext returns the principal stored in the context, or an error if the request is unauthenticated
+func FromContext(ctx context.Context) (*Principal, error) {
+	if p, ok := ctx.Value(principalCtxKey{}).(*Principal); ok && p != nil {
+		return p, nil
+	}
+	return nil, errors.New("no authenticated user found in context")
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/ahummel25/user-auth-api/config"
+	"github.com/ahummel25/user-auth-api/graphql/model"
+	"github.com/ahummel25/user-auth-api/service/token"
+	"github.com/ahummel25/user-auth-api/service/user"
+)
+
+// IntrospectionPath is where resource servers ask whether an access token is active (RFC 7662)
+const IntrospectionPath = "/oauth/introspect"
+
+// roleScopes are the scopes reported for each role. Admins hold every user scope as well.
+var roleScopes = map[model.Role]string{
+	model.RoleUser:  "user",
+	model.RoleAdmin: "admin user",
+}
+
+// IntrospectionResponse is the RFC 7662 introspection response. Only Active is set for tokens
+// which are not active.
+type IntrospectionResponse struct {
+	Active    bool       `json:"active"`
+	Subject   string     `json:"sub,omitempty"`
+	Role      model.Role `json:"role,omitempty"`
+	Scope     string     `json:"scope,omitempty"`
+	TokenType string     `json:"token_type,omitempty"`
+	ExpiresAt int64      `json:"exp,omitempty"`
+	IssuedAt  int64      `json:"iat,omitempty"`
+	NotBefore int64      `json:"nbf,omitempty"`
+	Issuer    string     `json:"iss,omitempty"`
+	Audience  []string   `json:"aud,omitempty"`
+	JTI       string     `json:"jti,omitempty"`
+}
+
+// IntrospectionHandler reports whether the access token in the "token" form parameter is active.
+// Callers authenticate with HTTP Basic client credentials configured in INTROSPECTION_CLIENTS.
+func IntrospectionHandler(users UserLoader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		configSupplier, err := config.FromContext(ctx)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		cfg, err := configSupplier.GetConfig()
+		if err != nil {
+			slog.Error("Failed to load config", "error", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		clientID, clientSecret, ok := r.BasicAuth()
+		if !ok || !validClient(cfg.IntrospectionClients, clientID, clientSecret) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="introspection"`)
+			writeOAuthError(w, http.StatusUnauthorized, "invalid_client")
+			return
+		}
+		rawToken := r.PostFormValue("token")
+		if rawToken == "" {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request")
+			return
+		}
+
+		response, err := introspect(ctx, users, rawToken)
+		if err != nil {
+			slog.Error("Failed to introspect access token", "error", err, "client_id", clientID)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, response)
+	})
+}
+
+// introspect validates the token and checks its subject still exists, reporting the user's
+// current role rather than the one the token was issued with. Restricted tokens report no role.
+func introspect(ctx context.Context, users UserLoader, rawToken string) (*IntrospectionResponse, error) {
+	inactive := &IntrospectionResponse{Active: false}
+
+	parsed, err := token.JwtValidate(ctx, rawToken)
+	if errors.Is(err, token.ErrTokenInvalid) || errors.Is(err, token.ErrTokenRevoked) {
+		return inactive, nil
+	} else if err != nil {
+		return nil, err
+	}
+	claims, ok := parsed.Claims.(*token.JwtCustomClaim)
+	if !ok {
+		return inactive, nil
+	}
+
+	currentUser, err := users.GetUserByID(ctx, claims.UserID)
+	if user.IsNotFound(err) {
+		return inactive, nil
+	} else if err != nil {
+		return nil, err
+	}
+	// Restricted tokens only grant their own scope, and leave out the role so that resource
+	// servers authorizing by role do not treat them as a session of the user
+	role, scope := currentUser.Role, roleScopes[currentUser.Role]
+	if claims.Scope != "" {
+		role, scope = "", claims.Scope
+	}
+
+	return &IntrospectionResponse{
+		Active:    true,
+		Subject:   claims.Subject,
+		Role:      role,
+		Scope:     scope,
+		TokenType: bearerScheme,
+		ExpiresAt: claims.ExpiresAt.Unix(),
+		IssuedAt:  claims.IssuedAt.Unix(),
+		NotBefore: claims.NotBefore.Unix(),
+		Issuer:    claims.Issuer,
+		Audience:  claims.Audience,
+		JTI:       claims.ID,
+	}, nil
+}
+
+// validClient compares the digest of the presented secret in constant time, so that the
+// response time reveals nothing about how much of it was correct
+func validClient(clients map[string]string, clientID, clientSecret string) bool {
+	digest := sha256.Sum256([]byte(clientSecret))
+	expected, ok := clients[clientID]
+	if !ok {
+		// Compare anyway so that unknown clients take as long as known ones
+		expected = hex.EncodeToString(make([]byte, sha256.Size))
+	}
+	match := subtle.ConstantTimeCompare([]byte(hex.EncodeToString(digest[:])), []byte(expected)) == 1
+	return ok && match
+}
+
+// writeOAuthError writes an OAuth 2.0 error response (RFC 6749 section 5.2)
+func writeOAuthError(w http.ResponseWriter, status int, errCode string) {
+	writeJSON(w, status, map[string]string{"error": errCode})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+
+	"github.com/ahummel25/user-auth-api/graphql/model"
+	"github.com/ahummel25/user-auth-api/service/token"
+	tokenMocks "github.com/ahummel25/user-auth-api/service/token/mocks"
+	"github.com/ahummel25/user-auth-api/service/user"
+	userMocks "github.com/ahummel25/user-auth-api/service/user/mocks"
+)
+
+func introspectRequest(
+	t *testing.T,
+	coll *userMocks.MockUserCollection,
+	revocations int64,
+	form url.Values,
+	clientID, clientSecret string,
+) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, IntrospectionPath, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if clientID != "" {
+		req.SetBasicAuth(clientID, clientSecret)
+	}
+	revokedColl := tokenMocks.NewMockRevokedTokenCollection(t)
+	revokedColl.On("CountDocuments", mock.Anything, mock.AnythingOfType("bson.M")).Return(revocations, nil).Maybe()
+	ctx := user.NewContext(req.Context(), user.GetUsersCollectionKey(), coll)
+	req = req.WithContext(token.NewContext(ctx, token.GetRevokedTokensCollectionKey(), revokedColl))
+
+	rec := httptest.NewRecorder()
+	IntrospectionHandler(user.New()).ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeIntrospection(t *testing.T, rec *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return body
+}
+
+func TestIntrospectionHandler(t *testing.T) {
+	userFilter := bson.M{"user_id": testUserID}
+	validToken, expiresAt, err := token.JwtGenerate(context.Background(), testUserID, model.RoleUser, token.NewFamilyID())
+	require.NoError(t, err)
+	tokenForm := url.Values{"token": {validToken}}
+
+	t.Run("active token", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockColl.On("FindOne", mock.Anything, userFilter).Return(mongo.NewSingleResultFromDocument(
+			bson.M{"user_id": testUserID, "role": model.RoleUser}, nil, nil))
+
+		body := decodeIntrospection(t, introspectRequest(t, mockColl, 0, tokenForm, testClientID, testClientSecret))
+		assert.Equal(t, true, body["active"])
+		assert.Equal(t, testUserID, body["sub"])
+		assert.Equal(t, "USER", body["role"])
+		assert.Equal(t, "user", body["scope"])
+		assert.Equal(t, "Bearer", body["token_type"])
+		assert.Equal(t, float64(expiresAt.Unix()), body["exp"])
+		assert.NotEmpty(t, body["jti"])
+	})
+
+	t.Run("reports the current role of the user", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockColl.On("FindOne", mock.Anything, userFilter).Return(mongo.NewSingleResultFromDocument(
+			bson.M{"user_id": testUserID, "role": model.RoleAdmin}, nil, nil))
+
+		body := decodeIntrospection(t, introspectRequest(t, mockColl, 0, tokenForm, testClientID, testClientSecret))
+		assert.Equal(t, true, body["active"])
+		assert.Equal(t, "ADMIN", body["role"])
+		assert.Equal(t, "admin user", body["scope"])
+	})
+
+	t.Run("reports the scope of restricted tokens", func(t *testing.T) {
+		restricted, _, err := token.JwtGenerateRestricted(context.Background(), testUserID, model.RoleUser, token.ScopeChangePassword)
+		require.NoError(t, err)
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockColl.On("FindOne", mock.Anything, userFilter).Return(mongo.NewSingleResultFromDocument(
+			bson.M{"user_id": testUserID, "role": model.RoleUser}, nil, nil))
+
+		form := url.Values{"token": {restricted}}
+		body := decodeIntrospection(t, introspectRequest(t, mockColl, 0, form, testClientID, testClientSecret))
+		assert.Equal(t, true, body["active"])
+		assert.Equal(t, "change_password", body["scope"])
+		assert.NotContains(t, body, "role")
+	})
+
+	inactive := []struct {
+		name        string
+		form        url.Values
+		revocations int64
+		userErr     error
+	}{
+		{name: "malformed token", form: url.Values{"token": {"not-a-token"}}},
+		{name: "revoked token", form: tokenForm, revocations: 1},
+		{name: "user no longer exists", form: tokenForm, userErr: mongo.ErrNoDocuments},
+	}
+	for _, tt := range inactive {
+		t.Run(tt.name, func(t *testing.T) {
+			mockColl := userMocks.NewMockUserCollection(t)
+			if tt.userErr != nil {
+				mockColl.On("FindOne", mock.Anything, userFilter).
+					Return(mongo.NewSingleResultFromDocument(bson.M{}, tt.userErr, nil))
+			}
+
+			rec := introspectRequest(t, mockColl, tt.revocations, tt.form, testClientID, testClientSecret)
+			assert.JSONEq(t, `{"active":false}`, rec.Body.String())
+			assert.Equal(t, http.StatusOK, rec.Code)
+		})
+	}
+
+	t.Run("expired token", func(t *testing.T) {
+		expired := jwt.NewWithClaims(jwt.SigningMethodES256, &token.JwtCustomClaim{
+			UserID: testUserID,
+			Role:   model.RoleUser,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        "token-id",
+				Issuer:    "user-auth-api",
+				Subject:   testUserID,
+				Audience:  jwt.ClaimStrings{"user-auth-api"},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+				NotBefore: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+				IssuedAt:  jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+			},
+		})
+		expired.Header["kid"] = testKeyID
+		signed, err := expired.SignedString(testSigningKey)
+		require.NoError(t, err)
+
+		rec := introspectRequest(t, userMocks.NewMockUserCollection(t), 0,
+			url.Values{"token": {signed}}, testClientID, testClientSecret)
+		assert.JSONEq(t, `{"active":false}`, rec.Body.String())
+	})
+
+	t.Run("client authentication", func(t *testing.T) {
+		tests := []struct {
+			name, clientID, clientSecret string
+		}{
+			{name: "no credentials"},
+			{name: "wrong secret", clientID: testClientID, clientSecret: "guess"},
+			{name: "unknown client", clientID: "unknown", clientSecret: testClientSecret},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				rec := introspectRequest(t, userMocks.NewMockUserCollection(t), 0, tokenForm, tt.clientID, tt.clientSecret)
+				assert.Equal(t, http.StatusUnauthorized, rec.Code)
+				assert.Contains(t, rec.Header().Get("WWW-Authenticate"), "Basic")
+				assert.JSONEq(t, `{"error":"invalid_client"}`, rec.Body.String())
+			})
+		}
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		rec := introspectRequest(t, userMocks.NewMockUserCollection(t), 0, url.Values{}, testClientID, testClientSecret)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"error":"invalid_request"}`, rec.Body.String())
+	})
+}
@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/ahummel25/user-auth-api/service/token"
+)
+
+// JWKSPath is where the public signing keys are published for other services
+const JWKSPath = "/.well-known/jwks.json"
+
+// jwksMaxAge bounds how long verifiers cache the key set. Keys are published ahead of their
+// activation, so this only needs to be shorter than the gap between publishing and activating.
+const jwksMaxAge = "max-age=300"
+
+// JWKSHandler serves the public keys used to verify access tokens as a JSON Web Key Set
+func JWKSHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		set, err := token.PublicJWKS(r.Context())
+		if err != nil {
+			slog.Error("Failed to load JWT signing keys", "error", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, "+jwksMaxAge)
+		_ = json.NewEncoder(w).Encode(set)
+	})
+}
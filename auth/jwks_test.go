@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ahummel25/user-auth-api/service/token"
+)
+
+func TestJWKSHandler(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, JWKSPath, nil)
+	rec := httptest.NewRecorder()
+	JWKSHandler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Cache-Control"), "max-age=")
+
+	var set token.JWKS
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &set))
+	require.Len(t, set.Keys, 1)
+	assert.Equal(t, testKeyID, set.Keys[0].KeyID)
+	assert.Equal(t, "EC", set.Keys[0].KeyType)
+	assert.Equal(t, "ES256", set.Keys[0].Algorithm)
+	assert.NotContains(t, rec.Body.String(), `"d"`)
+}
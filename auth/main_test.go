@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"testing"
+	"time"
+)
+
+const (
+	testKeyID        = "auth-test"
+	testClientID     = "orders-api"
+	testClientSecret = "orders-api-secret"
+)
+
+// testSigningKey is configured as the only access token signing key for the tests in this package
+var testSigningKey *ecdsa.PrivateKey
+
+func TestMain(m *testing.M) {
+	var err error
+	if testSigningKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		panic(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(testSigningKey)
+	if err != nil {
+		panic(err)
+	}
+	keys, err := json.Marshal([]map[string]any{{
+		"kid":        testKeyID,
+		"alg":        "ES256",
+		"privateKey": string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"activeFrom": time.Now().Add(-time.Hour),
+	}})
+	if err != nil {
+		panic(err)
+	}
+	_ = os.Setenv("JWT_SIGNING_KEYS", string(keys))
+	secretDigest := sha256.Sum256([]byte(testClientSecret))
+	_ = os.Setenv("INTROSPECTION_CLIENTS", testClientID+":"+hex.EncodeToString(secretDigest[:]))
+	os.Exit(m.Run())
+}
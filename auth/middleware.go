@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ahummel25/user-auth-api/graphql/errcode"
+	"github.com/ahummel25/user-auth-api/graphql/model"
+	"github.com/ahummel25/user-auth-api/service/token"
+	"github.com/ahummel25/user-auth-api/service/user"
+)
+
+const bearerScheme = "Bearer"
+
+// UserLoader loads the user a validated token was issued to
+type UserLoader interface {
+	GetUserByID(ctx context.Context, userID string) (*model.User, error)
+}
+
+// Middleware validates the bearer token of each request, if one is present, and stores the
+// authenticated principal in the request context. Requests without credentials are passed
+// through unauthenticated so that public operations such as login keep working.
+func Middleware(users UserLoader) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			scheme, rawToken, found := strings.Cut(header, " ")
+			if header == "" || !strings.EqualFold(scheme, bearerScheme) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rawToken = strings.TrimSpace(rawToken)
+			if !found || rawToken == "" {
+				writeUnauthorized(w, "invalid_request", "missing bearer token")
+				return
+			}
+
+			ctx := r.Context()
+			parsed, err := token.JwtValidate(ctx, rawToken)
+			if err != nil {
+				if !errors.Is(err, token.ErrTokenRevoked) && !errors.Is(err, token.ErrTokenInvalid) {
+					slog.Error("Failed to validate access token", "error", err)
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					return
+				}
+				writeUnauthorized(w, "invalid_token", describeTokenError(err))
+				return
+			}
+			claims, ok := parsed.Claims.(*token.JwtCustomClaim)
+			if !ok || !parsed.Valid || claims.UserID == "" {
+				writeUnauthorized(w, "invalid_token", "token is invalid")
+				return
+			}
+
+			currentUser, err := users.GetUserByID(ctx, claims.UserID)
+			if err != nil {
+				if user.IsNotFound(err) {
+					writeUnauthorized(w, "invalid_token", "token subject no longer exists")
+					return
+				}
+				slog.Error("Failed to load authenticated user", "error", err, "user_id", claims.UserID)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			ctx = NewContext(ctx, &Principal{User: currentUser, Claims: claims})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// describeTokenError maps a token validation error to a client facing description
+func describeTokenError(err error) string {
+	if errors.Is(err, token.ErrTokenRevoked) {
+		return "token has been revoked"
+	}
+	if errors.Is(err, token.ErrTokenExpired) {
+		return "token is expired"
+	}
+	return "token is invalid"
+}
+
+// writeUnauthorized writes a 401 response in the GraphQL error format along with the
+// WWW-Authenticate challenge described by RFC 6750
+func writeUnauthorized(w http.ResponseWriter, errCode, description string) {
+	w.Header().Set("WWW-Authenticate",
+		fmt.Sprintf(`%s error=%q, error_description=%q`, bearerScheme, errCode, description))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	body := map[string]any{
+		"errors": []map[string]any{{
+			"message":    description,
+			"extensions": map[string]any{"code": errcode.Unauthenticated},
+		}},
+	}
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+
+	"github.com/ahummel25/user-auth-api/graphql/model"
+	"github.com/ahummel25/user-auth-api/service/token"
+	tokenMocks "github.com/ahummel25/user-auth-api/service/token/mocks"
+	"github.com/ahummel25/user-auth-api/service/user"
+	userMocks "github.com/ahummel25/user-auth-api/service/user/mocks"
+)
+
+const testUserID = "dfb8fe7f-56e4-47dc-b5bc-f6f0f524402b"
+
+func serve(t *testing.T, coll *userMocks.MockUserCollection, authorization string) (*httptest.ResponseRecorder, *Principal) {
+	return serveWithRevocations(t, coll, 0, authorization)
+}
+
+func serveWithRevocations(
+	t *testing.T,
+	coll *userMocks.MockUserCollection,
+	revocations int64,
+	authorization string,
+) (*httptest.ResponseRecorder, *Principal) {
+	t.Helper()
+	var principal *Principal
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = FromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+	req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	revokedColl := tokenMocks.NewMockRevokedTokenCollection(t)
+	revokedColl.On("CountDocuments", mock.Anything, mock.AnythingOfType("bson.M")).Return(revocations, nil).Maybe()
+	ctx := user.NewContext(req.Context(), user.GetUsersCollectionKey(), coll)
+	req = req.WithContext(token.NewContext(ctx, token.GetRevokedTokensCollectionKey(), revokedColl))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	Middleware(user.New())(next).ServeHTTP(rec, req)
+	return rec, principal
+}
+
+func TestMiddleware(t *testing.T) {
+	userFilter := bson.M{"user_id": testUserID}
+	userDoc := bson.M{"user_id": testUserID, "email": "test@example.com", "role": model.RoleAdmin}
+	validToken, _, err := token.JwtGenerate(context.Background(), testUserID, model.RoleAdmin, token.NewFamilyID())
+	require.NoError(t, err)
+
+	t.Run("no authorization header", func(t *testing.T) {
+		rec, principal := serve(t, userMocks.NewMockUserCollection(t), "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Nil(t, principal)
+	})
+
+	t.Run("non bearer scheme is ignored", func(t *testing.T) {
+		rec, principal := serve(t, userMocks.NewMockUserCollection(t), "Basic dXNlcjpwYXNz")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Nil(t, principal)
+	})
+
+	t.Run("valid token", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockColl.On("FindOne", mock.Anything, userFilter).
+			Return(mongo.NewSingleResultFromDocument(userDoc, nil, nil))
+
+		rec, principal := serve(t, mockColl, "Bearer "+validToken)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		require.NotNil(t, principal)
+		assert.Equal(t, testUserID, principal.User.ID)
+		assert.Equal(t, "test@example.com", principal.User.Email)
+		assert.Equal(t, testUserID, principal.Claims.UserID)
+		assert.Equal(t, model.RoleAdmin, principal.Claims.Role)
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		rec, principal := serve(t, userMocks.NewMockUserCollection(t), "Bearer ")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Contains(t, rec.Header().Get("WWW-Authenticate"), `error="invalid_request"`)
+		assert.Nil(t, principal)
+	})
+
+	t.Run("malformed token", func(t *testing.T) {
+		rec, principal := serve(t, userMocks.NewMockUserCollection(t), "Bearer not-a-token")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Contains(t, rec.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+		assert.JSONEq(t,
+			`{"errors":[{"message":"token is invalid","extensions":{"code":"UNAUTHENTICATED"}}]}`,
+			rec.Body.String())
+		assert.Nil(t, principal)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		expired := jwt.NewWithClaims(jwt.SigningMethodES256, &token.JwtCustomClaim{
+			UserID: testUserID,
+			Role:   model.RoleUser,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        "token-id",
+				Issuer:    "user-auth-api",
+				Subject:   testUserID,
+				Audience:  jwt.ClaimStrings{"user-auth-api"},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+				NotBefore: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+				IssuedAt:  jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+			},
+		})
+		expired.Header["kid"] = testKeyID
+		signed, err := expired.SignedString(testSigningKey)
+		require.NoError(t, err)
+
+		rec, principal := serve(t, userMocks.NewMockUserCollection(t), "Bearer "+signed)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Contains(t, rec.Header().Get("WWW-Authenticate"), `error_description="token is expired"`)
+		assert.Nil(t, principal)
+	})
+
+	t.Run("expired token with a forged signature", func(t *testing.T) {
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		forged := jwt.NewWithClaims(jwt.SigningMethodES256, &token.JwtCustomClaim{
+			UserID: testUserID,
+			Role:   model.RoleAdmin,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        "token-id",
+				Issuer:    "user-auth-api",
+				Subject:   testUserID,
+				Audience:  jwt.ClaimStrings{"user-auth-api"},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+				NotBefore: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+				IssuedAt:  jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+			},
+		})
+		forged.Header["kid"] = testKeyID
+		signed, err := forged.SignedString(otherKey)
+		require.NoError(t, err)
+
+		rec, principal := serve(t, userMocks.NewMockUserCollection(t), "Bearer "+signed)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Contains(t, rec.Header().Get("WWW-Authenticate"), `error_description="token is invalid"`)
+		assert.Nil(t, principal)
+	})
+
+	t.Run("revoked token", func(t *testing.T) {
+		rec, principal := serveWithRevocations(t, userMocks.NewMockUserCollection(t), 1, "Bearer "+validToken)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Contains(t, rec.Header().Get("WWW-Authenticate"), `error_description="token has been revoked"`)
+		assert.Nil(t, principal)
+	})
+
+	t.Run("user no longer exists", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockColl.On("FindOne", mock.Anything, userFilter).
+			Return(mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil))
+
+		rec, principal := serve(t, mockColl, "Bearer "+validToken)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Nil(t, principal)
+	})
+
+	t.Run("user lookup fails", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockColl.On("FindOne", mock.Anything, userFilter).
+			Return(mongo.NewSingleResultFromDocument(bson.M{}, errors.New("database error"), nil))
+
+		rec, principal := serve(t, mockColl, "Bearer "+validToken)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Nil(t, principal)
+	})
+}
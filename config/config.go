@@ -2,8 +2,48 @@ package config
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
+)
+
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+	defaultJWTIssuer       = "user-auth-api"
+
+	defaultPasswordResetTokenTTL     = time.Hour
+	defaultEmailVerificationTokenTTL = 48 * time.Hour
+	defaultSMTPPort                  = 587
+	defaultMFAChallengeTTL           = 5 * time.Minute
+	defaultTOTPIssuer                = "user-auth-api"
+	defaultWebAuthnRPName            = "user-auth-api"
+	defaultWebAuthnChallengeTTL      = 5 * time.Minute
+	defaultLockoutThreshold          = 5
+	defaultLockoutIPThreshold        = 20
+	defaultLockoutDuration           = 15 * time.Minute
+	defaultLoginDelay                = time.Second
+	defaultPasswordHashAlgorithm     = "argon2id"
+	// Argon2id parameters recommended by OWASP, 19 MiB of memory and 2 passes on a single thread
+	defaultArgon2Memory            = 19 * 1024
+	defaultArgon2Iterations        = 2
+	defaultArgon2Parallelism       = 1
+	defaultBcryptCost              = 10
+	defaultPasswordMinLength       = 8
+	defaultPasswordMaxLength       = 128
+	defaultPasswordMinScore        = 2
+	defaultBreachedPasswordsAction = "block"
+	defaultPasswordHistorySize     = 5
+)
+
+var (
+	defaultJWTAudience          = []string{"user-auth-api"}
+	defaultJWTAllowedAlgorithms = []string{"RS256", "ES256", "EdDSA"}
 )
 
 // Supplier provides both database credentials and system configuration parameters
@@ -13,11 +53,92 @@ type Supplier interface {
 
 // config represents the configuration values from environment variables
 type config struct {
-	AppName    string
-	IAMRoleARN string
-	Cluster    string
-	Domain     string
-	IsDev      bool // Computed at runtime based on AWS_LAMBDA_FUNCTION_NAME
+	AppName         string
+	IAMRoleARN      string
+	Cluster         string
+	Domain          string
+	IsDev           bool          // Computed at runtime based on AWS_LAMBDA_FUNCTION_NAME
+	AccessTokenTTL  time.Duration // Lifetime of issued access tokens
+	RefreshTokenTTL time.Duration // Lifetime of issued refresh tokens
+	// JWTSigningKeys is the JSON encoded set of access token signing keys, or empty to read them
+	// from JWTSigningKeysFile
+	JWTSigningKeys     string
+	JWTSigningKeysFile string
+	JWTIssuer          string   // Issuer (iss) of access tokens, required when validating
+	JWTAudience        []string // Audiences (aud) of access tokens, validation requires one of them
+	// JWTAllowedAlgorithms lists the signing algorithms accepted when validating access tokens
+	JWTAllowedAlgorithms []string
+	// IntrospectionClients maps the client IDs allowed to call the token introspection endpoint
+	// to the hex encoded SHA-256 digest of their secret
+	IntrospectionClients map[string]string
+	// PasswordResetURL is the page of the client application where users choose a new password.
+	// The reset token is appended as the "token" query parameter.
+	PasswordResetURL      string
+	PasswordResetTokenTTL time.Duration // Lifetime of password reset tokens
+	// EmailVerificationURL is the page of the client application which confirms an email address.
+	// The verification token is appended as the "token" query parameter.
+	EmailVerificationURL      string
+	EmailVerificationTokenTTL time.Duration // Lifetime of email verification tokens
+	RequireVerifiedEmail      bool          // Whether Login refuses users who have not verified their email
+	// NotifyFile is the file outgoing messages are appended to in local development, or empty to
+	// write them to stdout
+	NotifyFile string
+	NotifyFrom string // Sender address of outgoing messages
+	// SMTPHost is the server outgoing messages are delivered through, or empty to not use SMTP
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	// MFAEncryptionKey is the base64 encoded 256-bit AES key TOTP secrets are encrypted with at rest
+	MFAEncryptionKey string
+	MFAChallengeTTL  time.Duration // Lifetime of the challenge tokens login returns to users with MFA
+	TOTPIssuer       string        // Issuer shown next to the account in authenticator apps
+	// WebAuthnRPID is the relying party ID passkeys are scoped to, the domain of the client
+	// application or a parent domain of it
+	WebAuthnRPID         string
+	WebAuthnRPName       string        // Relying party name shown by authenticators
+	WebAuthnOrigins      []string      // Origins of the client applications passkey ceremonies may come from
+	WebAuthnChallengeTTL time.Duration // Lifetime of passkey registration and login challenges
+	// LockoutThreshold is the number of consecutive failed logins with a username or email after
+	// which it is locked
+	LockoutThreshold int
+	// LockoutIPThreshold is the number of failed logins from a client IP, with any identifier,
+	// after which the IP is locked
+	LockoutIPThreshold int
+	// LockoutDuration is how long failed logins are remembered, and so how long a lock lasts
+	LockoutDuration time.Duration
+	LoginDelay      time.Duration // Wait after a failed login with an identifier, doubling with every failure
+	// PasswordHashAlgorithm is the algorithm new passwords are hashed with, argon2id or bcrypt.
+	// Hashes of the other algorithm, or of weaker parameters, are upgraded on login.
+	PasswordHashAlgorithm string
+	Argon2Memory          int // Memory argon2id uses, in KiB
+	Argon2Iterations      int // Number of passes argon2id makes over its memory
+	Argon2Parallelism     int // Number of threads argon2id uses
+	BcryptCost            int // Cost of bcrypt hashes, the base 2 logarithm of its number of rounds
+	PasswordMinLength     int // Minimum number of characters of new passwords
+	PasswordMaxLength     int // Maximum number of characters of new passwords
+	// PasswordRequiredClasses lists the character classes new passwords must contain, out of lower,
+	// upper, digit and symbol
+	PasswordRequiredClasses []string
+	// PasswordBannedWordsFile is a file of words, one per line, new passwords must not contain, or
+	// empty to ban none
+	PasswordBannedWordsFile string
+	// PasswordMinScore is the minimum estimated strength of new passwords, from 0 (guessable within a
+	// thousand guesses) to 4 (over ten billion guesses)
+	PasswordMinScore int
+	// BreachedPasswordsFile is a local copy of the Have I Been Pwned SHA-1 or NTLM password hashes,
+	// sorted by hash, new passwords are looked up in, or empty to not look them up
+	BreachedPasswordsFile string
+	// BreachedPasswordsAction is what happens to new passwords found in BreachedPasswordsFile, block
+	// to refuse them or warn to only log them
+	BreachedPasswordsAction string
+	// PasswordHistorySize is the number of previous passwords of a user kept, which new passwords of
+	// the user must differ from as well as from the current one. With 0 none are kept, and new
+	// passwords only have to differ from the current one.
+	PasswordHistorySize int
+	// PasswordMaxAge is how long passwords may be used before users must change them on login, or 0
+	// for passwords which never expire
+	PasswordMaxAge time.Duration
 }
 
 // configCtxKey is the context key for the Config value stored in the context
@@ -29,8 +150,9 @@ type envConfigSupplier struct {
 }
 
 var (
-	cfg  *config
-	once sync.Once
+	cfg    *config
+	cfgErr error
+	once   sync.Once
 )
 
 // GetConfig retrieves configuration from environment variables
@@ -43,11 +165,278 @@ func (cs *envConfigSupplier) GetConfig() (config, error) {
 			Cluster:    os.Getenv("DB_CLUSTER_NAME"),
 			Domain:     os.Getenv("DB_DOMAIN"),
 			IsDev:      isDev,
+
+			JWTSigningKeys:     os.Getenv("JWT_SIGNING_KEYS"),
+			JWTSigningKeysFile: os.Getenv("JWT_SIGNING_KEYS_FILE"),
+			JWTIssuer:          os.Getenv("JWT_ISSUER"),
+
+			JWTAudience:          listFromEnv("JWT_AUDIENCE", defaultJWTAudience),
+			JWTAllowedAlgorithms: listFromEnv("JWT_ALLOWED_ALGORITHMS", defaultJWTAllowedAlgorithms),
+
+			PasswordResetURL:     os.Getenv("PASSWORD_RESET_URL"),
+			EmailVerificationURL: os.Getenv("EMAIL_VERIFICATION_URL"),
+			NotifyFile:           os.Getenv("NOTIFY_FILE"),
+			NotifyFrom:           os.Getenv("NOTIFY_FROM"),
+
+			SMTPHost:     os.Getenv("SMTP_HOST"),
+			SMTPUsername: os.Getenv("SMTP_USERNAME"),
+			SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+
+			MFAEncryptionKey: os.Getenv("MFA_ENCRYPTION_KEY"),
+			TOTPIssuer:       os.Getenv("TOTP_ISSUER"),
+
+			WebAuthnRPID:    os.Getenv("WEBAUTHN_RP_ID"),
+			WebAuthnRPName:  os.Getenv("WEBAUTHN_RP_NAME"),
+			WebAuthnOrigins: listFromEnv("WEBAUTHN_ORIGINS", nil),
+
+			PasswordHashAlgorithm:   os.Getenv("PASSWORD_HASH_ALGORITHM"),
+			PasswordRequiredClasses: listFromEnv("PASSWORD_REQUIRED_CLASSES", nil),
+			PasswordBannedWordsFile: os.Getenv("PASSWORD_BANNED_WORDS_FILE"),
+			BreachedPasswordsFile:   os.Getenv("BREACHED_PASSWORDS_FILE"),
+			BreachedPasswordsAction: os.Getenv("BREACHED_PASSWORDS_ACTION"),
+		}
+		if cfg.JWTIssuer == "" {
+			cfg.JWTIssuer = defaultJWTIssuer
+		}
+		if cfg.TOTPIssuer == "" {
+			cfg.TOTPIssuer = defaultTOTPIssuer
+		}
+		if cfg.WebAuthnRPName == "" {
+			cfg.WebAuthnRPName = defaultWebAuthnRPName
+		}
+		if cfg.PasswordHashAlgorithm == "" {
+			cfg.PasswordHashAlgorithm = defaultPasswordHashAlgorithm
+		}
+		if cfg.BreachedPasswordsAction == "" {
+			cfg.BreachedPasswordsAction = defaultBreachedPasswordsAction
+		}
+		if cfg.AccessTokenTTL, cfgErr = durationFromEnv("ACCESS_TOKEN_TTL", defaultAccessTokenTTL); cfgErr != nil {
+			return
+		}
+		if cfg.RefreshTokenTTL, cfgErr = durationFromEnv("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL); cfgErr != nil {
+			return
+		}
+		if cfg.PasswordResetTokenTTL, cfgErr = durationFromEnv("PASSWORD_RESET_TOKEN_TTL", defaultPasswordResetTokenTTL); cfgErr != nil {
+			return
+		}
+		if cfg.EmailVerificationTokenTTL, cfgErr = durationFromEnv(
+			"EMAIL_VERIFICATION_TOKEN_TTL", defaultEmailVerificationTokenTTL,
+		); cfgErr != nil {
+			return
 		}
+		if cfg.RequireVerifiedEmail, cfgErr = boolFromEnv("REQUIRE_VERIFIED_EMAIL"); cfgErr != nil {
+			return
+		}
+		if cfg.SMTPPort, cfgErr = portFromEnv("SMTP_PORT", defaultSMTPPort); cfgErr != nil {
+			return
+		}
+		if cfg.MFAChallengeTTL, cfgErr = durationFromEnv("MFA_CHALLENGE_TTL", defaultMFAChallengeTTL); cfgErr != nil {
+			return
+		}
+		if cfg.WebAuthnChallengeTTL, cfgErr = durationFromEnv(
+			"WEBAUTHN_CHALLENGE_TTL", defaultWebAuthnChallengeTTL,
+		); cfgErr != nil {
+			return
+		}
+		if cfg.LockoutThreshold, cfgErr = countFromEnv("LOCKOUT_THRESHOLD", defaultLockoutThreshold); cfgErr != nil {
+			return
+		}
+		if cfg.LockoutIPThreshold, cfgErr = countFromEnv("LOCKOUT_IP_THRESHOLD", defaultLockoutIPThreshold); cfgErr != nil {
+			return
+		}
+		if cfg.LockoutDuration, cfgErr = durationFromEnv("LOCKOUT_DURATION", defaultLockoutDuration); cfgErr != nil {
+			return
+		}
+		if cfg.LoginDelay, cfgErr = durationFromEnv("LOGIN_DELAY", defaultLoginDelay); cfgErr != nil {
+			return
+		}
+		if cfg.Argon2Memory, cfgErr = countFromEnv("ARGON2_MEMORY", defaultArgon2Memory); cfgErr != nil {
+			return
+		}
+		if cfg.Argon2Iterations, cfgErr = countFromEnv("ARGON2_ITERATIONS", defaultArgon2Iterations); cfgErr != nil {
+			return
+		}
+		if cfg.Argon2Parallelism, cfgErr = countFromEnv("ARGON2_PARALLELISM", defaultArgon2Parallelism); cfgErr != nil {
+			return
+		}
+		if cfg.BcryptCost, cfgErr = countFromEnv("BCRYPT_COST", defaultBcryptCost); cfgErr != nil {
+			return
+		}
+		if cfg.PasswordMinLength, cfgErr = countFromEnv("PASSWORD_MIN_LENGTH", defaultPasswordMinLength); cfgErr != nil {
+			return
+		}
+		if cfg.PasswordMaxLength, cfgErr = countFromEnv("PASSWORD_MAX_LENGTH", defaultPasswordMaxLength); cfgErr != nil {
+			return
+		}
+		if cfg.PasswordMaxLength < cfg.PasswordMinLength {
+			cfgErr = fmt.Errorf("invalid PASSWORD_MAX_LENGTH: must be at least PASSWORD_MIN_LENGTH")
+			return
+		}
+		if cfg.PasswordMinScore, cfgErr = scoreFromEnv("PASSWORD_MIN_SCORE", defaultPasswordMinScore); cfgErr != nil {
+			return
+		}
+		if cfg.PasswordHistorySize, cfgErr = sizeFromEnv("PASSWORD_HISTORY_SIZE", defaultPasswordHistorySize); cfgErr != nil {
+			return
+		}
+		if cfg.PasswordMaxAge, cfgErr = optionalDurationFromEnv("PASSWORD_MAX_AGE"); cfgErr != nil {
+			return
+		}
+		cfg.IntrospectionClients, cfgErr = clientsFromEnv("INTROSPECTION_CLIENTS")
 	})
+	if cfgErr != nil {
+		return config{}, cfgErr
+	}
 	return *cfg, nil
 }
 
+// durationFromEnv parses a Go duration string (e.g. "15m") from the given environment variable,
+// returning the fallback when the variable is unset
+func durationFromEnv(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid %s: must be positive", key)
+	}
+	return d, nil
+}
+
+// optionalDurationFromEnv parses a duration (e.g. "2160h") from the given environment variable, where
+// 0 turns off what it limits, returning 0 when the variable is unset
+func optionalDurationFromEnv(key string) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid %s: must not be negative", key)
+	}
+	return d, nil
+}
+
+// boolFromEnv parses a boolean (e.g. "true" or "1") from the given environment variable, returning
+// false when the variable is unset
+func boolFromEnv(key string) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return b, nil
+}
+
+// portFromEnv parses a TCP port number from the given environment variable, returning the fallback
+// when the variable is unset
+func portFromEnv(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid %s: must be between 1 and 65535", key)
+	}
+	return port, nil
+}
+
+// countFromEnv parses a positive integer from the given environment variable, returning the
+// fallback when the variable is unset
+func countFromEnv(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if n < 1 {
+		return 0, fmt.Errorf("invalid %s: must be positive", key)
+	}
+	return n, nil
+}
+
+// sizeFromEnv parses a number of items to keep, which may be 0 to keep none, from the given
+// environment variable, returning the fallback when the variable is unset
+func sizeFromEnv(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("invalid %s: must not be negative", key)
+	}
+	return n, nil
+}
+
+// scoreFromEnv parses a password strength score, between 0 and 4, from the given environment
+// variable, returning the fallback when the variable is unset
+func scoreFromEnv(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	score, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if score < 0 || score > 4 {
+		return 0, fmt.Errorf("invalid %s: must be between 0 and 4", key)
+	}
+	return score, nil
+}
+
+// listFromEnv splits a comma separated list from the given environment variable, returning the
+// fallback when the variable is unset
+func listFromEnv(key string, fallback []string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return fallback
+	}
+	return values
+}
+
+// clientsFromEnv parses a comma separated list of "clientID:secretSHA256" pairs from the given
+// environment variable
+func clientsFromEnv(key string) (map[string]string, error) {
+	clients := make(map[string]string)
+	for _, entry := range listFromEnv(key, nil) {
+		id, digest, found := strings.Cut(entry, ":")
+		if !found || id == "" {
+			return nil, fmt.Errorf("invalid %s: expected clientID:secretSHA256", key)
+		}
+		if _, err := hex.DecodeString(digest); err != nil || len(digest) != sha256.Size*2 {
+			return nil, fmt.Errorf("invalid %s: secret of %q is not a hex encoded SHA-256 digest", key, id)
+		}
+		clients[id] = strings.ToLower(digest)
+	}
+	return clients, nil
+}
+
 // NewContext returns a new context containing the config
 func NewContext(ctx context.Context, s Supplier) context.Context {
 	return context.WithValue(ctx, configCtxKey{}, s)
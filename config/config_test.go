@@ -3,8 +3,10 @@ package config
 import (
 	"context"
 	"os"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -17,6 +19,54 @@ var (
 		"IAM_ROLE_ARN":             "",
 		"DB_CLUSTER_NAME":          "",
 		"DB_DOMAIN":                "",
+		"ACCESS_TOKEN_TTL":         "",
+		"REFRESH_TOKEN_TTL":        "",
+		"JWT_SIGNING_KEYS":         "",
+		"JWT_SIGNING_KEYS_FILE":    "",
+		"JWT_ISSUER":               "",
+		"JWT_AUDIENCE":             "",
+		"JWT_ALLOWED_ALGORITHMS":   "",
+		"INTROSPECTION_CLIENTS":    "",
+		"PASSWORD_RESET_URL":       "",
+		"PASSWORD_RESET_TOKEN_TTL": "",
+		"NOTIFY_FILE":              "",
+
+		"EMAIL_VERIFICATION_URL":       "",
+		"EMAIL_VERIFICATION_TOKEN_TTL": "",
+		"REQUIRE_VERIFIED_EMAIL":       "",
+
+		"NOTIFY_FROM":   "",
+		"SMTP_HOST":     "",
+		"SMTP_PORT":     "",
+		"SMTP_USERNAME": "",
+		"SMTP_PASSWORD": "",
+
+		"MFA_ENCRYPTION_KEY": "",
+		"MFA_CHALLENGE_TTL":  "",
+		"TOTP_ISSUER":        "",
+
+		"WEBAUTHN_RP_ID":             "",
+		"WEBAUTHN_RP_NAME":           "",
+		"WEBAUTHN_ORIGINS":           "",
+		"WEBAUTHN_CHALLENGE_TTL":     "",
+		"LOCKOUT_THRESHOLD":          "",
+		"LOCKOUT_IP_THRESHOLD":       "",
+		"LOCKOUT_DURATION":           "",
+		"LOGIN_DELAY":                "",
+		"PASSWORD_HASH_ALGORITHM":    "",
+		"ARGON2_MEMORY":              "",
+		"ARGON2_ITERATIONS":          "",
+		"ARGON2_PARALLELISM":         "",
+		"BCRYPT_COST":                "",
+		"PASSWORD_MIN_LENGTH":        "",
+		"PASSWORD_MAX_LENGTH":        "",
+		"PASSWORD_REQUIRED_CLASSES":  "",
+		"PASSWORD_BANNED_WORDS_FILE": "",
+		"BREACHED_PASSWORDS_FILE":    "",
+		"BREACHED_PASSWORDS_ACTION":  "",
+		"PASSWORD_HISTORY_SIZE":      "",
+		"PASSWORD_MAX_AGE":           "",
+		"PASSWORD_MIN_SCORE":         "",
 	}
 )
 
@@ -48,6 +98,7 @@ func (suite *ConfigTestSuite) SetupTest() {
 	}
 	// Reset singleton for each test
 	cfg = nil
+	cfgErr = nil
 	once = sync.Once{}
 }
 
@@ -67,6 +118,382 @@ func (suite *ConfigTestSuite) TestGetConfig_Development() {
 	suite.Assert().Equal("test-role", config.IAMRoleARN)
 	suite.Assert().Equal("test-cluster", config.Cluster)
 	suite.Assert().Equal("test-domain", config.Domain)
+	suite.Assert().Equal(defaultAccessTokenTTL, config.AccessTokenTTL)
+	suite.Assert().Equal(defaultRefreshTokenTTL, config.RefreshTokenTTL)
+	suite.Assert().Equal(defaultJWTIssuer, config.JWTIssuer)
+	suite.Assert().Equal(defaultJWTAudience, config.JWTAudience)
+	suite.Assert().Equal(defaultJWTAllowedAlgorithms, config.JWTAllowedAlgorithms)
+	suite.Assert().Empty(config.IntrospectionClients)
+	suite.Assert().Equal(defaultPasswordResetTokenTTL, config.PasswordResetTokenTTL)
+	suite.Assert().Empty(config.PasswordResetURL)
+	suite.Assert().Empty(config.NotifyFile)
+	suite.Assert().Equal(defaultEmailVerificationTokenTTL, config.EmailVerificationTokenTTL)
+	suite.Assert().False(config.RequireVerifiedEmail)
+	suite.Assert().Empty(config.SMTPHost)
+	suite.Assert().Equal(defaultSMTPPort, config.SMTPPort)
+}
+
+func (suite *ConfigTestSuite) TestGetConfig_TokenTTLs() {
+	_ = os.Setenv("ACCESS_TOKEN_TTL", "5m")
+	_ = os.Setenv("REFRESH_TOKEN_TTL", "168h")
+	_ = os.Setenv("PASSWORD_RESET_TOKEN_TTL", "30m")
+
+	supplier := &envConfigSupplier{}
+	config, err := supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Equal(5*time.Minute, config.AccessTokenTTL)
+	suite.Assert().Equal(168*time.Hour, config.RefreshTokenTTL)
+	suite.Assert().Equal(30*time.Minute, config.PasswordResetTokenTTL)
+}
+
+func (suite *ConfigTestSuite) TestGetConfig_PasswordReset() {
+	_ = os.Setenv("PASSWORD_RESET_URL", "https://app.example.com/reset-password")
+	_ = os.Setenv("NOTIFY_FILE", "/tmp/outbox.log")
+
+	supplier := &envConfigSupplier{}
+	config, err := supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Equal("https://app.example.com/reset-password", config.PasswordResetURL)
+	suite.Assert().Equal("/tmp/outbox.log", config.NotifyFile)
+}
+
+func (suite *ConfigTestSuite) TestGetConfig_JWTValidation() {
+	_ = os.Setenv("JWT_ISSUER", "https://auth.example.com")
+	_ = os.Setenv("JWT_AUDIENCE", "user-auth-api, orders-api,")
+	_ = os.Setenv("JWT_ALLOWED_ALGORITHMS", "ES256")
+
+	supplier := &envConfigSupplier{}
+	config, err := supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Equal("https://auth.example.com", config.JWTIssuer)
+	suite.Assert().Equal([]string{"user-auth-api", "orders-api"}, config.JWTAudience)
+	suite.Assert().Equal([]string{"ES256"}, config.JWTAllowedAlgorithms)
+}
+
+func (suite *ConfigTestSuite) TestGetConfig_IntrospectionClients() {
+	digest := strings.Repeat("ab", 32)
+	_ = os.Setenv("INTROSPECTION_CLIENTS", "orders-api:"+strings.ToUpper(digest)+", billing:"+digest)
+
+	supplier := &envConfigSupplier{}
+	config, err := supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Equal(map[string]string{"orders-api": digest, "billing": digest}, config.IntrospectionClients)
+}
+
+func (suite *ConfigTestSuite) TestGetConfig_InvalidIntrospectionClients() {
+	for _, value := range []string{"orders-api", ":" + strings.Repeat("ab", 32), "orders-api:plaintext"} {
+		_ = os.Setenv("INTROSPECTION_CLIENTS", value)
+		cfg, cfgErr, once = nil, nil, sync.Once{}
+
+		supplier := &envConfigSupplier{}
+		_, err := supplier.GetConfig()
+
+		suite.Require().Error(err, value)
+		suite.Assert().Contains(err.Error(), "INTROSPECTION_CLIENTS")
+	}
+}
+
+func (suite *ConfigTestSuite) TestGetConfig_EmailVerification() {
+	_ = os.Setenv("EMAIL_VERIFICATION_URL", "https://app.example.com/verify-email")
+	_ = os.Setenv("EMAIL_VERIFICATION_TOKEN_TTL", "24h")
+	_ = os.Setenv("REQUIRE_VERIFIED_EMAIL", "true")
+
+	supplier := &envConfigSupplier{}
+	config, err := supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Equal("https://app.example.com/verify-email", config.EmailVerificationURL)
+	suite.Assert().Equal(24*time.Hour, config.EmailVerificationTokenTTL)
+	suite.Assert().True(config.RequireVerifiedEmail)
+}
+
+func (suite *ConfigTestSuite) TestGetConfig_InvalidRequireVerifiedEmail() {
+	_ = os.Setenv("REQUIRE_VERIFIED_EMAIL", "sometimes")
+
+	supplier := &envConfigSupplier{}
+	_, err := supplier.GetConfig()
+
+	suite.Require().Error(err)
+	suite.Assert().Contains(err.Error(), "REQUIRE_VERIFIED_EMAIL")
+}
+
+func (suite *ConfigTestSuite) TestGetConfig_SMTP() {
+	_ = os.Setenv("NOTIFY_FROM", "no-reply@example.com")
+	_ = os.Setenv("SMTP_HOST", "smtp.example.com")
+	_ = os.Setenv("SMTP_PORT", "2525")
+	_ = os.Setenv("SMTP_USERNAME", "mailer")
+	_ = os.Setenv("SMTP_PASSWORD", "secret")
+
+	supplier := &envConfigSupplier{}
+	config, err := supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Equal("no-reply@example.com", config.NotifyFrom)
+	suite.Assert().Equal("smtp.example.com", config.SMTPHost)
+	suite.Assert().Equal(2525, config.SMTPPort)
+	suite.Assert().Equal("mailer", config.SMTPUsername)
+	suite.Assert().Equal("secret", config.SMTPPassword)
+}
+
+func (suite *ConfigTestSuite) TestGetConfig_InvalidSMTPPort() {
+	for _, value := range []string{"smtp", "0", "70000"} {
+		_ = os.Setenv("SMTP_PORT", value)
+		cfg, cfgErr, once = nil, nil, sync.Once{}
+
+		supplier := &envConfigSupplier{}
+		_, err := supplier.GetConfig()
+
+		suite.Require().Error(err, value)
+		suite.Assert().Contains(err.Error(), "SMTP_PORT")
+	}
+}
+
+func (suite *ConfigTestSuite) TestGetConfig_MFA() {
+	supplier := &envConfigSupplier{}
+	config, err := supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Empty(config.MFAEncryptionKey)
+	suite.Assert().Equal(defaultMFAChallengeTTL, config.MFAChallengeTTL)
+	suite.Assert().Equal(defaultTOTPIssuer, config.TOTPIssuer)
+
+	_ = os.Setenv("MFA_ENCRYPTION_KEY", "a2V5")
+	_ = os.Setenv("MFA_CHALLENGE_TTL", "2m")
+	_ = os.Setenv("TOTP_ISSUER", "Example")
+	cfg, cfgErr, once = nil, nil, sync.Once{}
+
+	config, err = supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Equal("a2V5", config.MFAEncryptionKey)
+	suite.Assert().Equal(2*time.Minute, config.MFAChallengeTTL)
+	suite.Assert().Equal("Example", config.TOTPIssuer)
+}
+
+func (suite *ConfigTestSuite) TestGetConfig_WebAuthn() {
+	supplier := &envConfigSupplier{}
+	config, err := supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Empty(config.WebAuthnRPID)
+	suite.Assert().Equal(defaultWebAuthnRPName, config.WebAuthnRPName)
+	suite.Assert().Empty(config.WebAuthnOrigins)
+	suite.Assert().Equal(defaultWebAuthnChallengeTTL, config.WebAuthnChallengeTTL)
+
+	_ = os.Setenv("WEBAUTHN_RP_ID", "example.com")
+	_ = os.Setenv("WEBAUTHN_RP_NAME", "Example")
+	_ = os.Setenv("WEBAUTHN_ORIGINS", "https://example.com, https://app.example.com")
+	_ = os.Setenv("WEBAUTHN_CHALLENGE_TTL", "2m")
+	cfg, cfgErr, once = nil, nil, sync.Once{}
+
+	config, err = supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Equal("example.com", config.WebAuthnRPID)
+	suite.Assert().Equal("Example", config.WebAuthnRPName)
+	suite.Assert().Equal([]string{"https://example.com", "https://app.example.com"}, config.WebAuthnOrigins)
+	suite.Assert().Equal(2*time.Minute, config.WebAuthnChallengeTTL)
+}
+
+func (suite *ConfigTestSuite) TestGetConfig_Lockout() {
+	supplier := &envConfigSupplier{}
+	config, err := supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Equal(defaultLockoutThreshold, config.LockoutThreshold)
+	suite.Assert().Equal(defaultLockoutIPThreshold, config.LockoutIPThreshold)
+	suite.Assert().Equal(defaultLockoutDuration, config.LockoutDuration)
+	suite.Assert().Equal(defaultLoginDelay, config.LoginDelay)
+
+	_ = os.Setenv("LOCKOUT_THRESHOLD", "3")
+	_ = os.Setenv("LOCKOUT_IP_THRESHOLD", "50")
+	_ = os.Setenv("LOCKOUT_DURATION", "1h")
+	_ = os.Setenv("LOGIN_DELAY", "500ms")
+	cfg, cfgErr, once = nil, nil, sync.Once{}
+
+	config, err = supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Equal(3, config.LockoutThreshold)
+	suite.Assert().Equal(50, config.LockoutIPThreshold)
+	suite.Assert().Equal(time.Hour, config.LockoutDuration)
+	suite.Assert().Equal(500*time.Millisecond, config.LoginDelay)
+
+	_ = os.Setenv("LOCKOUT_THRESHOLD", "0")
+	cfg, cfgErr, once = nil, nil, sync.Once{}
+
+	_, err = supplier.GetConfig()
+
+	suite.Require().Error(err)
+	suite.Assert().Contains(err.Error(), "LOCKOUT_THRESHOLD")
+}
+
+func (suite *ConfigTestSuite) TestGetConfig_PasswordHashing() {
+	supplier := &envConfigSupplier{}
+	config, err := supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Equal("argon2id", config.PasswordHashAlgorithm)
+	suite.Assert().Equal(19*1024, config.Argon2Memory)
+	suite.Assert().Equal(2, config.Argon2Iterations)
+	suite.Assert().Equal(1, config.Argon2Parallelism)
+	suite.Assert().Equal(10, config.BcryptCost)
+
+	_ = os.Setenv("PASSWORD_HASH_ALGORITHM", "bcrypt")
+	_ = os.Setenv("ARGON2_MEMORY", "65536")
+	_ = os.Setenv("ARGON2_ITERATIONS", "3")
+	_ = os.Setenv("ARGON2_PARALLELISM", "4")
+	_ = os.Setenv("BCRYPT_COST", "12")
+	cfg, cfgErr, once = nil, nil, sync.Once{}
+
+	config, err = supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Equal("bcrypt", config.PasswordHashAlgorithm)
+	suite.Assert().Equal(65536, config.Argon2Memory)
+	suite.Assert().Equal(3, config.Argon2Iterations)
+	suite.Assert().Equal(4, config.Argon2Parallelism)
+	suite.Assert().Equal(12, config.BcryptCost)
+}
+
+func (suite *ConfigTestSuite) TestGetConfig_PasswordPolicy() {
+	supplier := &envConfigSupplier{}
+	config, err := supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Equal(8, config.PasswordMinLength)
+	suite.Assert().Equal(128, config.PasswordMaxLength)
+	suite.Assert().Empty(config.PasswordRequiredClasses)
+	suite.Assert().Empty(config.PasswordBannedWordsFile)
+	suite.Assert().Equal(2, config.PasswordMinScore)
+
+	_ = os.Setenv("PASSWORD_MIN_LENGTH", "12")
+	_ = os.Setenv("PASSWORD_MAX_LENGTH", "72")
+	_ = os.Setenv("PASSWORD_REQUIRED_CLASSES", "upper, digit")
+	_ = os.Setenv("PASSWORD_BANNED_WORDS_FILE", "/etc/banned.txt")
+	_ = os.Setenv("PASSWORD_MIN_SCORE", "0")
+	cfg, cfgErr, once = nil, nil, sync.Once{}
+
+	config, err = supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Equal(12, config.PasswordMinLength)
+	suite.Assert().Equal(72, config.PasswordMaxLength)
+	suite.Assert().Equal([]string{"upper", "digit"}, config.PasswordRequiredClasses)
+	suite.Assert().Equal("/etc/banned.txt", config.PasswordBannedWordsFile)
+	suite.Assert().Zero(config.PasswordMinScore)
+
+	_ = os.Setenv("PASSWORD_MIN_SCORE", "5")
+	cfg, cfgErr, once = nil, nil, sync.Once{}
+
+	_, err = supplier.GetConfig()
+	suite.Assert().ErrorContains(err, "invalid PASSWORD_MIN_SCORE")
+
+	_ = os.Setenv("PASSWORD_MIN_SCORE", "")
+	_ = os.Setenv("PASSWORD_MAX_LENGTH", "10")
+	cfg, cfgErr, once = nil, nil, sync.Once{}
+
+	_, err = supplier.GetConfig()
+	suite.Assert().ErrorContains(err, "invalid PASSWORD_MAX_LENGTH")
+}
+
+func (suite *ConfigTestSuite) TestGetConfig_BreachedPasswords() {
+	supplier := &envConfigSupplier{}
+	config, err := supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Empty(config.BreachedPasswordsFile)
+	suite.Assert().Equal("block", config.BreachedPasswordsAction)
+
+	_ = os.Setenv("BREACHED_PASSWORDS_FILE", "/data/pwned-passwords-sha1-ordered-by-hash.txt")
+	_ = os.Setenv("BREACHED_PASSWORDS_ACTION", "warn")
+	cfg, cfgErr, once = nil, nil, sync.Once{}
+
+	config, err = supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Equal("/data/pwned-passwords-sha1-ordered-by-hash.txt", config.BreachedPasswordsFile)
+	suite.Assert().Equal("warn", config.BreachedPasswordsAction)
+}
+
+func (suite *ConfigTestSuite) TestGetConfig_PasswordHistory() {
+	supplier := &envConfigSupplier{}
+	config, err := supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Equal(5, config.PasswordHistorySize)
+
+	_ = os.Setenv("PASSWORD_HISTORY_SIZE", "12")
+	cfg, cfgErr, once = nil, nil, sync.Once{}
+
+	config, err = supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Equal(12, config.PasswordHistorySize)
+
+	// 0 keeps no previous passwords, so only the current one is compared
+	_ = os.Setenv("PASSWORD_HISTORY_SIZE", "0")
+	cfg, cfgErr, once = nil, nil, sync.Once{}
+
+	config, err = supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Zero(config.PasswordHistorySize)
+
+	_ = os.Setenv("PASSWORD_HISTORY_SIZE", "-1")
+	cfg, cfgErr, once = nil, nil, sync.Once{}
+
+	_, err = supplier.GetConfig()
+
+	suite.Require().Error(err)
+	suite.Assert().Contains(err.Error(), "PASSWORD_HISTORY_SIZE")
+}
+
+func (suite *ConfigTestSuite) TestGetConfig_PasswordMaxAge() {
+	supplier := &envConfigSupplier{}
+	config, err := supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Zero(config.PasswordMaxAge)
+
+	_ = os.Setenv("PASSWORD_MAX_AGE", "2160h")
+	cfg, cfgErr, once = nil, nil, sync.Once{}
+
+	config, err = supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Equal(90*24*time.Hour, config.PasswordMaxAge)
+
+	// 0 is accepted for passwords which never expire, like leaving it unset
+	_ = os.Setenv("PASSWORD_MAX_AGE", "0")
+	cfg, cfgErr, once = nil, nil, sync.Once{}
+
+	config, err = supplier.GetConfig()
+
+	suite.Require().NoError(err)
+	suite.Assert().Zero(config.PasswordMaxAge)
+
+	_ = os.Setenv("PASSWORD_MAX_AGE", "-1h")
+	cfg, cfgErr, once = nil, nil, sync.Once{}
+
+	_, err = supplier.GetConfig()
+
+	suite.Require().Error(err)
+	suite.Assert().Contains(err.Error(), "PASSWORD_MAX_AGE")
+}
+
+func (suite *ConfigTestSuite) TestGetConfig_InvalidTokenTTL() {
+	_ = os.Setenv("ACCESS_TOKEN_TTL", "soon")
+
+	supplier := &envConfigSupplier{}
+	_, err := supplier.GetConfig()
+
+	suite.Require().Error(err)
+	suite.Assert().Contains(err.Error(), "ACCESS_TOKEN_TTL")
 }
 
 func (suite *ConfigTestSuite) TestGetConfig_Production() {
@@ -76,6 +503,7 @@ func (suite *ConfigTestSuite) TestGetConfig_Production() {
 	_ = os.Setenv("IAM_ROLE_ARN", "prod-role")
 	_ = os.Setenv("DB_CLUSTER_NAME", "prod-cluster")
 	_ = os.Setenv("DB_DOMAIN", "prod-domain")
+	_ = os.Setenv("JWT_SIGNING_KEYS_FILE", "/opt/keys.json")
 
 	supplier := &envConfigSupplier{}
 	config, err := supplier.GetConfig()
@@ -86,6 +514,8 @@ func (suite *ConfigTestSuite) TestGetConfig_Production() {
 	suite.Assert().Equal("prod-role", config.IAMRoleARN)
 	suite.Assert().Equal("prod-cluster", config.Cluster)
 	suite.Assert().Equal("prod-domain", config.Domain)
+	suite.Assert().Empty(config.JWTSigningKeys)
+	suite.Assert().Equal("/opt/keys.json", config.JWTSigningKeysFile)
 }
 
 func (suite *ConfigTestSuite) TestContext() {
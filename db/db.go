@@ -11,6 +11,7 @@ import (
 	awsConfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 
@@ -22,13 +23,97 @@ type CollectionName string
 
 var (
 	// DB and collection names for users
-	usersDB         DBName         = "users"
-	usersCollection CollectionName = "users"
+	usersDB                    DBName         = "users"
+	usersCollection            CollectionName = "users"
+	refreshTokensCollection    CollectionName = "refresh_tokens"
+	revokedTokensCollection    CollectionName = "revoked_tokens"
+	actionTokensCollection     CollectionName = "action_tokens"
+	outboxCollection           CollectionName = "notification_outbox"
+	webAuthnSessionsCollection CollectionName = "webauthn_sessions"
+	loginAttemptsCollection    CollectionName = "login_attempts"
+)
+
+const (
+	// sentMessageRetention is how long delivered messages are kept in the outbox
+	sentMessageRetention = 7 * 24 * time.Hour
+	// failedMessageRetention is how long messages given up on are kept in the outbox for inspection
+	failedMessageRetention = 30 * 24 * time.Hour
 )
 
 // collectionToDBMap maps collection names to their respective database names
 var collectionToDBMap = map[CollectionName]DBName{
-	usersCollection: usersDB,
+	usersCollection:            usersDB,
+	refreshTokensCollection:    usersDB,
+	revokedTokensCollection:    usersDB,
+	actionTokensCollection:     usersDB,
+	outboxCollection:           usersDB,
+	webAuthnSessionsCollection: usersDB,
+	loginAttemptsCollection:    usersDB,
+}
+
+// collectionIndexes lists the indexes to ensure on a collection the first time it is fetched
+var collectionIndexes = map[CollectionName][]mongo.IndexModel{
+	usersCollection: {
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		// An email or user name belongs to a single user, even when users are written concurrently
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_name", Value: 1}}, Options: options.Index().SetUnique(true)},
+		// Back the sort orders of the users listing, with the user ID breaking ties
+		{Keys: bson.D{{Key: "creation_date", Value: 1}, {Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "email", Value: 1}, {Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "user_name", Value: 1}, {Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "last_login_date", Value: 1}, {Key: "user_id", Value: 1}}},
+		// A passkey belongs to a single user
+		{
+			Keys: bson.D{{Key: "passkeys.credential_id", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"passkeys.credential_id": bson.M{"$exists": true}}),
+		},
+	},
+	refreshTokensCollection: {
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "family_id", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		// Let Mongo purge refresh tokens once they expire
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+	revokedTokensCollection: {
+		{Keys: bson.D{{Key: "jti", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "revoked_before", Value: 1}}},
+		// Denylist entries are only needed until the tokens they cover expire
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+	actionTokensCollection: {
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "purpose", Value: 1}}},
+		// Let Mongo purge action tokens once they expire
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+	outboxCollection: {
+		{Keys: bson.D{{Key: "message_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		// Back the claim of the longest due pending message
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		// Let Mongo purge delivered messages after a while, and failed ones once they were kept
+		// for inspection long enough
+		{
+			Keys:    bson.D{{Key: "sent_date", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(sentMessageRetention.Seconds())),
+		},
+		{
+			Keys:    bson.D{{Key: "failed_date", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(failedMessageRetention.Seconds())),
+		},
+	},
+	webAuthnSessionsCollection: {
+		{Keys: bson.D{{Key: "challenge", Value: 1}}, Options: options.Index().SetUnique(true)},
+		// Let Mongo purge ceremonies once they expire
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+	loginAttemptsCollection: {
+		{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+		// Let Mongo purge failed logins once they are forgotten
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
 }
 
 // DBManager manages the database connection and collections
@@ -178,7 +263,13 @@ func (m *DBManager) getCollections(ctx context.Context, collectionNames []Collec
 			if !ok {
 				return nil, fmt.Errorf("invalid collection provided: %s", collectionName)
 			}
-			m.collections[collectionName] = m.connection.Database(string(dbName)).Collection(string(collectionName))
+			collection := m.connection.Database(string(dbName)).Collection(string(collectionName))
+			if indexes, ok := collectionIndexes[collectionName]; ok {
+				if _, err := collection.Indexes().CreateMany(ctx, indexes); err != nil {
+					return nil, fmt.Errorf("failed to create indexes for collection %s: %w", collectionName, err)
+				}
+			}
+			m.collections[collectionName] = collection
 		}
 	}
 
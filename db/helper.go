@@ -6,6 +6,10 @@ import (
 
 	"go.mongodb.org/mongo-driver/v2/mongo"
 
+	"github.com/ahummel25/user-auth-api/service/lockout"
+	"github.com/ahummel25/user-auth-api/service/notify"
+	"github.com/ahummel25/user-auth-api/service/passkey"
+	"github.com/ahummel25/user-auth-api/service/token"
 	"github.com/ahummel25/user-auth-api/service/user"
 )
 
@@ -20,7 +24,10 @@ func setupUserDBContext(ctx context.Context, dbManager DBContextManager) (contex
 		dbManager = globalDBManager
 	}
 
-	collectionsToGet := []CollectionName{usersCollection}
+	collectionsToGet := []CollectionName{
+		usersCollection, refreshTokensCollection, revokedTokensCollection, actionTokensCollection, outboxCollection,
+		webAuthnSessionsCollection, loginAttemptsCollection,
+	}
 	collections, err := dbManager.getCollections(ctx, collectionsToGet)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get collections: %w", err)
@@ -31,7 +38,43 @@ func setupUserDBContext(ctx context.Context, dbManager DBContextManager) (contex
 		return nil, fmt.Errorf("users collection not found in retrieved collections")
 	}
 
-	return user.NewContext(ctx, user.GetUsersCollectionKey(), userCollection), nil
+	refreshTokenCollection, exists := collections[refreshTokensCollection]
+	if !exists {
+		return nil, fmt.Errorf("refresh tokens collection not found in retrieved collections")
+	}
+
+	revokedTokenCollection, exists := collections[revokedTokensCollection]
+	if !exists {
+		return nil, fmt.Errorf("revoked tokens collection not found in retrieved collections")
+	}
+
+	actionTokenCollection, exists := collections[actionTokensCollection]
+	if !exists {
+		return nil, fmt.Errorf("action tokens collection not found in retrieved collections")
+	}
+
+	notificationOutboxCollection, exists := collections[outboxCollection]
+	if !exists {
+		return nil, fmt.Errorf("notification outbox collection not found in retrieved collections")
+	}
+
+	sessionsCollection, exists := collections[webAuthnSessionsCollection]
+	if !exists {
+		return nil, fmt.Errorf("WebAuthn sessions collection not found in retrieved collections")
+	}
+
+	attemptsCollection, exists := collections[loginAttemptsCollection]
+	if !exists {
+		return nil, fmt.Errorf("login attempts collection not found in retrieved collections")
+	}
+
+	ctx = user.NewContext(ctx, user.GetUsersCollectionKey(), userCollection)
+	ctx = token.NewContext(ctx, token.GetRefreshTokensCollectionKey(), refreshTokenCollection)
+	ctx = token.NewContext(ctx, token.GetRevokedTokensCollectionKey(), revokedTokenCollection)
+	ctx = token.NewContext(ctx, token.GetActionTokensCollectionKey(), actionTokenCollection)
+	ctx = passkey.NewContext(ctx, passkey.GetSessionsCollectionKey(), sessionsCollection)
+	ctx = lockout.NewContext(ctx, lockout.GetAttemptsCollectionKey(), attemptsCollection)
+	return notify.NewCollectionContext(ctx, notify.GetOutboxCollectionKey(), notificationOutboxCollection), nil
 }
 
 // SetupDBContext is maintained for backward compatibility
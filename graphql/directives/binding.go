@@ -22,6 +22,8 @@ func init() {
 	uni := ut.New(en, en)
 	trans, _ = uni.GetTranslator("en")
 	_ = enTranslations.RegisterDefaultTranslations(validate, trans)
+	// The default translations have no message for this tag
+	ValidateAddTranslation("bcp47_language_tag", " must be a BCP 47 language tag, e.g. en or es-MX")
 }
 
 // Binding implements the binding directive function and handles any field or input validation errors
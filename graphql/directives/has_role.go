@@ -5,11 +5,36 @@ import (
 	"fmt"
 
 	"github.com/99designs/gqlgen/graphql"
+
+	"github.com/ahummel25/user-auth-api/auth"
+	"github.com/ahummel25/user-auth-api/graphql/errcode"
 	"github.com/ahummel25/user-auth-api/graphql/model"
+	"github.com/ahummel25/user-auth-api/service/token"
 )
 
+// roleRank orders roles by privilege so that a higher ranked role satisfies any lower ranked one
+var roleRank = map[model.Role]int{
+	model.RoleUser:  1,
+	model.RoleAdmin: 2,
+}
+
+// HasRole implements the hasRole directive, rejecting callers who are not authenticated or whose
+// role does not satisfy the role required by the field
 func HasRole(ctx context.Context, obj interface{}, next graphql.Resolver, role model.Role, action model.Action,
 ) (res interface{}, err error) {
+	principal, err := auth.FromContext(ctx)
+	if err != nil {
+		return nil, errcode.New(errcode.Unauthenticated, "authentication required")
+	}
+	if !satisfiesRole(principal.User.Role, role) {
+		return nil, errcode.New(errcode.Forbidden, fmt.Sprintf("%s role required to %s", role, action))
+	}
+	// Users who must change their password get a token which permits nothing else, except ending it
+	if principal.Claims != nil && principal.Claims.Scope == token.ScopeChangePassword &&
+		action != model.ActionChangePassword && action != model.ActionLogout {
+		return nil, errcode.New(errcode.PasswordChangeRequired, fmt.Sprintf("password must be changed before %s", action))
+	}
+
 	fc := graphql.GetFieldContext(ctx).Args
 
 	switch action.String() {
@@ -18,19 +43,53 @@ func HasRole(ctx context.Context, obj interface{}, next graphql.Resolver, role m
 		if !ok {
 			return nil, fmt.Errorf("invalid user")
 		}
-	case model.ActionDeleteUser.String():
+	case model.ActionDeleteUser.String(), model.ActionUnlockUser.String(), model.ActionForcePasswordReset.String():
 		// The userID is directly available in the args
 		_, ok := fc["userID"].(string)
 		if !ok {
 			return nil, fmt.Errorf("invalid userID")
 		}
-		// Here you should implement the logic to check if the current user has the required role
-		// This might involve getting the current user from the context and checking their role
-		// For example:
-		// currentUser := auth.GetUserFromContext(ctx)
-		// if currentUser.Role != role {
-		//     return nil, fmt.Errorf("user does not have the required role")
-		// }
+	case model.ActionRevokeAllSessions.String():
+		userID, ok := fc["userID"].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid userID")
+		}
+		// Only admins may act on behalf of another user
+		if userID != principal.User.ID && !satisfiesRole(principal.User.Role, model.RoleAdmin) {
+			return nil, errcode.New(errcode.Forbidden, fmt.Sprintf("%s role required to %s of another user", model.RoleAdmin, action))
+		}
+	case model.ActionUpdateUser.String():
+		userID, ok := fc["id"].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid id")
+		}
+		input, ok := fc["input"].(model.UpdateUserInput)
+		if !ok {
+			return nil, fmt.Errorf("invalid input")
+		}
+		if !satisfiesRole(principal.User.Role, model.RoleAdmin) {
+			if userID != principal.User.ID {
+				return nil, errcode.New(errcode.Forbidden, fmt.Sprintf("%s role required to %s of another user", model.RoleAdmin, action))
+			}
+			// Users must not be able to grant themselves a role
+			if input.Role != nil {
+				return nil, errcode.New(errcode.Forbidden, fmt.Sprintf("%s role required to change the role of a user", model.RoleAdmin))
+			}
+			// A stolen session must not be enough to move the account to another email address, from
+			// where its password could be reset
+			if input.Email != nil && input.CurrentPassword == nil {
+				return nil, errcode.New(errcode.Forbidden, "current password required to change the email address")
+			}
+		}
 	}
 	return next(ctx)
 }
+
+// satisfiesRole reports whether the given role grants at least the privileges of the required role
+func satisfiesRole(role model.Role, required model.Role) bool {
+	rank, ok := roleRank[role]
+	if !ok {
+		return false
+	}
+	return rank >= roleRank[required]
+}
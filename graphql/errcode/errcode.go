@@ -0,0 +1,65 @@
+package errcode
+
+import (
+	"context"
+	"errors"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Codes surfaced under the "code" key of the GraphQL error extensions
+const (
+	// Unauthenticated indicates the request carries no valid credentials
+	Unauthenticated = "UNAUTHENTICATED"
+	// Forbidden indicates the caller is authenticated but lacks the required role
+	Forbidden = "FORBIDDEN"
+	// Conflict indicates the request was based on a stale version of the resource
+	Conflict = "CONFLICT"
+	// EmailNotVerified indicates the user must verify their email address before logging in
+	EmailNotVerified = "EMAIL_NOT_VERIFIED"
+	// AccountLocked indicates the username or email is temporarily locked after too many failed logins
+	AccountLocked = "ACCOUNT_LOCKED"
+	// TooManyAttempts indicates a login came too soon after failed ones and must be retried later
+	TooManyAttempts = "TOO_MANY_ATTEMPTS"
+	// PasswordPolicyViolation indicates a new password breaks rules of the password policy, which
+	// are listed under the "violations" key of the extensions
+	PasswordPolicyViolation = "PASSWORD_POLICY_VIOLATION"
+	// PasswordChangeRequired indicates the caller must change their password with changePassword,
+	// the only action their access token permits, before doing anything else
+	PasswordChangeRequired = "PASSWORD_CHANGE_REQUIRED"
+)
+
+// Error is an error carrying a machine readable code that is surfaced in the GraphQL error extensions
+type Error struct {
+	Code    string
+	Message string
+	// Extensions are surfaced in the GraphQL error extensions along with the code
+	Extensions map[string]interface{}
+}
+
+// New returns a new Error with the given code and message
+func New(code string, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Presenter is a gqlgen error presenter which adds the code of any Error in the chain to the
+// extensions of the resulting GraphQL error
+func Presenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+	var codeErr *Error
+	if errors.As(err, &codeErr) {
+		if gqlErr.Extensions == nil {
+			gqlErr.Extensions = map[string]interface{}{}
+		}
+		for key, value := range codeErr.Extensions {
+			gqlErr.Extensions[key] = value
+		}
+		gqlErr.Extensions["code"] = codeErr.Code
+	}
+	return gqlErr
+}
@@ -10,12 +10,34 @@ import (
 	"time"
 )
 
-// The input needed to authenticate a user.
+// The input needed to authenticate a user, either with their username or email and password, or with a passkey.
 type AuthParams struct {
 	// The user's username or email address
-	UsernameOrEmail string `json:"usernameOrEmail"`
+	UsernameOrEmail *string `json:"usernameOrEmail,omitempty"`
 	// The user's password
-	Password string `json:"password"`
+	Password *string `json:"password,omitempty"`
+	// The JSON encoded credential returned by navigator.credentials.get() for the options of beginPasskeyLogin. The passkey identifies the user.
+	Passkey *string `json:"passkey,omitempty"`
+}
+
+// The result of a successful authentication. Tokens are only issued once the user is AUTHENTICATED.
+type AuthPayload struct {
+	// The outcome of the authentication
+	Status AuthStatus `json:"status"`
+	// The authenticated user
+	User *User `json:"user,omitempty"`
+	// The signed access token to send as a bearer token on subsequent requests, restricted to changePassword when the status is PASSWORD_CHANGE_REQUIRED
+	AccessToken *string `json:"accessToken,omitempty"`
+	// The date and time at which the access token expires
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// The single-use token to exchange for a new token pair once the access token expires
+	RefreshToken *string `json:"refreshToken,omitempty"`
+	// The date and time at which the refresh token expires
+	RefreshTokenExpiresAt *time.Time `json:"refreshTokenExpiresAt,omitempty"`
+	// The challenge to pass to verifyMfa along with a code when the status is MFA_REQUIRED
+	MfaChallenge *string `json:"mfaChallenge,omitempty"`
+	// The date and time at which the MFA challenge expires
+	MfaChallengeExpiresAt *time.Time `json:"mfaChallengeExpiresAt,omitempty"`
 }
 
 type Mutation struct {
@@ -33,13 +55,69 @@ type NewUserInput struct {
 	UserName string `json:"userName"`
 	// The user's role
 	Role *Role `json:"role,omitempty"`
-	// The user's password
+	// The user's password, which must follow the password policy
 	Password string `json:"password"`
+	// The BCP 47 language tag of the language messages are sent to the user in. Defaults to English.
+	Locale *string `json:"locale,omitempty"`
+}
+
+// Information about the current page of a connection.
+type PageInfo struct {
+	// Whether more items follow this page
+	HasNextPage bool `json:"hasNextPage"`
+	// Whether items precede this page
+	HasPreviousPage bool `json:"hasPreviousPage"`
+	// The cursor of the first item of this page
+	StartCursor *string `json:"startCursor,omitempty"`
+	// The cursor of the last item of this page, to pass as `after` for the next page
+	EndCursor *string `json:"endCursor,omitempty"`
+}
+
+// A passkey registered by a user.
+type Passkey struct {
+	// The base64url encoded credential ID
+	ID string `json:"id"`
+	// The name the user gave the passkey
+	Name *string `json:"name,omitempty"`
+	// The date and time at which the passkey was registered
+	CreatedAt time.Time `json:"createdAt"`
+	// The date and time at which the passkey was last used to log in
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
 }
 
 type Query struct {
 }
 
+// A TOTP secret being enrolled, pending confirmation with confirmTotp.
+type TotpEnrollment struct {
+	// The base32 encoded secret, for authenticator apps which cannot scan the URI
+	Secret string `json:"secret"`
+	// The otpauth:// URI of the secret, usually shown as a QR code
+	URI string `json:"uri"`
+	// Single-use codes to use in place of a TOTP code, e.g. if the authenticator is lost. They are only shown once.
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// The changes to apply to an existing user. Fields which are omitted or null are left unchanged.
+type UpdateUserInput struct {
+	// The user's e-mail address
+	Email *string `json:"email,omitempty"`
+	// The user's first name
+	FirstName *string `json:"firstName,omitempty"`
+	// The user's last name
+	LastName *string `json:"lastName,omitempty"`
+	// The user's username
+	UserName *string `json:"userName,omitempty"`
+	// The user's role
+	Role *Role `json:"role,omitempty"`
+	// The BCP 47 language tag of the language messages are sent to the user in
+	Locale *string `json:"locale,omitempty"`
+	// The version of the user the changes are based on. The update is rejected if the user has changed since.
+	Version int `json:"version"`
+	// The user's current password, which users must give to change their own e-mail address
+	CurrentPassword *string `json:"currentPassword,omitempty"`
+}
+
 // An object representing an individual user.
 type User struct {
 	// The user's unique user ID
@@ -56,6 +134,52 @@ type User struct {
 	Role Role `json:"role"`
 	// The user's last login date
 	LastLoginDate *time.Time `json:"lastLoginDate,omitempty"`
+	// Whether the user has confirmed they receive mail at their e-mail address
+	EmailVerified bool `json:"emailVerified"`
+	// Whether the user must enter a code from their authenticator app to log in
+	MfaEnabled bool `json:"mfaEnabled"`
+	// The date and time at which the user's password was last set
+	PasswordChangedAt *time.Time `json:"passwordChangedAt,omitempty"`
+	// Whether the user must change their password on their next login
+	MustChangePassword bool `json:"mustChangePassword"`
+	// The passkeys the user can log in with
+	Passkeys []*Passkey `json:"passkeys"`
+	// The version of the user, incremented on every update
+	Version int `json:"version"`
+	// The BCP 47 language tag of the language messages are sent to the user in, e.g. en or es-MX
+	Locale *string `json:"locale,omitempty"`
+}
+
+// A page of users.
+type UserConnection struct {
+	// The users of this page
+	Edges []*UserEdge `json:"edges"`
+	// Information about this page
+	PageInfo *PageInfo `json:"pageInfo"`
+	// The total number of users matching the filter
+	TotalCount int `json:"totalCount"`
+}
+
+// A user in a paginated list along with its cursor.
+type UserEdge struct {
+	// The cursor of this user
+	Cursor string `json:"cursor"`
+	// The user
+	Node *User `json:"node"`
+}
+
+// The criteria a user must match to be listed. All given criteria must match.
+type UserFilter struct {
+	// Only list users with this role
+	Role *Role `json:"role,omitempty"`
+	// Only list users whose e-mail address starts with this prefix, ignoring case
+	EmailPrefix *string `json:"emailPrefix,omitempty"`
+	// Only list users whose username starts with this prefix, ignoring case
+	UserNamePrefix *string `json:"userNamePrefix,omitempty"`
+	// Only list users who last logged in at or after this date
+	LastLoginFrom *time.Time `json:"lastLoginFrom,omitempty"`
+	// Only list users who last logged in before this date
+	LastLoginTo *time.Time `json:"lastLoginTo,omitempty"`
 }
 
 type UserObject struct {
@@ -63,6 +187,14 @@ type UserObject struct {
 	User *User `json:"user"`
 }
 
+// The order to list users in.
+type UserSort struct {
+	// The field to sort by
+	Field UserSortField `json:"field"`
+	// The direction to sort in
+	Direction *SortDirection `json:"direction,omitempty"`
+}
+
 type Action string
 
 const (
@@ -70,16 +202,52 @@ const (
 	ActionCreateUser Action = "CREATE_USER"
 	// Delete User Action
 	ActionDeleteUser Action = "DELETE_USER"
+	// Logout Action
+	ActionLogout Action = "LOGOUT"
+	// Revoke All Sessions Action
+	ActionRevokeAllSessions Action = "REVOKE_ALL_SESSIONS"
+	// View Own Profile Action
+	ActionMe Action = "ME"
+	// Get User Action
+	ActionGetUser Action = "GET_USER"
+	// List Users Action
+	ActionListUsers Action = "LIST_USERS"
+	// Update User Action
+	ActionUpdateUser Action = "UPDATE_USER"
+	// Change Password Action
+	ActionChangePassword Action = "CHANGE_PASSWORD"
+	// Enroll MFA Action
+	ActionEnrollMfa Action = "ENROLL_MFA"
+	// Register Passkey Action
+	ActionRegisterPasskey Action = "REGISTER_PASSKEY"
+	// Manage Passkeys Action
+	ActionManagePasskeys Action = "MANAGE_PASSKEYS"
+	// Unlock User Action
+	ActionUnlockUser Action = "UNLOCK_USER"
+	// Force Password Reset Action
+	ActionForcePasswordReset Action = "FORCE_PASSWORD_RESET"
 )
 
 var AllAction = []Action{
 	ActionCreateUser,
 	ActionDeleteUser,
+	ActionLogout,
+	ActionRevokeAllSessions,
+	ActionMe,
+	ActionGetUser,
+	ActionListUsers,
+	ActionUpdateUser,
+	ActionChangePassword,
+	ActionEnrollMfa,
+	ActionRegisterPasskey,
+	ActionManagePasskeys,
+	ActionUnlockUser,
+	ActionForcePasswordReset,
 }
 
 func (e Action) IsValid() bool {
 	switch e {
-	case ActionCreateUser, ActionDeleteUser:
+	case ActionCreateUser, ActionDeleteUser, ActionLogout, ActionRevokeAllSessions, ActionMe, ActionGetUser, ActionListUsers, ActionUpdateUser, ActionChangePassword, ActionEnrollMfa, ActionRegisterPasskey, ActionManagePasskeys, ActionUnlockUser, ActionForcePasswordReset:
 		return true
 	}
 	return false
@@ -120,6 +288,67 @@ func (e Action) MarshalJSON() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// The outcome of an authentication attempt.
+type AuthStatus string
+
+const (
+	// The user is authenticated and the payload carries their tokens
+	AuthStatusAuthenticated AuthStatus = "AUTHENTICATED"
+	// The password was correct, but the user must complete their second factor with verifyMfa
+	AuthStatusMfaRequired AuthStatus = "MFA_REQUIRED"
+	// The password or passkey was correct, but the password has to be changed first. The access token only permits changePassword and logout, after which the user logs in again.
+	AuthStatusPasswordChangeRequired AuthStatus = "PASSWORD_CHANGE_REQUIRED"
+)
+
+var AllAuthStatus = []AuthStatus{
+	AuthStatusAuthenticated,
+	AuthStatusMfaRequired,
+	AuthStatusPasswordChangeRequired,
+}
+
+func (e AuthStatus) IsValid() bool {
+	switch e {
+	case AuthStatusAuthenticated, AuthStatusMfaRequired, AuthStatusPasswordChangeRequired:
+		return true
+	}
+	return false
+}
+
+func (e AuthStatus) String() string {
+	return string(e)
+}
+
+func (e *AuthStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = AuthStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid AuthStatus", str)
+	}
+	return nil
+}
+
+func (e AuthStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *AuthStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e AuthStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
 type Role string
 
 const (
@@ -176,3 +405,124 @@ func (e Role) MarshalJSON() ([]byte, error) {
 	e.MarshalGQL(&buf)
 	return buf.Bytes(), nil
 }
+
+type SortDirection string
+
+const (
+	// Ascending order
+	SortDirectionAsc SortDirection = "ASC"
+	// Descending order
+	SortDirectionDesc SortDirection = "DESC"
+)
+
+var AllSortDirection = []SortDirection{
+	SortDirectionAsc,
+	SortDirectionDesc,
+}
+
+func (e SortDirection) IsValid() bool {
+	switch e {
+	case SortDirectionAsc, SortDirectionDesc:
+		return true
+	}
+	return false
+}
+
+func (e SortDirection) String() string {
+	return string(e)
+}
+
+func (e *SortDirection) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SortDirection(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SortDirection", str)
+	}
+	return nil
+}
+
+func (e SortDirection) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *SortDirection) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e SortDirection) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+// The fields users can be sorted by.
+type UserSortField string
+
+const (
+	// The date the user was created
+	UserSortFieldCreationDate UserSortField = "CREATION_DATE"
+	// The user's e-mail address
+	UserSortFieldEmail UserSortField = "EMAIL"
+	// The user's username
+	UserSortFieldUserName UserSortField = "USER_NAME"
+	// The user's last login date. Users who never logged in sort first in ascending order.
+	UserSortFieldLastLoginDate UserSortField = "LAST_LOGIN_DATE"
+)
+
+var AllUserSortField = []UserSortField{
+	UserSortFieldCreationDate,
+	UserSortFieldEmail,
+	UserSortFieldUserName,
+	UserSortFieldLastLoginDate,
+}
+
+func (e UserSortField) IsValid() bool {
+	switch e {
+	case UserSortFieldCreationDate, UserSortFieldEmail, UserSortFieldUserName, UserSortFieldLastLoginDate:
+		return true
+	}
+	return false
+}
+
+func (e UserSortField) String() string {
+	return string(e)
+}
+
+func (e *UserSortField) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = UserSortField(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid UserSortField", str)
+	}
+	return nil
+}
+
+func (e UserSortField) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *UserSortField) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e UserSortField) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
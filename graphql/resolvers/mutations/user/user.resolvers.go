@@ -3,6 +3,7 @@ package user
 import (
 	"context"
 
+	"github.com/ahummel25/user-auth-api/auth"
 	"github.com/ahummel25/user-auth-api/graphql/model"
 	"github.com/ahummel25/user-auth-api/service/user"
 )
@@ -19,3 +20,111 @@ func (r *Resolver) CreateUser(ctx context.Context, params model.NewUserInput) (*
 func (r *Resolver) DeleteUser(ctx context.Context, userID string) (bool, error) {
 	return r.UserService.DeleteUser(ctx, userID)
 }
+
+func (r *Resolver) UnlockUser(ctx context.Context, userID string) (bool, error) {
+	return r.UserService.UnlockUser(ctx, userID)
+}
+
+func (r *Resolver) ForcePasswordReset(ctx context.Context, userID string) (bool, error) {
+	return r.UserService.ForcePasswordReset(ctx, userID)
+}
+
+func (r *Resolver) RefreshToken(ctx context.Context, refreshToken string) (*model.AuthPayload, error) {
+	return r.UserService.RefreshToken(ctx, refreshToken)
+}
+
+func (r *Resolver) Logout(ctx context.Context) (bool, error) {
+	principal, err := auth.FromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	return r.UserService.Logout(ctx, principal.Claims)
+}
+
+func (r *Resolver) RevokeAllSessions(ctx context.Context, userID string) (bool, error) {
+	return r.UserService.RevokeAllSessions(ctx, userID)
+}
+
+func (r *Resolver) UpdateUser(ctx context.Context, id string, input model.UpdateUserInput) (*model.User, error) {
+	return r.UserService.UpdateUser(ctx, id, input)
+}
+
+func (r *Resolver) ChangePassword(ctx context.Context, currentPassword string, newPassword string) (bool, error) {
+	principal, err := auth.FromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	return r.UserService.ChangePassword(ctx, principal.Claims, currentPassword, newPassword)
+}
+
+func (r *Resolver) RequestPasswordReset(ctx context.Context, email string) (bool, error) {
+	return r.UserService.RequestPasswordReset(ctx, email)
+}
+
+func (r *Resolver) ResetPassword(ctx context.Context, token string, newPassword string) (bool, error) {
+	return r.UserService.ResetPassword(ctx, token, newPassword)
+}
+
+func (r *Resolver) VerifyEmail(ctx context.Context, token string) (bool, error) {
+	return r.UserService.VerifyEmail(ctx, token)
+}
+
+func (r *Resolver) ResendVerification(ctx context.Context, email string) (bool, error) {
+	return r.UserService.ResendVerification(ctx, email)
+}
+
+func (r *Resolver) EnrollTotp(ctx context.Context) (*model.TotpEnrollment, error) {
+	principal, err := auth.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.UserService.EnrollTotp(ctx, principal.User.ID)
+}
+
+func (r *Resolver) ConfirmTotp(ctx context.Context, code string) (bool, error) {
+	principal, err := auth.FromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	return r.UserService.ConfirmTotp(ctx, principal.User.ID, code)
+}
+
+func (r *Resolver) VerifyMfa(ctx context.Context, challenge string, code string) (*model.AuthPayload, error) {
+	return r.UserService.VerifyMfa(ctx, challenge, code)
+}
+
+func (r *Resolver) RegenerateRecoveryCodes(ctx context.Context, code string) ([]string, error) {
+	principal, err := auth.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.UserService.RegenerateRecoveryCodes(ctx, principal.User.ID, code)
+}
+
+func (r *Resolver) BeginPasskeyRegistration(ctx context.Context, currentPassword string, code *string) (string, error) {
+	principal, err := auth.FromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return r.UserService.BeginPasskeyRegistration(ctx, principal.User.ID, currentPassword, code)
+}
+
+func (r *Resolver) FinishPasskeyRegistration(ctx context.Context, response string, name *string) (*model.Passkey, error) {
+	principal, err := auth.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.UserService.FinishPasskeyRegistration(ctx, principal.User.ID, response, name)
+}
+
+func (r *Resolver) DeletePasskey(ctx context.Context, id string) (bool, error) {
+	principal, err := auth.FromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	return r.UserService.DeletePasskey(ctx, principal.User.ID, id)
+}
+
+func (r *Resolver) BeginPasskeyLogin(ctx context.Context) (string, error) {
+	return r.UserService.BeginPasskeyLogin(ctx)
+}
@@ -2,16 +2,61 @@ package user
 
 import (
 	"context"
+	"errors"
 
+	"github.com/ahummel25/user-auth-api/auth"
 	"github.com/ahummel25/user-auth-api/graphql/model"
 	"github.com/ahummel25/user-auth-api/service/user"
 )
 
+// errInvalidAuthParams is returned for login params with neither or both a password and a passkey
+var errInvalidAuthParams = errors.New("login requires either a username or email and password, or a passkey")
+
 // Resolver contains the services that user query resolver calls into
 type Resolver struct {
 	UserService user.API
 }
 
-func (r *Resolver) Login(ctx context.Context, params model.AuthParams) (*model.UserObject, error) {
-	return r.UserService.Login(ctx, params.UsernameOrEmail, params.Password)
+func (r *Resolver) Login(ctx context.Context, params model.AuthParams) (*model.AuthPayload, error) {
+	switch {
+	case params.Passkey != nil && params.Password == nil:
+		return r.UserService.LoginWithPasskey(ctx, *params.Passkey)
+	case params.Passkey == nil && params.UsernameOrEmail != nil && params.Password != nil:
+		return r.UserService.Login(ctx, *params.UsernameOrEmail, *params.Password)
+	}
+	return nil, errInvalidAuthParams
+}
+
+func (r *Resolver) Me(ctx context.Context) (*model.User, error) {
+	principal, err := auth.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.UserService.GetUserByID(ctx, principal.User.ID)
+}
+
+func (r *Resolver) User(ctx context.Context, id string) (*model.User, error) {
+	return r.UserService.GetUserByID(ctx, id)
+}
+
+func (r *Resolver) Users(
+	ctx context.Context,
+	filter *model.UserFilter,
+	sort *model.UserSort,
+	first *int,
+	after *string,
+) (*model.UserConnection, error) {
+	pageSize := user.DefaultPageSize
+	if first != nil {
+		pageSize = *first
+	}
+	return r.UserService.ListUsers(ctx, filter, sort, pageSize, after)
+}
+
+func (r *Resolver) Passkeys(ctx context.Context) ([]*model.Passkey, error) {
+	principal, err := auth.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.UserService.ListPasskeys(ctx, principal.User.ID)
 }
@@ -14,7 +14,9 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/ahummel25/user-auth-api/auth"
 	"github.com/ahummel25/user-auth-api/graphql/directives"
+	"github.com/ahummel25/user-auth-api/graphql/errcode"
 	"github.com/ahummel25/user-auth-api/graphql/generated"
 	"github.com/ahummel25/user-auth-api/graphql/model"
 	"github.com/ahummel25/user-auth-api/graphql/resolvers"
@@ -22,6 +24,7 @@ import (
 	userMutation "github.com/ahummel25/user-auth-api/graphql/resolvers/mutations/user"
 	"github.com/ahummel25/user-auth-api/graphql/resolvers/query"
 	userQuery "github.com/ahummel25/user-auth-api/graphql/resolvers/query/user"
+	"github.com/ahummel25/user-auth-api/service/token"
 	userMocks "github.com/ahummel25/user-auth-api/service/user/mocks"
 	"github.com/ahummel25/user-auth-api/testutils"
 )
@@ -42,6 +45,21 @@ var (
 	mockPassword         = "mockPassword123"
 	mockUserName         = "mock_username"
 	mockRole             = model.RoleUser
+	mockAccessToken      = "mock.access.token"
+	mockRefreshToken     = "mock-refresh-token"
+	mockSessionID        = "5b0e2a8c-1f1e-4a38-9c63-3d1c0e0b7f11"
+	mockOtherUserID      = "0c6f3e0a-7d2b-4b8e-a4f5-2e9b1c7d8a90"
+	errInvalidRefresh    = errors.New("invalid refresh token")
+	errPasswordPolicy    = &errcode.Error{
+		Code:    errcode.PasswordPolicyViolation,
+		Message: "password must be at least 8 characters",
+		Extensions: map[string]interface{}{"violations": []map[string]string{
+			{"rule": "MIN_LENGTH", "message": "password must be at least 8 characters"},
+		}},
+	}
+	// passwordPolicyExtensions are the GraphQL error extensions of errPasswordPolicy
+	passwordPolicyExtensions = `{"code":"PASSWORD_POLICY_VIOLATION",` +
+		`"violations":[{"message":"password must be at least 8 characters","rule":"MIN_LENGTH"}]}`
 )
 
 var (
@@ -55,7 +73,10 @@ var (
 		  userName
 		  role
 		  lastLoginDate
+		  emailVerified
 		}
+		accessToken
+		expiresAt
 	  }
 	}`
 
@@ -75,6 +96,182 @@ var (
 	deleteUser = `mutation DeleteUser($userID: ID!) {
 		deleteUser(userID: $userID)
 	}`
+
+	unlockUser = `mutation UnlockUser($userID: ID!) {
+		unlockUser(userID: $userID)
+	}`
+
+	forcePasswordReset = `mutation ForcePasswordReset($userID: ID!) {
+		forcePasswordReset(userID: $userID)
+	}`
+
+	logout = `mutation Logout {
+		logout
+	}`
+
+	revokeAllSessions = `mutation RevokeAllSessions($userID: ID!) {
+		revokeAllSessions(userID: $userID)
+	}`
+
+	updateUser = `mutation UpdateUser($id: ID!, $input: UpdateUserInput!) {
+	  updateUser(id: $id, input: $input) {
+		id
+		firstName
+		version
+	  }
+	}`
+
+	changePassword = `mutation ChangePassword($currentPassword: String!, $newPassword: String!) {
+		changePassword(currentPassword: $currentPassword, newPassword: $newPassword)
+	}`
+
+	requestPasswordReset = `mutation RequestPasswordReset($email: String!) {
+		requestPasswordReset(email: $email)
+	}`
+
+	resetPassword = `mutation ResetPassword($token: String!, $newPassword: String!) {
+		resetPassword(token: $token, newPassword: $newPassword)
+	}`
+
+	verifyEmail = `mutation VerifyEmail($token: String!) {
+		verifyEmail(token: $token)
+	}`
+
+	resendVerification = `mutation ResendVerification($email: String!) {
+		resendVerification(email: $email)
+	}`
+
+	loginStatus = `query Login($usernameOrEmail: String!, $password: String!) {
+	  auth: login(params: {usernameOrEmail: $usernameOrEmail, password: $password}) {
+		status
+		user {
+		  id
+		}
+		accessToken
+		mfaChallenge
+		mfaChallengeExpiresAt
+	  }
+	}`
+
+	enrollTotp = `mutation EnrollTotp {
+	  enrollTotp {
+		secret
+		uri
+		recoveryCodes
+	  }
+	}`
+
+	confirmTotp = `mutation ConfirmTotp($code: String!) {
+		confirmTotp(code: $code)
+	}`
+
+	verifyMfa = `mutation VerifyMfa($challenge: String!, $code: String!) {
+	  verifyMfa(challenge: $challenge, code: $code) {
+		status
+		user {
+		  id
+		  mfaEnabled
+		}
+		accessToken
+		refreshToken
+	  }
+	}`
+
+	regenerateRecoveryCodes = `mutation RegenerateRecoveryCodes($code: String!) {
+		regenerateRecoveryCodes(code: $code)
+	}`
+
+	loginPasskey = `query Login($passkey: String) {
+	  auth: login(params: {passkey: $passkey}) {
+		status
+		user {
+		  id
+		}
+		accessToken
+	  }
+	}`
+
+	beginPasskeyRegistration = `mutation BeginPasskeyRegistration($currentPassword: String!, $code: String) {
+		beginPasskeyRegistration(currentPassword: $currentPassword, code: $code)
+	}`
+
+	finishPasskeyRegistration = `mutation FinishPasskeyRegistration($response: String!, $name: String) {
+	  finishPasskeyRegistration(response: $response, name: $name) {
+		id
+		name
+		createdAt
+		lastUsedAt
+	  }
+	}`
+
+	passkeys = `query Passkeys {
+	  passkeys {
+		id
+		name
+		createdAt
+		lastUsedAt
+	  }
+	}`
+
+	deletePasskey = `mutation DeletePasskey($id: ID!) {
+		deletePasskey(id: $id)
+	}`
+
+	beginPasskeyLogin = `mutation BeginPasskeyLogin {
+		beginPasskeyLogin
+	}`
+
+	getUser = `query User($id: ID!) {
+	  user(id: $id) {
+		id
+		email
+		role
+	  }
+	}`
+
+	listUsers = `query Users($filter: UserFilter, $sort: UserSort, $first: Int, $after: String) {
+	  users(filter: $filter, sort: $sort, first: $first, after: $after) {
+		edges {
+		  cursor
+		  node {
+			id
+			email
+		  }
+		}
+		pageInfo {
+		  hasNextPage
+		  hasPreviousPage
+		  startCursor
+		  endCursor
+		}
+		totalCount
+	  }
+	}`
+
+	me = `query Me {
+	  me {
+		id
+		firstName
+		lastName
+		email
+		userName
+		role
+		lastLoginDate
+		emailVerified
+	  }
+	}`
+
+	refreshToken = `mutation RefreshToken($refreshToken: String!) {
+	  refreshToken(refreshToken: $refreshToken) {
+		user {
+		  id
+		}
+		accessToken
+		expiresAt
+		refreshToken
+		refreshTokenExpiresAt
+	  }
+	}`
 )
 
 func setup(t *testing.T) (*client.Client, *userMocks.MockAPI) {
@@ -93,12 +290,35 @@ func setup(t *testing.T) (*client.Client, *userMocks.MockAPI) {
 	cfg.Directives.Binding = directives.Binding
 	cfg.Directives.HasRole = directives.HasRole
 	srv := handler.New(generated.NewExecutableSchema(cfg))
+	srv.SetErrorPresenter(errcode.Presenter)
 	srv.AddTransport(transport.GET{})
 	srv.AddTransport(transport.POST{})
 	c := client.New(srv)
 	return c, mockUserService
 }
 
+// asRole returns a client option which authenticates the request as a user with the given role
+func asRole(role model.Role) client.Option {
+	return func(bd *client.Request) {
+		principal := &auth.Principal{
+			User:   &model.User{ID: mockUserID, Role: role},
+			Claims: &token.JwtCustomClaim{UserID: mockUserID, Role: role, SessionID: mockSessionID},
+		}
+		bd.HTTP = bd.HTTP.WithContext(auth.NewContext(bd.HTTP.Context(), principal))
+	}
+}
+
+// asPasswordChangeRequired authenticates the request with a token only permitting changePassword and logout
+func asPasswordChangeRequired() client.Option {
+	return func(bd *client.Request) {
+		principal := &auth.Principal{
+			User:   &model.User{ID: mockUserID, Role: model.RoleUser, MustChangePassword: true},
+			Claims: &token.JwtCustomClaim{UserID: mockUserID, Role: model.RoleUser, Scope: token.ScopeChangePassword},
+		}
+		bd.HTTP = bd.HTTP.WithContext(auth.NewContext(bd.HTTP.Context(), principal))
+	}
+}
+
 func assertUserEqual(t *testing.T, expected, actual model.User) {
 	assert.Equal(t, expected.ID, actual.ID)
 	assert.Equal(t, expected.FirstName, actual.FirstName)
@@ -106,6 +326,7 @@ func assertUserEqual(t *testing.T, expected, actual model.User) {
 	assert.Equal(t, expected.Email, actual.Email)
 	assert.Equal(t, expected.UserName, actual.UserName)
 	assert.Equal(t, expected.Role, actual.Role)
+	assert.Equal(t, expected.EmailVerified, actual.EmailVerified)
 	if expected.LastLoginDate != nil {
 		assert.NotNil(t, actual.LastLoginDate)
 		assert.Equal(t, expected.LastLoginDate, actual.LastLoginDate)
@@ -114,6 +335,37 @@ func assertUserEqual(t *testing.T, expected, actual model.User) {
 	}
 }
 
+// userResponse is a User as returned by the API, where dates are serialized as strings
+type userResponse struct {
+	ID            string
+	FirstName     string
+	LastName      string
+	Email         string
+	UserName      string
+	Role          model.Role
+	LastLoginDate *string
+	EmailVerified bool
+}
+
+func (u userResponse) toModel(t *testing.T) model.User {
+	var lastLoginDate *time.Time
+	if u.LastLoginDate != nil {
+		parsedTime, err := time.Parse(time.RFC3339, *u.LastLoginDate)
+		require.NoError(t, err)
+		lastLoginDate = &parsedTime
+	}
+	return model.User{
+		ID:            u.ID,
+		FirstName:     u.FirstName,
+		LastName:      u.LastName,
+		Email:         u.Email,
+		UserName:      u.UserName,
+		Role:          u.Role,
+		LastLoginDate: lastLoginDate,
+		EmailVerified: u.EmailVerified,
+	}
+}
+
 func createMockUser() *model.User {
 	now := testutils.CurrentTime.Now()
 	return &model.User{
@@ -124,6 +376,21 @@ func createMockUser() *model.User {
 		UserName:      mockUserName,
 		Role:          mockRole,
 		LastLoginDate: &now,
+		EmailVerified: true,
+	}
+}
+
+func createMockAuthPayload(user *model.User) *model.AuthPayload {
+	accessToken, refreshToken := mockAccessToken, mockRefreshToken
+	expiresAt := testutils.CurrentTime.Now().Add(72 * time.Hour)
+	refreshTokenExpiresAt := testutils.CurrentTime.Now().Add(30 * 24 * time.Hour)
+	return &model.AuthPayload{
+		Status:                model.AuthStatusAuthenticated,
+		User:                  user,
+		AccessToken:           &accessToken,
+		ExpiresAt:             &expiresAt,
+		RefreshToken:          &refreshToken,
+		RefreshTokenExpiresAt: &refreshTokenExpiresAt,
 	}
 }
 
@@ -163,7 +430,7 @@ func Test_Login(t *testing.T) {
 			name: "Success",
 			setupMock: func(mockService *userMocks.MockAPI) {
 				mockService.On("Login", ctxMatcher, mockUserName, mockPassword).
-					Return(&model.UserObject{User: createMockUser()}, nil)
+					Return(createMockAuthPayload(createMockUser()), nil)
 			},
 			expectedLastLoginDate: testutils.TimePtr(testutils.CurrentTime.Now()),
 		},
@@ -174,7 +441,7 @@ func Test_Login(t *testing.T) {
 				oldTime := testutils.CurrentTime.Now().Add(-24 * time.Hour)
 				user.LastLoginDate = &oldTime
 				mockService.On("Login", ctxMatcher, mockUserName, mockPassword).
-					Return(&model.UserObject{User: user}, nil)
+					Return(createMockAuthPayload(user), nil)
 			},
 			expectedLastLoginDate: testutils.TimePtr(testutils.CurrentTime.Now().Add(-24 * time.Hour)),
 		},
@@ -211,7 +478,10 @@ func Test_Login(t *testing.T) {
 						UserName      string
 						Role          model.Role
 						LastLoginDate *string
+						EmailVerified bool
 					}
+					AccessToken string
+					ExpiresAt   string
 				}
 			}
 			err := c.Post(loginQuery, &response,
@@ -244,8 +514,13 @@ func Test_Login(t *testing.T) {
 					UserName:      response.Auth.User.UserName,
 					Role:          response.Auth.User.Role,
 					LastLoginDate: lastLoginTime,
+					EmailVerified: response.Auth.User.EmailVerified,
 				}
 				assertUserEqual(t, *expectedUser, actualUser)
+				assert.Equal(t, mockAccessToken, response.Auth.AccessToken)
+				expiresAt, err := time.Parse(time.RFC3339, response.Auth.ExpiresAt)
+				require.NoError(t, err)
+				assert.Equal(t, testutils.CurrentTime.Now().Add(72*time.Hour), expiresAt)
 			}
 		})
 	}
@@ -253,11 +528,12 @@ func Test_Login(t *testing.T) {
 
 func Test_CreateUser(t *testing.T) {
 	tests := []struct {
-		name              string
-		input             model.NewUserInput
-		setupMock         func(*userMocks.MockAPI, model.NewUserInput)
-		expectedError     string
-		expectedErrorPath string
+		name               string
+		input              model.NewUserInput
+		setupMock          func(*userMocks.MockAPI, model.NewUserInput)
+		expectedError      string
+		expectedErrorPath  string
+		expectedExtensions string
 	}{
 		{
 			name: "Success",
@@ -284,13 +560,17 @@ func Test_CreateUser(t *testing.T) {
 			expectedErrorPath: `["createUser"]`,
 		},
 		{
-			name: "Invalid password",
+			name: "Password breaking the policy",
 			input: model.NewUserInput{
 				Email: mockEmail, FirstName: mockFirstName, LastName: mockLastName,
 				UserName: mockUserName, Password: "123ABC",
 			},
-			expectedError:     "password must be at least 8 characters in length",
-			expectedErrorPath: `["createUser","user","password"]`,
+			setupMock: func(mockService *userMocks.MockAPI, input model.NewUserInput) {
+				mockService.On("CreateUser", ctxMatcher, input).Return(nil, errPasswordPolicy)
+			},
+			expectedError:      errPasswordPolicy.Error(),
+			expectedErrorPath:  `["createUser"]`,
+			expectedExtensions: passwordPolicyExtensions,
 		},
 		{
 			name: "Invalid email",
@@ -311,11 +591,15 @@ func Test_CreateUser(t *testing.T) {
 			}
 
 			var response struct{ CreateUser struct{ model.User } }
-			err := c.Post(createUser, &response, client.Var("newUserInput", tt.input))
+			err := c.Post(createUser, &response, client.Var("newUserInput", tt.input), asRole(model.RoleAdmin))
 
 			if tt.expectedError != "" {
+				expected := `{"message":"` + tt.expectedError + `","path":` + tt.expectedErrorPath
+				if tt.expectedExtensions != "" {
+					expected += `,"extensions":` + tt.expectedExtensions
+				}
 				require.Error(t, err)
-				require.EqualError(t, err, `[{"message":"`+tt.expectedError+`","path":`+tt.expectedErrorPath+`}]`)
+				require.EqualError(t, err, `[`+expected+`}]`)
 				assert.Empty(t, response)
 			} else {
 				require.NoError(t, err)
@@ -355,7 +639,7 @@ func Test_DeleteUser(t *testing.T) {
 			tt.setupMock(mockUserService)
 
 			var response struct{ DeleteUser bool }
-			err := c.Post(deleteUser, &response, client.Var("userID", mockUserID))
+			err := c.Post(deleteUser, &response, client.Var("userID", mockUserID), asRole(model.RoleAdmin))
 
 			if tt.expectedError != "" {
 				require.Error(t, err)
@@ -369,3 +653,1081 @@ func Test_DeleteUser(t *testing.T) {
 		})
 	}
 }
+
+func Test_UnlockUser(t *testing.T) {
+	c, mockUserService := setup(t)
+	mockUserService.On("UnlockUser", ctxMatcher, mockUserID).Return(true, nil)
+
+	var response struct{ UnlockUser bool }
+	err := c.Post(unlockUser, &response, client.Var("userID", mockUserID), asRole(model.RoleAdmin))
+
+	require.NoError(t, err)
+	assert.True(t, response.UnlockUser)
+}
+
+func Test_ForcePasswordReset(t *testing.T) {
+	c, mockUserService := setup(t)
+	mockUserService.On("ForcePasswordReset", ctxMatcher, mockUserID).Return(true, nil)
+
+	var response struct{ ForcePasswordReset bool }
+	err := c.Post(forcePasswordReset, &response, client.Var("userID", mockUserID), asRole(model.RoleAdmin))
+
+	require.NoError(t, err)
+	assert.True(t, response.ForcePasswordReset)
+}
+
+func Test_LoginLocked(t *testing.T) {
+	c, mockUserService := setup(t)
+	mockUserService.On("Login", ctxMatcher, mockUserName, mockPassword).
+		Return(nil, errcode.New(errcode.AccountLocked, "account is locked"))
+
+	var response struct{ Auth *struct{ AccessToken string } }
+	err := c.Post(loginQuery, &response,
+		client.Var("usernameOrEmail", mockUserName),
+		client.Var("password", mockPassword),
+	)
+
+	require.EqualError(t, err,
+		`[{"message":"account is locked","path":["auth"],"extensions":{"code":"ACCOUNT_LOCKED"}}]`)
+	assert.Nil(t, response.Auth)
+}
+
+func Test_RefreshToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		setupMock     func(*userMocks.MockAPI)
+		expectedError string
+	}{
+		{
+			name: "Success",
+			setupMock: func(mockService *userMocks.MockAPI) {
+				mockService.On("RefreshToken", ctxMatcher, mockRefreshToken).
+					Return(createMockAuthPayload(createMockUser()), nil)
+			},
+		},
+		{
+			name: "Invalid refresh token",
+			setupMock: func(mockService *userMocks.MockAPI) {
+				mockService.On("RefreshToken", ctxMatcher, mockRefreshToken).
+					Return(nil, errInvalidRefresh)
+			},
+			expectedError: errInvalidRefresh.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, mockUserService := setup(t)
+			tt.setupMock(mockUserService)
+
+			var response struct {
+				RefreshToken struct {
+					User                  struct{ ID string }
+					AccessToken           string
+					ExpiresAt             string
+					RefreshToken          string
+					RefreshTokenExpiresAt string
+				}
+			}
+			// Refreshing is public; the refresh token itself is the credential
+			err := c.Post(refreshToken, &response, client.Var("refreshToken", mockRefreshToken))
+
+			if tt.expectedError != "" {
+				require.Error(t, err)
+				require.EqualError(t, err, `[{"message":"`+tt.expectedError+`","path":["refreshToken"]}]`)
+				assert.Empty(t, response)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, mockUserID, response.RefreshToken.User.ID)
+				assert.Equal(t, mockAccessToken, response.RefreshToken.AccessToken)
+				assert.Equal(t, mockRefreshToken, response.RefreshToken.RefreshToken)
+				assert.NotEmpty(t, response.RefreshToken.RefreshTokenExpiresAt)
+			}
+			mockUserService.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_Logout(t *testing.T) {
+	claimsMatcher := mock.MatchedBy(func(claims *token.JwtCustomClaim) bool {
+		return claims.UserID == mockUserID && claims.SessionID == mockSessionID
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		mockUserService.On("Logout", ctxMatcher, claimsMatcher).Return(true, nil)
+
+		var response struct{ Logout bool }
+		err := c.Post(logout, &response, asRole(model.RoleUser))
+
+		require.NoError(t, err)
+		assert.True(t, response.Logout)
+	})
+
+	t.Run("Password change required", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		// The restricted token can be revoked without changing the password first
+		mockUserService.On("Logout", ctxMatcher, mock.MatchedBy(func(claims *token.JwtCustomClaim) bool {
+			return claims.UserID == mockUserID && claims.Scope == token.ScopeChangePassword
+		})).Return(true, nil)
+
+		var response struct{ Logout bool }
+		err := c.Post(logout, &response, asPasswordChangeRequired())
+
+		require.NoError(t, err)
+		assert.True(t, response.Logout)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		c, _ := setup(t)
+
+		var response struct{ Logout bool }
+		err := c.Post(logout, &response)
+
+		require.EqualError(t, err,
+			`[{"message":"authentication required","path":["logout"],"extensions":{"code":"UNAUTHENTICATED"}}]`)
+		assert.False(t, response.Logout)
+	})
+}
+
+func Test_RevokeAllSessions(t *testing.T) {
+	tests := []struct {
+		name          string
+		userID        string
+		role          model.Role
+		setupMock     func(*userMocks.MockAPI, string)
+		expectedError string
+	}{
+		{
+			name:   "User revokes own sessions",
+			userID: mockUserID,
+			role:   model.RoleUser,
+			setupMock: func(mockService *userMocks.MockAPI, userID string) {
+				mockService.On("RevokeAllSessions", ctxMatcher, userID).Return(true, nil)
+			},
+		},
+		{
+			name:   "Admin revokes another user's sessions",
+			userID: mockOtherUserID,
+			role:   model.RoleAdmin,
+			setupMock: func(mockService *userMocks.MockAPI, userID string) {
+				mockService.On("RevokeAllSessions", ctxMatcher, userID).Return(true, nil)
+			},
+		},
+		{
+			name:   "User revokes another user's sessions",
+			userID: mockOtherUserID,
+			role:   model.RoleUser,
+			expectedError: `[{"message":"ADMIN role required to REVOKE_ALL_SESSIONS of another user",` +
+				`"path":["revokeAllSessions"],"extensions":{"code":"FORBIDDEN"}}]`,
+		},
+		{
+			name:   "User not found",
+			userID: mockOtherUserID,
+			role:   model.RoleAdmin,
+			setupMock: func(mockService *userMocks.MockAPI, userID string) {
+				mockService.On("RevokeAllSessions", ctxMatcher, userID).Return(false, errNoUserFound)
+			},
+			expectedError: `[{"message":"` + errNoUserFound.Error() + `","path":["revokeAllSessions"]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, mockUserService := setup(t)
+			if tt.setupMock != nil {
+				tt.setupMock(mockUserService, tt.userID)
+			}
+
+			var response struct{ RevokeAllSessions bool }
+			err := c.Post(revokeAllSessions, &response, client.Var("userID", tt.userID), asRole(tt.role))
+
+			if tt.expectedError != "" {
+				require.EqualError(t, err, tt.expectedError)
+				assert.False(t, response.RevokeAllSessions)
+			} else {
+				require.NoError(t, err)
+				assert.True(t, response.RevokeAllSessions)
+			}
+			mockUserService.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_UpdateUser(t *testing.T) {
+	firstName := "Renamed"
+	role := model.RoleAdmin
+	email := "renamed@example.com"
+	updatedUser := createMockUser()
+	updatedUser.FirstName = firstName
+	updatedUser.Version = 3
+	errStaleUser := errcode.New(errcode.Conflict, "user has been modified since it was read, reload it and try again")
+
+	tests := []struct {
+		name          string
+		userID        string
+		role          model.Role
+		input         map[string]any
+		setupMock     func(*userMocks.MockAPI, string)
+		expectedError string
+	}{
+		{
+			name:   "User updates own profile",
+			userID: mockUserID,
+			role:   model.RoleUser,
+			input:  map[string]any{"firstName": firstName, "version": 2},
+			setupMock: func(mockService *userMocks.MockAPI, userID string) {
+				mockService.On("UpdateUser", ctxMatcher, userID, model.UpdateUserInput{FirstName: &firstName, Version: 2}).
+					Return(updatedUser, nil)
+			},
+		},
+		{
+			name:   "Admin changes the role of another user",
+			userID: mockOtherUserID,
+			role:   model.RoleAdmin,
+			input:  map[string]any{"role": role, "version": 2},
+			setupMock: func(mockService *userMocks.MockAPI, userID string) {
+				mockService.On("UpdateUser", ctxMatcher, userID, model.UpdateUserInput{Role: &role, Version: 2}).
+					Return(updatedUser, nil)
+			},
+		},
+		{
+			name:   "User changes own email with current password",
+			userID: mockUserID,
+			role:   model.RoleUser,
+			input:  map[string]any{"email": email, "currentPassword": mockPassword, "version": 2},
+			setupMock: func(mockService *userMocks.MockAPI, userID string) {
+				mockService.On("UpdateUser", ctxMatcher, userID, model.UpdateUserInput{
+					Email: &email, CurrentPassword: &mockPassword, Version: 2,
+				}).Return(updatedUser, nil)
+			},
+		},
+		{
+			name:   "User changes own email without current password",
+			userID: mockUserID,
+			role:   model.RoleUser,
+			input:  map[string]any{"email": email, "version": 2},
+			expectedError: `[{"message":"current password required to change the email address",` +
+				`"path":["updateUser"],"extensions":{"code":"FORBIDDEN"}}]`,
+		},
+		{
+			name:   "Admin changes the email of another user",
+			userID: mockOtherUserID,
+			role:   model.RoleAdmin,
+			input:  map[string]any{"email": email, "version": 2},
+			setupMock: func(mockService *userMocks.MockAPI, userID string) {
+				mockService.On("UpdateUser", ctxMatcher, userID, model.UpdateUserInput{Email: &email, Version: 2}).
+					Return(updatedUser, nil)
+			},
+		},
+		{
+			name:   "User updates another user",
+			userID: mockOtherUserID,
+			role:   model.RoleUser,
+			input:  map[string]any{"firstName": firstName, "version": 2},
+			expectedError: `[{"message":"ADMIN role required to UPDATE_USER of another user",` +
+				`"path":["updateUser"],"extensions":{"code":"FORBIDDEN"}}]`,
+		},
+		{
+			name:   "User changes own role",
+			userID: mockUserID,
+			role:   model.RoleUser,
+			input:  map[string]any{"role": role, "version": 2},
+			expectedError: `[{"message":"ADMIN role required to change the role of a user",` +
+				`"path":["updateUser"],"extensions":{"code":"FORBIDDEN"}}]`,
+		},
+		{
+			name:          "Invalid email",
+			userID:        mockUserID,
+			role:          model.RoleUser,
+			input:         map[string]any{"email": mockInvalidEmail, "version": 2},
+			expectedError: `[{"message":"email must be a valid email address","path":["updateUser","input","email"]}]`,
+		},
+		{
+			name:   "Invalid locale",
+			userID: mockUserID,
+			role:   model.RoleUser,
+			input:  map[string]any{"locale": "not a locale", "version": 2},
+			expectedError: `[{"message":"locale must be a BCP 47 language tag, e.g. en or es-MX",` +
+				`"path":["updateUser","input","locale"]}]`,
+		},
+		{
+			name:   "Stale version",
+			userID: mockUserID,
+			role:   model.RoleUser,
+			input:  map[string]any{"firstName": firstName, "version": 1},
+			setupMock: func(mockService *userMocks.MockAPI, userID string) {
+				mockService.On("UpdateUser", ctxMatcher, userID, model.UpdateUserInput{FirstName: &firstName, Version: 1}).
+					Return(nil, errStaleUser)
+			},
+			expectedError: `[{"message":"` + errStaleUser.Error() + `","path":["updateUser"],` +
+				`"extensions":{"code":"CONFLICT"}}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, mockUserService := setup(t)
+			if tt.setupMock != nil {
+				tt.setupMock(mockUserService, tt.userID)
+			}
+
+			var response struct {
+				UpdateUser *struct {
+					ID, FirstName string
+					Version       int
+				}
+			}
+			err := c.Post(updateUser, &response, client.Var("id", tt.userID), client.Var("input", tt.input), asRole(tt.role))
+
+			if tt.expectedError != "" {
+				require.EqualError(t, err, tt.expectedError)
+				assert.Nil(t, response.UpdateUser)
+			} else {
+				require.NoError(t, err)
+				require.NotNil(t, response.UpdateUser)
+				assert.Equal(t, firstName, response.UpdateUser.FirstName)
+				assert.Equal(t, 3, response.UpdateUser.Version)
+			}
+			mockUserService.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_ChangePassword(t *testing.T) {
+	newPassword := "newPassword123"
+	claimsMatcher := mock.MatchedBy(func(claims *token.JwtCustomClaim) bool {
+		return claims.UserID == mockUserID && claims.SessionID == mockSessionID
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		mockUserService.On("ChangePassword", ctxMatcher, claimsMatcher, mockPassword, newPassword).Return(true, nil)
+
+		var response struct{ ChangePassword bool }
+		err := c.Post(changePassword, &response,
+			client.Var("currentPassword", mockPassword), client.Var("newPassword", newPassword), asRole(model.RoleUser))
+
+		require.NoError(t, err)
+		assert.True(t, response.ChangePassword)
+	})
+
+	t.Run("Wrong current password", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		mockUserService.On("ChangePassword", ctxMatcher, claimsMatcher, "wrongPassword", newPassword).
+			Return(false, errInvalidPassword)
+
+		var response struct{ ChangePassword bool }
+		err := c.Post(changePassword, &response,
+			client.Var("currentPassword", "wrongPassword"), client.Var("newPassword", newPassword), asRole(model.RoleUser))
+
+		require.EqualError(t, err, `[{"message":"`+errInvalidPassword.Error()+`","path":["changePassword"]}]`)
+		assert.False(t, response.ChangePassword)
+	})
+
+	t.Run("New password breaking the policy", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		mockUserService.On("ChangePassword", ctxMatcher, claimsMatcher, mockPassword, "short").
+			Return(false, errPasswordPolicy)
+
+		var response struct{ ChangePassword bool }
+		err := c.Post(changePassword, &response,
+			client.Var("currentPassword", mockPassword), client.Var("newPassword", "short"), asRole(model.RoleUser))
+
+		require.EqualError(t, err, `[{"message":"`+errPasswordPolicy.Error()+`","path":["changePassword"],`+
+			`"extensions":`+passwordPolicyExtensions+`}]`)
+		assert.False(t, response.ChangePassword)
+	})
+
+	t.Run("Password change required", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		restricted := mock.MatchedBy(func(claims *token.JwtCustomClaim) bool {
+			return claims.UserID == mockUserID && claims.Scope == token.ScopeChangePassword
+		})
+		mockUserService.On("ChangePassword", ctxMatcher, restricted, mockPassword, newPassword).Return(true, nil)
+
+		var response struct{ ChangePassword bool }
+		err := c.Post(changePassword, &response,
+			client.Var("currentPassword", mockPassword), client.Var("newPassword", newPassword), asPasswordChangeRequired())
+
+		require.NoError(t, err)
+		assert.True(t, response.ChangePassword)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		c, _ := setup(t)
+
+		var response struct{ ChangePassword bool }
+		err := c.Post(changePassword, &response,
+			client.Var("currentPassword", mockPassword), client.Var("newPassword", newPassword))
+
+		require.EqualError(t, err, `[{"message":"authentication required","path":["changePassword"],`+
+			`"extensions":{"code":"UNAUTHENTICATED"}}]`)
+	})
+}
+
+func Test_RequestPasswordReset(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		mockUserService.On("RequestPasswordReset", ctxMatcher, mockEmail).Return(true, nil)
+
+		var response struct{ RequestPasswordReset bool }
+		err := c.Post(requestPasswordReset, &response, client.Var("email", mockEmail))
+
+		require.NoError(t, err)
+		assert.True(t, response.RequestPasswordReset)
+	})
+
+	t.Run("Invalid email", func(t *testing.T) {
+		c, _ := setup(t)
+
+		var response struct{ RequestPasswordReset bool }
+		err := c.Post(requestPasswordReset, &response, client.Var("email", mockInvalidEmail))
+
+		require.EqualError(t, err,
+			`[{"message":"email must be a valid email address","path":["requestPasswordReset","email"]}]`)
+	})
+}
+
+func Test_ResetPassword(t *testing.T) {
+	const resetToken = "reset-token"
+	newPassword := "newPassword123"
+	errInvalidResetToken := errors.New("invalid or expired token")
+
+	t.Run("Success", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		mockUserService.On("ResetPassword", ctxMatcher, resetToken, newPassword).Return(true, nil)
+
+		var response struct{ ResetPassword bool }
+		err := c.Post(resetPassword, &response, client.Var("token", resetToken), client.Var("newPassword", newPassword))
+
+		require.NoError(t, err)
+		assert.True(t, response.ResetPassword)
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		mockUserService.On("ResetPassword", ctxMatcher, resetToken, newPassword).Return(false, errInvalidResetToken)
+
+		var response struct{ ResetPassword bool }
+		err := c.Post(resetPassword, &response, client.Var("token", resetToken), client.Var("newPassword", newPassword))
+
+		require.EqualError(t, err, `[{"message":"invalid or expired token","path":["resetPassword"]}]`)
+		assert.False(t, response.ResetPassword)
+	})
+
+	t.Run("New password breaking the policy", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		mockUserService.On("ResetPassword", ctxMatcher, resetToken, "short").Return(false, errPasswordPolicy)
+
+		var response struct{ ResetPassword bool }
+		err := c.Post(resetPassword, &response, client.Var("token", resetToken), client.Var("newPassword", "short"))
+
+		require.EqualError(t, err, `[{"message":"`+errPasswordPolicy.Error()+`","path":["resetPassword"],`+
+			`"extensions":`+passwordPolicyExtensions+`}]`)
+		assert.False(t, response.ResetPassword)
+	})
+}
+
+func Test_VerifyEmail(t *testing.T) {
+	const verificationToken = "verification-token"
+
+	t.Run("Success", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		mockUserService.On("VerifyEmail", ctxMatcher, verificationToken).Return(true, nil)
+
+		var response struct{ VerifyEmail bool }
+		err := c.Post(verifyEmail, &response, client.Var("token", verificationToken))
+
+		require.NoError(t, err)
+		assert.True(t, response.VerifyEmail)
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		mockUserService.On("VerifyEmail", ctxMatcher, verificationToken).
+			Return(false, errors.New("invalid or expired token"))
+
+		var response struct{ VerifyEmail bool }
+		err := c.Post(verifyEmail, &response, client.Var("token", verificationToken))
+
+		require.EqualError(t, err, `[{"message":"invalid or expired token","path":["verifyEmail"]}]`)
+		assert.False(t, response.VerifyEmail)
+	})
+}
+
+func Test_ResendVerification(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		mockUserService.On("ResendVerification", ctxMatcher, mockEmail).Return(true, nil)
+
+		var response struct{ ResendVerification bool }
+		err := c.Post(resendVerification, &response, client.Var("email", mockEmail))
+
+		require.NoError(t, err)
+		assert.True(t, response.ResendVerification)
+	})
+
+	t.Run("Invalid email", func(t *testing.T) {
+		c, _ := setup(t)
+
+		var response struct{ ResendVerification bool }
+		err := c.Post(resendVerification, &response, client.Var("email", mockInvalidEmail))
+
+		require.EqualError(t, err,
+			`[{"message":"email must be a valid email address","path":["resendVerification","email"]}]`)
+	})
+}
+
+func Test_LoginMfaRequired(t *testing.T) {
+	c, mockUserService := setup(t)
+	challenge := "mfa-challenge"
+	expiresAt := testutils.CurrentTime.Now().Add(5 * time.Minute)
+	mockUserService.On("Login", ctxMatcher, mockUserName, mockPassword).Return(&model.AuthPayload{
+		Status:                model.AuthStatusMfaRequired,
+		MfaChallenge:          &challenge,
+		MfaChallengeExpiresAt: &expiresAt,
+	}, nil)
+
+	var response struct {
+		Auth struct {
+			Status                model.AuthStatus
+			User                  *struct{ ID string }
+			AccessToken           *string
+			MfaChallenge          string
+			MfaChallengeExpiresAt string
+		}
+	}
+	err := c.Post(loginStatus, &response,
+		client.Var("usernameOrEmail", mockUserName),
+		client.Var("password", mockPassword),
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, model.AuthStatusMfaRequired, response.Auth.Status)
+	assert.Nil(t, response.Auth.User)
+	assert.Nil(t, response.Auth.AccessToken)
+	assert.Equal(t, challenge, response.Auth.MfaChallenge)
+	assert.Equal(t, expiresAt.Format(time.RFC3339), response.Auth.MfaChallengeExpiresAt)
+}
+
+func Test_EnrollTotp(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		enrollment := &model.TotpEnrollment{
+			Secret:        "JBSWY3DPEHPK3PXP",
+			URI:           "otpauth://totp/user-auth-api:mock?secret=JBSWY3DPEHPK3PXP",
+			RecoveryCodes: []string{"abcde-fghij", "klmno-pqrst"},
+		}
+		mockUserService.On("EnrollTotp", ctxMatcher, mockUserID).Return(enrollment, nil)
+
+		var response struct{ EnrollTotp model.TotpEnrollment }
+		err := c.Post(enrollTotp, &response, asRole(model.RoleUser))
+
+		require.NoError(t, err)
+		assert.Equal(t, *enrollment, response.EnrollTotp)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		c, _ := setup(t)
+
+		var response struct{ EnrollTotp *model.TotpEnrollment }
+		err := c.Post(enrollTotp, &response)
+
+		require.EqualError(t, err,
+			`[{"message":"authentication required","path":["enrollTotp"],"extensions":{"code":"UNAUTHENTICATED"}}]`)
+	})
+}
+
+func Test_ConfirmTotp(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		mockUserService.On("ConfirmTotp", ctxMatcher, mockUserID, "123456").Return(true, nil)
+
+		var response struct{ ConfirmTotp bool }
+		err := c.Post(confirmTotp, &response, client.Var("code", "123456"), asRole(model.RoleUser))
+
+		require.NoError(t, err)
+		assert.True(t, response.ConfirmTotp)
+	})
+
+	t.Run("Malformed code", func(t *testing.T) {
+		c, _ := setup(t)
+
+		var response struct{ ConfirmTotp bool }
+		err := c.Post(confirmTotp, &response, client.Var("code", "12345a"), asRole(model.RoleUser))
+
+		require.EqualError(t, err, `[{"message":"code must be a valid numeric value","path":["confirmTotp","code"]}]`)
+	})
+}
+
+func Test_VerifyMfa(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		user := createMockUser()
+		user.MfaEnabled = true
+		mockUserService.On("VerifyMfa", ctxMatcher, "mfa-challenge", "123456").Return(createMockAuthPayload(user), nil)
+
+		var response struct {
+			VerifyMfa struct {
+				Status model.AuthStatus
+				User   struct {
+					ID         string
+					MfaEnabled bool
+				}
+				AccessToken  string
+				RefreshToken string
+			}
+		}
+		err := c.Post(verifyMfa, &response, client.Var("challenge", "mfa-challenge"), client.Var("code", "123456"))
+
+		require.NoError(t, err)
+		assert.Equal(t, model.AuthStatusAuthenticated, response.VerifyMfa.Status)
+		assert.Equal(t, mockUserID, response.VerifyMfa.User.ID)
+		assert.True(t, response.VerifyMfa.User.MfaEnabled)
+		assert.Equal(t, mockAccessToken, response.VerifyMfa.AccessToken)
+		assert.Equal(t, mockRefreshToken, response.VerifyMfa.RefreshToken)
+	})
+
+	t.Run("Invalid code", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		mockUserService.On("VerifyMfa", ctxMatcher, "mfa-challenge", "000000").Return(nil, errors.New("invalid code"))
+
+		var response struct {
+			VerifyMfa *struct{ Status model.AuthStatus }
+		}
+		err := c.Post(verifyMfa, &response, client.Var("challenge", "mfa-challenge"), client.Var("code", "000000"))
+
+		require.EqualError(t, err, `[{"message":"invalid code","path":["verifyMfa"]}]`)
+	})
+}
+
+func Test_RegenerateRecoveryCodes(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		codes := []string{"abcde-fghij", "klmno-pqrst"}
+		mockUserService.On("RegenerateRecoveryCodes", ctxMatcher, mockUserID, "123456").Return(codes, nil)
+
+		var response struct{ RegenerateRecoveryCodes []string }
+		err := c.Post(regenerateRecoveryCodes, &response, client.Var("code", "123456"), asRole(model.RoleUser))
+
+		require.NoError(t, err)
+		assert.Equal(t, codes, response.RegenerateRecoveryCodes)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		c, _ := setup(t)
+
+		var response struct{ RegenerateRecoveryCodes []string }
+		err := c.Post(regenerateRecoveryCodes, &response, client.Var("code", "123456"))
+
+		require.EqualError(t, err,
+			`[{"message":"authentication required","path":["regenerateRecoveryCodes"],"extensions":{"code":"UNAUTHENTICATED"}}]`)
+	})
+}
+
+func Test_LoginWithPasskey(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		mockUserService.On("LoginWithPasskey", ctxMatcher, `{"id":"credential"}`).
+			Return(createMockAuthPayload(createMockUser()), nil)
+
+		var response struct {
+			Auth struct {
+				Status      model.AuthStatus
+				User        struct{ ID string }
+				AccessToken string
+			}
+		}
+		err := c.Post(loginPasskey, &response, client.Var("passkey", `{"id":"credential"}`))
+
+		require.NoError(t, err)
+		assert.Equal(t, model.AuthStatusAuthenticated, response.Auth.Status)
+		assert.Equal(t, mockUserID, response.Auth.User.ID)
+		assert.Equal(t, mockAccessToken, response.Auth.AccessToken)
+	})
+
+	t.Run("No credentials", func(t *testing.T) {
+		c, _ := setup(t)
+
+		var response struct {
+			Auth *struct{ Status model.AuthStatus }
+		}
+		err := c.Post(loginPasskey, &response)
+
+		require.EqualError(t, err,
+			`[{"message":"login requires either a username or email and password, or a passkey","path":["auth"]}]`)
+	})
+
+	t.Run("Password and passkey", func(t *testing.T) {
+		c, _ := setup(t)
+
+		var response struct {
+			Auth *struct{ Status model.AuthStatus }
+		}
+		err := c.Post(`query {
+		  auth: login(params: {usernameOrEmail: "user", password: "secret", passkey: "{}"}) { status }
+		}`, &response)
+
+		require.EqualError(t, err,
+			`[{"message":"login requires either a username or email and password, or a passkey","path":["auth"]}]`)
+	})
+}
+
+func Test_PasskeyRegistration(t *testing.T) {
+	t.Run("Begin", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		code := "123456"
+		mockUserService.On("BeginPasskeyRegistration", ctxMatcher, mockUserID, "currentPassword", &code).
+			Return(`{"publicKey":{}}`, nil)
+
+		var response struct{ BeginPasskeyRegistration string }
+		err := c.Post(beginPasskeyRegistration, &response,
+			client.Var("currentPassword", "currentPassword"),
+			client.Var("code", code),
+			asRole(model.RoleUser),
+		)
+
+		require.NoError(t, err)
+		assert.Equal(t, `{"publicKey":{}}`, response.BeginPasskeyRegistration)
+	})
+
+	t.Run("Finish", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		name := "Laptop"
+		createdAt := testutils.CurrentTime.Now()
+		mockUserService.On("FinishPasskeyRegistration", ctxMatcher, mockUserID, `{"id":"credential"}`, &name).
+			Return(&model.Passkey{ID: "credential", Name: &name, CreatedAt: createdAt}, nil)
+
+		var response struct {
+			FinishPasskeyRegistration struct {
+				ID         string
+				Name       string
+				CreatedAt  string
+				LastUsedAt *string
+			}
+		}
+		err := c.Post(finishPasskeyRegistration, &response,
+			client.Var("response", `{"id":"credential"}`),
+			client.Var("name", name),
+			asRole(model.RoleUser),
+		)
+
+		require.NoError(t, err)
+		assert.Equal(t, "credential", response.FinishPasskeyRegistration.ID)
+		assert.Equal(t, name, response.FinishPasskeyRegistration.Name)
+		assert.Equal(t, createdAt.Format(time.RFC3339), response.FinishPasskeyRegistration.CreatedAt)
+		assert.Nil(t, response.FinishPasskeyRegistration.LastUsedAt)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		c, _ := setup(t)
+
+		var response struct{ BeginPasskeyRegistration *string }
+		err := c.Post(beginPasskeyRegistration, &response, client.Var("currentPassword", "currentPassword"))
+
+		require.EqualError(t, err,
+			`[{"message":"authentication required","path":["beginPasskeyRegistration"],"extensions":{"code":"UNAUTHENTICATED"}}]`)
+	})
+}
+
+func Test_Passkeys(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		name := "Laptop"
+		createdAt := testutils.CurrentTime.Now()
+		mockUserService.On("ListPasskeys", ctxMatcher, mockUserID).
+			Return([]*model.Passkey{{ID: "credential", Name: &name, CreatedAt: createdAt}}, nil)
+
+		var response struct {
+			Passkeys []struct {
+				ID         string
+				Name       string
+				CreatedAt  string
+				LastUsedAt *string
+			}
+		}
+		err := c.Post(passkeys, &response, asRole(model.RoleUser))
+
+		require.NoError(t, err)
+		require.Len(t, response.Passkeys, 1)
+		assert.Equal(t, "credential", response.Passkeys[0].ID)
+		assert.Equal(t, name, response.Passkeys[0].Name)
+		assert.Equal(t, createdAt.Format(time.RFC3339), response.Passkeys[0].CreatedAt)
+		assert.Nil(t, response.Passkeys[0].LastUsedAt)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		c, _ := setup(t)
+
+		var response struct{ Passkeys []struct{ ID string } }
+		err := c.Post(passkeys, &response)
+
+		require.EqualError(t, err,
+			`[{"message":"authentication required","path":["passkeys"],"extensions":{"code":"UNAUTHENTICATED"}}]`)
+	})
+}
+
+func Test_DeletePasskey(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		mockUserService.On("DeletePasskey", ctxMatcher, mockUserID, "credential").Return(true, nil)
+
+		var response struct{ DeletePasskey bool }
+		err := c.Post(deletePasskey, &response, client.Var("id", "credential"), asRole(model.RoleUser))
+
+		require.NoError(t, err)
+		assert.True(t, response.DeletePasskey)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		c, _ := setup(t)
+
+		var response struct{ DeletePasskey *bool }
+		err := c.Post(deletePasskey, &response, client.Var("id", "credential"))
+
+		require.EqualError(t, err,
+			`[{"message":"authentication required","path":["deletePasskey"],"extensions":{"code":"UNAUTHENTICATED"}}]`)
+	})
+}
+
+func Test_BeginPasskeyLogin(t *testing.T) {
+	c, mockUserService := setup(t)
+	mockUserService.On("BeginPasskeyLogin", ctxMatcher).Return(`{"publicKey":{}}`, nil)
+
+	var response struct{ BeginPasskeyLogin string }
+	err := c.Post(beginPasskeyLogin, &response)
+
+	require.NoError(t, err)
+	assert.Equal(t, `{"publicKey":{}}`, response.BeginPasskeyLogin)
+}
+
+func Test_Me(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		expectedUser := createMockUser()
+		mockUserService.On("GetUserByID", ctxMatcher, mockUserID).Return(expectedUser, nil)
+
+		var response struct{ Me *userResponse }
+		err := c.Post(me, &response, asRole(model.RoleUser))
+
+		require.NoError(t, err)
+		require.NotNil(t, response.Me)
+		assertUserEqual(t, *expectedUser, response.Me.toModel(t))
+	})
+
+	t.Run("User no longer exists", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		mockUserService.On("GetUserByID", ctxMatcher, mockUserID).Return(nil, errNoUserFound)
+
+		var response struct{ Me *userResponse }
+		err := c.Post(me, &response, asRole(model.RoleUser))
+
+		require.EqualError(t, err, `[{"message":"`+errNoUserFound.Error()+`","path":["me"]}]`)
+		assert.Nil(t, response.Me)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		c, _ := setup(t)
+
+		var response struct{ Me *userResponse }
+		err := c.Post(me, &response)
+
+		require.EqualError(t, err,
+			`[{"message":"authentication required","path":["me"],"extensions":{"code":"UNAUTHENTICATED"}}]`)
+		assert.Nil(t, response.Me)
+	})
+}
+
+func Test_User(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		expectedUser := createMockUser()
+		mockUserService.On("GetUserByID", ctxMatcher, mockOtherUserID).Return(expectedUser, nil)
+
+		var response struct{ User *userResponse }
+		err := c.Post(getUser, &response, client.Var("id", mockOtherUserID), asRole(model.RoleAdmin))
+
+		require.NoError(t, err)
+		require.NotNil(t, response.User)
+		assert.Equal(t, expectedUser.ID, response.User.ID)
+		assert.Equal(t, expectedUser.Email, response.User.Email)
+	})
+
+	t.Run("User not found", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		mockUserService.On("GetUserByID", ctxMatcher, mockOtherUserID).Return(nil, errNoUserFound)
+
+		var response struct{ User *userResponse }
+		err := c.Post(getUser, &response, client.Var("id", mockOtherUserID), asRole(model.RoleAdmin))
+
+		require.EqualError(t, err, `[{"message":"`+errNoUserFound.Error()+`","path":["user"]}]`)
+		assert.Nil(t, response.User)
+	})
+}
+
+func Test_Users(t *testing.T) {
+	role := model.RoleAdmin
+	emailPrefix := "adm"
+	direction := model.SortDirectionDesc
+	after, pageCursor := "cursor-1", "cursor-2"
+	connection := &model.UserConnection{
+		Edges: []*model.UserEdge{{Cursor: pageCursor, Node: createMockUser()}},
+		PageInfo: &model.PageInfo{
+			HasNextPage:     true,
+			HasPreviousPage: true,
+			StartCursor:     &pageCursor,
+			EndCursor:       &pageCursor,
+		},
+		TotalCount: 5,
+	}
+
+	type usersResponse struct {
+		Users struct {
+			Edges []struct {
+				Cursor string
+				Node   struct{ ID, Email string }
+			}
+			PageInfo   model.PageInfo
+			TotalCount int
+		}
+	}
+
+	t.Run("Filter, sort and cursor are passed through", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		filter := &model.UserFilter{Role: &role, EmailPrefix: &emailPrefix}
+		sort := &model.UserSort{Field: model.UserSortFieldEmail, Direction: &direction}
+		mockUserService.On("ListUsers", ctxMatcher, filter, sort, 1, &after).Return(connection, nil)
+
+		var response usersResponse
+		err := c.Post(listUsers, &response,
+			client.Var("filter", map[string]any{"role": role, "emailPrefix": emailPrefix}),
+			client.Var("sort", map[string]any{"field": model.UserSortFieldEmail, "direction": direction}),
+			client.Var("first", 1),
+			client.Var("after", after),
+			asRole(model.RoleAdmin),
+		)
+
+		require.NoError(t, err)
+		require.Len(t, response.Users.Edges, 1)
+		assert.Equal(t, pageCursor, response.Users.Edges[0].Cursor)
+		assert.Equal(t, mockUserID, response.Users.Edges[0].Node.ID)
+		assert.Equal(t, *connection.PageInfo, response.Users.PageInfo)
+		assert.Equal(t, 5, response.Users.TotalCount)
+	})
+
+	t.Run("Default page size", func(t *testing.T) {
+		c, mockUserService := setup(t)
+		mockUserService.On("ListUsers", ctxMatcher, (*model.UserFilter)(nil), (*model.UserSort)(nil),
+			20, (*string)(nil)).Return(connection, nil)
+
+		var response usersResponse
+		err := c.Post(`query { users { totalCount } }`, &response, asRole(model.RoleAdmin))
+
+		require.NoError(t, err)
+		assert.Equal(t, 5, response.Users.TotalCount)
+	})
+
+	t.Run("Page size out of range", func(t *testing.T) {
+		c, _ := setup(t)
+
+		var response usersResponse
+		err := c.Post(listUsers, &response, client.Var("first", 101), asRole(model.RoleAdmin))
+
+		require.EqualError(t, err, `[{"message":"first must be 100 or less","path":["users","first"]}]`)
+	})
+
+	t.Run("Forbidden for users", func(t *testing.T) {
+		c, _ := setup(t)
+
+		var response usersResponse
+		err := c.Post(listUsers, &response, asRole(model.RoleUser))
+
+		require.EqualError(t, err, `[{"message":"ADMIN role required to LIST_USERS","path":["users"],`+
+			`"extensions":{"code":"FORBIDDEN"}}]`)
+	})
+}
+
+func Test_HasRole(t *testing.T) {
+	newUserInput := model.NewUserInput{
+		Email: mockEmail, FirstName: mockFirstName, LastName: mockLastName,
+		UserName: mockUserName, Password: mockPassword,
+	}
+	tests := []struct {
+		name          string
+		query         string
+		vars          []client.Option
+		expectedError string
+	}{
+		{
+			name:  "Create user unauthenticated",
+			query: createUser,
+			vars:  []client.Option{client.Var("newUserInput", newUserInput)},
+			expectedError: `[{"message":"authentication required","path":["createUser"],` +
+				`"extensions":{"code":"UNAUTHENTICATED"}}]`,
+		},
+		{
+			name:  "Create user without admin role",
+			query: createUser,
+			vars:  []client.Option{client.Var("newUserInput", newUserInput), asRole(model.RoleUser)},
+			expectedError: `[{"message":"ADMIN role required to CREATE_USER","path":["createUser"],` +
+				`"extensions":{"code":"FORBIDDEN"}}]`,
+		},
+		{
+			name:  "Get user without admin role",
+			query: getUser,
+			vars:  []client.Option{client.Var("id", mockOtherUserID), asRole(model.RoleUser)},
+			expectedError: `[{"message":"ADMIN role required to GET_USER","path":["user"],` +
+				`"extensions":{"code":"FORBIDDEN"}}]`,
+		},
+		{
+			name:  "Delete user unauthenticated",
+			query: deleteUser,
+			vars:  []client.Option{client.Var("userID", mockUserID)},
+			expectedError: `[{"message":"authentication required","path":["deleteUser"],` +
+				`"extensions":{"code":"UNAUTHENTICATED"}}]`,
+		},
+		{
+			name:  "Delete user without admin role",
+			query: deleteUser,
+			vars:  []client.Option{client.Var("userID", mockUserID), asRole(model.RoleUser)},
+			expectedError: `[{"message":"ADMIN role required to DELETE_USER","path":["deleteUser"],` +
+				`"extensions":{"code":"FORBIDDEN"}}]`,
+		},
+		{
+			name:  "Unlock user without admin role",
+			query: unlockUser,
+			vars:  []client.Option{client.Var("userID", mockUserID), asRole(model.RoleUser)},
+			expectedError: `[{"message":"ADMIN role required to UNLOCK_USER","path":["unlockUser"],` +
+				`"extensions":{"code":"FORBIDDEN"}}]`,
+		},
+		{
+			name:  "Force password reset without admin role",
+			query: forcePasswordReset,
+			vars:  []client.Option{client.Var("userID", mockUserID), asRole(model.RoleUser)},
+			expectedError: `[{"message":"ADMIN role required to FORCE_PASSWORD_RESET","path":["forcePasswordReset"],` +
+				`"extensions":{"code":"FORBIDDEN"}}]`,
+		},
+		{
+			name:  "Me with a token restricted to changing the password",
+			query: me,
+			vars:  []client.Option{asPasswordChangeRequired()},
+			expectedError: `[{"message":"password must be changed before ME","path":["me"],` +
+				`"extensions":{"code":"PASSWORD_CHANGE_REQUIRED"}}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The user service must never be reached when the directive rejects the caller
+			c, mockUserService := setup(t)
+
+			var response map[string]interface{}
+			err := c.Post(tt.query, &response, tt.vars...)
+
+			require.Error(t, err)
+			require.EqualError(t, err, tt.expectedError)
+			mockUserService.AssertExpectations(t)
+		})
+	}
+}
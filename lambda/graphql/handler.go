@@ -2,6 +2,7 @@ package graphql
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"time"
 
@@ -17,14 +18,18 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/vektah/gqlparser/v2/ast"
 
+	"github.com/ahummel25/user-auth-api/auth"
 	"github.com/ahummel25/user-auth-api/db"
 	"github.com/ahummel25/user-auth-api/graphql/directives"
+	"github.com/ahummel25/user-auth-api/graphql/errcode"
 	"github.com/ahummel25/user-auth-api/graphql/generated"
 	"github.com/ahummel25/user-auth-api/graphql/resolvers"
 	"github.com/ahummel25/user-auth-api/graphql/resolvers/mutations"
 	userMutation "github.com/ahummel25/user-auth-api/graphql/resolvers/mutations/user"
 	"github.com/ahummel25/user-auth-api/graphql/resolvers/query"
 	userQuery "github.com/ahummel25/user-auth-api/graphql/resolvers/query/user"
+	"github.com/ahummel25/user-auth-api/service/lockout"
+	"github.com/ahummel25/user-auth-api/service/token"
 	"github.com/ahummel25/user-auth-api/service/user"
 )
 
@@ -47,6 +52,9 @@ func NewServer(es graphql.ExecutableSchema) *handler.Server {
 	srv.AddTransport(transport.POST{})
 	srv.AddTransport(transport.MultipartForm{})
 
+	// Surface error codes in the GraphQL error extensions
+	srv.SetErrorPresenter(errcode.Presenter)
+
 	// Set up query cache
 	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
 
@@ -59,7 +67,9 @@ func NewServer(es graphql.ExecutableSchema) *handler.Server {
 	return srv
 }
 
-func init() {
+// NewRouter builds the router shared by the Lambda handler and the local server. Any middlewares
+// provided run ahead of request authentication.
+func NewRouter(mwf ...mux.MiddlewareFunc) *mux.Router {
 	r := mux.NewRouter()
 	DefaultTranslation()
 
@@ -82,10 +92,24 @@ func init() {
 	schema := generated.NewExecutableSchema(cfg)
 	server := NewServer(schema)
 
+	r.Use(mwf...)
+	r.Use(lockout.ClientIPMiddleware)
+	r.Use(auth.Middleware(userService))
+
 	r.Handle("/graphiql", playground.Handler("GraphQL playground", "/graphql"))
 	r.Handle("/apollo", playground.ApolloSandboxHandler("GraphQL Apollo playground", "/graphql"))
 	r.Handle("/graphql", server)
-	muxAdapter = gorillamux.New(r)
+	r.Handle(auth.JWKSPath, auth.JWKSHandler()).Methods(http.MethodGet)
+	r.Handle(auth.IntrospectionPath, auth.IntrospectionHandler(userService)).Methods(http.MethodPost)
+	return r
+}
+
+func init() {
+	// Refuse to start without key material to sign access tokens with
+	if err := token.InitSigningKeys(context.Background()); err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+	muxAdapter = gorillamux.New(NewRouter())
 }
 
 // LambdaHandler is our lambda handler invoked by the `lambda.Start` function call
@@ -114,8 +138,8 @@ func LambdaHandler(
 		return apiGWResponse, err
 	}
 
+	// Keep the status code set by the router so that authentication failures surface as 401s
 	apiGWResponse = *switchableAPIGatewayResponse.Version1()
 	apiGWResponse.IsBase64Encoded = false
-	apiGWResponse.StatusCode = http.StatusOK
 	return apiGWResponse, nil
 }
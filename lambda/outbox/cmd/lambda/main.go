@@ -0,0 +1,11 @@
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/ahummel25/user-auth-api/lambda/outbox"
+)
+
+func main() {
+	lambda.Start(outbox.LambdaHandler)
+}
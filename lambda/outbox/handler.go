@@ -0,0 +1,35 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/ahummel25/user-auth-api/db"
+	"github.com/ahummel25/user-auth-api/service/notify"
+)
+
+// batchSize is the most messages delivered per invocation, keeping each run well within the
+// function timeout
+const batchSize = 100
+
+// LambdaHandler delivers the queued notifications which are due. It runs on a schedule, delivering
+// the messages requests queued and retrying those whose delivery failed or was interrupted.
+func LambdaHandler(ctx context.Context) error {
+	ctx, err := db.SetupDBContext(ctx)
+	if err != nil {
+		return err
+	}
+	transport, err := notify.NewTransport(ctx)
+	if err != nil {
+		return err
+	}
+
+	delivered, err := notify.ProcessOutbox(ctx, transport, batchSize)
+	if err != nil {
+		return err
+	}
+	if delivered > 0 {
+		slog.Info("Delivered queued notifications", "count", delivered)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/ahummel25/user-auth-api/db/mongo"
+)
+
+// AttemptsCollection is an interface that wraps the database.Collection interface
+type AttemptsCollection interface {
+	mongo.Collection
+}
+
+// attemptsCollectionCtxKey represents the context key of the login attempts Mongo collection
+type attemptsCollectionCtxKey struct{}
+
+// clientIPCtxKey is the context key for the IP address of the client making the request
+type clientIPCtxKey struct{}
+
+// NewContext returns a new context containing the given attempts collection under the given
+// context key
+func NewContext(ctx context.Context, collectionCtxKey any, collection AttemptsCollection) context.Context {
+	return context.WithValue(ctx, collectionCtxKey, collection)
+}
+
+// AttemptsFromContext returns the AttemptsCollection from the context, or an error if not found
+func AttemptsFromContext(ctx context.Context) (AttemptsCollection, error) {
+	if c, ok := ctx.Value(GetAttemptsCollectionKey()).(AttemptsCollection); ok {
+		return c, nil
+	}
+	return nil, errors.New("login attempts collection not found in context")
+}
+
+// GetAttemptsCollectionKey is a wrapper function around the attemptsCollectionCtxKey returning a pointer to that value
+func GetAttemptsCollectionKey() *attemptsCollectionCtxKey {
+	return &attemptsCollectionCtxKey{}
+}
+
+// NewClientIPContext returns a new context containing the IP address of the client
+func NewClientIPContext(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPCtxKey{}, ip)
+}
+
+// ClientIPFromContext returns the IP address of the client, or an empty string if it is unknown
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPCtxKey{}).(string)
+	return ip
+}
+
+// ClientIPMiddleware stores the IP address of the client of each request in the request context.
+// Behind API Gateway the remote address is the source IP of the caller, so forwarding headers,
+// which the caller controls, are not consulted.
+func ClientIPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+		next.ServeHTTP(w, r.WithContext(NewClientIPContext(r.Context(), ip)))
+	})
+}
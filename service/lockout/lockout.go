@@ -0,0 +1,199 @@
+package lockout
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"github.com/ahummel25/user-auth-api/config"
+	"github.com/ahummel25/user-auth-api/graphql/errcode"
+)
+
+// maxLoginDelay caps the delay between logins of a user, however many times they failed
+const maxLoginDelay = time.Minute
+
+var (
+	// ErrLocked is returned for logins with an identifier locked after too many failed attempts
+	ErrLocked = errcode.New(errcode.AccountLocked,
+		"account is temporarily locked after too many failed login attempts, try again later")
+	// ErrThrottled is returned for logins made too soon after a failed one, or from a client with
+	// too many failed attempts
+	ErrThrottled = errcode.New(errcode.TooManyAttempts, "too many failed login attempts, try again later")
+)
+
+// attemptsDB counts the recent failed logins with a login identifier or from a client IP. Failures
+// are forgotten once none happened for the lockout duration, which also lifts a lock.
+type attemptsDB struct {
+	// Key is the hashed login identifier or the client IP the failures are counted for, prefixed
+	// with its kind
+	Key             string    `bson:"key"`
+	Failures        int       `bson:"failures"`
+	LastFailureDate time.Time `bson:"last_failure_date"`
+	ExpiresAt       time.Time `bson:"expires_at"`
+}
+
+// Helper function to build the attempts key of a login identifier, a user name or email. Failures
+// are counted for the identifier whether or not a user has it, so that the responses to logins do
+// not tell which users exist. Identifiers are compared ignoring case and surrounding spaces, and
+// are hashed, as users sometimes type their password into them.
+func identifierKey(identifier string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(identifier))))
+	return "login:" + hex.EncodeToString(sum[:])
+}
+
+// Helper function to build the attempts key of a client IP
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Helper function to compute how long to wait after the given number of consecutive failed logins,
+// doubling the base delay with every failure
+func loginDelay(base time.Duration, failures int) time.Duration {
+	if failures < 1 {
+		return 0
+	}
+	delay := base
+	for i := 1; i < failures && delay < maxLoginDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxLoginDelay)
+}
+
+// Helper function to find the failures counted for the given key, or nil if none are
+func findAttempts(ctx context.Context, attemptsCollection AttemptsCollection, key string, now time.Time) (*attemptsDB, error) {
+	var attempts attemptsDB
+	filter := bson.M{"key": key, "expires_at": bson.M{"$gt": now}}
+	if err := attemptsCollection.FindOne(ctx, filter).Decode(&attempts); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &attempts, nil
+}
+
+// Check returns an error if a login with any of the given identifiers must be refused without
+// checking the password or second factor. This is the case while an identifier or the IP of the
+// client is locked, and until the delay after the last failure with an identifier has passed.
+func Check(ctx context.Context, identifiers ...string) error {
+	attemptsCollection, err := AttemptsFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	configSupplier, err := config.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	cfg, err := configSupplier.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	if ip := ClientIPFromContext(ctx); ip != "" {
+		attempts, err := findAttempts(ctx, attemptsCollection, ipKey(ip), now)
+		if err != nil {
+			return err
+		}
+		if attempts != nil && attempts.Failures >= cfg.LockoutIPThreshold {
+			return ErrThrottled
+		}
+	}
+	for _, identifier := range identifiers {
+		attempts, err := findAttempts(ctx, attemptsCollection, identifierKey(identifier), now)
+		if err != nil {
+			return err
+		} else if attempts == nil {
+			continue
+		}
+		if attempts.Failures >= cfg.LockoutThreshold {
+			return ErrLocked
+		}
+		if now.Before(attempts.LastFailureDate.Add(loginDelay(cfg.LoginDelay, attempts.Failures))) {
+			return ErrThrottled
+		}
+	}
+	return nil
+}
+
+// RecordFailure counts a failed login against each of the given identifiers and the IP of the
+// client
+func RecordFailure(ctx context.Context, identifiers ...string) error {
+	attemptsCollection, err := AttemptsFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	configSupplier, err := config.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	cfg, err := configSupplier.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	ip := ClientIPFromContext(ctx)
+	if ip != "" {
+		failures, err := countFailure(ctx, attemptsCollection, ipKey(ip), cfg.LockoutDuration)
+		if err != nil {
+			return err
+		}
+		if failures == cfg.LockoutIPThreshold {
+			slog.Warn("Client IP locked after too many failed logins", "ip", ip, "failures", failures)
+		}
+	}
+	for _, identifier := range identifiers {
+		failures, err := countFailure(ctx, attemptsCollection, identifierKey(identifier), cfg.LockoutDuration)
+		if err != nil {
+			return err
+		}
+		// The identifier itself is left out, as it may be a mistyped password
+		if failures == cfg.LockoutThreshold {
+			slog.Warn("Login identifier locked after too many failed logins", "ip", ip, "failures", failures)
+		}
+	}
+	return nil
+}
+
+// Helper function to count a failure for the given key, starting over when the previous failures
+// have expired, returning the number of failures counted since
+func countFailure(ctx context.Context, attemptsCollection AttemptsCollection, key string, duration time.Duration) (int, error) {
+	now := time.Now().UTC()
+	recent := bson.M{"$gt": bson.A{"$expires_at", now}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"failures":          bson.M{"$cond": bson.A{recent, bson.M{"$add": bson.A{"$failures", 1}}, 1}},
+		"last_failure_date": now,
+		"expires_at":        now.Add(duration),
+	}}}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var attempts attemptsDB
+	if err := attemptsCollection.FindOneAndUpdate(ctx, bson.M{"key": key}, update, opts).Decode(&attempts); err != nil {
+		return 0, err
+	}
+	return attempts.Failures, nil
+}
+
+// Reset forgets the failed logins with the given identifiers, lifting their locks. Failures of
+// client IPs are kept, so that logging into an account of their own does not let an attacker keep
+// guessing the passwords of others.
+func Reset(ctx context.Context, identifiers ...string) error {
+	attemptsCollection, err := AttemptsFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	for _, identifier := range identifiers {
+		if _, err = attemptsCollection.DeleteOne(ctx, bson.M{"key": identifierKey(identifier)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package lockout
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+
+	"github.com/ahummel25/user-auth-api/service/lockout/mocks"
+)
+
+// Helper function to match the lookup of the unexpired failures of the given key
+func attemptsFilter(key string) any {
+	return mock.MatchedBy(func(filter bson.M) bool {
+		return filter["key"] == key && filter["expires_at"] != nil
+	})
+}
+
+// Helper function to make a mock collection return the given failures for the key, or none when
+// attempts is nil
+func onFindAttempts(mockColl *mocks.MockAttemptsCollection, key string, attempts *attemptsDB) {
+	result := mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil)
+	if attempts != nil {
+		attempts.Key = key
+		result = mongo.NewSingleResultFromDocument(attempts, nil, nil)
+	}
+	mockColl.On("FindOne", mock.Anything, attemptsFilter(key)).Return(result)
+}
+
+func TestIdentifierKey(t *testing.T) {
+	key := identifierKey("testuser")
+	assert.True(t, strings.HasPrefix(key, "login:"))
+	assert.NotContains(t, key, "testuser")
+	assert.Equal(t, key, identifierKey("  TestUser "))
+	assert.NotEqual(t, key, identifierKey("test@example.com"))
+}
+
+func TestLoginDelay(t *testing.T) {
+	assert.Zero(t, loginDelay(time.Second, 0))
+	assert.Equal(t, time.Second, loginDelay(time.Second, 1))
+	assert.Equal(t, 2*time.Second, loginDelay(time.Second, 2))
+	assert.Equal(t, 8*time.Second, loginDelay(time.Second, 4))
+	assert.Equal(t, maxLoginDelay, loginDelay(time.Second, 10))
+	assert.Equal(t, maxLoginDelay, loginDelay(time.Second, 1000))
+}
+
+func TestCheck(t *testing.T) {
+	longAgo := time.Now().UTC().Add(-time.Hour)
+
+	tests := []struct {
+		name     string
+		user     *attemptsDB
+		ip       *attemptsDB
+		expected error
+	}{
+		{name: "no failures"},
+		{name: "delay passed", user: &attemptsDB{Failures: 4, LastFailureDate: longAgo}},
+		{name: "within delay", user: &attemptsDB{Failures: 3, LastFailureDate: time.Now().UTC()}, expected: ErrThrottled},
+		{name: "locked identifier", user: &attemptsDB{Failures: 5, LastFailureDate: longAgo}, expected: ErrLocked},
+		{name: "failures from IP", ip: &attemptsDB{Failures: 19, LastFailureDate: time.Now().UTC()}},
+		{name: "locked IP", ip: &attemptsDB{Failures: 20, LastFailureDate: longAgo}, expected: ErrThrottled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockColl := mocks.NewMockAttemptsCollection(t)
+			ctx := NewContext(context.Background(), GetAttemptsCollectionKey(), mockColl)
+			ctx = NewClientIPContext(ctx, "192.0.2.1")
+			onFindAttempts(mockColl, "ip:192.0.2.1", tt.ip)
+			if tt.ip == nil || tt.expected == nil {
+				onFindAttempts(mockColl, identifierKey("testuser"), tt.user)
+			}
+
+			assert.Equal(t, tt.expected, Check(ctx, "testuser"))
+		})
+	}
+
+	t.Run("any identifier locked", func(t *testing.T) {
+		mockColl := mocks.NewMockAttemptsCollection(t)
+		ctx := NewContext(context.Background(), GetAttemptsCollectionKey(), mockColl)
+		onFindAttempts(mockColl, identifierKey("testuser"), nil)
+		onFindAttempts(mockColl, identifierKey("test@example.com"), &attemptsDB{Failures: 5, LastFailureDate: longAgo})
+
+		assert.Equal(t, ErrLocked, Check(ctx, "testuser", "test@example.com"))
+	})
+
+	t.Run("no identifier nor client", func(t *testing.T) {
+		mockColl := mocks.NewMockAttemptsCollection(t)
+		ctx := NewContext(context.Background(), GetAttemptsCollectionKey(), mockColl)
+
+		assert.NoError(t, Check(ctx))
+	})
+}
+
+func TestRecordFailure(t *testing.T) {
+	t.Run("identifiers and client IP", func(t *testing.T) {
+		mockColl := mocks.NewMockAttemptsCollection(t)
+		ctx := NewContext(context.Background(), GetAttemptsCollectionKey(), mockColl)
+		ctx = NewClientIPContext(ctx, "192.0.2.1")
+
+		var update mongo.Pipeline
+		for _, key := range []string{"ip:192.0.2.1", identifierKey("testuser"), identifierKey("test@example.com")} {
+			mockColl.On("FindOneAndUpdate", ctx, bson.M{"key": key}, mock.AnythingOfType("mongo.Pipeline"), mock.Anything).
+				Run(func(args mock.Arguments) { update = args.Get(2).(mongo.Pipeline) }).
+				Return(mongo.NewSingleResultFromDocument(attemptsDB{Key: key, Failures: 5}, nil, nil))
+		}
+
+		require.NoError(t, RecordFailure(ctx, "testuser", "test@example.com"))
+		set := update[0][0].Value.(bson.M)
+		// Failures older than the lockout duration start over
+		assert.Contains(t, set["failures"], "$cond")
+		assert.WithinDuration(t, time.Now().Add(15*time.Minute), set["expires_at"].(time.Time), 5*time.Second)
+	})
+
+	t.Run("client IP only", func(t *testing.T) {
+		mockColl := mocks.NewMockAttemptsCollection(t)
+		ctx := NewContext(context.Background(), GetAttemptsCollectionKey(), mockColl)
+		ctx = NewClientIPContext(ctx, "192.0.2.1")
+		mockColl.On("FindOneAndUpdate", ctx, bson.M{"key": "ip:192.0.2.1"}, mock.AnythingOfType("mongo.Pipeline"), mock.Anything).
+			Return(mongo.NewSingleResultFromDocument(attemptsDB{Failures: 1}, nil, nil))
+
+		assert.NoError(t, RecordFailure(ctx))
+	})
+}
+
+func TestReset(t *testing.T) {
+	mockColl := mocks.NewMockAttemptsCollection(t)
+	ctx := NewContext(context.Background(), GetAttemptsCollectionKey(), mockColl)
+	for _, identifier := range []string{"testuser", "test@example.com"} {
+		mockColl.On("DeleteOne", ctx, bson.M{"key": identifierKey(identifier)}).Return(&mongo.DeleteResult{DeletedCount: 1}, nil)
+	}
+
+	assert.NoError(t, Reset(ctx, "testuser", "test@example.com"))
+}
+
+func TestClientIPMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		expected   string
+	}{
+		{name: "local server", remoteAddr: "192.0.2.1:51234", expected: "192.0.2.1"},
+		{name: "IPv6", remoteAddr: "[2001:db8::1]:51234", expected: "2001:db8::1"},
+		{name: "API Gateway source IP", remoteAddr: "192.0.2.1", expected: "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ip string
+			handler := ClientIPMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				ip = ClientIPFromContext(r.Context())
+			}))
+			r := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+			r.RemoteAddr = tt.remoteAddr
+			// Forwarding headers are set by the caller and must not be trusted
+			r.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			assert.Equal(t, tt.expected, ip)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package mfa
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/ahummel25/user-auth-api/config"
+)
+
+// encryptionVersion prefixes encrypted secrets, leaving room to change the scheme later
+const encryptionVersion = "v1"
+
+var errNoEncryptionKey = errors.New("no MFA encryption key configured")
+
+var (
+	aead     cipher.AEAD
+	aeadErr  error
+	aeadOnce sync.Once
+)
+
+// Helper function to get the cipher secrets are encrypted with, loading its key on first use
+func getAEAD(ctx context.Context) (cipher.AEAD, error) {
+	aeadOnce.Do(func() {
+		aead, aeadErr = loadAEAD(ctx)
+	})
+	return aead, aeadErr
+}
+
+func loadAEAD(ctx context.Context) (cipher.AEAD, error) {
+	configSupplier, err := config.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := configSupplier.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	return newAEAD(cfg.MFAEncryptionKey, cfg.IsDev)
+}
+
+// newAEAD builds an AES-256-GCM cipher from the base64 encoded key. Only development may run
+// without a key, in which case secrets do not survive a restart.
+func newAEAD(encodedKey string, isDev bool) (cipher.AEAD, error) {
+	key := make([]byte, 32)
+	if encodedKey == "" {
+		if !isDev {
+			return nil, errNoEncryptionKey
+		}
+		slog.Warn("No MFA encryption key configured, generating an ephemeral development key")
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if key, err = base64.StdEncoding.DecodeString(encodedKey); err != nil {
+			return nil, fmt.Errorf("invalid MFA_ENCRYPTION_KEY: %w", err)
+		}
+		if len(key) != 32 {
+			return nil, errors.New("invalid MFA_ENCRYPTION_KEY: must be 32 bytes")
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptSecret encrypts a secret of the given user for storage. The ciphertext is bound to the
+// user, so that it cannot be decrypted as the secret of another user.
+func EncryptSecret(ctx context.Context, userID string, secret string) (string, error) {
+	gcm, err := getAEAD(ctx)
+	if err != nil {
+		return "", err
+	}
+	return seal(gcm, userID, secret)
+}
+
+// DecryptSecret decrypts a secret of the given user encrypted by EncryptSecret
+func DecryptSecret(ctx context.Context, userID string, encrypted string) (string, error) {
+	gcm, err := getAEAD(ctx)
+	if err != nil {
+		return "", err
+	}
+	return open(gcm, userID, encrypted)
+}
+
+func seal(gcm cipher.AEAD, userID string, secret string) (string, error) {
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(secret), []byte(userID))
+	return encryptionVersion + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func open(gcm cipher.AEAD, userID string, encrypted string) (string, error) {
+	version, encoded, ok := strings.Cut(encrypted, ":")
+	if !ok || version != encryptionVersion {
+		return "", errors.New("unsupported encrypted secret format")
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("encrypted secret is truncated")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	secret, err := gcm.Open(nil, nonce, ciphertext, []byte(userID))
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
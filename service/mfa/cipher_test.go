@@ -0,0 +1,79 @@
+package mfa
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testEncryptionKey = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+
+func TestSealOpen(t *testing.T) {
+	gcm, err := newAEAD(testEncryptionKey, false)
+	require.NoError(t, err)
+
+	encrypted, err := seal(gcm, "test-id", rfcSecret)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encrypted, "v1:"))
+	assert.NotContains(t, encrypted, rfcSecret)
+
+	t.Run("round trip", func(t *testing.T) {
+		secret, err := open(gcm, "test-id", encrypted)
+
+		require.NoError(t, err)
+		assert.Equal(t, rfcSecret, secret)
+	})
+
+	t.Run("bound to the user", func(t *testing.T) {
+		_, err := open(gcm, "other-id", encrypted)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		tampered := encrypted[:len(encrypted)-2] + "AA"
+		_, err := open(gcm, "test-id", tampered)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("another key", func(t *testing.T) {
+		other, err := newAEAD(base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210")), false)
+		require.NoError(t, err)
+
+		_, err = open(other, "test-id", encrypted)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		_, err := open(gcm, "test-id", rfcSecret)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestNewAEAD(t *testing.T) {
+	t.Run("missing key outside development", func(t *testing.T) {
+		_, err := newAEAD("", false)
+
+		assert.Equal(t, errNoEncryptionKey, err)
+	})
+
+	t.Run("ephemeral development key", func(t *testing.T) {
+		_, err := newAEAD("", true)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		for _, key := range []string{"not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+			_, err := newAEAD(key, false)
+
+			assert.ErrorContains(t, err, "MFA_ENCRYPTION_KEY", key)
+		}
+	})
+}
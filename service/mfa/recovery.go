@@ -0,0 +1,78 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// RecoveryCodeCount is the number of recovery codes in a set
+	RecoveryCodeCount = 10
+	// recoveryCodeSize is the number of random bytes of a recovery code, 50 bits once encoded as
+	// its 10 characters
+	recoveryCodeSize = 10 * 5 / 8
+)
+
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// GenerateRecoveryCodes returns a new set of single-use recovery codes, formatted for display as
+// xxxxx-xxxxx, along with the bcrypt hashes to store in their place
+func GenerateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	hashes := make([]string, 0, RecoveryCodeCount)
+	for range RecoveryCodeCount {
+		b := make([]byte, recoveryCodeSize)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := recoveryCodeEncoding.EncodeToString(b)
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, string(hash))
+	}
+	return codes, hashes, nil
+}
+
+// MatchRecoveryCode returns the hash among the given ones which the recovery code matches. The
+// code is matched regardless of case, spaces and dashes, as users may retype it from paper.
+func MatchRecoveryCode(hashes []string, code string) (string, bool) {
+	code = normalizeRecoveryCode(code)
+	if len(code) != recoveryCodeEncoding.EncodedLen(recoveryCodeSize) {
+		return "", false
+	}
+	for _, hash := range hashes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(code)) == nil {
+			return hash, true
+		}
+	}
+	return "", false
+}
+
+// IsTOTPCode reports whether the code has the format of a TOTP code rather than a recovery code
+func IsTOTPCode(code string) bool {
+	if len(code) != totpDigits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Helper function to strip a recovery code of its formatting
+func normalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+}
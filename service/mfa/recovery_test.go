@@ -0,0 +1,63 @@
+package mfa
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, hashes, err := GenerateRecoveryCodes()
+	require.NoError(t, err)
+
+	require.Len(t, codes, RecoveryCodeCount)
+	require.Len(t, hashes, RecoveryCodeCount)
+	seen := map[string]bool{}
+	for _, code := range codes {
+		assert.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, code)
+		assert.False(t, seen[code], "duplicate code %s", code)
+		seen[code] = true
+	}
+
+	last := RecoveryCodeCount - 1
+	hash, ok := MatchRecoveryCode(hashes, codes[last])
+	assert.True(t, ok)
+	assert.Equal(t, hashes[last], hash)
+}
+
+func TestMatchRecoveryCode(t *testing.T) {
+	codes, hashes, err := GenerateRecoveryCodes()
+	require.NoError(t, err)
+	hashes = hashes[:2]
+
+	tests := []struct {
+		name     string
+		code     string
+		wantHash string
+		wantOK   bool
+	}{
+		{name: "formatted", code: codes[1], wantHash: hashes[1], wantOK: true},
+		{name: "retyped", code: " " + codes[0][:3] + " " + codes[0][3:] + " ", wantHash: hashes[0], wantOK: true},
+		{name: "upper case", code: strings.ToUpper(codes[0]), wantHash: hashes[0], wantOK: true},
+		{name: "not in set", code: codes[2]},
+		{name: "wrong length", code: codes[0][:9]},
+		{name: "TOTP code", code: "123456"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, ok := MatchRecoveryCode(hashes, tt.code)
+
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantHash, hash)
+		})
+	}
+}
+
+func TestIsTOTPCode(t *testing.T) {
+	assert.True(t, IsTOTPCode("012345"))
+	assert.False(t, IsTOTPCode("01234"))
+	assert.False(t, IsTOTPCode("01234a"))
+	assert.False(t, IsTOTPCode("abcde-fghij"))
+}
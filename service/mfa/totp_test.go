@@ -0,0 +1,100 @@
+package mfa
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA-1 seed of the RFC 6238 test vectors, base32 encoded
+var rfcSecret = secretEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestGenerateCode(t *testing.T) {
+	key := []byte("12345678901234567890")
+	// RFC 6238 appendix B, truncated to 6 digits
+	tests := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range tests {
+		assert.Equal(t, want, generateCode(key, unix/30), unix)
+	}
+}
+
+func TestGenerateCodeRoundTrip(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	now := time.Now()
+
+	code, err := GenerateCode(secret, now)
+	require.NoError(t, err)
+
+	step, ok := ValidateCode(secret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, now.Unix()/30, step)
+}
+
+func TestValidateCode(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := now.Unix() / 30
+
+	tests := []struct {
+		name     string
+		code     string
+		at       time.Time
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current code", code: "005924", at: now, wantStep: step, wantOK: true},
+		{name: "previous code", code: "005924", at: now.Add(30 * time.Second), wantStep: step, wantOK: true},
+		{name: "next code", code: "005924", at: now.Add(-30 * time.Second), wantStep: step, wantOK: true},
+		{name: "expired code", code: "005924", at: now.Add(90 * time.Second)},
+		{name: "wrong code", code: "123456", at: now},
+		{name: "wrong length", code: "05924", at: now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := ValidateCode(rfcSecret, tt.code, tt.at)
+
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantStep, gotStep)
+		})
+	}
+
+	t.Run("invalid secret", func(t *testing.T) {
+		_, ok := ValidateCode("not base32!", "005924", now)
+
+		assert.False(t, ok)
+	})
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+
+	key, err := secretEncoding.DecodeString(secret)
+	require.NoError(t, err)
+	assert.Len(t, key, secretSize)
+
+	other, err := GenerateSecret()
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, other)
+}
+
+func TestKeyURI(t *testing.T) {
+	uri, err := url.Parse(KeyURI("Example App", "test@example.com", rfcSecret))
+	require.NoError(t, err)
+
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/Example App:test@example.com", uri.Path)
+	assert.Equal(t, rfcSecret, uri.Query().Get("secret"))
+	assert.Equal(t, "Example App", uri.Query().Get("issuer"))
+	assert.Equal(t, "6", uri.Query().Get("digits"))
+	assert.Equal(t, "30", uri.Query().Get("period"))
+}
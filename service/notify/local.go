@@ -0,0 +1,64 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// writerSender writes messages to a writer instead of delivering them, for local development
+type writerSender struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSender returns a sender which writes every message to w
+func NewWriterSender(w io.Writer) Sender {
+	return &writerSender{w: w}
+}
+
+func (s *writerSender) Send(_ context.Context, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return writeMessage(s.w, msg)
+}
+
+// fileSender appends messages to a file instead of delivering them, for local development
+type fileSender struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileSender returns a sender which appends every message to the file at path, creating it if
+// needed
+func NewFileSender(path string) Sender {
+	return &fileSender{path: path}
+}
+
+func (s *fileSender) Send(_ context.Context, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if err = writeMessage(f, msg); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Helper function to write a message in a readable form
+func writeMessage(w io.Writer, msg Message) error {
+	_, err := fmt.Fprintf(w, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().UTC().Format(time.RFC1123Z), msg.To, msg.Subject, msg.Text)
+	if err != nil || msg.HTML == "" {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "--- HTML ---\n%s\n\n", msg.HTML)
+	return err
+}
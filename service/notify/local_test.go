@@ -0,0 +1,54 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testMessage = Message{To: "test@example.com", Subject: "Reset your password", Text: "Your code is 1234"}
+
+func TestWriterSender(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, NewWriterSender(&buf).Send(context.Background(), testMessage))
+
+	assert.Contains(t, buf.String(), "To: test@example.com\n")
+	assert.Contains(t, buf.String(), "Subject: Reset your password\n")
+	assert.Contains(t, buf.String(), "\n\nYour code is 1234\n")
+	assert.NotContains(t, buf.String(), "HTML")
+}
+
+func TestWriterSenderHTML(t *testing.T) {
+	var buf bytes.Buffer
+	msg := testMessage
+	msg.HTML = "<p>Your code is 1234</p>"
+	require.NoError(t, NewWriterSender(&buf).Send(context.Background(), msg))
+
+	assert.Contains(t, buf.String(), "--- HTML ---\n<p>Your code is 1234</p>\n")
+}
+
+func TestFileSender(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.log")
+	sender := NewFileSender(path)
+
+	require.NoError(t, sender.Send(context.Background(), testMessage))
+	require.NoError(t, sender.Send(context.Background(), testMessage))
+
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, bytes.Count(contents, []byte("Subject: Reset your password")))
+}
+
+func TestFromContext(t *testing.T) {
+	sender := NewWriterSender(&bytes.Buffer{})
+
+	fromContext, err := FromContext(NewContext(context.Background(), sender))
+
+	require.NoError(t, err)
+	assert.Same(t, sender, fromContext)
+}
@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/ahummel25/user-auth-api/config"
+	"github.com/ahummel25/user-auth-api/db/mongo"
+)
+
+var errNoSender = errors.New("no notification sender configured")
+
+// Message is a message to deliver to a user. HTML is an optional alternative rendering of Text.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Sender delivers messages to users
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// OutboxCollection is an interface that wraps the database.Collection interface
+type OutboxCollection interface {
+	mongo.Collection
+}
+
+// senderCtxKey is the context key for the Sender stored in the context
+type senderCtxKey struct{}
+
+// outboxCollectionCtxKey represents the context key of the notification outbox Mongo collection
+type outboxCollectionCtxKey struct{}
+
+// NewContext returns a new context containing the given sender
+func NewContext(ctx context.Context, s Sender) context.Context {
+	return context.WithValue(ctx, senderCtxKey{}, s)
+}
+
+// NewCollectionContext returns a new context containing the given outbox collection under the
+// given context key
+func NewCollectionContext(ctx context.Context, collectionCtxKey any, collection OutboxCollection) context.Context {
+	return context.WithValue(ctx, collectionCtxKey, collection)
+}
+
+// OutboxFromContext returns the OutboxCollection from the context, or an error if not found
+func OutboxFromContext(ctx context.Context) (OutboxCollection, error) {
+	if c, ok := ctx.Value(GetOutboxCollectionKey()).(OutboxCollection); ok {
+		return c, nil
+	}
+	return nil, errors.New("outbox collection not found in context")
+}
+
+// GetOutboxCollectionKey is a wrapper function around the outboxCollectionCtxKey returning a pointer to that value
+func GetOutboxCollectionKey() *outboxCollectionCtxKey {
+	return &outboxCollectionCtxKey{}
+}
+
+// FromContext returns the sender stored in the context. When none was stored, messages are queued
+// in the outbox if its collection is in the context, or go through the configured transport.
+func FromContext(ctx context.Context) (Sender, error) {
+	if s, ok := ctx.Value(senderCtxKey{}).(Sender); ok {
+		return s, nil
+	}
+	if _, err := OutboxFromContext(ctx); err == nil {
+		return NewOutbox(), nil
+	}
+	return NewTransport(ctx)
+}
+
+// NewTransport returns the sender which actually delivers messages: SMTP when SMTP_HOST is set,
+// otherwise in local development NOTIFY_FILE, or stdout if it is unset
+func NewTransport(ctx context.Context) (Sender, error) {
+	configSupplier, err := config.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := configSupplier.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case cfg.SMTPHost != "":
+		return NewSMTPSender(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.NotifyFrom)
+	case !cfg.IsDev:
+		return nil, errNoSender
+	case cfg.NotifyFile != "":
+		return NewFileSender(cfg.NotifyFile), nil
+	default:
+		return NewWriterSender(os.Stdout), nil
+	}
+}
@@ -0,0 +1,173 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	// outboxLease is how long a message being delivered is hidden from other deliveries. A message
+	// whose delivery was interrupted, e.g. by a Lambda timeout, is retried once its lease expires.
+	outboxLease = 5 * time.Minute
+	// outboxMaxAttempts is the number of deliveries attempted before a message is given up on
+	outboxMaxAttempts = 8
+	outboxMinBackoff  = time.Minute
+	outboxMaxBackoff  = time.Hour
+)
+
+// outboxStatus is the delivery status of a message in the outbox
+type outboxStatus string
+
+const (
+	outboxStatusPending outboxStatus = "pending"
+	outboxStatusSent    outboxStatus = "sent"
+	outboxStatusFailed  outboxStatus = "failed"
+)
+
+// outboxMessageDB is a message queued for delivery. NextAttemptAt is when the message may next be
+// claimed for delivery, and Attempts counts the deliveries claimed so far. Text and HTML, which may
+// carry tokens, are removed once the message is sent or given up on.
+type outboxMessageDB struct {
+	MessageID     string       `bson:"message_id"`
+	To            string       `bson:"to"`
+	Subject       string       `bson:"subject"`
+	Text          string       `bson:"text"`
+	HTML          string       `bson:"html"`
+	Status        outboxStatus `bson:"status"`
+	Attempts      int          `bson:"attempts"`
+	NextAttemptAt time.Time    `bson:"next_attempt_at"`
+	LastError     string       `bson:"last_error,omitempty"`
+	CreationDate  time.Time    `bson:"creation_date"`
+	SentDate      *time.Time   `bson:"sent_date"`
+	FailedDate    *time.Time   `bson:"failed_date,omitempty"`
+}
+
+// outbox queues messages for ProcessOutbox to deliver
+type outbox struct{}
+
+// NewOutbox returns a sender which queues messages in the outbox collection of the context, to be
+// delivered by ProcessOutbox. Messages are not delivered while the request that sends them waits,
+// so that how long the transport takes does not tell callers whether a message was sent at all.
+// Delivery is at least once.
+func NewOutbox() Sender {
+	return &outbox{}
+}
+
+// Send returns once the message is queued
+func (o *outbox) Send(ctx context.Context, msg Message) error {
+	outboxCollection, err := OutboxFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	queued := outboxMessageDB{
+		MessageID:     uuid.New().String(),
+		To:            msg.To,
+		Subject:       msg.Subject,
+		Text:          msg.Text,
+		HTML:          msg.HTML,
+		Status:        outboxStatusPending,
+		NextAttemptAt: now,
+		CreationDate:  now,
+	}
+	_, err = outboxCollection.InsertOne(ctx, queued)
+	return err
+}
+
+// ProcessOutbox delivers up to limit queued messages which are due, returning how many were
+// delivered. Failed deliveries are rescheduled with an exponential backoff.
+func ProcessOutbox(ctx context.Context, transport Sender, limit int) (int, error) {
+	outboxCollection, err := OutboxFromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for range limit {
+		queued, err := claimDueMessage(ctx, outboxCollection)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			break
+		} else if err != nil {
+			return delivered, err
+		}
+		if err = deliver(ctx, outboxCollection, transport, queued); err != nil {
+			return delivered, err
+		}
+		if queued.Status == outboxStatusSent {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// Helper function to claim the longest due pending message, leasing it to the caller
+func claimDueMessage(ctx context.Context, outboxCollection OutboxCollection) (*outboxMessageDB, error) {
+	now := time.Now().UTC()
+	filter := bson.M{"status": outboxStatusPending, "next_attempt_at": bson.M{"$lte": now}}
+	update := bson.M{
+		"$set": bson.M{"next_attempt_at": now.Add(outboxLease)},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var queued outboxMessageDB
+	if err := outboxCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&queued); err != nil {
+		return nil, err
+	}
+	return &queued, nil
+}
+
+// Helper function to hand a claimed message to the transport and record the outcome, updating
+// the status of the message in place. The returned error is about recording the outcome only.
+func deliver(ctx context.Context, outboxCollection OutboxCollection, transport Sender, queued *outboxMessageDB) error {
+	msg := Message{To: queued.To, Subject: queued.Subject, Text: queued.Text, HTML: queued.HTML}
+	sendErr := transport.Send(ctx, msg)
+
+	now := time.Now().UTC()
+	set := bson.M{}
+	switch {
+	case sendErr == nil:
+		queued.Status = outboxStatusSent
+		set["sent_date"] = now
+	case queued.Attempts >= outboxMaxAttempts:
+		slog.Error("Giving up on delivering message", "error", sendErr, "message_id", queued.MessageID)
+		queued.Status = outboxStatusFailed
+		set["failed_date"] = now
+		set["last_error"] = sendErr.Error()
+	default:
+		slog.Warn("Failed to deliver message, will retry", "error", sendErr,
+			"message_id", queued.MessageID, "attempts", queued.Attempts)
+		set["next_attempt_at"] = now.Add(outboxBackoff(queued.Attempts))
+		set["last_error"] = sendErr.Error()
+	}
+	set["status"] = queued.Status
+	update := bson.M{"$set": set}
+	// The bodies are no longer needed once the message is done with, and must not outlive the
+	// tokens they carry
+	if queued.Status != outboxStatusPending {
+		update["$unset"] = bson.M{"text": "", "html": ""}
+	}
+
+	_, err := outboxCollection.UpdateOne(ctx, bson.M{"message_id": queued.MessageID}, update)
+	return err
+}
+
+// Helper function to compute the delay before retrying a message after the given number of
+// failed attempts, doubling from a minute up to an hour
+func outboxBackoff(attempts int) time.Duration {
+	backoff := outboxMinBackoff
+	for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, outboxMaxBackoff)
+}
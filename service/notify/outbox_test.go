@@ -0,0 +1,204 @@
+package notify_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+
+	"github.com/ahummel25/user-auth-api/service/notify"
+	notifyMocks "github.com/ahummel25/user-auth-api/service/notify/mocks"
+)
+
+var testMessage = notify.Message{To: "test@example.com", Subject: "Hi", Text: "Hello", HTML: "<p>Hello</p>"}
+
+// Helper function to create a context with a mock outbox collection
+func withMockOutbox(t *testing.T) (context.Context, *notifyMocks.MockOutboxCollection) {
+	t.Helper()
+	mockColl := notifyMocks.NewMockOutboxCollection(t)
+	return notify.NewCollectionContext(context.Background(), notify.GetOutboxCollectionKey(), mockColl), mockColl
+}
+
+// deliveryUpdate is the update recording a delivery attempt
+type deliveryUpdate struct {
+	Set   bson.M
+	Unset bson.M
+}
+
+// Helper function to capture the update recording a delivery attempt
+func captureDeliveryUpdate(mockColl *notifyMocks.MockOutboxCollection, ctx context.Context, filter any) *deliveryUpdate {
+	update := &deliveryUpdate{}
+	mockColl.On("UpdateOne", ctx, filter, mock.AnythingOfType("bson.M")).
+		Run(func(args mock.Arguments) {
+			update.Set = args.Get(2).(bson.M)["$set"].(bson.M)
+			update.Unset, _ = args.Get(2).(bson.M)["$unset"].(bson.M)
+		}).
+		Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	return update
+}
+
+func TestOutboxSend(t *testing.T) {
+	t.Run("queues for delivery", func(t *testing.T) {
+		ctx, mockColl := withMockOutbox(t)
+
+		var queued bson.M
+		mockColl.On("InsertOne", ctx, mock.Anything).
+			Run(func(args mock.Arguments) {
+				raw, err := bson.Marshal(args.Get(1))
+				require.NoError(t, err)
+				require.NoError(t, bson.Unmarshal(raw, &queued))
+			}).
+			Return(&mongo.InsertOneResult{}, nil)
+
+		require.NoError(t, notify.NewOutbox().Send(ctx, testMessage))
+
+		assert.Equal(t, "pending", queued["status"])
+		assert.EqualValues(t, 0, queued["attempts"])
+		assert.Equal(t, testMessage.HTML, queued["html"])
+		// Due right away, for the processor to deliver outside of the request
+		assert.False(t, queued["next_attempt_at"].(bson.DateTime).Time().After(time.Now()))
+		mockColl.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("queueing failure", func(t *testing.T) {
+		ctx, mockColl := withMockOutbox(t)
+
+		mockColl.On("InsertOne", ctx, mock.Anything).Return(nil, errors.New("connection refused"))
+
+		assert.Error(t, notify.NewOutbox().Send(ctx, testMessage))
+	})
+
+	t.Run("no outbox collection", func(t *testing.T) {
+		assert.Error(t, notify.NewOutbox().Send(context.Background(), testMessage))
+	})
+}
+
+// Helper function to mock the claim of a due message, followed by an empty outbox
+func claimMessages(mockColl *notifyMocks.MockOutboxCollection, ctx context.Context, messages ...bson.M) {
+	for _, message := range messages {
+		mockColl.On("FindOneAndUpdate", ctx, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M"), mock.Anything).
+			Return(mongo.NewSingleResultFromDocument(message, nil, nil)).Once()
+	}
+	mockColl.On("FindOneAndUpdate", ctx, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M"), mock.Anything).
+		Return(mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil)).Once()
+}
+
+func TestProcessOutbox(t *testing.T) {
+	queuedMessage := func(messageID string, attempts int) bson.M {
+		return bson.M{
+			"message_id": messageID,
+			"to":         testMessage.To,
+			"subject":    testMessage.Subject,
+			"text":       testMessage.Text,
+			"html":       testMessage.HTML,
+			"status":     "pending",
+			"attempts":   attempts,
+		}
+	}
+
+	t.Run("delivers due messages", func(t *testing.T) {
+		ctx, mockColl := withMockOutbox(t)
+		transport := notifyMocks.NewMockSender(t)
+
+		claimMessages(mockColl, ctx, queuedMessage("message-1", 2), queuedMessage("message-2", 2))
+		transport.On("Send", ctx, testMessage).Return(nil).Twice()
+		first := captureDeliveryUpdate(mockColl, ctx, bson.M{"message_id": "message-1"})
+		second := captureDeliveryUpdate(mockColl, ctx, bson.M{"message_id": "message-2"})
+
+		delivered, err := notify.ProcessOutbox(ctx, transport, 10)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, delivered)
+		assert.EqualValues(t, "sent", first.Set["status"])
+		assert.EqualValues(t, "sent", second.Set["status"])
+		assert.NotNil(t, first.Set["sent_date"])
+		// The bodies, which may carry tokens, are not kept once sent
+		assert.Equal(t, bson.M{"text": "", "html": ""}, first.Unset)
+	})
+
+	t.Run("claims due pending messages only", func(t *testing.T) {
+		ctx, mockColl := withMockOutbox(t)
+		transport := notifyMocks.NewMockSender(t)
+
+		var filter, update bson.M
+		mockColl.On("FindOneAndUpdate", ctx, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M"), mock.Anything).
+			Run(func(args mock.Arguments) {
+				filter = args.Get(1).(bson.M)
+				update = args.Get(2).(bson.M)
+			}).
+			Return(mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil))
+
+		delivered, err := notify.ProcessOutbox(ctx, transport, 10)
+
+		require.NoError(t, err)
+		assert.Zero(t, delivered)
+		assert.EqualValues(t, "pending", filter["status"])
+		assert.Contains(t, filter, "next_attempt_at")
+		assert.Equal(t, bson.M{"attempts": 1}, update["$inc"])
+	})
+
+	t.Run("stops at the limit", func(t *testing.T) {
+		ctx, mockColl := withMockOutbox(t)
+		transport := notifyMocks.NewMockSender(t)
+
+		mockColl.On("FindOneAndUpdate", ctx, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M"), mock.Anything).
+			Return(mongo.NewSingleResultFromDocument(queuedMessage("message-1", 2), nil, nil)).Once()
+		transport.On("Send", ctx, testMessage).Return(nil).Once()
+		captureDeliveryUpdate(mockColl, ctx, bson.M{"message_id": "message-1"})
+
+		delivered, err := notify.ProcessOutbox(ctx, transport, 1)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, delivered)
+	})
+
+	t.Run("backs off exponentially", func(t *testing.T) {
+		ctx, mockColl := withMockOutbox(t)
+		transport := notifyMocks.NewMockSender(t)
+
+		claimMessages(mockColl, ctx, queuedMessage("message-1", 3))
+		transport.On("Send", ctx, testMessage).Return(errors.New("connection refused"))
+		update := captureDeliveryUpdate(mockColl, ctx, bson.M{"message_id": "message-1"})
+
+		delivered, err := notify.ProcessOutbox(ctx, transport, 10)
+
+		require.NoError(t, err)
+		assert.Zero(t, delivered)
+		assert.EqualValues(t, "pending", update.Set["status"])
+		assert.WithinDuration(t, time.Now().Add(4*time.Minute), update.Set["next_attempt_at"].(time.Time), 5*time.Second)
+	})
+
+	t.Run("gives up after the last attempt", func(t *testing.T) {
+		ctx, mockColl := withMockOutbox(t)
+		transport := notifyMocks.NewMockSender(t)
+
+		claimMessages(mockColl, ctx, queuedMessage("message-1", 8))
+		transport.On("Send", ctx, testMessage).Return(errors.New("mailbox unavailable"))
+		update := captureDeliveryUpdate(mockColl, ctx, bson.M{"message_id": "message-1"})
+
+		delivered, err := notify.ProcessOutbox(ctx, transport, 10)
+
+		require.NoError(t, err)
+		assert.Zero(t, delivered)
+		assert.EqualValues(t, "failed", update.Set["status"])
+		assert.Equal(t, "mailbox unavailable", update.Set["last_error"])
+		// Failed messages are kept for inspection a while longer, without their bodies
+		assert.NotNil(t, update.Set["failed_date"])
+		assert.Equal(t, bson.M{"text": "", "html": ""}, update.Unset)
+	})
+}
+
+func TestFromContextWithOutbox(t *testing.T) {
+	ctx, _ := withMockOutbox(t)
+
+	sender, err := notify.FromContext(ctx)
+
+	require.NoError(t, err)
+	assert.IsType(t, notify.NewOutbox(), sender)
+}
@@ -0,0 +1,220 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// smtpDialTimeout bounds connecting to the relay
+	smtpDialTimeout = 10 * time.Second
+	// smtpTimeout bounds a whole delivery, unless the context has an earlier deadline
+	smtpTimeout = 30 * time.Second
+)
+
+var (
+	errInvalidHeader = errors.New("message header contains a line break")
+	errNoAuth        = errors.New("SMTP relay does not support authentication")
+)
+
+// dialFunc matches net.Dialer.DialContext, which smtpSender connects with unless replaced in tests
+type dialFunc func(ctx context.Context, network string, addr string) (net.Conn, error)
+
+// smtpSender delivers messages through an SMTP relay, upgrading the connection with STARTTLS
+// when the relay offers it
+type smtpSender struct {
+	host string
+	addr string
+	auth smtp.Auth
+	from *mail.Address
+	dial dialFunc
+}
+
+// NewSMTPSender returns a sender which delivers messages through the SMTP relay at host:port.
+// The relay is authenticated with PLAIN when a username is given, which net/smtp only allows
+// over TLS or to localhost.
+func NewSMTPSender(host string, port int, username string, password string, from string) (Sender, error) {
+	fromAddress, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address %q: %w", from, err)
+	}
+	s := &smtpSender{
+		host: host,
+		addr: net.JoinHostPort(host, strconv.Itoa(port)),
+		from: fromAddress,
+		dial: (&net.Dialer{Timeout: smtpDialTimeout}).DialContext,
+	}
+	if username != "" {
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s, nil
+}
+
+// Send delivers the message within smtpTimeout, or by the deadline of the context if that is
+// earlier, so that a relay which stops responding cannot hold up the caller
+func (s *smtpSender) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+	raw, err := buildMIMEMessage(s.from, to, msg)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(smtpTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn, err := s.dial(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	// The deadline covers every exchange with the relay, which net/smtp has no timeouts for
+	if err = conn.SetDeadline(deadline); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	return s.sendMail(conn, to.Address, raw)
+}
+
+// Helper function to run the SMTP session delivering a message over the given connection, which
+// is closed once done. This follows smtp.SendMail.
+func (s *smtpSender) sendMail(conn net.Conn, to string, raw []byte) error {
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return err
+		}
+	}
+	if s.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errNoAuth
+		}
+		if err = c.Auth(s.auth); err != nil {
+			return err
+		}
+	}
+	if err = c.Mail(s.from.Address); err != nil {
+		return err
+	}
+	if err = c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(raw); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// Helper function to build a MIME message with the text body and, if the message has one, an
+// HTML alternative
+func buildMIMEMessage(from *mail.Address, to *mail.Address, msg Message) ([]byte, error) {
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, errInvalidHeader
+	}
+
+	var buf bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+	header("From", from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().UTC().Format(time.RFC1123Z))
+	messageID, err := newMessageID(from.Address)
+	if err != nil {
+		return nil, err
+	}
+	header("Message-ID", messageID)
+	header("MIME-Version", "1.0")
+
+	if msg.HTML == "" {
+		header("Content-Type", `text/plain; charset="utf-8"`)
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err = writeQuotedPrintable(&buf, msg.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	header("Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": parts.Boundary()}))
+	buf.WriteString("\r\n")
+	// Clients show the last alternative they support, so the richest comes last
+	for _, part := range []struct{ contentType, content string }{
+		{`text/plain; charset="utf-8"`, msg.Text},
+		{`text/html; charset="utf-8"`, msg.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err = writeQuotedPrintable(w, part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err = parts.Close(); err != nil {
+		return nil, err
+	}
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+// Helper function to write content encoded as quoted-printable
+func writeQuotedPrintable(w interface{ Write([]byte) (int, error) }, content string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(content)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// Helper function to generate a unique Message-ID in the domain of the sender
+func newMessageID(fromAddress string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	domain := "localhost"
+	if at := strings.LastIndexByte(fromAddress, '@'); at >= 0 {
+		domain = fromAddress[at+1:]
+	}
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain), nil
+}
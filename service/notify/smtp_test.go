@@ -0,0 +1,173 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sentMail struct {
+	addr string
+	auth string
+	from string
+	to   []string
+	msg  []byte
+}
+
+// Helper function to serve an SMTP session on conn like a relay offering PLAIN authentication,
+// recording the mail sent through it
+func serveSMTP(conn net.Conn, sent *sentMail) {
+	relay := textproto.NewConn(conn)
+	defer relay.Close()
+	_ = relay.PrintfLine("220 smtp.example.com ESMTP")
+	for {
+		line, err := relay.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			_ = relay.PrintfLine("250-smtp.example.com")
+			_ = relay.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			sent.auth = arg
+			_ = relay.PrintfLine("235 Authentication successful")
+		case "MAIL":
+			sent.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			_ = relay.PrintfLine("250 OK")
+		case "RCPT":
+			sent.to = append(sent.to, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			_ = relay.PrintfLine("250 OK")
+		case "DATA":
+			_ = relay.PrintfLine("354 Go ahead")
+			sent.msg, _ = relay.ReadDotBytes()
+			_ = relay.PrintfLine("250 OK")
+		case "QUIT":
+			_ = relay.PrintfLine("221 Bye")
+			return
+		default:
+			_ = relay.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+// Helper function to create an SMTP sender connected to a fake relay recording the mail sent.
+// The relay is on localhost, as net/smtp only sends PLAIN credentials over TLS or to localhost.
+func newTestSMTPSender(t *testing.T, username string) (*smtpSender, *sentMail) {
+	t.Helper()
+	sender, err := NewSMTPSender("localhost", 587, username, "secret", "Example <no-reply@example.com>")
+	require.NoError(t, err)
+	s := sender.(*smtpSender)
+	sent := &sentMail{}
+	s.dial = func(_ context.Context, _ string, addr string) (net.Conn, error) {
+		sent.addr = addr
+		client, server := net.Pipe()
+		go serveSMTP(server, sent)
+		return client, nil
+	}
+	return s, sent
+}
+
+func TestSMTPSender(t *testing.T) {
+	t.Run("multipart message", func(t *testing.T) {
+		s, sent := newTestSMTPSender(t, "user")
+		msg := Message{To: "test@example.com", Subject: "Réinitialiser", Text: "Hello", HTML: "<p>Hello</p>"}
+
+		require.NoError(t, s.Send(context.Background(), msg))
+
+		assert.Equal(t, "localhost:587", sent.addr)
+		assert.True(t, strings.HasPrefix(sent.auth, "PLAIN "))
+		assert.Equal(t, "no-reply@example.com", sent.from)
+		assert.Equal(t, []string{"test@example.com"}, sent.to)
+
+		parsed, err := mail.ReadMessage(strings.NewReader(string(sent.msg)))
+		require.NoError(t, err)
+		subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+		require.NoError(t, err)
+		assert.Equal(t, "Réinitialiser", subject)
+		assert.Contains(t, parsed.Header.Get("From"), "no-reply@example.com")
+		assert.True(t, strings.HasSuffix(parsed.Header.Get("Message-ID"), "@example.com>"))
+
+		mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+		require.NoError(t, err)
+		assert.Equal(t, "multipart/alternative", mediaType)
+		parts := multipart.NewReader(parsed.Body, params["boundary"])
+		var bodies []string
+		for {
+			part, err := parts.NextPart()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+			body, err := io.ReadAll(part)
+			require.NoError(t, err)
+			bodies = append(bodies, part.Header.Get("Content-Type")+": "+string(body))
+		}
+		assert.Equal(t, []string{`text/plain; charset="utf-8": Hello`, `text/html; charset="utf-8": <p>Hello</p>`}, bodies)
+	})
+
+	t.Run("text only message", func(t *testing.T) {
+		s, sent := newTestSMTPSender(t, "")
+
+		require.NoError(t, s.Send(context.Background(), Message{To: "test@example.com", Subject: "Hi", Text: "Hello"}))
+
+		assert.Empty(t, sent.auth)
+		parsed, err := mail.ReadMessage(strings.NewReader(string(sent.msg)))
+		require.NoError(t, err)
+		assert.Contains(t, parsed.Header.Get("Content-Type"), "text/plain")
+	})
+
+	t.Run("unresponsive relay", func(t *testing.T) {
+		s, _ := newTestSMTPSender(t, "")
+		// The relay accepts the connection but never greets
+		s.dial = func(context.Context, string, string) (net.Conn, error) {
+			client, server := net.Pipe()
+			t.Cleanup(func() { _ = server.Close() })
+			return client, nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		err := s.Send(ctx, Message{To: "test@example.com", Subject: "Hi", Text: "Hello"})
+
+		// The delivery gives up at the deadline of the context
+		var netErr net.Error
+		require.ErrorAs(t, err, &netErr)
+		assert.True(t, netErr.Timeout())
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("header injection", func(t *testing.T) {
+		s, sent := newTestSMTPSender(t, "")
+		msg := Message{To: "test@example.com", Subject: "Hi\r\nBcc: victim@example.com", Text: "Hello"}
+
+		assert.Equal(t, errInvalidHeader, s.Send(context.Background(), msg))
+		assert.Nil(t, sent.msg)
+	})
+
+	t.Run("invalid recipient", func(t *testing.T) {
+		s, sent := newTestSMTPSender(t, "")
+
+		assert.Error(t, s.Send(context.Background(), Message{To: "not an address", Subject: "Hi", Text: "Hello"}))
+		assert.Nil(t, sent.msg)
+	})
+}
+
+func TestNewSMTPSenderInvalidFrom(t *testing.T) {
+	_, err := NewSMTPSender("smtp.example.com", 587, "", "", "")
+
+	assert.Error(t, err)
+}
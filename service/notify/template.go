@@ -0,0 +1,108 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Names of the message templates
+const (
+	TemplateEmailChanged      = "email_changed"
+	TemplateEmailVerification = "email_verification"
+	TemplatePasswordReset     = "password_reset"
+)
+
+// defaultLocale is used when no template exists for the requested locale
+const defaultLocale = "en"
+
+// templates holds a directory per locale. Each message has a <name>.txt template defining its
+// "subject" and "text", and a <name>.html template defining its "html" body.
+//
+//go:embed templates
+var templates embed.FS
+
+// ActionData is the data of messages asking the user to act with a token, either by following
+// Link or, when the client application has no page for it, by entering Token
+type ActionData struct {
+	Link  string
+	Token string
+	TTL   time.Duration
+}
+
+// EmailChangedData is the data of the notice sent to the previous email address of a user whose
+// email address was changed
+type EmailChangedData struct {
+	NewEmail string
+}
+
+var templateFuncs = map[string]any{"duration": formatDuration}
+
+// Render renders the named message template in the locale closest to the given BCP 47 language
+// tag, falling back to English
+func Render(name string, locale string, to string, data any) (Message, error) {
+	dir := templateDir(name, locale)
+
+	text, err := texttemplate.New(name).Funcs(templateFuncs).ParseFS(templates, path.Join(dir, name+".txt"))
+	if err != nil {
+		return Message{}, err
+	}
+	html, err := htmltemplate.New(name).Funcs(templateFuncs).ParseFS(templates, path.Join(dir, name+".html"))
+	if err != nil {
+		return Message{}, err
+	}
+
+	var subject, textBody, htmlBody bytes.Buffer
+	if err = text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err = text.ExecuteTemplate(&textBody, "text", data); err != nil {
+		return Message{}, err
+	}
+	if err = html.ExecuteTemplate(&htmlBody, "html", data); err != nil {
+		return Message{}, err
+	}
+	return Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    textBody.String(),
+		HTML:    htmlBody.String(),
+	}, nil
+}
+
+// Helper function to find the template directory of a message for a locale, trying the full
+// language tag, then its base language, then the default locale
+func templateDir(name string, locale string) string {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	candidates := []string{locale}
+	if base, _, found := strings.Cut(locale, "-"); found {
+		candidates = append(candidates, base)
+	}
+	for _, candidate := range candidates {
+		if candidate == "" || strings.Contains(candidate, ".") {
+			continue
+		}
+		dir := path.Join("templates", candidate)
+		if _, err := fs.Stat(templates, path.Join(dir, name+".txt")); err == nil {
+			return dir
+		}
+	}
+	return path.Join("templates", defaultLocale)
+}
+
+// Helper function to format a duration without its zero units, e.g. 1h rather than 1h0m0s
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
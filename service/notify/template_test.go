@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	data := ActionData{Link: "https://app.example.com/reset?a=1&token=abc", Token: "abc", TTL: 90 * time.Minute}
+
+	t.Run("renders every part", func(t *testing.T) {
+		msg, err := Render(TemplatePasswordReset, "en", "test@example.com", data)
+
+		require.NoError(t, err)
+		assert.Equal(t, "test@example.com", msg.To)
+		assert.Equal(t, "Reset your password", msg.Subject)
+		assert.Contains(t, msg.Text, "Follow this link to choose a new password: https://app.example.com/reset?a=1&token=abc")
+		assert.Contains(t, msg.Text, "It expires in 1h30m.")
+		// The HTML body is escaped
+		assert.Contains(t, msg.HTML, `href="https://app.example.com/reset?a=1&amp;token=abc"`)
+	})
+
+	t.Run("without link", func(t *testing.T) {
+		msg, err := Render(TemplateEmailVerification, "en", "test@example.com", ActionData{Token: "abc", TTL: time.Hour})
+
+		require.NoError(t, err)
+		assert.Contains(t, msg.Text, "Use this code to confirm your email address: abc")
+		assert.Contains(t, msg.HTML, "<strong>abc</strong>")
+	})
+
+	locales := []struct {
+		locale  string
+		subject string
+	}{
+		{locale: "es", subject: "Restablece tu contraseña"},
+		{locale: "es-MX", subject: "Restablece tu contraseña"},
+		{locale: "ES_mx", subject: "Restablece tu contraseña"},
+		{locale: "fr", subject: "Reset your password"},
+		{locale: "", subject: "Reset your password"},
+		{locale: "../en", subject: "Reset your password"},
+	}
+	for _, tt := range locales {
+		t.Run("locale "+tt.locale, func(t *testing.T) {
+			msg, err := Render(TemplatePasswordReset, tt.locale, "test@example.com", data)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.subject, msg.Subject)
+		})
+	}
+
+	t.Run("email changed", func(t *testing.T) {
+		msg, err := Render(TemplateEmailChanged, "en", "old@example.com", EmailChangedData{NewEmail: "new@example.com"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "old@example.com", msg.To)
+		assert.Equal(t, "Your email address was changed", msg.Subject)
+		assert.Contains(t, msg.Text, "changed to new@example.com")
+	})
+
+	t.Run("unknown template", func(t *testing.T) {
+		_, err := Render("unknown", "en", "test@example.com", data)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestTemplatesAreComplete(t *testing.T) {
+	templateData := map[string]any{
+		TemplatePasswordReset:     ActionData{Token: "abc", TTL: time.Hour},
+		TemplateEmailVerification: ActionData{Token: "abc", TTL: time.Hour},
+		TemplateEmailChanged:      EmailChangedData{NewEmail: "new@example.com"},
+	}
+	for _, locale := range []string{"en", "es"} {
+		for name, data := range templateData {
+			msg, err := Render(name, locale, "test@example.com", data)
+
+			require.NoError(t, err, "%s/%s", locale, name)
+			assert.NotEmpty(t, msg.Subject, "%s/%s", locale, name)
+			assert.NotEmpty(t, msg.Text, "%s/%s", locale, name)
+			assert.NotEmpty(t, msg.HTML, "%s/%s", locale, name)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		time.Hour:                  "1h",
+		48 * time.Hour:             "48h",
+		90 * time.Minute:           "1h30m",
+		15 * time.Minute:           "15m",
+		time.Hour + 30*time.Second: "1h0m30s",
+		45 * time.Second:           "45s",
+	}
+	for d, want := range tests {
+		assert.Equal(t, want, formatDuration(d))
+	}
+}
@@ -0,0 +1,35 @@
+package passkey
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ahummel25/user-auth-api/db/mongo"
+)
+
+// SessionCollection is an interface that wraps the database.Collection interface
+type SessionCollection interface {
+	mongo.Collection
+}
+
+// sessionsCollectionCtxKey represents the context key of the WebAuthn sessions Mongo collection
+type sessionsCollectionCtxKey struct{}
+
+// NewContext returns a new context containing the given sessions collection under the given
+// context key
+func NewContext(ctx context.Context, collectionCtxKey any, collection SessionCollection) context.Context {
+	return context.WithValue(ctx, collectionCtxKey, collection)
+}
+
+// SessionsFromContext returns the SessionCollection from the context, or an error if not found
+func SessionsFromContext(ctx context.Context) (SessionCollection, error) {
+	if c, ok := ctx.Value(GetSessionsCollectionKey()).(SessionCollection); ok {
+		return c, nil
+	}
+	return nil, errors.New("WebAuthn sessions collection not found in context")
+}
+
+// GetSessionsCollectionKey is a wrapper function around the sessionsCollectionCtxKey returning a pointer to that value
+func GetSessionsCollectionKey() *sessionsCollectionCtxKey {
+	return &sessionsCollectionCtxKey{}
+}
@@ -0,0 +1,352 @@
+package passkey
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+
+	"github.com/ahummel25/user-auth-api/config"
+)
+
+// Relying party used in local development when none is configured
+const (
+	devRPID   = "localhost"
+	devOrigin = "http://localhost:3000"
+)
+
+var (
+	// ErrInvalidCredential is returned for registration and login responses which fail
+	// verification. The reason is logged rather than returned to the caller.
+	ErrInvalidCredential = errors.New("invalid passkey")
+
+	errNotConfigured = errors.New("no WebAuthn relying party configured")
+)
+
+// Credential is a passkey registered by a user. Credentials are stored on their user.
+type Credential struct {
+	ID              []byte   `bson:"credential_id"`
+	PublicKey       []byte   `bson:"public_key"`
+	AttestationType string   `bson:"attestation_type"`
+	Transports      []string `bson:"transports,omitempty"`
+	AAGUID          []byte   `bson:"aaguid"`
+	// SignCount is the signature counter of the authenticator, which only increases unless the
+	// credential was cloned. Synced passkeys always report 0.
+	SignCount      uint32     `bson:"sign_count"`
+	BackupEligible bool       `bson:"backup_eligible"`
+	BackupState    bool       `bson:"backup_state"`
+	Name           string     `bson:"name,omitempty"`
+	CreationDate   time.Time  `bson:"creation_date"`
+	LastUsedDate   *time.Time `bson:"last_used_date"`
+}
+
+// EncodedID returns the credential ID the way browsers encode it, as unpadded base64url
+func (c Credential) EncodedID() string {
+	return base64.RawURLEncoding.EncodeToString(c.ID)
+}
+
+// Account is a user taking part in a ceremony, along with their registered credentials
+type Account struct {
+	UserID      string
+	Name        string
+	DisplayName string
+	Credentials []Credential
+}
+
+func (a Account) WebAuthnID() []byte {
+	return []byte(a.UserID)
+}
+
+func (a Account) WebAuthnName() string {
+	return a.Name
+}
+
+func (a Account) WebAuthnDisplayName() string {
+	return a.DisplayName
+}
+
+func (a Account) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(a.Credentials))
+	for _, c := range a.Credentials {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(c.Transports))
+		for _, t := range c.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+		credentials = append(credentials, webauthn.Credential{
+			ID:              c.ID,
+			PublicKey:       c.PublicKey,
+			AttestationType: c.AttestationType,
+			Transport:       transports,
+			Flags:           webauthn.CredentialFlags{BackupEligible: c.BackupEligible, BackupState: c.BackupState},
+			Authenticator:   webauthn.Authenticator{AAGUID: c.AAGUID, SignCount: c.SignCount},
+		})
+	}
+	return credentials
+}
+
+// ceremony is the kind of WebAuthn ceremony a session belongs to
+type ceremony string
+
+const (
+	ceremonyRegistration ceremony = "registration"
+	ceremonyLogin        ceremony = "login"
+)
+
+// sessionDB is the state of a ceremony between issuing its options and verifying the response of
+// the authenticator. Sessions are looked up by the challenge the response signs, and can only be
+// used once.
+type sessionDB struct {
+	Challenge string   `bson:"challenge"`
+	Ceremony  ceremony `bson:"ceremony"`
+	// UserID is the user registering a credential, or empty for a login
+	UserID string `bson:"user_id,omitempty"`
+	// Session is the JSON encoded webauthn.SessionData
+	Session   []byte     `bson:"session"`
+	ExpiresAt time.Time  `bson:"expires_at"`
+	UsedDate  *time.Time `bson:"used_date"`
+}
+
+// Helper function to build the relying party from the configuration. Only development may run
+// without one, in which case the client application is assumed to run on localhost:3000.
+func newWebAuthn(ctx context.Context) (*webauthn.WebAuthn, time.Duration, error) {
+	configSupplier, err := config.FromContext(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	cfg, err := configSupplier.GetConfig()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rpID, origins := cfg.WebAuthnRPID, cfg.WebAuthnOrigins
+	if rpID == "" || len(origins) == 0 {
+		if !cfg.IsDev {
+			return nil, 0, errNotConfigured
+		}
+		if rpID == "" {
+			rpID = devRPID
+		}
+		if len(origins) == 0 {
+			origins = []string{devOrigin}
+		}
+	}
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: cfg.WebAuthnRPName,
+		RPOrigins:     origins,
+		// Passkeys are discoverable and verify the user, so that they replace both the username
+		// and password
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			UserVerification:   protocol.VerificationRequired,
+		},
+		AttestationPreference: protocol.PreferNoAttestation,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return w, cfg.WebAuthnChallengeTTL, nil
+}
+
+// BeginRegistration starts registering a new passkey for the account, returning the JSON encoded
+// options to pass to navigator.credentials.create() in the browser
+func BeginRegistration(ctx context.Context, account Account) (string, error) {
+	w, ttl, err := newWebAuthn(ctx)
+	if err != nil {
+		return "", err
+	}
+	// Authenticators refuse to register a second passkey of the account
+	exclusions := webauthn.Credentials(account.WebAuthnCredentials()).CredentialDescriptors()
+	creation, session, err := w.BeginRegistration(account, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		return "", err
+	}
+	if err = saveSession(ctx, ceremonyRegistration, account.UserID, session, ttl); err != nil {
+		return "", err
+	}
+	options, err := json.Marshal(creation)
+	if err != nil {
+		return "", err
+	}
+	return string(options), nil
+}
+
+// FinishRegistration verifies the JSON encoded credential created by the browser for the
+// registration the account began, returning the new credential to store
+func FinishRegistration(ctx context.Context, account Account, response string) (*Credential, error) {
+	w, _, err := newWebAuthn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBytes([]byte(response))
+	if err != nil {
+		slog.Warn("Failed to parse passkey registration", "error", err, "user_id", account.UserID)
+		return nil, ErrInvalidCredential
+	}
+	session, err := takeSession(ctx, ceremonyRegistration, account.UserID, parsed.Response.CollectedClientData.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := w.CreateCredential(account, *session, parsed)
+	if err != nil {
+		slog.Warn("Failed to verify passkey registration", "error", describe(err), "user_id", account.UserID)
+		return nil, ErrInvalidCredential
+	}
+	transports := make([]string, 0, len(created.Transport))
+	for _, t := range created.Transport {
+		transports = append(transports, string(t))
+	}
+	return &Credential{
+		ID:              created.ID,
+		PublicKey:       created.PublicKey,
+		AttestationType: created.AttestationType,
+		Transports:      transports,
+		AAGUID:          created.Authenticator.AAGUID,
+		SignCount:       created.Authenticator.SignCount,
+		BackupEligible:  created.Flags.BackupEligible,
+		BackupState:     created.Flags.BackupState,
+		CreationDate:    time.Now().UTC(),
+	}, nil
+}
+
+// BeginLogin starts a passkey login, returning the JSON encoded options to pass to
+// navigator.credentials.get() in the browser. The user is identified by the passkey they pick.
+func BeginLogin(ctx context.Context) (string, error) {
+	w, ttl, err := newWebAuthn(ctx)
+	if err != nil {
+		return "", err
+	}
+	assertion, session, err := w.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return "", err
+	}
+	if err = saveSession(ctx, ceremonyLogin, "", session, ttl); err != nil {
+		return "", err
+	}
+	options, err := json.Marshal(assertion)
+	if err != nil {
+		return "", err
+	}
+	return string(options), nil
+}
+
+// FinishLogin verifies the JSON encoded assertion created by the browser for a login begun with
+// BeginLogin. The account of the user the passkey belongs to is loaded with lookup. The returned
+// credential carries the updated signature counter, to store in place of the previous one.
+func FinishLogin(
+	ctx context.Context, response string, lookup func(userID string) (Account, error),
+) (string, *Credential, error) {
+	w, _, err := newWebAuthn(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBytes([]byte(response))
+	if err != nil {
+		slog.Warn("Failed to parse passkey login", "error", err)
+		return "", nil, ErrInvalidCredential
+	}
+	session, err := takeSession(ctx, ceremonyLogin, "", parsed.Response.CollectedClientData.Challenge)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var account Account
+	handler := func(_ []byte, userHandle []byte) (webauthn.User, error) {
+		account, err = lookup(string(userHandle))
+		return account, err
+	}
+	validated, err := w.ValidateDiscoverableLogin(handler, *session, parsed)
+	if err != nil {
+		slog.Warn("Failed to verify passkey login", "error", describe(err), "user_id", account.UserID)
+		return "", nil, ErrInvalidCredential
+	}
+	if validated.Authenticator.CloneWarning {
+		// The counter went backwards, so another authenticator may hold a copy of the key
+		slog.Warn("Refusing passkey whose signature counter did not increase",
+			"user_id", account.UserID, "credential_id", base64.RawURLEncoding.EncodeToString(validated.ID))
+		return "", nil, ErrInvalidCredential
+	}
+
+	for _, c := range account.Credentials {
+		if string(c.ID) == string(validated.ID) {
+			c.SignCount = validated.Authenticator.SignCount
+			c.BackupState = validated.Flags.BackupState
+			return account.UserID, &c, nil
+		}
+	}
+	return "", nil, ErrInvalidCredential
+}
+
+// Helper function to store the session of a ceremony until its response comes back
+func saveSession(ctx context.Context, c ceremony, userID string, session *webauthn.SessionData, ttl time.Duration) error {
+	sessionCollection, err := SessionsFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	_, err = sessionCollection.InsertOne(ctx, sessionDB{
+		Challenge: session.Challenge,
+		Ceremony:  c,
+		UserID:    userID,
+		Session:   data,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	})
+	return err
+}
+
+// Helper function to use up the unexpired session of a ceremony which issued the given challenge.
+// A response whose session is unknown, expired or already used is invalid.
+func takeSession(ctx context.Context, c ceremony, userID string, challenge string) (*webauthn.SessionData, error) {
+	sessionCollection, err := SessionsFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	filter := bson.M{
+		"challenge":  challenge,
+		"ceremony":   c,
+		"used_date":  nil,
+		"expires_at": bson.M{"$gt": now},
+	}
+	if userID != "" {
+		filter["user_id"] = userID
+	}
+	var stored sessionDB
+	err = sessionCollection.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"used_date": now}}).Decode(&stored)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrInvalidCredential
+		}
+		return nil, err
+	}
+
+	var session webauthn.SessionData
+	if err = json.Unmarshal(stored.Session, &session); err != nil {
+		return nil, fmt.Errorf("invalid WebAuthn session: %w", err)
+	}
+	return &session, nil
+}
+
+// Helper function to include the details of WebAuthn protocol errors, which their message leaves
+// out, in the logs
+func describe(err error) string {
+	var protocolErr *protocol.Error
+	if errors.As(err, &protocolErr) && protocolErr.Details != "" {
+		return fmt.Sprintf("%s: %s", protocolErr.Error(), protocolErr.Details)
+	}
+	return err.Error()
+}
@@ -0,0 +1,251 @@
+package passkey
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"github.com/ahummel25/user-auth-api/service/passkey/mocks"
+	"github.com/ahummel25/user-auth-api/service/passkey/passkeytest"
+)
+
+// Helper function to create a context whose sessions collection keeps the sessions inserted into
+// it, handing each out once to the ceremony and user it was issued for
+func withSessionStore(t *testing.T) context.Context {
+	t.Helper()
+	mockColl := mocks.NewMockSessionCollection(t)
+	sessions := map[string]*sessionDB{}
+
+	mockColl.On("InsertOne", mock.Anything, mock.AnythingOfType("passkey.sessionDB")).
+		Run(func(args mock.Arguments) {
+			session := args.Get(1).(sessionDB)
+			sessions[session.Challenge] = &session
+		}).
+		Return(&mongo.InsertOneResult{}, nil).Maybe()
+	mockColl.On("FindOneAndUpdate", mock.Anything, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M")).
+		Return(func(_ context.Context, filter any, _ any, _ ...options.Lister[options.FindOneAndUpdateOptions]) *mongo.SingleResult {
+			f := filter.(bson.M)
+			session, ok := sessions[f["challenge"].(string)]
+			if !ok || session.Ceremony != f["ceremony"] || (f["user_id"] != nil && session.UserID != f["user_id"]) {
+				return mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil)
+			}
+			delete(sessions, session.Challenge)
+			return mongo.NewSingleResultFromDocument(session, nil, nil)
+		}).Maybe()
+
+	return NewContext(context.Background(), GetSessionsCollectionKey(), mockColl)
+}
+
+// Helper function to register a passkey of the authenticator for the account
+func register(t *testing.T, ctx context.Context, authenticator *passkeytest.Authenticator, account Account) *Credential {
+	t.Helper()
+	options, err := BeginRegistration(ctx, account)
+	require.NoError(t, err)
+	response, err := authenticator.Register(options)
+	require.NoError(t, err)
+	credential, err := FinishRegistration(ctx, account, response)
+	require.NoError(t, err)
+	return credential
+}
+
+func TestRegistration(t *testing.T) {
+	account := Account{UserID: "test-id", Name: "test@example.com", DisplayName: "Test User"}
+
+	t.Run("valid credential", func(t *testing.T) {
+		ctx := withSessionStore(t)
+		authenticator, err := passkeytest.NewAuthenticator(devOrigin)
+		require.NoError(t, err)
+
+		options, err := BeginRegistration(ctx, account)
+		require.NoError(t, err)
+
+		var creation struct {
+			PublicKey struct {
+				RP                     struct{ ID string }
+				AuthenticatorSelection struct{ ResidentKey, UserVerification string }
+			}
+		}
+		require.NoError(t, json.Unmarshal([]byte(options), &creation))
+		assert.Equal(t, devRPID, creation.PublicKey.RP.ID)
+		assert.Equal(t, "required", creation.PublicKey.AuthenticatorSelection.ResidentKey)
+		assert.Equal(t, "required", creation.PublicKey.AuthenticatorSelection.UserVerification)
+
+		response, err := authenticator.Register(options)
+		require.NoError(t, err)
+		credential, err := FinishRegistration(ctx, account, response)
+
+		require.NoError(t, err)
+		assert.Equal(t, authenticator.CredentialID(), credential.ID)
+		assert.NotEmpty(t, credential.PublicKey)
+		assert.Equal(t, "none", credential.AttestationType)
+		assert.Equal(t, []string{"internal"}, credential.Transports)
+		assert.False(t, credential.CreationDate.IsZero())
+	})
+
+	t.Run("registration of another user", func(t *testing.T) {
+		ctx := withSessionStore(t)
+		authenticator, err := passkeytest.NewAuthenticator(devOrigin)
+		require.NoError(t, err)
+
+		options, err := BeginRegistration(ctx, account)
+		require.NoError(t, err)
+		response, err := authenticator.Register(options)
+		require.NoError(t, err)
+		credential, err := FinishRegistration(ctx, Account{UserID: "other-id"}, response)
+
+		assert.Equal(t, ErrInvalidCredential, err)
+		assert.Nil(t, credential)
+	})
+
+	t.Run("wrong origin", func(t *testing.T) {
+		ctx := withSessionStore(t)
+		authenticator, err := passkeytest.NewAuthenticator("https://phishing.example")
+		require.NoError(t, err)
+
+		options, err := BeginRegistration(ctx, account)
+		require.NoError(t, err)
+		response, err := authenticator.Register(options)
+		require.NoError(t, err)
+		credential, err := FinishRegistration(ctx, account, response)
+
+		assert.Equal(t, ErrInvalidCredential, err)
+		assert.Nil(t, credential)
+	})
+
+	t.Run("malformed response", func(t *testing.T) {
+		ctx := withSessionStore(t)
+
+		credential, err := FinishRegistration(ctx, account, "{}")
+
+		assert.Equal(t, ErrInvalidCredential, err)
+		assert.Nil(t, credential)
+	})
+}
+
+func TestLogin(t *testing.T) {
+	account := Account{UserID: "test-id", Name: "test@example.com", DisplayName: "Test User"}
+
+	// Helper function to begin a login and sign it with the authenticator
+	signLogin := func(t *testing.T, ctx context.Context, authenticator *passkeytest.Authenticator) string {
+		t.Helper()
+		options, err := BeginLogin(ctx)
+		require.NoError(t, err)
+		response, err := authenticator.Login(options)
+		require.NoError(t, err)
+		return response
+	}
+
+	t.Run("valid assertion", func(t *testing.T) {
+		ctx := withSessionStore(t)
+		authenticator, err := passkeytest.NewAuthenticator(devOrigin)
+		require.NoError(t, err)
+		account := account
+		account.Credentials = []Credential{*register(t, ctx, authenticator, account)}
+
+		var lookedUp string
+		userID, credential, err := FinishLogin(ctx, signLogin(t, ctx, authenticator), func(userID string) (Account, error) {
+			lookedUp = userID
+			return account, nil
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, "test-id", lookedUp)
+		assert.Equal(t, "test-id", userID)
+		assert.Equal(t, authenticator.CredentialID(), credential.ID)
+		assert.Equal(t, uint32(1), credential.SignCount)
+	})
+
+	t.Run("synced passkey", func(t *testing.T) {
+		ctx := withSessionStore(t)
+		authenticator, err := passkeytest.NewAuthenticator(devOrigin)
+		require.NoError(t, err)
+		authenticator.Synced = true
+		account := account
+		account.Credentials = []Credential{*register(t, ctx, authenticator, account)}
+		assert.True(t, account.Credentials[0].BackupEligible)
+
+		for range 2 {
+			_, credential, err := FinishLogin(ctx, signLogin(t, ctx, authenticator), func(string) (Account, error) {
+				return account, nil
+			})
+
+			require.NoError(t, err)
+			assert.Zero(t, credential.SignCount)
+		}
+	})
+
+	t.Run("replayed assertion", func(t *testing.T) {
+		ctx := withSessionStore(t)
+		authenticator, err := passkeytest.NewAuthenticator(devOrigin)
+		require.NoError(t, err)
+		account := account
+		account.Credentials = []Credential{*register(t, ctx, authenticator, account)}
+		lookup := func(string) (Account, error) { return account, nil }
+
+		response := signLogin(t, ctx, authenticator)
+		_, _, err = FinishLogin(ctx, response, lookup)
+		require.NoError(t, err)
+		userID, credential, err := FinishLogin(ctx, response, lookup)
+
+		assert.Equal(t, ErrInvalidCredential, err)
+		assert.Empty(t, userID)
+		assert.Nil(t, credential)
+	})
+
+	t.Run("cloned authenticator", func(t *testing.T) {
+		ctx := withSessionStore(t)
+		authenticator, err := passkeytest.NewAuthenticator(devOrigin)
+		require.NoError(t, err)
+		account := account
+		credential := register(t, ctx, authenticator, account)
+		// Another copy of the key already signed more often
+		credential.SignCount = 5
+		account.Credentials = []Credential{*credential}
+
+		userID, credential, err := FinishLogin(ctx, signLogin(t, ctx, authenticator), func(string) (Account, error) {
+			return account, nil
+		})
+
+		assert.Equal(t, ErrInvalidCredential, err)
+		assert.Empty(t, userID)
+		assert.Nil(t, credential)
+	})
+
+	t.Run("unregistered passkey", func(t *testing.T) {
+		ctx := withSessionStore(t)
+		authenticator, err := passkeytest.NewAuthenticator(devOrigin)
+		require.NoError(t, err)
+		// The passkey was registered, then removed from the account
+		register(t, ctx, authenticator, account)
+
+		userID, credential, err := FinishLogin(ctx, signLogin(t, ctx, authenticator), func(string) (Account, error) {
+			return account, nil
+		})
+
+		assert.Equal(t, ErrInvalidCredential, err)
+		assert.Empty(t, userID)
+		assert.Nil(t, credential)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		ctx := withSessionStore(t)
+		authenticator, err := passkeytest.NewAuthenticator(devOrigin)
+		require.NoError(t, err)
+		register(t, ctx, authenticator, account)
+
+		userID, credential, err := FinishLogin(ctx, signLogin(t, ctx, authenticator), func(string) (Account, error) {
+			return Account{}, mongo.ErrNoDocuments
+		})
+
+		assert.Equal(t, ErrInvalidCredential, err)
+		assert.Empty(t, userID)
+		assert.Nil(t, credential)
+	})
+}
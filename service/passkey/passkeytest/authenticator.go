@@ -0,0 +1,189 @@
+// Package passkeytest provides a software authenticator for testing passkey ceremonies without a
+// browser or security key.
+package passkeytest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+// Authenticator flags (WebAuthn §6.1)
+const (
+	flagUserPresent    byte = 0x01
+	flagUserVerified   byte = 0x04
+	flagBackupEligible byte = 0x08
+	flagBackupState    byte = 0x10
+	flagAttestedData   byte = 0x40
+)
+
+var encoding = base64.RawURLEncoding
+
+// Authenticator is a software authenticator holding a single ES256 passkey, answering ceremonies
+// as a browser would on behalf of the given origin
+type Authenticator struct {
+	Origin string
+	// SignCount is the signature counter, incremented on every login unless Synced is set
+	SignCount uint32
+	// Synced makes the passkey look like one synced between devices, which is backup eligible and
+	// reports no signature counter
+	Synced bool
+
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+}
+
+// NewAuthenticator returns an authenticator with a new key pair
+func NewAuthenticator(origin string) (*Authenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	credentialID := make([]byte, 16)
+	if _, err = rand.Read(credentialID); err != nil {
+		return nil, err
+	}
+	return &Authenticator{Origin: origin, key: key, credentialID: credentialID}, nil
+}
+
+// CredentialID returns the ID of the passkey
+func (a *Authenticator) CredentialID() []byte {
+	return a.credentialID
+}
+
+// Register creates the passkey for the JSON encoded creation options, returning the JSON encoded
+// credential the browser would send to the server
+func (a *Authenticator) Register(options string) (string, error) {
+	var creation protocol.CredentialCreation
+	if err := json.Unmarshal([]byte(options), &creation); err != nil {
+		return "", err
+	}
+	userID, ok := creation.Response.User.ID.(string)
+	if !ok {
+		return "", errors.New("options have no user ID")
+	}
+	userHandle, err := encoding.DecodeString(userID)
+	if err != nil {
+		return "", err
+	}
+	a.userHandle = userHandle
+
+	clientData, err := a.clientData(protocol.CreateCeremony, creation.Response.Challenge)
+	if err != nil {
+		return "", err
+	}
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: a.key.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	authData := a.authData(creation.Response.RelyingParty.ID, flagAttestedData)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, publicKey...)
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return a.credential(map[string]any{
+		"clientDataJSON":    encoding.EncodeToString(clientData),
+		"attestationObject": encoding.EncodeToString(attestation),
+		"transports":        []string{"internal"},
+	})
+}
+
+// Login signs the challenge of the JSON encoded request options with the passkey, returning the
+// JSON encoded assertion the browser would send to the server
+func (a *Authenticator) Login(options string) (string, error) {
+	var assertion protocol.CredentialAssertion
+	if err := json.Unmarshal([]byte(options), &assertion); err != nil {
+		return "", err
+	}
+	if a.userHandle == nil {
+		return "", errors.New("passkey was not registered")
+	}
+
+	clientData, err := a.clientData(protocol.AssertCeremony, assertion.Response.Challenge)
+	if err != nil {
+		return "", err
+	}
+	if !a.Synced {
+		a.SignCount++
+	}
+	authData := a.authData(assertion.Response.RelyingPartyID, 0)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return a.credential(map[string]any{
+		"clientDataJSON":    encoding.EncodeToString(clientData),
+		"authenticatorData": encoding.EncodeToString(authData),
+		"signature":         encoding.EncodeToString(signature),
+		"userHandle":        encoding.EncodeToString(a.userHandle),
+	})
+}
+
+// Helper function to build the client data the browser collects for a ceremony
+func (a *Authenticator) clientData(ceremony protocol.CeremonyType, challenge protocol.URLEncodedBase64) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":        ceremony,
+		"challenge":   challenge.String(),
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+}
+
+// Helper function to build the authenticator data of a ceremony, up to the signature counter
+func (a *Authenticator) authData(rpID string, flags byte) []byte {
+	flags |= flagUserPresent | flagUserVerified
+	if a.Synced {
+		flags |= flagBackupEligible | flagBackupState
+	}
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	authData := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(authData, a.SignCount)
+}
+
+// Helper function to wrap an authenticator response as the JSON encoded PublicKeyCredential
+func (a *Authenticator) credential(response map[string]any) (string, error) {
+	id := encoding.EncodeToString(a.credentialID)
+	credential, err := json.Marshal(map[string]any{
+		"id":                      id,
+		"rawId":                   id,
+		"type":                    "public-key",
+		"authenticatorAttachment": "platform",
+		"response":                response,
+		"clientExtensionResults":  map[string]any{},
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(credential), nil
+}
@@ -0,0 +1,152 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	// MD4 is broken, but NTLM hashes are MD4 digests
+	"golang.org/x/crypto/md4"
+)
+
+// Actions taken on new passwords found in the breached password corpus
+const (
+	BreachActionBlock = "block"
+	BreachActionWarn  = "warn"
+)
+
+// Lengths of the hex encoded hashes of the corpus formats
+const (
+	sha1HexLength = 2 * sha1.Size
+	ntlmHexLength = 2 * md4.Size
+)
+
+// BreachCorpus looks passwords up in a local copy of the Have I Been Pwned breached password
+// corpus, in its downloadable format of one upper case hex SHA-1 or NTLM hash per line, followed by
+// a colon and the number of breaches it was seen in, sorted by hash. The corpus is far too large to
+// load, so the file is binary searched on every lookup.
+type BreachCorpus struct {
+	path string
+}
+
+// NewBreachCorpus returns a corpus reading the given file
+func NewBreachCorpus(path string) *BreachCorpus {
+	return &BreachCorpus{path: path}
+}
+
+// Count returns how often the password was seen in breaches, or 0 if it never was
+func (c *BreachCorpus) Count(plaintext string) (int, error) {
+	file, err := os.Open(c.path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open breached passwords: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	// The hash algorithm is told by the length of the hashes
+	_, first, err := lineAt(file, 0, info.Size())
+	if err != nil {
+		return 0, fmt.Errorf("failed to read breached passwords: %w", err)
+	}
+	first = strings.TrimSpace(first)
+	var hash string
+	switch hashLength(first) {
+	case sha1HexLength:
+		sum := sha1.Sum([]byte(plaintext))
+		hash = strings.ToUpper(hex.EncodeToString(sum[:]))
+	case ntlmHexLength:
+		hash = ntlmHash(plaintext)
+	default:
+		return 0, fmt.Errorf("unsupported breached passwords format, line %q is neither a SHA-1 nor an NTLM hash", first)
+	}
+	return search(file, info.Size(), hash)
+}
+
+// Helper function to compute the NTLM hash of a password, the MD4 digest of its UTF-16 encoding
+func ntlmHash(plaintext string) string {
+	encoded := utf16.Encode([]rune(plaintext))
+	h := md4.New()
+	for _, unit := range encoded {
+		_ = binary.Write(h, binary.LittleEndian, unit)
+	}
+	return strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
+}
+
+// Helper function to get the length of the hash a corpus line starts with
+func hashLength(line string) int {
+	if i := strings.IndexByte(line, ':'); i >= 0 {
+		return i
+	}
+	return len(line)
+}
+
+// Helper function to binary search the sorted corpus for the hash, returning its count
+func search(file io.ReaderAt, size int64, hash string) (int, error) {
+	// Lines starting in [lo, hi) are left to search
+	lo, hi := int64(0), size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, line, err := lineAt(file, mid, size)
+		if err != nil {
+			return 0, err
+		}
+		if start >= hi || line == "" {
+			hi = mid
+			continue
+		}
+		lineHash, count, _ := strings.Cut(strings.TrimSpace(line), ":")
+		switch strings.Compare(hash, strings.ToUpper(lineHash)) {
+		case 0:
+			if count == "" {
+				return 1, nil
+			}
+			n, err := strconv.Atoi(count)
+			if err != nil {
+				return 0, fmt.Errorf("malformed breached passwords line %q: %w", strings.TrimSpace(line), err)
+			}
+			return max(n, 1), nil
+		case -1:
+			hi = mid
+		default:
+			lo = start + int64(len(line))
+		}
+	}
+	return 0, nil
+}
+
+// Helper function to read the first line starting at or after the offset, returning its offset and
+// the line with its line ending, or the size of the file and an empty line if there is none
+func lineAt(file io.ReaderAt, offset int64, size int64) (int64, string, error) {
+	start := offset
+	reader := bufio.NewReader(io.NewSectionReader(file, offset, size-offset))
+	if offset > 0 {
+		// Finish the line the offset falls in, unless it starts right at the offset
+		reader = bufio.NewReader(io.NewSectionReader(file, offset-1, size-offset+1))
+		skipped, err := reader.ReadString('\n')
+		if errors.Is(err, io.EOF) {
+			return size, "", nil
+		} else if err != nil {
+			return 0, "", err
+		}
+		start = offset - 1 + int64(len(skipped))
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, "", err
+	}
+	if line == "" {
+		return size, "", nil
+	}
+	return start, line, nil
+}
@@ -0,0 +1,134 @@
+package password
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Helper function to write a corpus file of the given hashes and counts, sorted by hash, with
+// made-up hashes around them
+func writeCorpus(t *testing.T, hashLength int, lineEnding string, counts map[string]int) string {
+	t.Helper()
+	lines := make([]string, 0, len(counts)+500)
+	for hash, count := range counts {
+		lines = append(lines, fmt.Sprintf("%s:%d", hash, count))
+	}
+	for i := range 500 {
+		sum := sha1.Sum([]byte(fmt.Sprint("filler", i)))
+		lines = append(lines, fmt.Sprintf("%s:%d", strings.ToUpper(hex.EncodeToString(sum[:]))[:hashLength], i+1))
+	}
+	slices.Sort(lines)
+
+	var corpus bytes.Buffer
+	for _, line := range lines {
+		corpus.WriteString(line + lineEnding)
+	}
+	path := filepath.Join(t.TempDir(), "pwned-passwords.txt")
+	require.NoError(t, os.WriteFile(path, corpus.Bytes(), 0o600))
+	return path
+}
+
+// Helper function to compute the SHA-1 hash of a password as the corpus lists it
+func sha1Hash(plaintext string) string {
+	sum := sha1.Sum([]byte(plaintext))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func TestNTLMHash(t *testing.T) {
+	assert.Equal(t, "8846F7EAEE8FB117AD06BDD830B7586C", ntlmHash("password"))
+	assert.Equal(t, "31D6CFE0D16AE931B73C59D7E0C089C0", ntlmHash(""))
+}
+
+func TestBreachCorpus(t *testing.T) {
+	// Hashes sorting first and last are found as well as any other
+	first := strings.Repeat("0", 40)
+	last := strings.Repeat("F", 40)
+
+	tests := []struct {
+		name       string
+		lineEnding string
+	}{
+		{name: "LF line endings", lineEnding: "\n"},
+		{name: "CRLF line endings", lineEnding: "\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeCorpus(t, sha1HexLength, tt.lineEnding, map[string]int{
+				sha1Hash("password"): 9545824, sha1Hash("hunter2"): 17043, first: 3, last: 4,
+			})
+			corpus := NewBreachCorpus(path)
+
+			for plaintext, expected := range map[string]int{"password": 9545824, "hunter2": 17043, "Glacier-Orbit-42": 0} {
+				count, err := corpus.Count(plaintext)
+				require.NoError(t, err)
+				assert.Equal(t, expected, count, plaintext)
+			}
+			for hash, expected := range map[string]int{first: 3, last: 4} {
+				file, err := os.Open(path)
+				require.NoError(t, err)
+				info, err := file.Stat()
+				require.NoError(t, err)
+				count, err := search(file, info.Size(), hash)
+				require.NoError(t, err)
+				assert.Equal(t, expected, count)
+				require.NoError(t, file.Close())
+			}
+		})
+	}
+
+	t.Run("NTLM hashes", func(t *testing.T) {
+		path := writeCorpus(t, ntlmHexLength, "\n", map[string]int{ntlmHash("password"): 12})
+
+		count, err := NewBreachCorpus(path).Count("password")
+
+		require.NoError(t, err)
+		assert.Equal(t, 12, count)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := NewBreachCorpus(filepath.Join(t.TempDir(), "missing.txt")).Count("password")
+
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "passwords.txt")
+		require.NoError(t, os.WriteFile(path, []byte("password\n"), 0o600))
+
+		_, err := NewBreachCorpus(path).Count("password")
+
+		assert.ErrorContains(t, err, "unsupported breached passwords format")
+	})
+}
+
+func TestCheckBreached(t *testing.T) {
+	path := writeCorpus(t, sha1HexLength, "\n", map[string]int{sha1Hash("Glacier-Orbit-42"): 2})
+	rules := Rules{BreachedPasswords: NewBreachCorpus(path), BreachAction: BreachActionBlock}
+
+	violation, err := rules.CheckBreached("Glacier-Orbit-42", Account{})
+	require.NoError(t, err)
+	assert.Equal(t, &Violation{Rule: RuleBreached, Message: "password has appeared in a data breach and must not be used"}, violation)
+
+	violation, err = rules.CheckBreached("Quartz-Meadow-17", Account{})
+	require.NoError(t, err)
+	assert.Nil(t, violation)
+
+	rules.BreachAction = BreachActionWarn
+	violation, err = rules.CheckBreached("Glacier-Orbit-42", Account{UserName: "jdoe"})
+	require.NoError(t, err)
+	assert.Nil(t, violation)
+
+	violation, err = Rules{}.CheckBreached("Glacier-Orbit-42", Account{})
+	require.NoError(t, err)
+	assert.Nil(t, violation)
+}
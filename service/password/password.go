@@ -0,0 +1,233 @@
+// Package password hashes user passwords and verifies them against stored hashes, following a
+// configurable policy of which algorithm and parameters to hash new passwords with, and checks new
+// passwords against the rules of the password policy.
+package password
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/ahummel25/user-auth-api/config"
+)
+
+// Algorithms passwords can be hashed with, as named in the configuration and in PHC strings
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// ErrUnsupportedHash is returned when verifying a password against a hash of an unknown format
+var ErrUnsupportedHash = errors.New("unsupported password hash")
+
+// PHC strings encode their salt and hash in unpadded standard base64
+var phcEncoding = base64.RawStdEncoding
+
+// Hasher hashes passwords and verifies them against stored hashes
+type Hasher interface {
+	// Hash returns a new hash of the password, salted at random
+	Hash(password string) (string, error)
+	// Verify reports whether the password matches the hash
+	Verify(hash string, password string) (bool, error)
+	// NeedsRehash reports whether the hash is of another algorithm, or of weaker parameters, than
+	// the hashes the hasher makes
+	NeedsRehash(hash string) bool
+}
+
+// Argon2id hashes passwords with argon2id, in the PHC string format
+// $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<hash>
+type Argon2id struct {
+	Memory      uint32 // Memory used, in KiB
+	Iterations  uint32 // Number of passes over the memory
+	Parallelism uint8  // Number of threads
+}
+
+func (a Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, argon2KeyLength)
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", AlgorithmArgon2id, argon2.Version,
+		a.Memory, a.Iterations, a.Parallelism, phcEncoding.EncodeToString(salt), phcEncoding.EncodeToString(key)), nil
+}
+
+func (a Argon2id) Verify(hash string, password string) (bool, error) {
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+	computed := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(computed, key) == 1, nil
+}
+
+func (a Argon2id) NeedsRehash(hash string) bool {
+	params, _, key, err := parseArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return params.Memory < a.Memory || params.Iterations < a.Iterations || params.Parallelism < a.Parallelism ||
+		len(key) < argon2KeyLength
+}
+
+// Helper function to split an argon2id PHC string into its parameters, salt and hash
+func parseArgon2id(hash string) (Argon2id, []byte, []byte, error) {
+	var params Argon2id
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != AlgorithmArgon2id {
+		return params, nil, nil, ErrUnsupportedHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("%w: argon2id version %s", ErrUnsupportedHash, parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("malformed argon2id parameters: %w", err)
+	}
+	salt, err := phcEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("malformed argon2id salt: %w", err)
+	}
+	key, err := phcEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, fmt.Errorf("malformed argon2id hash: %w", err)
+	}
+	return params, salt, key, nil
+}
+
+// Bcrypt hashes passwords with bcrypt. Its hashes keep the $2b$<cost>$<salt and hash> format every
+// bcrypt implementation reads, which predates PHC strings.
+type Bcrypt struct {
+	Cost int
+}
+
+func (b Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (b Bcrypt) Verify(hash string, password string) (bool, error) {
+	if !isBcrypt(hash) {
+		return false, ErrUnsupportedHash
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (b Bcrypt) NeedsRehash(hash string) bool {
+	if !isBcrypt(hash) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost < b.Cost
+}
+
+// Helper function to tell bcrypt hashes apart by their prefix
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// policy hashes new passwords with the hasher of the configured algorithm, and verifies passwords
+// against hashes of any supported algorithm
+type policy struct {
+	current Hasher
+}
+
+func (p policy) Hash(password string) (string, error) {
+	return p.current.Hash(password)
+}
+
+func (p policy) Verify(hash string, password string) (bool, error) {
+	if strings.HasPrefix(hash, "$"+AlgorithmArgon2id+"$") {
+		return Argon2id{}.Verify(hash, password)
+	}
+	if isBcrypt(hash) {
+		return Bcrypt{}.Verify(hash, password)
+	}
+	return false, ErrUnsupportedHash
+}
+
+func (p policy) NeedsRehash(hash string) bool {
+	return p.current.NeedsRehash(hash)
+}
+
+// NewPolicy returns a hasher making hashes of the given algorithm, with the parameters given for
+// it, which verifies passwords against hashes of either algorithm
+func NewPolicy(algorithm string, argon2id Argon2id, bcryptParams Bcrypt) (Hasher, error) {
+	switch algorithm {
+	case AlgorithmArgon2id:
+		if argon2id.Memory < 8*uint32(argon2id.Parallelism) || argon2id.Iterations < 1 || argon2id.Parallelism < 1 {
+			return nil, fmt.Errorf("invalid argon2id parameters m=%d,t=%d,p=%d",
+				argon2id.Memory, argon2id.Iterations, argon2id.Parallelism)
+		}
+		return policy{current: argon2id}, nil
+	case AlgorithmBcrypt:
+		if bcryptParams.Cost < bcrypt.MinCost || bcryptParams.Cost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("invalid bcrypt cost %d, must be between %d and %d",
+				bcryptParams.Cost, bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		return policy{current: bcryptParams}, nil
+	}
+	return nil, fmt.Errorf("unsupported password hash algorithm %q", algorithm)
+}
+
+// New returns the hasher of the configured password hashing policy
+func New(ctx context.Context) (Hasher, error) {
+	configSupplier, err := config.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := configSupplier.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Argon2Memory > math.MaxUint32 || cfg.Argon2Iterations > math.MaxUint32 || cfg.Argon2Parallelism > math.MaxUint8 {
+		return nil, fmt.Errorf("invalid argon2id parameters m=%d,t=%d,p=%d",
+			cfg.Argon2Memory, cfg.Argon2Iterations, cfg.Argon2Parallelism)
+	}
+	return NewPolicy(cfg.PasswordHashAlgorithm, Argon2id{
+		Memory:      uint32(cfg.Argon2Memory),
+		Iterations:  uint32(cfg.Argon2Iterations),
+		Parallelism: uint8(cfg.Argon2Parallelism),
+	}, Bcrypt{Cost: cfg.BcryptCost})
+}
+
+// dummyHashes caches the dummy hash of each hasher
+var dummyHashes sync.Map
+
+// DummyHash returns a hash made by the hasher which no password is expected to match. Verifying the
+// passwords of logins to unknown users against it takes as long as verifying those of existing
+// users, so that the response time does not tell whether a user exists.
+func DummyHash(h Hasher) (string, error) {
+	if hash, ok := dummyHashes.Load(h); ok {
+		return hash.(string), nil
+	}
+	hash, err := h.Hash("not the password of any user")
+	if err != nil {
+		return "", err
+	}
+	dummyHashes.Store(h, hash)
+	return hash, nil
+}
@@ -0,0 +1,161 @@
+package password
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Cheap parameters keeping the tests fast
+var (
+	testArgon2id = Argon2id{Memory: 64, Iterations: 1, Parallelism: 1}
+	testBcrypt   = Bcrypt{Cost: bcrypt.MinCost}
+)
+
+func TestArgon2id(t *testing.T) {
+	hash, err := testArgon2id.Hash("correct horse")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$"))
+	assert.Len(t, strings.Split(hash, "$"), 6)
+
+	other, err := testArgon2id.Hash("correct horse")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other, "hashes must be salted")
+
+	ok, err := testArgon2id.Verify(hash, "correct horse")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = testArgon2id.Verify(hash, "battery staple")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = testArgon2id.Verify("$argon2id$v=19$m=64,t=1,p=1$bad salt$", "correct horse")
+	assert.Error(t, err)
+	_, err = testArgon2id.Verify("$argon2i$v=19$m=64,t=1,p=1$c2FsdA$aGFzaA", "correct horse")
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+}
+
+func TestBcrypt(t *testing.T) {
+	hash, err := testBcrypt.Hash("correct horse")
+	require.NoError(t, err)
+
+	ok, err := testBcrypt.Verify(hash, "correct horse")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = testBcrypt.Verify(hash, "battery staple")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = testBcrypt.Verify("plaintext", "plaintext")
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argon2idHash, err := testArgon2id.Hash("password")
+	require.NoError(t, err)
+	bcryptHash, err := testBcrypt.Hash("password")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		hasher   Hasher
+		hash     string
+		expected bool
+	}{
+		{name: "same argon2id parameters", hasher: testArgon2id, hash: argon2idHash},
+		{name: "weaker argon2id parameters", hasher: testArgon2id, hash: "$argon2id$v=19$m=32,t=1,p=1$c2FsdHNhbHQ$aGFzaA", expected: true},
+		{name: "more memory", hasher: Argon2id{Memory: 128, Iterations: 1, Parallelism: 1}, hash: argon2idHash, expected: true},
+		{name: "more iterations", hasher: Argon2id{Memory: 64, Iterations: 2, Parallelism: 1}, hash: argon2idHash, expected: true},
+		{name: "bcrypt hash under argon2id", hasher: testArgon2id, hash: bcryptHash, expected: true},
+		{name: "same bcrypt cost", hasher: testBcrypt, hash: bcryptHash},
+		{name: "higher bcrypt cost", hasher: Bcrypt{Cost: bcrypt.MinCost + 1}, hash: bcryptHash, expected: true},
+		{name: "argon2id hash under bcrypt", hasher: testBcrypt, hash: argon2idHash, expected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.hasher.NeedsRehash(tt.hash))
+		})
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	argon2idHash, err := testArgon2id.Hash("password")
+	require.NoError(t, err)
+	bcryptHash, err := testBcrypt.Hash("password")
+	require.NoError(t, err)
+
+	for _, algorithm := range []string{AlgorithmArgon2id, AlgorithmBcrypt} {
+		t.Run(algorithm, func(t *testing.T) {
+			hasher, err := NewPolicy(algorithm, testArgon2id, testBcrypt)
+			require.NoError(t, err)
+
+			hash, err := hasher.Hash("password")
+			require.NoError(t, err)
+			assert.False(t, hasher.NeedsRehash(hash))
+			if algorithm == AlgorithmArgon2id {
+				assert.True(t, strings.HasPrefix(hash, "$argon2id$"))
+			} else {
+				assert.True(t, strings.HasPrefix(hash, "$2a$"))
+			}
+
+			// Hashes of either algorithm are verified, whichever new passwords are hashed with
+			for _, hash := range []string{argon2idHash, bcryptHash} {
+				ok, err := hasher.Verify(hash, "password")
+				require.NoError(t, err)
+				assert.True(t, ok)
+				ok, err = hasher.Verify(hash, "wrong")
+				require.NoError(t, err)
+				assert.False(t, ok)
+			}
+
+			_, err = hasher.Verify("$scrypt$ln=15,r=8,p=1$c2FsdA$aGFzaA", "password")
+			assert.ErrorIs(t, err, ErrUnsupportedHash)
+		})
+	}
+
+	t.Run("invalid policies", func(t *testing.T) {
+		_, err := NewPolicy("scrypt", testArgon2id, testBcrypt)
+		assert.Error(t, err)
+		_, err = NewPolicy(AlgorithmArgon2id, Argon2id{Memory: 64, Parallelism: 1}, testBcrypt)
+		assert.Error(t, err)
+		_, err = NewPolicy(AlgorithmBcrypt, testArgon2id, Bcrypt{Cost: bcrypt.MaxCost + 1})
+		assert.Error(t, err)
+	})
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range []string{"PASSWORD_HASH_ALGORITHM", "ARGON2_MEMORY", "ARGON2_ITERATIONS", "ARGON2_PARALLELISM", "BCRYPT_COST"} {
+		if value, ok := os.LookupEnv(name); ok {
+			t.Setenv(name, value)
+			_ = os.Unsetenv(name)
+		}
+	}
+
+	hasher, err := New(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, policy{current: Argon2id{Memory: 19 * 1024, Iterations: 2, Parallelism: 1}}, hasher)
+}
+
+func TestDummyHash(t *testing.T) {
+	hasher, err := NewPolicy(AlgorithmArgon2id, testArgon2id, testBcrypt)
+	require.NoError(t, err)
+
+	hash, err := DummyHash(hasher)
+	require.NoError(t, err)
+	assert.False(t, hasher.NeedsRehash(hash))
+
+	cached, err := DummyHash(hasher)
+	require.NoError(t, err)
+	assert.Equal(t, hash, cached)
+
+	ok, err := hasher.Verify(hash, "")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,293 @@
+package password
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/ahummel25/user-auth-api/config"
+	"github.com/ahummel25/user-auth-api/graphql/errcode"
+)
+
+// Character classes new passwords can be required to contain
+const (
+	ClassLower  = "lower"
+	ClassUpper  = "upper"
+	ClassDigit  = "digit"
+	ClassSymbol = "symbol"
+)
+
+// Rules of the password policy a violation can be of
+const (
+	RuleMinLength      = "MIN_LENGTH"
+	RuleMaxLength      = "MAX_LENGTH"
+	RuleCharacterClass = "CHARACTER_CLASS"
+	RulePersonalInfo   = "PERSONAL_INFO"
+	RuleBannedWord     = "BANNED_WORD"
+	RuleStrength       = "STRENGTH"
+	RuleBreached       = "BREACHED"
+)
+
+// classDescriptions describes each character class in violation messages
+var classDescriptions = map[string]string{
+	ClassLower:  "a lowercase letter",
+	ClassUpper:  "an uppercase letter",
+	ClassDigit:  "a digit",
+	ClassSymbol: "a symbol",
+}
+
+// bannedWordsFiles caches the banned words read from each file
+var bannedWordsFiles sync.Map
+
+// Account is what is known about the user choosing a password, which the password must not contain
+type Account struct {
+	UserName  string
+	Email     string
+	FirstName string
+	LastName  string
+}
+
+// Helper function to list the parts of the account long enough to be looked for in passwords
+func (a Account) personalInfo() []string {
+	values := []string{a.UserName, a.Email, a.FirstName, a.LastName}
+	if local, _, ok := strings.Cut(a.Email, "@"); ok {
+		values = append(values, local)
+	}
+	var info []string
+	for _, value := range values {
+		if value = strings.ToLower(strings.TrimSpace(value)); len([]rune(value)) >= minMatchLength {
+			info = append(info, value)
+		}
+	}
+	return info
+}
+
+// Violation is a rule of the password policy a password breaks
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Rules are the password policy new passwords must follow
+type Rules struct {
+	MinLength       int
+	MaxLength       int
+	RequiredClasses []string
+	// BannedWords are lower case words passwords must not contain, even with characters commonly
+	// substituted for letters, e.g. p@ssw0rd for password
+	BannedWords []string
+	MinScore    int
+	// BreachedPasswords are the passwords known from breaches, or nil to not look passwords up
+	BreachedPasswords *BreachCorpus
+	// BreachAction is BreachActionBlock to refuse breached passwords or BreachActionWarn to only log
+	// them
+	BreachAction string
+}
+
+// Check returns every rule the password breaks, or nothing if it follows the policy. Breached
+// passwords are looked up separately, by CheckBreached.
+func (r Rules) Check(plaintext string, account Account) []Violation {
+	var violations []Violation
+	length := len([]rune(plaintext))
+	if length < r.MinLength {
+		violations = append(violations, Violation{
+			Rule: RuleMinLength, Message: fmt.Sprintf("password must be at least %d characters", r.MinLength),
+		})
+	}
+	if length > r.MaxLength {
+		violations = append(violations, Violation{
+			Rule: RuleMaxLength, Message: fmt.Sprintf("password must be at most %d characters", r.MaxLength),
+		})
+	}
+	classes := characterClasses(plaintext)
+	for _, class := range r.RequiredClasses {
+		if !slices.Contains(classes, class) {
+			violations = append(violations, Violation{
+				Rule: RuleCharacterClass, Message: "password must contain " + classDescriptions[class],
+			})
+		}
+	}
+
+	lower, unleeted := normalize(plaintext)
+	personalInfo := account.personalInfo()
+	if containsAny(lower, unleeted, personalInfo) {
+		violations = append(violations, Violation{
+			Rule: RulePersonalInfo, Message: "password must not contain the user name, email or name of the user",
+		})
+	}
+	if containsAny(lower, unleeted, r.BannedWords) {
+		violations = append(violations, Violation{
+			Rule: RuleBannedWord, Message: "password must not contain a commonly used word",
+		})
+	}
+	// Scoring takes a while for long passwords, which are refused anyway when too long
+	if length <= r.MaxLength && Score(plaintext, append(personalInfo, r.BannedWords...)...) < r.MinScore {
+		violations = append(violations, Violation{
+			Rule: RuleStrength, Message: "password is too easy to guess, use a longer password or more words",
+		})
+	}
+	return violations
+}
+
+// CheckBreached returns a violation if the password is known from breaches and the policy blocks
+// breached passwords. When it only warns about them, the account is logged instead.
+func (r Rules) CheckBreached(plaintext string, account Account) (*Violation, error) {
+	if r.BreachedPasswords == nil {
+		return nil, nil
+	}
+	breaches, err := r.BreachedPasswords.Count(plaintext)
+	if err != nil || breaches == 0 {
+		return nil, err
+	}
+	if r.BreachAction == BreachActionWarn {
+		slog.Warn("New password is known from breaches", "user_name", account.UserName, "breaches", breaches)
+		return nil, nil
+	}
+	return &Violation{
+		Rule: RuleBreached, Message: "password has appeared in a data breach and must not be used",
+	}, nil
+}
+
+// Helper function to list the character classes the password contains
+func characterClasses(plaintext string) []string {
+	var classes []string
+	add := func(class string) {
+		if !slices.Contains(classes, class) {
+			classes = append(classes, class)
+		}
+	}
+	for _, r := range plaintext {
+		switch {
+		case unicode.IsLower(r):
+			add(ClassLower)
+		case unicode.IsUpper(r):
+			add(ClassUpper)
+		case unicode.IsDigit(r):
+			add(ClassDigit)
+		case !unicode.IsLetter(r):
+			add(ClassSymbol)
+		}
+	}
+	return classes
+}
+
+// Helper function to lower case the password, as typed and with the characters commonly substituted
+// for letters replaced
+func normalize(plaintext string) (string, string) {
+	lower := strings.ToLower(plaintext)
+	unleeted := strings.Map(func(r rune) rune {
+		if letter, ok := leetSubstitutions[r]; ok {
+			return letter
+		}
+		return r
+	}, lower)
+	return lower, unleeted
+}
+
+// Helper function to check whether either form of the password contains any of the words
+func containsAny(lower string, unleeted string, words []string) bool {
+	for _, word := range words {
+		if strings.Contains(lower, word) || strings.Contains(unleeted, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadRules returns the configured password policy
+func LoadRules(ctx context.Context) (Rules, error) {
+	configSupplier, err := config.FromContext(ctx)
+	if err != nil {
+		return Rules{}, err
+	}
+	cfg, err := configSupplier.GetConfig()
+	if err != nil {
+		return Rules{}, err
+	}
+	for _, class := range cfg.PasswordRequiredClasses {
+		if _, ok := classDescriptions[class]; !ok {
+			return Rules{}, fmt.Errorf("unknown password character class %q", class)
+		}
+	}
+	if cfg.BreachedPasswordsAction != BreachActionBlock && cfg.BreachedPasswordsAction != BreachActionWarn {
+		return Rules{}, fmt.Errorf("unknown breached passwords action %q", cfg.BreachedPasswordsAction)
+	}
+	rules := Rules{
+		MinLength:       cfg.PasswordMinLength,
+		MaxLength:       cfg.PasswordMaxLength,
+		RequiredClasses: cfg.PasswordRequiredClasses,
+		MinScore:        cfg.PasswordMinScore,
+		BreachAction:    cfg.BreachedPasswordsAction,
+	}
+	if cfg.BreachedPasswordsFile != "" {
+		rules.BreachedPasswords = NewBreachCorpus(cfg.BreachedPasswordsFile)
+	}
+	if cfg.PasswordBannedWordsFile != "" {
+		if rules.BannedWords, err = readBannedWords(cfg.PasswordBannedWordsFile); err != nil {
+			return Rules{}, err
+		}
+	}
+	return rules, nil
+}
+
+// Helper function to read the banned words from a file of one word per line, skipping blank lines
+// and comments starting with #
+func readBannedWords(path string) ([]string, error) {
+	if words, ok := bannedWordsFiles.Load(path); ok {
+		return words.([]string), nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read banned words: %w", err)
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if word != "" && !strings.HasPrefix(word, "#") {
+			words = append(words, word)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read banned words: %w", err)
+	}
+	bannedWordsFiles.Store(path, words)
+	return words, nil
+}
+
+// Validate returns an error listing every rule of the configured password policy the password
+// breaks, under the "violations" key of its GraphQL error extensions, or nil if it follows them all
+func Validate(ctx context.Context, plaintext string, account Account) error {
+	rules, err := LoadRules(ctx)
+	if err != nil {
+		return err
+	}
+	violations := rules.Check(plaintext, account)
+	breached, err := rules.CheckBreached(plaintext, account)
+	if err != nil {
+		return err
+	}
+	if breached != nil {
+		violations = append(violations, *breached)
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	messages := make([]string, len(violations))
+	for i, violation := range violations {
+		messages[i] = violation.Message
+	}
+	return &errcode.Error{
+		Code:       errcode.PasswordPolicyViolation,
+		Message:    strings.Join(messages, "; "),
+		Extensions: map[string]interface{}{"violations": violations},
+	}
+}
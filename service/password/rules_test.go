@@ -0,0 +1,113 @@
+package password
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ahummel25/user-auth-api/graphql/errcode"
+)
+
+func TestCheck(t *testing.T) {
+	rules := Rules{
+		MinLength:       10,
+		MaxLength:       20,
+		RequiredClasses: []string{ClassUpper, ClassDigit, ClassSymbol},
+		BannedWords:     []string{"acme", "winter"},
+		MinScore:        ScoreSafelyUnguessable,
+	}
+	account := Account{UserName: "jdoe", Email: "jane.doe@example.com", FirstName: "Jane", LastName: "Doe"}
+
+	// Helper function to list the rules of the violations
+	rulesOf := func(violations []Violation) []string {
+		var broken []string
+		for _, violation := range violations {
+			broken = append(broken, violation.Rule)
+		}
+		return broken
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		expected []string
+	}{
+		{name: "valid password", password: "Glacier-Orbit-42"},
+		{name: "too short", password: "Gl4cier!", expected: []string{RuleMinLength}},
+		{name: "too long", password: "Glacier-Orbit-42-Cactus-Violin", expected: []string{RuleMaxLength}},
+		{name: "missing classes", password: "glacierorbitcactus", expected: []string{
+			RuleCharacterClass, RuleCharacterClass, RuleCharacterClass,
+		}},
+		{name: "user name", password: "Glacier-JDoe-42", expected: []string{RulePersonalInfo}},
+		{name: "email", password: "Mail-Jane.Doe-7", expected: []string{RulePersonalInfo}},
+		{name: "name with substitutions", password: "Orbit-J4n3-Cactus9", expected: []string{RulePersonalInfo}},
+		{name: "banned word", password: "Glacier-W1nter-42", expected: []string{RuleBannedWord}},
+		{name: "guessable", password: "Password123!", expected: []string{RuleStrength}},
+		{name: "every rule", password: "acme", expected: []string{
+			RuleMinLength, RuleCharacterClass, RuleCharacterClass, RuleCharacterClass, RuleBannedWord, RuleStrength,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, rulesOf(rules.Check(tt.password, account)))
+		})
+	}
+
+	t.Run("messages", func(t *testing.T) {
+		violations := rules.Check("glacierorbitcactus", account)
+
+		assert.Equal(t, []Violation{
+			{Rule: RuleCharacterClass, Message: "password must contain an uppercase letter"},
+			{Rule: RuleCharacterClass, Message: "password must contain a digit"},
+			{Rule: RuleCharacterClass, Message: "password must contain a symbol"},
+		}, violations)
+	})
+}
+
+func TestLoadRules(t *testing.T) {
+	// The defaults are read once for the whole process, so only the banned words file is varied
+	rules, err := LoadRules(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, Rules{MinLength: 8, MaxLength: 128, MinScore: ScoreSomewhatGuessable, BreachAction: BreachActionBlock}, rules)
+
+	t.Run("banned words file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "banned.txt")
+		require.NoError(t, os.WriteFile(path, []byte("# Company names\nAcme\n\n  winter \n"), 0o600))
+
+		words, err := readBannedWords(path)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"acme", "winter"}, words)
+	})
+
+	t.Run("missing banned words file", func(t *testing.T) {
+		_, err := readBannedWords(filepath.Join(t.TempDir(), "missing.txt"))
+
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	ctx := context.Background()
+
+	assert.NoError(t, Validate(ctx, "Glacier-Orbit-42", Account{UserName: "jdoe"}))
+
+	err := Validate(ctx, "jdoe", Account{UserName: "jdoe"})
+
+	var codeErr *errcode.Error
+	require.True(t, errors.As(err, &codeErr))
+	assert.Equal(t, errcode.PasswordPolicyViolation, codeErr.Code)
+	assert.Equal(t, "password must be at least 8 characters; "+
+		"password must not contain the user name, email or name of the user; "+
+		"password is too easy to guess, use a longer password or more words", codeErr.Message)
+	assert.Equal(t, []Violation{
+		{Rule: RuleMinLength, Message: "password must be at least 8 characters"},
+		{Rule: RulePersonalInfo, Message: "password must not contain the user name, email or name of the user"},
+		{Rule: RuleStrength, Message: "password is too easy to guess, use a longer password or more words"},
+	}, codeErr.Extensions["violations"])
+}
@@ -0,0 +1,305 @@
+package password
+
+import (
+	_ "embed"
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Scores of passwords, estimated the way zxcvbn does from the number of guesses an attacker who
+// knows common passwords and patterns needs to find them
+const (
+	ScoreTooGuessable      = 0 // Fewer than 10^3 guesses
+	ScoreVeryGuessable     = 1 // Fewer than 10^6 guesses
+	ScoreSomewhatGuessable = 2 // Fewer than 10^8 guesses
+	ScoreSafelyUnguessable = 3 // Fewer than 10^10 guesses
+	ScoreVeryUnguessable   = 4 // At least 10^10 guesses
+)
+
+const (
+	// minMatchLength is the length of the shortest word, sequence or keyboard pattern matched
+	minMatchLength = 3
+	// minMatchGuesses is the fewest guesses any pattern spanning several characters counts for
+	minMatchGuesses = 50
+	// yearGuesses is the number of years from 1900 to 2039 guesses try
+	yearGuesses = 140
+	// keyboardGuesses is the number of guesses per character of a pattern along a keyboard row
+	keyboardGuesses = 40
+)
+
+// commonPasswordsFile lists the most common passwords, most common first
+//
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+// commonPasswords ranks the most common passwords, from 1 for the most common one
+var commonPasswords = sync.OnceValue(func() map[string]int {
+	ranks := map[string]int{}
+	for i, word := range strings.Fields(commonPasswordsFile) {
+		if _, ok := ranks[word]; !ok {
+			ranks[word] = i + 1
+		}
+	}
+	return ranks
+})
+
+// keyboardRows are the rows of a QWERTY keyboard patterns are looked for along
+var keyboardRows = []string{"1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+// leetSubstitutions maps the characters commonly substituted for letters back to the letters
+var leetSubstitutions = map[rune]rune{
+	'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '1': 'i', '!': 'i',
+	'0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z',
+}
+
+// match is a part of a password following a pattern, from start up to end, which is found within
+// 10^logGuesses guesses
+type match struct {
+	start, end int
+	logGuesses float64
+}
+
+// Score estimates how hard the password is to guess, from ScoreTooGuessable to
+// ScoreVeryUnguessable. The password is split into the common passwords, given words, sequences,
+// keyboard patterns, repeats and years it contains, and guessing the rest a character at a time,
+// whichever split needs the fewest guesses.
+func Score(plaintext string, words ...string) int {
+	logGuesses := estimateLogGuesses(plaintext, words)
+	switch {
+	case logGuesses < 3:
+		return ScoreTooGuessable
+	case logGuesses < 6:
+		return ScoreVeryGuessable
+	case logGuesses < 8:
+		return ScoreSomewhatGuessable
+	case logGuesses < 10:
+		return ScoreSafelyUnguessable
+	}
+	return ScoreVeryUnguessable
+}
+
+// Helper function to estimate the base 10 logarithm of the number of guesses needed to find the
+// password
+func estimateLogGuesses(plaintext string, words []string) float64 {
+	original := []rune(plaintext)
+	if len(original) == 0 {
+		return 0
+	}
+	lower := []rune(strings.ToLower(plaintext))
+	dictionary := map[string]int{}
+	for word, rank := range commonPasswords() {
+		dictionary[word] = rank
+	}
+	// Words known to the attacker, such as the user's name, are the first they would guess
+	for _, word := range words {
+		if word = strings.ToLower(word); len([]rune(word)) >= minMatchLength {
+			dictionary[word] = 1
+		}
+	}
+
+	var matches []match
+	matches = append(matches, dictionaryMatches(original, lower, dictionary)...)
+	matches = append(matches, sequenceMatches(lower)...)
+	matches = append(matches, keyboardMatches(lower)...)
+	matches = append(matches, repeatMatches(lower, words)...)
+	matches = append(matches, yearMatches(lower)...)
+
+	// best[i] is the fewest guesses finding the first i characters take, split as well as possible
+	logCardinality := math.Log10(float64(cardinality(original)))
+	best := make([]float64, len(lower)+1)
+	for end := 1; end <= len(lower); end++ {
+		best[end] = best[end-1] + logCardinality
+		for _, m := range matches {
+			if m.end == end {
+				best[end] = min(best[end], best[m.start]+max(m.logGuesses, math.Log10(minMatchGuesses)))
+			}
+		}
+	}
+	return best[len(lower)]
+}
+
+// Helper function to count the characters a brute force guess of the password draws from
+func cardinality(password []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r > unicode.MaxASCII:
+			other = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	n := 0
+	for _, class := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.present {
+			n += class.size
+		}
+	}
+	return n
+}
+
+// Helper function to find the dictionary words in the password, as typed or with the characters
+// commonly substituted for letters replaced
+func dictionaryMatches(original []rune, lower []rune, dictionary map[string]int) []match {
+	unleeted := make([]rune, len(lower))
+	for i, r := range lower {
+		unleeted[i] = r
+		if letter, ok := leetSubstitutions[r]; ok {
+			unleeted[i] = letter
+		}
+	}
+
+	var matches []match
+	for start := range lower {
+		for end := start + minMatchLength; end <= len(lower); end++ {
+			word := string(lower[start:end])
+			rank, ok := dictionary[word]
+			guesses := float64(rank)
+			if !ok {
+				if rank, ok = dictionary[string(unleeted[start:end])]; !ok {
+					continue
+				}
+				guesses = float64(rank) * 2
+			}
+			guesses *= capitalizationVariations(original[start:end])
+			matches = append(matches, match{start: start, end: end, logGuesses: math.Log10(guesses)})
+		}
+	}
+	return matches
+}
+
+// Helper function to count the ways a word could be capitalized as it is in the password
+func capitalizationVariations(word []rune) float64 {
+	var upper, lower int
+	for _, r := range word {
+		if unicode.IsUpper(r) {
+			upper++
+		} else if unicode.IsLower(r) {
+			lower++
+		}
+	}
+	if upper == 0 {
+		return 1
+	}
+	// Capitalized or all upper case words are guessed right after lower case ones
+	if lower == 0 || (upper == 1 && unicode.IsUpper(word[0])) {
+		return 2
+	}
+	variations := 0.0
+	for k := 1; k <= min(upper, lower); k++ {
+		variations += binomial(upper+lower, k)
+	}
+	return variations
+}
+
+// Helper function to compute the number of ways to choose k out of n
+func binomial(n int, k int) float64 {
+	result := 1.0
+	for i := 1; i <= k; i++ {
+		result = result * float64(n-k+i) / float64(i)
+	}
+	return result
+}
+
+// Helper function to find the runs of consecutive characters in the password, e.g. abc or 987
+func sequenceMatches(lower []rune) []match {
+	var matches []match
+	for start := 0; start < len(lower)-1; {
+		delta := lower[start+1] - lower[start]
+		end := start + 2
+		for end < len(lower) && lower[end]-lower[end-1] == delta {
+			end++
+		}
+		if (delta == 1 || delta == -1) && end-start >= minMatchLength {
+			// Sequences starting at either end of the alphabet or of the digits are guessed first
+			base := 26.0
+			if strings.ContainsRune("az019", lower[start]) {
+				base = 4
+			} else if unicode.IsDigit(lower[start]) {
+				base = 10
+			}
+			if delta == -1 {
+				base *= 2
+			}
+			matches = append(matches, match{start: start, end: end, logGuesses: math.Log10(base * float64(end-start))})
+		}
+		start = end - 1
+	}
+	return matches
+}
+
+// Helper function to find the runs of adjacent keys of a keyboard row in the password, e.g. qwerty
+func keyboardMatches(lower []rune) []match {
+	var matches []match
+	for start := 0; start < len(lower); start++ {
+		end := start + minMatchLength
+		for end <= len(lower) && onKeyboardRow(string(lower[start:end])) {
+			end++
+		}
+		if end--; end-start >= minMatchLength+1 {
+			matches = append(matches, match{start: start, end: end, logGuesses: math.Log10(keyboardGuesses * float64(end-start))})
+			start = end - 1
+		}
+	}
+	return matches
+}
+
+// Helper function to check whether the keys follow each other along a keyboard row, either way
+func onKeyboardRow(keys string) bool {
+	reversed := []rune(keys)
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+	for _, row := range keyboardRows {
+		if strings.Contains(row, keys) || strings.Contains(row, string(reversed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper function to find the characters or groups of characters repeated in the password, e.g.
+// aaa or abcabc, which are found by guessing the repeated part and how often it is repeated
+func repeatMatches(lower []rune, words []string) []match {
+	var matches []match
+	for start := range lower {
+		for unit := 1; start+2*unit <= len(lower); unit++ {
+			repeats := 1
+			for end := start + (repeats+1)*unit; end <= len(lower) &&
+				string(lower[end-unit:end]) == string(lower[start:start+unit]); end += unit {
+				repeats++
+			}
+			// Only the shortest repeated part is matched, e.g. a rather than aa in aaaa
+			if repeats >= 3 || (unit > 1 && repeats >= 2) {
+				logGuesses := estimateLogGuesses(string(lower[start:start+unit]), words) + math.Log10(float64(repeats))
+				matches = append(matches, match{start: start, end: start + repeats*unit, logGuesses: logGuesses})
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// Helper function to find the recent years in the password, which are often birth years
+func yearMatches(lower []rune) []match {
+	var matches []match
+	for start := 0; start+4 <= len(lower); start++ {
+		year := string(lower[start : start+4])
+		if (strings.HasPrefix(year, "19") || strings.HasPrefix(year, "20")) &&
+			unicode.IsDigit(lower[start+2]) && unicode.IsDigit(lower[start+3]) {
+			matches = append(matches, match{start: start, end: start + 4, logGuesses: math.Log10(yearGuesses)})
+		}
+	}
+	return matches
+}
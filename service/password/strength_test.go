@@ -0,0 +1,42 @@
+package password
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		password string
+		words    []string
+		expected int
+	}{
+		{password: "password", expected: ScoreTooGuessable},
+		{password: "P@ssw0rd", expected: ScoreTooGuessable},
+		{password: "qwertyuiop", expected: ScoreTooGuessable},
+		{password: "abcdefgh", expected: ScoreTooGuessable},
+		{password: "aaaaaaaa", expected: ScoreTooGuessable},
+		{password: "zxcvbnm123", expected: ScoreVeryGuessable},
+		{password: "abcabcabc1990", expected: ScoreVeryGuessable},
+		{password: "newPassword123", expected: ScoreSafelyUnguessable},
+		{password: "correct horse battery staple", expected: ScoreVeryUnguessable},
+		{password: "Tr0ub4dor&3", expected: ScoreVeryUnguessable},
+		// Words known to the attacker are guessed first
+		{password: "kowalski1990", expected: ScoreVeryUnguessable},
+		{password: "kowalski1990", words: []string{"Kowalski"}, expected: ScoreVeryGuessable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Score(tt.password, tt.words...))
+		})
+	}
+}
+
+func TestCapitalizationVariations(t *testing.T) {
+	assert.Equal(t, 1.0, capitalizationVariations([]rune("password")))
+	assert.Equal(t, 2.0, capitalizationVariations([]rune("Password")))
+	assert.Equal(t, 2.0, capitalizationVariations([]rune("PASSWORD")))
+	// One of the 8 letters upper case, other than the first
+	assert.Equal(t, 8.0, capitalizationVariations([]rune("passWord")))
+}
@@ -0,0 +1,147 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// ErrInvalidActionToken is returned when an action token is unknown, expired, already used or was
+// issued for another purpose
+var ErrInvalidActionToken = errors.New("invalid or expired token")
+
+// ActionPurpose is what an action token authorizes its bearer to do
+type ActionPurpose string
+
+const (
+	// PurposePasswordReset authorizes choosing a new password without knowing the current one
+	PurposePasswordReset ActionPurpose = "password_reset"
+	// PurposeEmailVerification proves the user receives mail at their email address
+	PurposeEmailVerification ActionPurpose = "email_verification"
+	// PurposeMFAChallenge proves the user entered their password, pending their second factor
+	PurposeMFAChallenge ActionPurpose = "mfa_challenge"
+)
+
+// actionTokenDB is a persisted single-use token sent to a user out of band, e.g. by email. Only
+// the SHA-256 hash of the token is stored.
+type actionTokenDB struct {
+	TokenHash    string        `bson:"token_hash"`
+	UserID       string        `bson:"user_id"`
+	Purpose      ActionPurpose `bson:"purpose"`
+	ExpiresAt    time.Time     `bson:"expires_at"`
+	CreationDate time.Time     `bson:"creation_date"`
+	UsedDate     *time.Time    `bson:"used_date"`
+	// Attempts counts the uses of the token checked by AttemptActionToken
+	Attempts int `bson:"attempts"`
+}
+
+// Helper function to get action token collection from context
+func getActionTokenCollection(ctx context.Context) (ActionTokenCollection, error) {
+	actionTokenCollection, err := ActionTokensFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return actionTokenCollection, nil
+}
+
+// IssueActionToken generates and persists a new single-use token allowing the given user to
+// perform the given action until it expires, returning the raw token
+func IssueActionToken(ctx context.Context, userID string, purpose ActionPurpose, ttl time.Duration) (string, error) {
+	actionTokenCollection, err := getActionTokenCollection(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	raw, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	actionToken := actionTokenDB{
+		TokenHash:    hashOpaqueToken(raw),
+		UserID:       userID,
+		Purpose:      purpose,
+		ExpiresAt:    now.Add(ttl),
+		CreationDate: now,
+	}
+	if _, err = actionTokenCollection.InsertOne(ctx, actionToken); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// ConsumeActionToken uses up the given token, returning the ID of the user it was issued to. Any
+// other outstanding token of the user for the same purpose is used up as well, so that only the
+// first of several requested tokens can be redeemed.
+func ConsumeActionToken(ctx context.Context, raw string, purpose ActionPurpose) (string, error) {
+	actionTokenCollection, err := getActionTokenCollection(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	filter := bson.M{
+		"token_hash": hashOpaqueToken(raw),
+		"purpose":    purpose,
+		"used_date":  nil,
+		"expires_at": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"used_date": now}}
+
+	var actionToken actionTokenDB
+	if err = actionTokenCollection.FindOneAndUpdate(ctx, filter, update).Decode(&actionToken); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return "", ErrInvalidActionToken
+		}
+		return "", err
+	}
+
+	if err = RevokeActionTokens(ctx, actionToken.UserID, purpose); err != nil {
+		return "", err
+	}
+	return actionToken.UserID, nil
+}
+
+// AttemptActionToken counts an attempt to use the given token without using it up, returning
+// the ID of the user it was issued to. The token is rejected once maxAttempts have been made, so
+// that whatever the attempt guesses, e.g. a one-time code, cannot be brute forced. Callers use
+// the token up with ConsumeActionToken once an attempt succeeds.
+func AttemptActionToken(ctx context.Context, raw string, purpose ActionPurpose, maxAttempts int) (string, error) {
+	actionTokenCollection, err := getActionTokenCollection(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	filter := bson.M{
+		"token_hash": hashOpaqueToken(raw),
+		"purpose":    purpose,
+		"used_date":  nil,
+		"expires_at": bson.M{"$gt": time.Now().UTC()},
+		"attempts":   bson.M{"$lt": maxAttempts},
+	}
+	update := bson.M{"$inc": bson.M{"attempts": 1}}
+
+	var actionToken actionTokenDB
+	if err = actionTokenCollection.FindOneAndUpdate(ctx, filter, update).Decode(&actionToken); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return "", ErrInvalidActionToken
+		}
+		return "", err
+	}
+	return actionToken.UserID, nil
+}
+
+// RevokeActionTokens uses up every outstanding token of the given user for the given purpose
+func RevokeActionTokens(ctx context.Context, userID string, purpose ActionPurpose) error {
+	actionTokenCollection, err := getActionTokenCollection(ctx)
+	if err != nil {
+		return err
+	}
+	outstanding := bson.M{"user_id": userID, "purpose": purpose, "used_date": nil}
+	update := bson.M{"$set": bson.M{"used_date": time.Now().UTC()}}
+	_, err = actionTokenCollection.UpdateMany(ctx, outstanding, update)
+	return err
+}
@@ -0,0 +1,110 @@
+package token
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+
+	tokenMocks "github.com/ahummel25/user-auth-api/service/token/mocks"
+)
+
+func TestIssueActionToken(t *testing.T) {
+	mockColl := tokenMocks.NewMockActionTokenCollection(t)
+	ctx := NewContext(context.Background(), GetActionTokensCollectionKey(), mockColl)
+
+	var inserted actionTokenDB
+	mockColl.On("InsertOne", ctx, mock.AnythingOfType("token.actionTokenDB")).
+		Run(func(args mock.Arguments) { inserted = args.Get(1).(actionTokenDB) }).
+		Return(&mongo.InsertOneResult{}, nil)
+
+	raw, err := IssueActionToken(ctx, "test-id", PurposePasswordReset, time.Hour)
+
+	require.NoError(t, err)
+	assert.NotEmpty(t, raw)
+	// Only the hash of the token may be persisted
+	assert.Equal(t, hashOpaqueToken(raw), inserted.TokenHash)
+	assert.Equal(t, "test-id", inserted.UserID)
+	assert.Equal(t, PurposePasswordReset, inserted.Purpose)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), inserted.ExpiresAt, 5*time.Second)
+	assert.Nil(t, inserted.UsedDate)
+}
+
+func TestConsumeActionToken(t *testing.T) {
+	const raw = "raw-action-token"
+	consumeFilter := mock.MatchedBy(func(filter bson.M) bool {
+		return filter["token_hash"] == hashOpaqueToken(raw) && filter["purpose"] == PurposePasswordReset &&
+			filter["used_date"] == nil && filter["expires_at"] != nil
+	})
+
+	t.Run("valid token", func(t *testing.T) {
+		mockColl := tokenMocks.NewMockActionTokenCollection(t)
+		ctx := NewContext(context.Background(), GetActionTokensCollectionKey(), mockColl)
+
+		stored := actionTokenDB{TokenHash: hashOpaqueToken(raw), UserID: "test-id", Purpose: PurposePasswordReset}
+		mockColl.On("FindOneAndUpdate", ctx, consumeFilter, mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(stored, nil, nil))
+		// Tokens requested alongside this one can no longer be used
+		mockColl.On("UpdateMany", ctx, bson.M{"user_id": "test-id", "purpose": PurposePasswordReset, "used_date": nil},
+			mock.AnythingOfType("bson.M")).Return(&mongo.UpdateResult{ModifiedCount: 1}, nil)
+
+		userID, err := ConsumeActionToken(ctx, raw, PurposePasswordReset)
+
+		require.NoError(t, err)
+		assert.Equal(t, "test-id", userID)
+	})
+
+	t.Run("unknown, expired or used token", func(t *testing.T) {
+		mockColl := tokenMocks.NewMockActionTokenCollection(t)
+		ctx := NewContext(context.Background(), GetActionTokensCollectionKey(), mockColl)
+
+		mockColl.On("FindOneAndUpdate", ctx, consumeFilter, mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil))
+
+		userID, err := ConsumeActionToken(ctx, raw, PurposePasswordReset)
+
+		assert.Equal(t, ErrInvalidActionToken, err)
+		assert.Empty(t, userID)
+	})
+}
+
+func TestAttemptActionToken(t *testing.T) {
+	const raw = "raw-action-token"
+	attemptFilter := mock.MatchedBy(func(filter bson.M) bool {
+		return filter["token_hash"] == hashOpaqueToken(raw) && filter["purpose"] == PurposeMFAChallenge &&
+			filter["used_date"] == nil && assert.ObjectsAreEqual(bson.M{"$lt": 5}, filter["attempts"])
+	})
+
+	t.Run("valid token", func(t *testing.T) {
+		mockColl := tokenMocks.NewMockActionTokenCollection(t)
+		ctx := NewContext(context.Background(), GetActionTokensCollectionKey(), mockColl)
+
+		stored := actionTokenDB{TokenHash: hashOpaqueToken(raw), UserID: "test-id", Purpose: PurposeMFAChallenge, Attempts: 1}
+		mockColl.On("FindOneAndUpdate", ctx, attemptFilter, bson.M{"$inc": bson.M{"attempts": 1}}).
+			Return(mongo.NewSingleResultFromDocument(stored, nil, nil))
+
+		userID, err := AttemptActionToken(ctx, raw, PurposeMFAChallenge, 5)
+
+		// The token is not used up, so no other token of the user is revoked
+		require.NoError(t, err)
+		assert.Equal(t, "test-id", userID)
+	})
+
+	t.Run("unknown, expired, used or exhausted token", func(t *testing.T) {
+		mockColl := tokenMocks.NewMockActionTokenCollection(t)
+		ctx := NewContext(context.Background(), GetActionTokensCollectionKey(), mockColl)
+
+		mockColl.On("FindOneAndUpdate", ctx, attemptFilter, mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil))
+
+		userID, err := AttemptActionToken(ctx, raw, PurposeMFAChallenge, 5)
+
+		assert.Equal(t, ErrInvalidActionToken, err)
+		assert.Empty(t, userID)
+	})
+}
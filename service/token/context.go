@@ -0,0 +1,76 @@
+package token
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ahummel25/user-auth-api/db/mongo"
+)
+
+// RefreshTokenCollection is an interface that wraps the database.Collection interface
+type RefreshTokenCollection interface {
+	mongo.Collection
+}
+
+// RevokedTokenCollection is an interface that wraps the database.Collection interface
+type RevokedTokenCollection interface {
+	mongo.Collection
+}
+
+// ActionTokenCollection is an interface that wraps the database.Collection interface
+type ActionTokenCollection interface {
+	mongo.Collection
+}
+
+// refreshTokensCollectionCtxKey represents the context key of the refresh tokens Mongo collection
+type refreshTokensCollectionCtxKey struct{}
+
+// revokedTokensCollectionCtxKey represents the context key of the revoked tokens Mongo collection
+type revokedTokensCollectionCtxKey struct{}
+
+// actionTokensCollectionCtxKey represents the context key of the action tokens Mongo collection
+type actionTokensCollectionCtxKey struct{}
+
+// NewContext returns a new context containing the given token collection under the given context key
+func NewContext(ctx context.Context, collectionCtxKey any, collection mongo.Collection) context.Context {
+	return context.WithValue(ctx, collectionCtxKey, collection)
+}
+
+// FromContext returns the RefreshTokenCollection from the context, or an error if not found
+func FromContext(ctx context.Context) (RefreshTokenCollection, error) {
+	if c, ok := ctx.Value(GetRefreshTokensCollectionKey()).(RefreshTokenCollection); ok {
+		return c, nil
+	}
+	return nil, errors.New("refresh token collection not found in context")
+}
+
+// RevokedTokensFromContext returns the RevokedTokenCollection from the context, or an error if not found
+func RevokedTokensFromContext(ctx context.Context) (RevokedTokenCollection, error) {
+	if c, ok := ctx.Value(GetRevokedTokensCollectionKey()).(RevokedTokenCollection); ok {
+		return c, nil
+	}
+	return nil, errors.New("revoked token collection not found in context")
+}
+
+// ActionTokensFromContext returns the ActionTokenCollection from the context, or an error if not found
+func ActionTokensFromContext(ctx context.Context) (ActionTokenCollection, error) {
+	if c, ok := ctx.Value(GetActionTokensCollectionKey()).(ActionTokenCollection); ok {
+		return c, nil
+	}
+	return nil, errors.New("action token collection not found in context")
+}
+
+// GetRefreshTokensCollectionKey is a wrapper function around the refreshTokensCollectionCtxKey returning a pointer to that value
+func GetRefreshTokensCollectionKey() *refreshTokensCollectionCtxKey {
+	return &refreshTokensCollectionCtxKey{}
+}
+
+// GetRevokedTokensCollectionKey is a wrapper function around the revokedTokensCollectionCtxKey returning a pointer to that value
+func GetRevokedTokensCollectionKey() *revokedTokensCollectionCtxKey {
+	return &revokedTokensCollectionCtxKey{}
+}
+
+// GetActionTokensCollectionKey is a wrapper function around the actionTokensCollectionCtxKey returning a pointer to that value
+func GetActionTokensCollectionKey() *actionTokensCollectionCtxKey {
+	return &actionTokensCollectionCtxKey{}
+}
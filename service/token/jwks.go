@@ -0,0 +1,79 @@
+package token
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// JWK is the public half of a signing key in the JSON Web Key format (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWKS returns the public keys other services should use to verify access tokens
+func PublicJWKS(ctx context.Context) (*JWKS, error) {
+	ks, err := getKeySet(ctx)
+	if err != nil {
+		return nil, err
+	}
+	set := &JWKS{Keys: []JWK{}}
+	for _, k := range ks.published(time.Now()) {
+		jwk, err := k.jwk()
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
+
+func (k *signingKey) jwk() (JWK, error) {
+	jwk := JWK{KeyID: k.id, Use: "sig", Algorithm: k.method.Alg()}
+	switch public := k.private.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = encodeJWKInt(public.N, 0)
+		jwk.E = encodeJWKInt(big.NewInt(int64(public.E)), 0)
+	case *ecdsa.PublicKey:
+		size := (public.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = public.Curve.Params().Name
+		jwk.X = encodeJWKInt(public.X, size)
+		jwk.Y = encodeJWKInt(public.Y, size)
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type %T", public)
+	}
+	return jwk, nil
+}
+
+// Helper function to base64url encode an integer as a big-endian byte string of the given size
+func encodeJWKInt(i *big.Int, size int) string {
+	b := i.Bytes()
+	if len(b) < size {
+		b = append(make([]byte, size-len(b)), b...)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
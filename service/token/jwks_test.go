@@ -0,0 +1,70 @@
+package token
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeJWKInt(t *testing.T, s string) *big.Int {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	require.NoError(t, err)
+	return new(big.Int).SetBytes(b)
+}
+
+func TestPublicJWKS(t *testing.T) {
+	set, err := PublicJWKS(context.Background())
+	require.NoError(t, err)
+	require.Len(t, set.Keys, 1)
+
+	jwk := set.Keys[0]
+	public := testKey.private.Public().(*ecdsa.PublicKey)
+	assert.Equal(t, "EC", jwk.KeyType)
+	assert.Equal(t, "test", jwk.KeyID)
+	assert.Equal(t, "sig", jwk.Use)
+	assert.Equal(t, "ES256", jwk.Algorithm)
+	assert.Equal(t, "P-256", jwk.Curve)
+	assert.Len(t, jwk.X, 43)
+	assert.Equal(t, public.X, decodeJWKInt(t, jwk.X))
+	assert.Equal(t, public.Y, decodeJWKInt(t, jwk.Y))
+	assert.Empty(t, jwk.N)
+}
+
+func TestSigningKeyJWK(t *testing.T) {
+	t.Run("RSA", func(t *testing.T) {
+		private, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		key := &signingKey{id: "rsa", method: jwt.SigningMethodRS256, private: private}
+
+		jwk, err := key.jwk()
+		require.NoError(t, err)
+		assert.Equal(t, "RSA", jwk.KeyType)
+		assert.Equal(t, "RS256", jwk.Algorithm)
+		assert.Equal(t, private.N, decodeJWKInt(t, jwk.N))
+		assert.Equal(t, "AQAB", jwk.E)
+		assert.Empty(t, jwk.Curve)
+	})
+
+	t.Run("Ed25519", func(t *testing.T) {
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		key := &signingKey{id: "ed", method: jwt.SigningMethodEdDSA, private: private}
+
+		jwk, err := key.jwk()
+		require.NoError(t, err)
+		assert.Equal(t, "OKP", jwk.KeyType)
+		assert.Equal(t, "EdDSA", jwk.Algorithm)
+		assert.Equal(t, "Ed25519", jwk.Curve)
+		assert.Equal(t, base64.RawURLEncoding.EncodeToString(public), jwk.X)
+	})
+}
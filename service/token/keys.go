@@ -0,0 +1,272 @@
+package token
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/ahummel25/user-auth-api/config"
+)
+
+const minRSAKeyBits = 2048
+
+var errNoSigningKeys = errors.New("no JWT signing keys configured, set JWT_SIGNING_KEYS or JWT_SIGNING_KEYS_FILE")
+
+// signingKeyConfig is the configured representation of a single signing key
+type signingKeyConfig struct {
+	KID string `json:"kid"`
+	Alg string `json:"alg"`
+	// PrivateKey is a PEM encoded PKCS #8, PKCS #1 (RSA) or SEC 1 (EC) private key
+	PrivateKey string `json:"privateKey"`
+	// ActiveFrom is when the key starts signing tokens. Keys are published ahead of this time
+	// so that verifiers can pick them up before the rotation happens.
+	ActiveFrom time.Time `json:"activeFrom"`
+	// RetireAt is when the key stops being published and accepted. It should be at least one
+	// access token lifetime after the next key becomes active.
+	RetireAt *time.Time `json:"retireAt,omitempty"`
+}
+
+// signingKey is a private key used to sign access tokens along with its rotation schedule
+type signingKey struct {
+	id         string
+	method     jwt.SigningMethod
+	private    crypto.Signer
+	activeFrom time.Time
+	retireAt   time.Time
+}
+
+func (k *signingKey) retired(now time.Time) bool {
+	return !k.retireAt.IsZero() && !now.Before(k.retireAt)
+}
+
+// keySet holds every configured signing key, newest first
+type keySet struct {
+	keys []*signingKey
+}
+
+// signer returns the most recently activated key which is not yet retired
+func (ks *keySet) signer(now time.Time) (*signingKey, error) {
+	for _, k := range ks.keys {
+		if !now.Before(k.activeFrom) && !k.retired(now) {
+			return k, nil
+		}
+	}
+	return nil, errors.New("no active JWT signing key")
+}
+
+// verifier returns the key with the given ID as long as it has not been retired
+func (ks *keySet) verifier(kid string, now time.Time) (*signingKey, bool) {
+	for _, k := range ks.keys {
+		if k.id == kid && !k.retired(now) {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+// published returns every key which verifiers should currently trust, including keys which
+// are scheduled to become active later
+func (ks *keySet) published(now time.Time) []*signingKey {
+	var trusted []*signingKey
+	for _, k := range ks.keys {
+		if !k.retired(now) {
+			trusted = append(trusted, k)
+		}
+	}
+	return trusted
+}
+
+var (
+	keys     *keySet
+	keysErr  error
+	keysOnce sync.Once
+)
+
+// InitSigningKeys loads the access token signing keys, returning an error if no usable key
+// material is configured outside of development
+func InitSigningKeys(ctx context.Context) error {
+	_, err := getKeySet(ctx)
+	return err
+}
+
+// Helper function to get the signing keys, loading them on first use
+func getKeySet(ctx context.Context) (*keySet, error) {
+	keysOnce.Do(func() {
+		keys, keysErr = loadKeySet(ctx)
+	})
+	return keys, keysErr
+}
+
+func loadKeySet(ctx context.Context) (*keySet, error) {
+	configSupplier, err := config.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := configSupplier.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	ks, err := parseKeySet(cfg.JWTSigningKeys, cfg.JWTSigningKeysFile, cfg.IsDev)
+	if err != nil {
+		return nil, err
+	}
+	// Tokens signed with a key the validator does not allow would be rejected on every request
+	for _, k := range ks.keys {
+		if !slices.Contains(cfg.JWTAllowedAlgorithms, k.method.Alg()) {
+			return nil, fmt.Errorf("JWT signing key %q uses %s which is not an allowed algorithm", k.id, k.method.Alg())
+		}
+	}
+	return ks, nil
+}
+
+// parseKeySet reads the signing keys from the inline JSON, falling back to the keys file. Only
+// development may run without any keys configured.
+func parseKeySet(keysJSON, keysFile string, isDev bool) (*keySet, error) {
+	raw := []byte(keysJSON)
+	if len(raw) == 0 && keysFile != "" {
+		var err error
+		if raw, err = os.ReadFile(keysFile); err != nil {
+			return nil, fmt.Errorf("failed to read JWT signing keys: %w", err)
+		}
+	}
+	if len(raw) == 0 {
+		if !isDev {
+			return nil, errNoSigningKeys
+		}
+		slog.Warn("No JWT signing keys configured, generating an ephemeral development key")
+		return newDevKeySet()
+	}
+
+	var configs []signingKeyConfig
+	if err := json.Unmarshal(raw, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse JWT signing keys: %w", err)
+	}
+	return newKeySet(configs, time.Now())
+}
+
+// newKeySet validates the configured keys and requires at least one of them to be active now
+func newKeySet(configs []signingKeyConfig, now time.Time) (*keySet, error) {
+	if len(configs) == 0 {
+		return nil, errNoSigningKeys
+	}
+	ks := &keySet{}
+	seen := make(map[string]bool, len(configs))
+	for _, c := range configs {
+		if c.KID == "" {
+			return nil, errors.New("JWT signing key is missing a kid")
+		} else if seen[c.KID] {
+			return nil, fmt.Errorf("duplicate JWT signing key %q", c.KID)
+		}
+		seen[c.KID] = true
+
+		key, err := parseSigningKey(c)
+		if err != nil {
+			return nil, fmt.Errorf("JWT signing key %q: %w", c.KID, err)
+		}
+		ks.keys = append(ks.keys, key)
+	}
+	sort.SliceStable(ks.keys, func(i, j int) bool {
+		return ks.keys[i].activeFrom.After(ks.keys[j].activeFrom)
+	})
+	if _, err := ks.signer(now); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// parseSigningKey decodes the private key and checks it is suitable for the configured algorithm
+func parseSigningKey(c signingKeyConfig) (*signingKey, error) {
+	block, _ := pem.Decode([]byte(c.PrivateKey))
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+	private, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{id: c.KID, private: private, activeFrom: c.ActiveFrom}
+	if c.RetireAt != nil {
+		if !c.RetireAt.After(c.ActiveFrom) {
+			return nil, errors.New("retireAt must be after activeFrom")
+		}
+		key.retireAt = *c.RetireAt
+	}
+
+	switch c.Alg {
+	case jwt.SigningMethodRS256.Alg():
+		rsaKey, ok := private.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("RS256 requires an RSA private key")
+		} else if rsaKey.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA keys must be at least %d bits", minRSAKeyBits)
+		}
+		key.method = jwt.SigningMethodRS256
+	case jwt.SigningMethodES256.Alg():
+		ecKey, ok := private.(*ecdsa.PrivateKey)
+		if !ok || ecKey.Curve != elliptic.P256() {
+			return nil, errors.New("ES256 requires a P-256 private key")
+		}
+		key.method = jwt.SigningMethodES256
+	case jwt.SigningMethodEdDSA.Alg():
+		if _, ok := private.(ed25519.PrivateKey); !ok {
+			return nil, errors.New("EdDSA requires an Ed25519 private key")
+		}
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", c.Alg)
+	}
+	return key, nil
+}
+
+// Helper function to parse the supported DER private key encodings
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key type")
+		}
+		return signer, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("failed to parse private key")
+}
+
+// newDevKeySet generates a throwaway ES256 key so that local development works without any
+// key material. Tokens signed with it do not survive a restart.
+func newDevKeySet() (*keySet, error) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &keySet{keys: []*signingKey{{
+		id:         "dev-" + uuid.NewString()[:8],
+		method:     jwt.SigningMethodES256,
+		private:    private,
+		activeFrom: time.Now(),
+	}}}, nil
+}
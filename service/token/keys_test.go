@@ -0,0 +1,182 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKey signs every token generated by the tests in this package
+var testKey *signingKey
+
+func TestMain(m *testing.M) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	testKey = &signingKey{id: "test", method: jwt.SigningMethodES256, private: private}
+	keysOnce.Do(func() {
+		keys = &keySet{keys: []*signingKey{testKey}}
+	})
+	os.Exit(m.Run())
+}
+
+func encodePEM(t *testing.T, key any) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+func newECKeyPEM(t *testing.T) string {
+	t.Helper()
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return encodePEM(t, private)
+}
+
+func TestNewKeySet(t *testing.T) {
+	now := time.Now()
+	ecPEM := newECKeyPEM(t)
+
+	t.Run("supported algorithms", func(t *testing.T) {
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		_, edKey, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		ks, err := newKeySet([]signingKeyConfig{
+			{KID: "rsa", Alg: "RS256", PrivateKey: encodePEM(t, rsaKey), ActiveFrom: now.Add(-3 * time.Hour)},
+			{KID: "ec", Alg: "ES256", PrivateKey: ecPEM, ActiveFrom: now.Add(-2 * time.Hour)},
+			{KID: "ed", Alg: "EdDSA", PrivateKey: encodePEM(t, edKey), ActiveFrom: now.Add(-time.Hour)},
+		}, now)
+		require.NoError(t, err)
+		require.Len(t, ks.keys, 3)
+		assert.Equal(t, "ed", ks.keys[0].id)
+		assert.Equal(t, "ec", ks.keys[1].id)
+		assert.Equal(t, "rsa", ks.keys[2].id)
+	})
+
+	t.Run("rotation schedule", func(t *testing.T) {
+		retireAt := now.Add(time.Hour)
+		ks, err := newKeySet([]signingKeyConfig{
+			{KID: "old", Alg: "ES256", PrivateKey: ecPEM, ActiveFrom: now.Add(-48 * time.Hour), RetireAt: &retireAt},
+			{KID: "current", Alg: "ES256", PrivateKey: newECKeyPEM(t), ActiveFrom: now.Add(-time.Hour)},
+			{KID: "next", Alg: "ES256", PrivateKey: newECKeyPEM(t), ActiveFrom: now.Add(24 * time.Hour)},
+		}, now)
+		require.NoError(t, err)
+
+		signer, err := ks.signer(now)
+		require.NoError(t, err)
+		assert.Equal(t, "current", signer.id)
+
+		// The next key takes over once it becomes active
+		signer, err = ks.signer(now.Add(25 * time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, "next", signer.id)
+
+		// Tokens from the old key verify until it is retired
+		_, ok := ks.verifier("old", now)
+		assert.True(t, ok)
+		_, ok = ks.verifier("old", retireAt)
+		assert.False(t, ok)
+		_, ok = ks.verifier("unknown", now)
+		assert.False(t, ok)
+
+		assert.Len(t, ks.published(now), 3)
+		assert.Len(t, ks.published(retireAt), 2)
+	})
+
+	t.Run("invalid configuration", func(t *testing.T) {
+		smallRSA, err := rsa.GenerateKey(rand.Reader, 1024)
+		require.NoError(t, err)
+		past := now.Add(-time.Hour)
+
+		tests := []struct {
+			name    string
+			configs []signingKeyConfig
+		}{
+			{name: "no keys"},
+			{name: "missing kid", configs: []signingKeyConfig{{Alg: "ES256", PrivateKey: ecPEM}}},
+			{name: "duplicate kid", configs: []signingKeyConfig{
+				{KID: "a", Alg: "ES256", PrivateKey: ecPEM},
+				{KID: "a", Alg: "ES256", PrivateKey: ecPEM},
+			}},
+			{name: "not PEM", configs: []signingKeyConfig{{KID: "a", Alg: "ES256", PrivateKey: "secret"}}},
+			{name: "symmetric algorithm", configs: []signingKeyConfig{{KID: "a", Alg: "HS256", PrivateKey: ecPEM}}},
+			{name: "algorithm mismatch", configs: []signingKeyConfig{{KID: "a", Alg: "RS256", PrivateKey: ecPEM}}},
+			{name: "weak RSA key", configs: []signingKeyConfig{
+				{KID: "a", Alg: "RS256", PrivateKey: encodePEM(t, smallRSA)},
+			}},
+			{name: "retired before active", configs: []signingKeyConfig{
+				{KID: "a", Alg: "ES256", PrivateKey: ecPEM, ActiveFrom: now, RetireAt: &past},
+			}},
+			{name: "no active key", configs: []signingKeyConfig{
+				{KID: "a", Alg: "ES256", PrivateKey: ecPEM, ActiveFrom: now.Add(time.Hour)},
+			}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := newKeySet(tt.configs, now)
+				assert.Error(t, err)
+			})
+		}
+	})
+}
+
+func TestParseKeySet(t *testing.T) {
+	keysJSON := `[{"kid":"inline","alg":"ES256","activeFrom":"2024-01-01T00:00:00Z","privateKey":` +
+		strconv.Quote(newECKeyPEM(t)) + `}]`
+
+	t.Run("dev mode generates a key", func(t *testing.T) {
+		ks, err := parseKeySet("", "", true)
+		require.NoError(t, err)
+		_, err = ks.signer(time.Now())
+		assert.NoError(t, err)
+	})
+
+	t.Run("refuses to run outside dev without keys", func(t *testing.T) {
+		_, err := parseKeySet("", "", false)
+		assert.ErrorIs(t, err, errNoSigningKeys)
+	})
+
+	t.Run("inline keys", func(t *testing.T) {
+		ks, err := parseKeySet(keysJSON, "", false)
+		require.NoError(t, err)
+		signer, err := ks.signer(time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, "inline", signer.id)
+	})
+
+	t.Run("keys file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys.json")
+		require.NoError(t, os.WriteFile(path, []byte(keysJSON), 0o600))
+
+		ks, err := parseKeySet("", path, false)
+		require.NoError(t, err)
+		assert.Len(t, ks.keys, 1)
+	})
+
+	t.Run("missing keys file", func(t *testing.T) {
+		_, err := parseKeySet("", filepath.Join(t.TempDir(), "missing.json"), true)
+		assert.Error(t, err)
+	})
+
+	t.Run("malformed JSON", func(t *testing.T) {
+		_, err := parseKeySet("{", "", false)
+		assert.Error(t, err)
+	})
+}
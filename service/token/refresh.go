@@ -0,0 +1,167 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+
+	"github.com/ahummel25/user-auth-api/config"
+	"github.com/ahummel25/user-auth-api/graphql/errcode"
+)
+
+var (
+	errInvalidRefreshToken = errcode.New(errcode.Unauthenticated, "invalid refresh token")
+	errRefreshTokenReused  = errcode.New(errcode.Unauthenticated, "refresh token has already been used")
+)
+
+// refreshTokenDB is a persisted refresh token. Only the SHA-256 hash of the token is stored.
+type refreshTokenDB struct {
+	TokenHash    string     `bson:"token_hash"`
+	FamilyID     string     `bson:"family_id"`
+	UserID       string     `bson:"user_id"`
+	ExpiresAt    time.Time  `bson:"expires_at"`
+	CreationDate time.Time  `bson:"creation_date"`
+	UsedDate     *time.Time `bson:"used_date"`
+	Revoked      bool       `bson:"revoked"`
+}
+
+// RefreshToken is the session a refresh token belongs to
+type RefreshToken struct {
+	UserID   string
+	FamilyID string
+}
+
+// Helper function to generate a random opaque token
+func newOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Helper function to hash a raw opaque token for storage and lookup
+func hashOpaqueToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// Helper function to get refresh token collection from context
+func getRefreshTokenCollection(ctx context.Context) (RefreshTokenCollection, error) {
+	refreshTokenCollection, err := FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return refreshTokenCollection, nil
+}
+
+// NewFamilyID returns a new identifier for a refresh token family. A family spans every refresh
+// token rotated from a single login and doubles as the session ID of the access tokens issued to it.
+func NewFamilyID() string {
+	return uuid.New().String()
+}
+
+// IssueRefreshToken generates and persists a new single-use refresh token in the given family,
+// returning the raw token along with its expiry
+func IssueRefreshToken(ctx context.Context, userID string, familyID string) (string, time.Time, error) {
+	refreshTokenCollection, err := getRefreshTokenCollection(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	configSupplier, err := config.FromContext(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	cfg, err := configSupplier.GetConfig()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	raw, err := newOpaqueToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(cfg.RefreshTokenTTL).Truncate(time.Millisecond)
+	refreshToken := refreshTokenDB{
+		TokenHash:    hashOpaqueToken(raw),
+		FamilyID:     familyID,
+		UserID:       userID,
+		ExpiresAt:    expiresAt,
+		CreationDate: now,
+	}
+	if _, err = refreshTokenCollection.InsertOne(ctx, refreshToken); err != nil {
+		return "", time.Time{}, err
+	}
+	return raw, expiresAt, nil
+}
+
+// RotateRefreshToken consumes the given refresh token, returning the session it belongs to so that
+// a replacement can be issued in the same family. Presenting a token which has already been rotated
+// is treated as token theft and revokes every access and refresh token of the user.
+func RotateRefreshToken(ctx context.Context, raw string) (*RefreshToken, error) {
+	refreshTokenCollection, err := getRefreshTokenCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	tokenHash := hashOpaqueToken(raw)
+	filter := bson.M{
+		"token_hash": tokenHash,
+		"used_date":  nil,
+		"revoked":    false,
+		"expires_at": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"used_date": now}}
+
+	var refreshToken refreshTokenDB
+	err = refreshTokenCollection.FindOneAndUpdate(ctx, filter, update).Decode(&refreshToken)
+	if err == nil {
+		return &RefreshToken{UserID: refreshToken.UserID, FamilyID: refreshToken.FamilyID}, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+
+	// The token is unusable; find out whether it is unknown, expired or being replayed
+	if err = refreshTokenCollection.FindOne(ctx, bson.M{"token_hash": tokenHash}).Decode(&refreshToken); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errInvalidRefreshToken
+		}
+		return nil, err
+	}
+	if refreshToken.UsedDate == nil {
+		return nil, errInvalidRefreshToken
+	}
+
+	// Whoever replayed it may already hold access tokens of the family, which are revoked along with
+	// every other token of the user rather than left valid until they expire
+	slog.Warn("Refresh token reuse detected, revoking every token of the user",
+		"user_id", refreshToken.UserID, "family_id", refreshToken.FamilyID)
+	if err = RevokeUserTokens(ctx, refreshToken.UserID); err != nil {
+		return nil, err
+	}
+	return nil, errRefreshTokenReused
+}
+
+// RevokeRefreshTokenFamily revokes every refresh token in the given family
+func RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	refreshTokenCollection, err := getRefreshTokenCollection(ctx)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{"revoked": true}}
+	_, err = refreshTokenCollection.UpdateMany(ctx, bson.M{"family_id": familyID}, update)
+	return err
+}
@@ -0,0 +1,162 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+
+	tokenMocks "github.com/ahummel25/user-auth-api/service/token/mocks"
+)
+
+func TestIssueRefreshToken(t *testing.T) {
+	t.Run("successful issue", func(t *testing.T) {
+		mockColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		var inserted refreshTokenDB
+		mockColl.On("InsertOne", ctx, mock.AnythingOfType("token.refreshTokenDB")).
+			Run(func(args mock.Arguments) { inserted = args.Get(1).(refreshTokenDB) }).
+			Return(&mongo.InsertOneResult{}, nil)
+
+		raw, expiresAt, err := IssueRefreshToken(ctx, "test-id", "family-id")
+
+		require.NoError(t, err)
+		assert.NotEmpty(t, raw)
+		assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), expiresAt, 5*time.Second)
+		// Only the hash of the token may be persisted
+		assert.Equal(t, hashOpaqueToken(raw), inserted.TokenHash)
+		assert.NotContains(t, inserted.TokenHash, raw)
+		assert.Equal(t, "test-id", inserted.UserID)
+		assert.Equal(t, "family-id", inserted.FamilyID)
+		assert.Equal(t, expiresAt, inserted.ExpiresAt)
+		assert.Nil(t, inserted.UsedDate)
+		assert.False(t, inserted.Revoked)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mockColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+		mockColl.On("InsertOne", ctx, mock.Anything).Return(nil, errors.New("database error"))
+
+		raw, _, err := IssueRefreshToken(ctx, "test-id", "family-id")
+
+		assert.Error(t, err)
+		assert.Empty(t, raw)
+	})
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	const raw = "raw-refresh-token"
+	tokenHash := hashOpaqueToken(raw)
+	rotateFilter := mock.MatchedBy(func(filter bson.M) bool {
+		return filter["token_hash"] == tokenHash && filter["used_date"] == nil && filter["revoked"] == false
+	})
+	lookupFilter := bson.M{"token_hash": tokenHash}
+
+	t.Run("successful rotation", func(t *testing.T) {
+		mockColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		stored := refreshTokenDB{TokenHash: tokenHash, FamilyID: "family-id", UserID: "test-id"}
+		mockColl.On("FindOneAndUpdate", ctx, rotateFilter, mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(stored, nil, nil))
+
+		session, err := RotateRefreshToken(ctx, raw)
+
+		require.NoError(t, err)
+		assert.Equal(t, &RefreshToken{UserID: "test-id", FamilyID: "family-id"}, session)
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		mockColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		mockColl.On("FindOneAndUpdate", ctx, rotateFilter, mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(refreshTokenDB{}, mongo.ErrNoDocuments, nil))
+		mockColl.On("FindOne", ctx, lookupFilter).
+			Return(mongo.NewSingleResultFromDocument(refreshTokenDB{}, mongo.ErrNoDocuments, nil))
+
+		session, err := RotateRefreshToken(ctx, raw)
+
+		assert.Nil(t, session)
+		assert.Equal(t, errInvalidRefreshToken, err)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		mockColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		stored := refreshTokenDB{
+			TokenHash: tokenHash,
+			FamilyID:  "family-id",
+			UserID:    "test-id",
+			ExpiresAt: time.Now().Add(-time.Minute),
+		}
+		mockColl.On("FindOneAndUpdate", ctx, rotateFilter, mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(refreshTokenDB{}, mongo.ErrNoDocuments, nil))
+		mockColl.On("FindOne", ctx, lookupFilter).Return(mongo.NewSingleResultFromDocument(stored, nil, nil))
+
+		session, err := RotateRefreshToken(ctx, raw)
+
+		assert.Nil(t, session)
+		assert.Equal(t, errInvalidRefreshToken, err)
+	})
+
+	t.Run("replayed token revokes every token of the user", func(t *testing.T) {
+		mockColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		mockRevokedColl := tokenMocks.NewMockRevokedTokenCollection(t)
+		ctx := NewContext(createContextWithMockCollection(mockColl), GetRevokedTokensCollectionKey(), mockRevokedColl)
+
+		usedDate := time.Now().Add(-time.Minute).UTC()
+		stored := refreshTokenDB{
+			TokenHash: tokenHash,
+			FamilyID:  "family-id",
+			UserID:    "test-id",
+			UsedDate:  &usedDate,
+		}
+		mockColl.On("FindOneAndUpdate", ctx, rotateFilter, mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(refreshTokenDB{}, mongo.ErrNoDocuments, nil))
+		mockColl.On("FindOne", ctx, lookupFilter).Return(mongo.NewSingleResultFromDocument(stored, nil, nil))
+		mockColl.On("UpdateMany", ctx, bson.M{"user_id": "test-id", "revoked": false}, bson.M{"$set": bson.M{"revoked": true}}).
+			Return(&mongo.UpdateResult{ModifiedCount: 2}, nil)
+		// Access tokens already issued from the family are revoked as well
+		var inserted revokedTokenDB
+		mockRevokedColl.On("InsertOne", ctx, mock.AnythingOfType("token.revokedTokenDB")).
+			Run(func(args mock.Arguments) { inserted = args.Get(1).(revokedTokenDB) }).
+			Return(&mongo.InsertOneResult{}, nil)
+
+		session, err := RotateRefreshToken(ctx, raw)
+
+		assert.Nil(t, session)
+		assert.Equal(t, errRefreshTokenReused, err)
+		assert.Equal(t, "test-id", inserted.UserID)
+		require.NotNil(t, inserted.RevokedBefore)
+		assert.Empty(t, inserted.ExceptSessionID)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mockColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		mockColl.On("FindOneAndUpdate", ctx, rotateFilter, mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(refreshTokenDB{}, errors.New("database error"), nil))
+
+		session, err := RotateRefreshToken(ctx, raw)
+
+		assert.Nil(t, session)
+		assert.EqualError(t, err, "database error")
+	})
+}
+
+// Helper function to create a context with mock collection
+func createContextWithMockCollection(collection RefreshTokenCollection) context.Context {
+	ctx := context.Background()
+	return NewContext(ctx, GetRefreshTokensCollectionKey(), collection)
+}
@@ -0,0 +1,146 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"github.com/ahummel25/user-auth-api/config"
+)
+
+// ErrTokenRevoked is returned by JwtValidate for access tokens revoked before their expiry
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// revokedTokenDB is an entry in the access token denylist. An entry either revokes a single token
+// by its jti, or every token issued to a user up to the second of RevokedBefore, other than those
+// of the ExceptSessionID session. Entries are purged by a TTL index once ExpiresAt passes, as the tokens
+// they cover will have expired by then.
+type revokedTokenDB struct {
+	JTI             string     `bson:"jti,omitempty"`
+	UserID          string     `bson:"user_id"`
+	RevokedBefore   *time.Time `bson:"revoked_before,omitempty"`
+	ExceptSessionID string     `bson:"except_session_id,omitempty"`
+	ExpiresAt       time.Time  `bson:"expires_at"`
+	CreationDate    time.Time  `bson:"creation_date"`
+}
+
+// Helper function to get revoked token collection from context
+func getRevokedTokenCollection(ctx context.Context) (RevokedTokenCollection, error) {
+	revokedTokenCollection, err := RevokedTokensFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return revokedTokenCollection, nil
+}
+
+// RevokeAccessToken adds the access token with the given claims to the denylist until it expires
+func RevokeAccessToken(ctx context.Context, claims *JwtCustomClaim) error {
+	revokedTokenCollection, err := getRevokedTokenCollection(ctx)
+	if err != nil {
+		return err
+	}
+	if claims.ID == "" {
+		return errors.New("token has no jti to revoke")
+	} else if claims.ExpiresAt == nil {
+		return errors.New("token has no expiry")
+	}
+
+	revokedToken := revokedTokenDB{
+		JTI:          claims.ID,
+		UserID:       claims.UserID,
+		ExpiresAt:    claims.ExpiresAt.UTC(),
+		CreationDate: time.Now().UTC(),
+	}
+	_, err = revokedTokenCollection.InsertOne(ctx, revokedToken)
+	return err
+}
+
+// RevokeUserTokens revokes every access token issued to the given user so far, along with all of
+// their refresh tokens
+func RevokeUserTokens(ctx context.Context, userID string) error {
+	return revokeUserTokens(ctx, userID, "")
+}
+
+// RevokeOtherSessions revokes the access and refresh tokens of every session of the given user
+// except the given one
+func RevokeOtherSessions(ctx context.Context, userID string, sessionID string) error {
+	if sessionID == "" {
+		return errors.New("session to keep has no ID")
+	}
+	return revokeUserTokens(ctx, userID, sessionID)
+}
+
+// Helper function to revoke the tokens issued to a user so far, sparing those of the given session
+// unless it is empty
+func revokeUserTokens(ctx context.Context, userID string, exceptSessionID string) error {
+	revokedTokenCollection, err := getRevokedTokenCollection(ctx)
+	if err != nil {
+		return err
+	}
+	refreshTokenCollection, err := getRefreshTokenCollection(ctx)
+	if err != nil {
+		return err
+	}
+	configSupplier, err := config.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	cfg, err := configSupplier.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	filter := bson.M{"user_id": userID, "revoked": false}
+	if exceptSessionID != "" {
+		filter["family_id"] = bson.M{"$ne": exceptSessionID}
+	}
+	update := bson.M{"$set": bson.M{"revoked": true}}
+	if _, err = refreshTokenCollection.UpdateMany(ctx, filter, update); err != nil {
+		return err
+	}
+
+	// Access tokens only record the second they were issued in, so every token of the current
+	// second is revoked. This includes any issued later in that second, which is safer than sparing
+	// those issued earlier; a login that quickly after a revocation has to be repeated.
+	revokedBefore := now.Truncate(time.Second)
+	// Any access token issued before now expires within one access token lifetime at the latest
+	revokedToken := revokedTokenDB{
+		UserID:          userID,
+		RevokedBefore:   &revokedBefore,
+		ExceptSessionID: exceptSessionID,
+		ExpiresAt:       now.Add(cfg.AccessTokenTTL),
+		CreationDate:    now,
+	}
+	_, err = revokedTokenCollection.InsertOne(ctx, revokedToken)
+	return err
+}
+
+// isRevoked reports whether the access token with the given claims has been revoked
+func isRevoked(ctx context.Context, claims *JwtCustomClaim) (bool, error) {
+	revokedTokenCollection, err := getRevokedTokenCollection(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.UTC()
+	}
+	filter := bson.M{
+		"$or": []bson.M{
+			{"jti": claims.ID},
+			{
+				"user_id":           claims.UserID,
+				"revoked_before":    bson.M{"$gte": issuedAt},
+				"except_session_id": bson.M{"$ne": claims.SessionID},
+			},
+		}}
+	count, err := revokedTokenCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
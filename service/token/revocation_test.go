@@ -0,0 +1,173 @@
+package token
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	tokenMocks "github.com/ahummel25/user-auth-api/service/token/mocks"
+)
+
+func TestRevokeAccessToken(t *testing.T) {
+	t.Run("successful revocation", func(t *testing.T) {
+		mockRevokedColl := tokenMocks.NewMockRevokedTokenCollection(t)
+		ctx := NewContext(context.Background(), GetRevokedTokensCollectionKey(), mockRevokedColl)
+
+		expiresAt := time.Now().Add(10 * time.Minute).Truncate(time.Second).UTC()
+		claims := &JwtCustomClaim{UserID: "test-id"}
+		claims.ID = "token-id"
+		claims.ExpiresAt = jwt.NewNumericDate(expiresAt)
+
+		var inserted revokedTokenDB
+		mockRevokedColl.On("InsertOne", ctx, mock.AnythingOfType("token.revokedTokenDB")).
+			Run(func(args mock.Arguments) { inserted = args.Get(1).(revokedTokenDB) }).
+			Return(&mongo.InsertOneResult{}, nil)
+
+		err := RevokeAccessToken(ctx, claims)
+
+		require.NoError(t, err)
+		assert.Equal(t, "token-id", inserted.JTI)
+		assert.Equal(t, "test-id", inserted.UserID)
+		assert.Equal(t, expiresAt, inserted.ExpiresAt)
+		assert.Nil(t, inserted.RevokedBefore)
+	})
+
+	t.Run("token without jti", func(t *testing.T) {
+		mockRevokedColl := tokenMocks.NewMockRevokedTokenCollection(t)
+		ctx := NewContext(context.Background(), GetRevokedTokensCollectionKey(), mockRevokedColl)
+
+		err := RevokeAccessToken(ctx, &JwtCustomClaim{UserID: "test-id"})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	mockRefreshColl := tokenMocks.NewMockRefreshTokenCollection(t)
+	mockRevokedColl := tokenMocks.NewMockRevokedTokenCollection(t)
+	ctx := NewContext(context.Background(), GetRefreshTokensCollectionKey(), mockRefreshColl)
+	ctx = NewContext(ctx, GetRevokedTokensCollectionKey(), mockRevokedColl)
+
+	mockRefreshColl.On("UpdateMany", ctx, bson.M{"user_id": "test-id", "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true}}).Return(&mongo.UpdateResult{ModifiedCount: 3}, nil)
+	var inserted revokedTokenDB
+	mockRevokedColl.On("InsertOne", ctx, mock.AnythingOfType("token.revokedTokenDB")).
+		Run(func(args mock.Arguments) { inserted = args.Get(1).(revokedTokenDB) }).
+		Return(&mongo.InsertOneResult{}, nil)
+
+	err := RevokeUserTokens(ctx, "test-id")
+
+	require.NoError(t, err)
+	assert.Empty(t, inserted.JTI)
+	assert.Equal(t, "test-id", inserted.UserID)
+	require.NotNil(t, inserted.RevokedBefore)
+	assert.WithinDuration(t, time.Now(), *inserted.RevokedBefore, 5*time.Second)
+	assert.Equal(t, inserted.RevokedBefore.Truncate(time.Second), *inserted.RevokedBefore)
+	// The entry must outlive every access token it covers
+	assert.WithinDuration(t, inserted.RevokedBefore.Add(15*time.Minute), inserted.ExpiresAt, time.Second)
+}
+
+func TestRevokeUserTokens_SameSecond(t *testing.T) {
+	mockRefreshColl := tokenMocks.NewMockRefreshTokenCollection(t)
+	mockRevokedColl := tokenMocks.NewMockRevokedTokenCollection(t)
+	ctx := NewContext(context.Background(), GetRefreshTokensCollectionKey(), mockRefreshColl)
+	ctx = NewContext(ctx, GetRevokedTokensCollectionKey(), mockRevokedColl)
+
+	mockRefreshColl.On("UpdateMany", ctx, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M")).
+		Return(&mongo.UpdateResult{}, nil)
+	var inserted revokedTokenDB
+	mockRevokedColl.On("InsertOne", ctx, mock.AnythingOfType("token.revokedTokenDB")).
+		Run(func(args mock.Arguments) { inserted = args.Get(1).(revokedTokenDB) }).
+		Return(&mongo.InsertOneResult{}, nil)
+	require.NoError(t, RevokeUserTokens(ctx, "test-id"))
+
+	// Count the entry the way the user-wide clause of the filter matches it
+	mockRevokedColl.On("CountDocuments", ctx, mock.AnythingOfType("bson.M")).
+		Return(func(_ context.Context, filter interface{}, _ ...options.Lister[options.CountOptions]) int64 {
+			clause := filter.(bson.M)["$or"].([]bson.M)[1]
+			issuedAt := clause["revoked_before"].(bson.M)["$gte"].(time.Time)
+			if inserted.RevokedBefore.Before(issuedAt) {
+				return 0
+			}
+			return 1
+		}, nil)
+
+	// A token issued in the same second as the revocation, after it, records the same iat
+	claims := &JwtCustomClaim{UserID: "test-id", SessionID: "session-id"}
+	claims.ID = "token-id"
+	claims.IssuedAt = jwt.NewNumericDate(inserted.RevokedBefore.Add(999 * time.Millisecond))
+	revoked, err := isRevoked(ctx, claims)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	// Tokens of the next second are issued after the revocation
+	claims.IssuedAt = jwt.NewNumericDate(inserted.RevokedBefore.Add(time.Second))
+	revoked, err = isRevoked(ctx, claims)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+}
+
+func TestRevokeOtherSessions(t *testing.T) {
+	t.Run("spares the given session", func(t *testing.T) {
+		mockRefreshColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		mockRevokedColl := tokenMocks.NewMockRevokedTokenCollection(t)
+		ctx := NewContext(context.Background(), GetRefreshTokensCollectionKey(), mockRefreshColl)
+		ctx = NewContext(ctx, GetRevokedTokensCollectionKey(), mockRevokedColl)
+
+		filter := bson.M{"user_id": "test-id", "revoked": false, "family_id": bson.M{"$ne": "session-id"}}
+		mockRefreshColl.On("UpdateMany", ctx, filter, bson.M{"$set": bson.M{"revoked": true}}).
+			Return(&mongo.UpdateResult{ModifiedCount: 2}, nil)
+		var inserted revokedTokenDB
+		mockRevokedColl.On("InsertOne", ctx, mock.AnythingOfType("token.revokedTokenDB")).
+			Run(func(args mock.Arguments) { inserted = args.Get(1).(revokedTokenDB) }).
+			Return(&mongo.InsertOneResult{}, nil)
+
+		err := RevokeOtherSessions(ctx, "test-id", "session-id")
+
+		require.NoError(t, err)
+		assert.Equal(t, "test-id", inserted.UserID)
+		assert.Equal(t, "session-id", inserted.ExceptSessionID)
+		assert.NotNil(t, inserted.RevokedBefore)
+	})
+
+	t.Run("session without ID", func(t *testing.T) {
+		err := RevokeOtherSessions(context.Background(), "test-id", "")
+
+		assert.Error(t, err)
+	})
+}
+
+func TestIsRevoked(t *testing.T) {
+	mockRevokedColl := tokenMocks.NewMockRevokedTokenCollection(t)
+	ctx := NewContext(context.Background(), GetRevokedTokensCollectionKey(), mockRevokedColl)
+
+	issuedAt := time.Now().Truncate(time.Second).UTC()
+	claims := &JwtCustomClaim{UserID: "test-id", SessionID: "session-id"}
+	claims.ID = "token-id"
+	claims.IssuedAt = jwt.NewNumericDate(issuedAt)
+
+	// A user-wide marker must not cover the session it was created to spare
+	expectedFilter := bson.M{
+		"$or": []bson.M{
+			{"jti": "token-id"},
+			{
+				"user_id":           "test-id",
+				"revoked_before":    bson.M{"$gte": issuedAt},
+				"except_session_id": bson.M{"$ne": "session-id"},
+			},
+		}}
+	mockRevokedColl.On("CountDocuments", ctx, expectedFilter).Return(int64(0), nil)
+
+	revoked, err := isRevoked(ctx, claims)
+
+	require.NoError(t, err)
+	assert.False(t, revoked)
+}
@@ -2,51 +2,154 @@ package token
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
-	"strconv"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/ahummel25/user-auth-api/config"
+	"github.com/ahummel25/user-auth-api/graphql/model"
 )
 
+// ScopeChangePassword restricts an access token to changing the password of its user
+const ScopeChangePassword = "change_password"
+
 type JwtCustomClaim struct {
-	UserID string `json:"userID"`
-	jwt.StandardClaims
+	UserID    string     `json:"userID"`
+	Role      model.Role `json:"role"`
+	SessionID string     `json:"sid"`
+	// Scope restricts the token to a single action, e.g. ScopeChangePassword, or is empty for tokens
+	// permitting every action of the role
+	Scope string `json:"scope,omitempty"`
+	jwt.RegisteredClaims
 }
 
-var jwtSecret = []byte(getJwtSecret())
+var (
+	// ErrTokenInvalid is returned for access tokens which fail parsing, signature or claims validation
+	ErrTokenInvalid = errors.New("token is invalid")
+	// ErrTokenExpired is returned alongside ErrTokenInvalid for otherwise valid tokens past their expiry
+	ErrTokenExpired = jwt.ErrTokenExpired
 
-func getJwtSecret() string {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		return "aSecret"
+	errUnknownSigningKey = errors.New("token is signed with an unknown key")
+)
+
+// Validate requires the claims every access token is issued with. It runs after the registered
+// claims have been validated.
+func (c *JwtCustomClaim) Validate() error {
+	switch {
+	case c.ID == "":
+		return errors.New("token has no jti")
+	case c.Subject == "" || c.Subject != c.UserID:
+		return errors.New("token subject does not match its user")
+	case c.IssuedAt == nil:
+		return errors.New("token has no iat")
+	case c.NotBefore == nil:
+		return errors.New("token has no nbf")
 	}
-	return secret
+	return nil
 }
 
-func JwtGenerate(ctx context.Context, userID uint64) (string, error) {
-	t := jwt.NewWithClaims(jwt.SigningMethodHS256, &JwtCustomClaim{
-		UserID: strconv.FormatUint(userID, 10),
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 72).Unix(),
-			IssuedAt:  time.Now().Unix(),
-		},
-	})
+// JwtGenerate signs a new access token for the given user and session and returns it along with its expiry
+func JwtGenerate(ctx context.Context, userID string, role model.Role, sessionID string) (string, time.Time, error) {
+	return signAccessToken(ctx, JwtCustomClaim{UserID: userID, Role: role, SessionID: sessionID})
+}
+
+// JwtGenerateRestricted signs a new access token for the given user which only permits the action
+// of the given scope, and returns it along with its expiry. It belongs to no session, as no refresh
+// token is issued with it.
+func JwtGenerateRestricted(ctx context.Context, userID string, role model.Role, scope string) (string, time.Time, error) {
+	return signAccessToken(ctx, JwtCustomClaim{UserID: userID, Role: role, Scope: scope})
+}
+
+// Helper function to sign an access token with the given custom claims, adding the registered ones
+func signAccessToken(ctx context.Context, claims JwtCustomClaim) (string, time.Time, error) {
+	configSupplier, err := config.FromContext(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	cfg, err := configSupplier.GetConfig()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	ks, err := getKeySet(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now := time.Now().UTC()
+	key, err := ks.signer(now)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := now.Add(cfg.AccessTokenTTL)
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        uuid.New().String(),
+		Issuer:    cfg.JWTIssuer,
+		Subject:   claims.UserID,
+		Audience:  cfg.JWTAudience,
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		NotBefore: jwt.NewNumericDate(now),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}
+	t := jwt.NewWithClaims(key.method, &claims)
 
-	token, err := t.SignedString(jwtSecret)
+	t.Header["kid"] = key.id
+
+	token, err := t.SignedString(key.private)
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
 
-	return token, nil
+	return token, time.Unix(expiresAt.Unix(), 0).UTC(), nil
 }
 
+// JwtValidate parses and verifies the given access token against the published signing keys and
+// the configured issuer, audience and algorithms, rejecting tokens which have been revoked
 func JwtValidate(ctx context.Context, token string) (*jwt.Token, error) {
-	return jwt.ParseWithClaims(token, &JwtCustomClaim{}, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("there's a problem with the signing method")
+	configSupplier, err := config.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := configSupplier.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	ks, err := getKeySet(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(cfg.JWTAllowedAlgorithms),
+		jwt.WithIssuer(cfg.JWTIssuer),
+		jwt.WithAudience(cfg.JWTAudience...),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	parsed, err := parser.ParseWithClaims(token, &JwtCustomClaim{}, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := ks.verifier(kid, time.Now())
+		if !ok {
+			return nil, errUnknownSigningKey
 		}
-		return jwtSecret, nil
+		// Only accept the algorithm the key was configured for
+		if t.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %q for key %q", t.Method.Alg(), kid)
+		}
+		return key.private.Public(), nil
 	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrTokenInvalid, err)
+	}
+
+	revoked, err := isRevoked(ctx, parsed.Claims.(*JwtCustomClaim))
+	if err != nil {
+		return nil, err
+	} else if revoked {
+		return nil, ErrTokenRevoked
+	}
+	return parsed, nil
 }
@@ -0,0 +1,225 @@
+package token
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ahummel25/user-auth-api/graphql/model"
+	tokenMocks "github.com/ahummel25/user-auth-api/service/token/mocks"
+)
+
+func TestJwtGenerate(t *testing.T) {
+	mockRevokedColl := tokenMocks.NewMockRevokedTokenCollection(t)
+	ctx := NewContext(context.Background(), GetRevokedTokensCollectionKey(), mockRevokedColl)
+	mockRevokedColl.On("CountDocuments", ctx, mock.AnythingOfType("bson.M")).Return(int64(0), nil)
+	userID := "dfb8fe7f-56e4-47dc-b5bc-f6f0f524402b"
+
+	sessionID := NewFamilyID()
+
+	signed, expiresAt, err := JwtGenerate(ctx, userID, model.RoleAdmin, sessionID)
+	require.NoError(t, err)
+	assert.NotEmpty(t, signed)
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), expiresAt, 5*time.Second)
+
+	parsed, err := JwtValidate(ctx, signed)
+	require.NoError(t, err)
+	assert.True(t, parsed.Valid)
+
+	claims, ok := parsed.Claims.(*JwtCustomClaim)
+	require.True(t, ok)
+	assert.Equal(t, userID, claims.UserID)
+	assert.Equal(t, userID, claims.Subject)
+	assert.Equal(t, model.RoleAdmin, claims.Role)
+	assert.Equal(t, sessionID, claims.SessionID)
+	assert.NotEmpty(t, claims.ID)
+	assert.Equal(t, "user-auth-api", claims.Issuer)
+	assert.Equal(t, jwt.ClaimStrings{"user-auth-api"}, claims.Audience)
+	assert.Equal(t, expiresAt, claims.ExpiresAt.UTC())
+	assert.Equal(t, claims.IssuedAt, claims.NotBefore)
+	assert.Equal(t, testKey.id, parsed.Header["kid"])
+	assert.Equal(t, "ES256", parsed.Method.Alg())
+	assert.Empty(t, claims.Scope)
+}
+
+func TestJwtGenerateRestricted(t *testing.T) {
+	mockRevokedColl := tokenMocks.NewMockRevokedTokenCollection(t)
+	ctx := NewContext(context.Background(), GetRevokedTokensCollectionKey(), mockRevokedColl)
+	mockRevokedColl.On("CountDocuments", ctx, mock.AnythingOfType("bson.M")).Return(int64(0), nil)
+	userID := "dfb8fe7f-56e4-47dc-b5bc-f6f0f524402b"
+
+	signed, expiresAt, err := JwtGenerateRestricted(ctx, userID, model.RoleUser, ScopeChangePassword)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), expiresAt, 5*time.Second)
+
+	parsed, err := JwtValidate(ctx, signed)
+	require.NoError(t, err)
+	claims, ok := parsed.Claims.(*JwtCustomClaim)
+	require.True(t, ok)
+	assert.Equal(t, userID, claims.Subject)
+	assert.Equal(t, ScopeChangePassword, claims.Scope)
+	assert.Empty(t, claims.SessionID)
+}
+
+// validClaims returns the claims of an access token which passes validation
+func validClaims() *JwtCustomClaim {
+	now := time.Now()
+	return &JwtCustomClaim{
+		UserID: "test-id",
+		Role:   model.RoleUser,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "token-id",
+			Issuer:    "user-auth-api",
+			Subject:   "test-id",
+			Audience:  jwt.ClaimStrings{"user-auth-api"},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+}
+
+// signTestToken signs the claims with the test key after applying the given modification
+func signTestToken(t *testing.T, modify func(claims *JwtCustomClaim)) string {
+	t.Helper()
+	claims := validClaims()
+	modify(claims)
+	unsigned := jwt.NewWithClaims(testKey.method, claims)
+	unsigned.Header["kid"] = testKey.id
+	signed, err := unsigned.SignedString(testKey.private)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestJwtValidate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("invalid claims", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			modify func(claims *JwtCustomClaim)
+		}{
+			{name: "wrong issuer", modify: func(c *JwtCustomClaim) { c.Issuer = "https://evil.example.com" }},
+			{name: "missing issuer", modify: func(c *JwtCustomClaim) { c.Issuer = "" }},
+			{name: "wrong audience", modify: func(c *JwtCustomClaim) { c.Audience = jwt.ClaimStrings{"orders-api"} }},
+			{name: "missing audience", modify: func(c *JwtCustomClaim) { c.Audience = nil }},
+			{name: "not yet valid", modify: func(c *JwtCustomClaim) { c.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Hour)) }},
+			{name: "missing nbf", modify: func(c *JwtCustomClaim) { c.NotBefore = nil }},
+			{name: "missing exp", modify: func(c *JwtCustomClaim) { c.ExpiresAt = nil }},
+			{name: "issued in the future", modify: func(c *JwtCustomClaim) { c.IssuedAt = jwt.NewNumericDate(time.Now().Add(time.Hour)) }},
+			{name: "missing iat", modify: func(c *JwtCustomClaim) { c.IssuedAt = nil }},
+			{name: "missing jti", modify: func(c *JwtCustomClaim) { c.ID = "" }},
+			{name: "subject mismatch", modify: func(c *JwtCustomClaim) { c.Subject = "other-id" }},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				parsed, err := JwtValidate(ctx, signTestToken(t, tt.modify))
+				assert.Nil(t, parsed)
+				assert.ErrorIs(t, err, ErrTokenInvalid)
+			})
+		}
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		signed := signTestToken(t, func(c *JwtCustomClaim) {
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+		})
+
+		_, err := JwtValidate(ctx, signed)
+		assert.ErrorIs(t, err, ErrTokenInvalid)
+		assert.ErrorIs(t, err, ErrTokenExpired)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		forged := jwt.NewWithClaims(jwt.SigningMethodES256, validClaims())
+		forged.Header["kid"] = testKey.id
+		signed, err := forged.SignedString(otherKey)
+		require.NoError(t, err)
+
+		_, err = JwtValidate(ctx, signed)
+		assert.ErrorIs(t, err, ErrTokenInvalid)
+		assert.NotErrorIs(t, err, ErrTokenExpired)
+	})
+
+	t.Run("unknown key ID", func(t *testing.T) {
+		unknown := jwt.NewWithClaims(testKey.method, validClaims())
+		unknown.Header["kid"] = "unknown"
+		signed, err := unknown.SignedString(testKey.private)
+		require.NoError(t, err)
+
+		_, err = JwtValidate(ctx, signed)
+		assert.ErrorIs(t, err, errUnknownSigningKey)
+	})
+
+	t.Run("symmetric algorithm", func(t *testing.T) {
+		// The public key must never be usable as an HMAC secret
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims())
+		forged.Header["kid"] = testKey.id
+		signed, err := forged.SignedString([]byte("aSecret"))
+		require.NoError(t, err)
+
+		_, err = JwtValidate(ctx, signed)
+		assert.ErrorIs(t, err, jwt.ErrTokenSignatureInvalid)
+	})
+
+	t.Run("unsigned token", func(t *testing.T) {
+		unsigned := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims())
+		unsigned.Header["kid"] = testKey.id
+		signed, err := unsigned.SignedString(jwt.UnsafeAllowNoneSignatureType)
+		require.NoError(t, err)
+
+		_, err = JwtValidate(ctx, signed)
+		assert.ErrorIs(t, err, ErrTokenInvalid)
+	})
+
+	t.Run("algorithm not matching the key", func(t *testing.T) {
+		_, edKey, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		forged := jwt.NewWithClaims(jwt.SigningMethodEdDSA, validClaims())
+		forged.Header["kid"] = testKey.id
+		signed, err := forged.SignedString(edKey)
+		require.NoError(t, err)
+
+		_, err = JwtValidate(ctx, signed)
+		assert.ErrorIs(t, err, ErrTokenInvalid)
+	})
+
+	t.Run("malformed token", func(t *testing.T) {
+		_, err := JwtValidate(ctx, "not-a-token")
+		assert.Error(t, err)
+	})
+
+	t.Run("revoked token", func(t *testing.T) {
+		mockRevokedColl := tokenMocks.NewMockRevokedTokenCollection(t)
+		ctx := NewContext(ctx, GetRevokedTokensCollectionKey(), mockRevokedColl)
+
+		signed, _, err := JwtGenerate(ctx, "test-id", model.RoleUser, NewFamilyID())
+		require.NoError(t, err)
+		mockRevokedColl.On("CountDocuments", ctx, mock.AnythingOfType("bson.M")).Return(int64(1), nil)
+
+		parsed, err := JwtValidate(ctx, signed)
+		assert.Nil(t, parsed)
+		assert.ErrorIs(t, err, ErrTokenRevoked)
+	})
+
+	t.Run("revocation lookup fails", func(t *testing.T) {
+		signed, _, err := JwtGenerate(ctx, "test-id", model.RoleUser, NewFamilyID())
+		require.NoError(t, err)
+
+		// Without a revocation store the token cannot be trusted
+		parsed, err := JwtValidate(ctx, signed)
+		assert.Nil(t, parsed)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,91 @@
+package user
+
+import (
+	"encoding/base64"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"github.com/ahummel25/user-auth-api/graphql/model"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// userSortFields maps each sortable field to its document field
+var userSortFields = map[model.UserSortField]string{
+	model.UserSortFieldCreationDate:  "creation_date",
+	model.UserSortFieldEmail:         "email",
+	model.UserSortFieldUserName:      "user_name",
+	model.UserSortFieldLastLoginDate: "last_login_date",
+}
+
+// userCursor identifies a position in a sorted list of users. The user ID breaks ties between
+// users sharing the same sort value, and the sort is recorded so that a cursor cannot be reused
+// with a different order.
+type userCursor struct {
+	Field     model.UserSortField `bson:"f"`
+	Direction model.SortDirection `bson:"d"`
+	Value     bson.RawValue       `bson:"v"`
+	UserID    string              `bson:"id"`
+}
+
+// Helper function to build the opaque cursor of a user document within the given sort
+func encodeUserCursor(sort model.UserSort, doc bson.Raw) (string, error) {
+	value, err := doc.LookupErr(userSortFields[sort.Field])
+	if err != nil {
+		// Documents without the field sort as null
+		value = bson.RawValue{Type: bson.TypeNull}
+	}
+	userID, ok := doc.Lookup("user_id").StringValueOK()
+	if !ok {
+		return "", errors.New("user document has no user_id")
+	}
+	raw, err := bson.Marshal(userCursor{Field: sort.Field, Direction: *sort.Direction, Value: value, UserID: userID})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// Helper function to decode a cursor, rejecting cursors from a different sort
+func decodeUserCursor(sort model.UserSort, cursor string) (*userCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var c userCursor
+	if err = bson.Unmarshal(raw, &c); err != nil || c.UserID == "" {
+		return nil, errInvalidCursor
+	}
+	if c.Field != sort.Field || c.Direction != *sort.Direction {
+		return nil, errInvalidCursor
+	}
+	return &c, nil
+}
+
+// afterCursorFilter matches the users which sort after the cursor. Mongo sorts missing and null
+// values before any other value, and range operators never match null, so positions among the
+// nulls are compared by user ID alone.
+func afterCursorFilter(sort model.UserSort, c *userCursor) bson.M {
+	field := userSortFields[sort.Field]
+	op := "$gt"
+	if *sort.Direction == model.SortDirectionDesc {
+		op = "$lt"
+	}
+	tieBreak := bson.M{field: c.Value, "user_id": bson.M{op: c.UserID}}
+
+	if c.Value.Type == bson.TypeNull {
+		tieBreak[field] = nil
+		if *sort.Direction == model.SortDirectionDesc {
+			// Nulls come last in descending order
+			return tieBreak
+		}
+		return bson.M{"$or": []bson.M{tieBreak, {field: bson.M{"$ne": nil}}}}
+	}
+
+	after := []bson.M{{field: bson.M{op: c.Value}}, tieBreak}
+	if *sort.Direction == model.SortDirectionDesc {
+		after = append(after, bson.M{field: nil})
+	}
+	return bson.M{"$or": after}
+}
@@ -8,6 +8,7 @@ import (
 	"context"
 
 	"github.com/ahummel25/user-auth-api/graphql/model"
+	"github.com/ahummel25/user-auth-api/service/token"
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -38,55 +39,1537 @@ func (_m *MockAPI) EXPECT() *MockAPI_Expecter {
 	return &MockAPI_Expecter{mock: &_m.Mock}
 }
 
+// BeginPasskeyLogin provides a mock function for the type MockAPI
+func (_mock *MockAPI) BeginPasskeyLogin(ctx context.Context) (string, error) {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for BeginPasskeyLogin")
+	}
+
+	var r0 string
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) (string, error)); ok {
+		return returnFunc(ctx)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context) string); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = returnFunc(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_BeginPasskeyLogin_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'BeginPasskeyLogin'
+type MockAPI_BeginPasskeyLogin_Call struct {
+	*mock.Call
+}
+
+// BeginPasskeyLogin is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockAPI_Expecter) BeginPasskeyLogin(ctx interface{}) *MockAPI_BeginPasskeyLogin_Call {
+	return &MockAPI_BeginPasskeyLogin_Call{Call: _e.mock.On("BeginPasskeyLogin", ctx)}
+}
+
+func (_c *MockAPI_BeginPasskeyLogin_Call) Run(run func(ctx context.Context)) *MockAPI_BeginPasskeyLogin_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_BeginPasskeyLogin_Call) Return(s string, err error) *MockAPI_BeginPasskeyLogin_Call {
+	_c.Call.Return(s, err)
+	return _c
+}
+
+func (_c *MockAPI_BeginPasskeyLogin_Call) RunAndReturn(run func(ctx context.Context) (string, error)) *MockAPI_BeginPasskeyLogin_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// BeginPasskeyRegistration provides a mock function for the type MockAPI
+func (_mock *MockAPI) BeginPasskeyRegistration(ctx context.Context, userID string, currentPassword string, code *string) (string, error) {
+	ret := _mock.Called(ctx, userID, currentPassword, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for BeginPasskeyRegistration")
+	}
+
+	var r0 string
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string, *string) (string, error)); ok {
+		return returnFunc(ctx, userID, currentPassword, code)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string, *string) string); ok {
+		r0 = returnFunc(ctx, userID, currentPassword, code)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string, *string) error); ok {
+		r1 = returnFunc(ctx, userID, currentPassword, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_BeginPasskeyRegistration_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'BeginPasskeyRegistration'
+type MockAPI_BeginPasskeyRegistration_Call struct {
+	*mock.Call
+}
+
+// BeginPasskeyRegistration is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID string
+//   - currentPassword string
+//   - code *string
+func (_e *MockAPI_Expecter) BeginPasskeyRegistration(ctx interface{}, userID interface{}, currentPassword interface{}, code interface{}) *MockAPI_BeginPasskeyRegistration_Call {
+	return &MockAPI_BeginPasskeyRegistration_Call{Call: _e.mock.On("BeginPasskeyRegistration", ctx, userID, currentPassword, code)}
+}
+
+func (_c *MockAPI_BeginPasskeyRegistration_Call) Run(run func(ctx context.Context, userID string, currentPassword string, code *string)) *MockAPI_BeginPasskeyRegistration_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		var arg3 *string
+		if args[3] != nil {
+			arg3 = args[3].(*string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_BeginPasskeyRegistration_Call) Return(s string, err error) *MockAPI_BeginPasskeyRegistration_Call {
+	_c.Call.Return(s, err)
+	return _c
+}
+
+func (_c *MockAPI_BeginPasskeyRegistration_Call) RunAndReturn(run func(ctx context.Context, userID string, currentPassword string, code *string) (string, error)) *MockAPI_BeginPasskeyRegistration_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ChangePassword provides a mock function for the type MockAPI
+func (_mock *MockAPI) ChangePassword(ctx context.Context, claims *token.JwtCustomClaim, currentPassword string, newPassword string) (bool, error) {
+	ret := _mock.Called(ctx, claims, currentPassword, newPassword)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ChangePassword")
+	}
+
+	var r0 bool
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *token.JwtCustomClaim, string, string) (bool, error)); ok {
+		return returnFunc(ctx, claims, currentPassword, newPassword)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *token.JwtCustomClaim, string, string) bool); ok {
+		r0 = returnFunc(ctx, claims, currentPassword, newPassword)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, *token.JwtCustomClaim, string, string) error); ok {
+		r1 = returnFunc(ctx, claims, currentPassword, newPassword)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_ChangePassword_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ChangePassword'
+type MockAPI_ChangePassword_Call struct {
+	*mock.Call
+}
+
+// ChangePassword is a helper method to define mock.On call
+//   - ctx context.Context
+//   - claims *token.JwtCustomClaim
+//   - currentPassword string
+//   - newPassword string
+func (_e *MockAPI_Expecter) ChangePassword(ctx interface{}, claims interface{}, currentPassword interface{}, newPassword interface{}) *MockAPI_ChangePassword_Call {
+	return &MockAPI_ChangePassword_Call{Call: _e.mock.On("ChangePassword", ctx, claims, currentPassword, newPassword)}
+}
+
+func (_c *MockAPI_ChangePassword_Call) Run(run func(ctx context.Context, claims *token.JwtCustomClaim, currentPassword string, newPassword string)) *MockAPI_ChangePassword_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 *token.JwtCustomClaim
+		if args[1] != nil {
+			arg1 = args[1].(*token.JwtCustomClaim)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		var arg3 string
+		if args[3] != nil {
+			arg3 = args[3].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_ChangePassword_Call) Return(b bool, err error) *MockAPI_ChangePassword_Call {
+	_c.Call.Return(b, err)
+	return _c
+}
+
+func (_c *MockAPI_ChangePassword_Call) RunAndReturn(run func(ctx context.Context, claims *token.JwtCustomClaim, currentPassword string, newPassword string) (bool, error)) *MockAPI_ChangePassword_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ConfirmTotp provides a mock function for the type MockAPI
+func (_mock *MockAPI) ConfirmTotp(ctx context.Context, userID string, code string) (bool, error) {
+	ret := _mock.Called(ctx, userID, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ConfirmTotp")
+	}
+
+	var r0 bool
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) (bool, error)); ok {
+		return returnFunc(ctx, userID, code)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) bool); ok {
+		r0 = returnFunc(ctx, userID, code)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = returnFunc(ctx, userID, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_ConfirmTotp_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ConfirmTotp'
+type MockAPI_ConfirmTotp_Call struct {
+	*mock.Call
+}
+
+// ConfirmTotp is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID string
+//   - code string
+func (_e *MockAPI_Expecter) ConfirmTotp(ctx interface{}, userID interface{}, code interface{}) *MockAPI_ConfirmTotp_Call {
+	return &MockAPI_ConfirmTotp_Call{Call: _e.mock.On("ConfirmTotp", ctx, userID, code)}
+}
+
+func (_c *MockAPI_ConfirmTotp_Call) Run(run func(ctx context.Context, userID string, code string)) *MockAPI_ConfirmTotp_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_ConfirmTotp_Call) Return(b bool, err error) *MockAPI_ConfirmTotp_Call {
+	_c.Call.Return(b, err)
+	return _c
+}
+
+func (_c *MockAPI_ConfirmTotp_Call) RunAndReturn(run func(ctx context.Context, userID string, code string) (bool, error)) *MockAPI_ConfirmTotp_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // CreateUser provides a mock function for the type MockAPI
 func (_mock *MockAPI) CreateUser(ctx context.Context, params model.NewUserInput) (*model.UserObject, error) {
 	ret := _mock.Called(ctx, params)
 
 	if len(ret) == 0 {
-		panic("no return value specified for CreateUser")
+		panic("no return value specified for CreateUser")
+	}
+
+	var r0 *model.UserObject
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, model.NewUserInput) (*model.UserObject, error)); ok {
+		return returnFunc(ctx, params)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, model.NewUserInput) *model.UserObject); ok {
+		r0 = returnFunc(ctx, params)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UserObject)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, model.NewUserInput) error); ok {
+		r1 = returnFunc(ctx, params)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_CreateUser_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateUser'
+type MockAPI_CreateUser_Call struct {
+	*mock.Call
+}
+
+// CreateUser is a helper method to define mock.On call
+//   - ctx context.Context
+//   - params model.NewUserInput
+func (_e *MockAPI_Expecter) CreateUser(ctx interface{}, params interface{}) *MockAPI_CreateUser_Call {
+	return &MockAPI_CreateUser_Call{Call: _e.mock.On("CreateUser", ctx, params)}
+}
+
+func (_c *MockAPI_CreateUser_Call) Run(run func(ctx context.Context, params model.NewUserInput)) *MockAPI_CreateUser_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 model.NewUserInput
+		if args[1] != nil {
+			arg1 = args[1].(model.NewUserInput)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_CreateUser_Call) Return(userObject *model.UserObject, err error) *MockAPI_CreateUser_Call {
+	_c.Call.Return(userObject, err)
+	return _c
+}
+
+func (_c *MockAPI_CreateUser_Call) RunAndReturn(run func(ctx context.Context, params model.NewUserInput) (*model.UserObject, error)) *MockAPI_CreateUser_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeletePasskey provides a mock function for the type MockAPI
+func (_mock *MockAPI) DeletePasskey(ctx context.Context, userID string, passkeyID string) (bool, error) {
+	ret := _mock.Called(ctx, userID, passkeyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeletePasskey")
+	}
+
+	var r0 bool
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) (bool, error)); ok {
+		return returnFunc(ctx, userID, passkeyID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) bool); ok {
+		r0 = returnFunc(ctx, userID, passkeyID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = returnFunc(ctx, userID, passkeyID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_DeletePasskey_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeletePasskey'
+type MockAPI_DeletePasskey_Call struct {
+	*mock.Call
+}
+
+// DeletePasskey is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID string
+//   - passkeyID string
+func (_e *MockAPI_Expecter) DeletePasskey(ctx interface{}, userID interface{}, passkeyID interface{}) *MockAPI_DeletePasskey_Call {
+	return &MockAPI_DeletePasskey_Call{Call: _e.mock.On("DeletePasskey", ctx, userID, passkeyID)}
+}
+
+func (_c *MockAPI_DeletePasskey_Call) Run(run func(ctx context.Context, userID string, passkeyID string)) *MockAPI_DeletePasskey_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_DeletePasskey_Call) Return(b bool, err error) *MockAPI_DeletePasskey_Call {
+	_c.Call.Return(b, err)
+	return _c
+}
+
+func (_c *MockAPI_DeletePasskey_Call) RunAndReturn(run func(ctx context.Context, userID string, passkeyID string) (bool, error)) *MockAPI_DeletePasskey_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeleteUser provides a mock function for the type MockAPI
+func (_mock *MockAPI) DeleteUser(ctx context.Context, userID string) (bool, error) {
+	ret := _mock.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteUser")
+	}
+
+	var r0 bool
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (bool, error)); ok {
+		return returnFunc(ctx, userID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = returnFunc(ctx, userID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_DeleteUser_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteUser'
+type MockAPI_DeleteUser_Call struct {
+	*mock.Call
+}
+
+// DeleteUser is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID string
+func (_e *MockAPI_Expecter) DeleteUser(ctx interface{}, userID interface{}) *MockAPI_DeleteUser_Call {
+	return &MockAPI_DeleteUser_Call{Call: _e.mock.On("DeleteUser", ctx, userID)}
+}
+
+func (_c *MockAPI_DeleteUser_Call) Run(run func(ctx context.Context, userID string)) *MockAPI_DeleteUser_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_DeleteUser_Call) Return(b bool, err error) *MockAPI_DeleteUser_Call {
+	_c.Call.Return(b, err)
+	return _c
+}
+
+func (_c *MockAPI_DeleteUser_Call) RunAndReturn(run func(ctx context.Context, userID string) (bool, error)) *MockAPI_DeleteUser_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// EnrollTotp provides a mock function for the type MockAPI
+func (_mock *MockAPI) EnrollTotp(ctx context.Context, userID string) (*model.TotpEnrollment, error) {
+	ret := _mock.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for EnrollTotp")
+	}
+
+	var r0 *model.TotpEnrollment
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (*model.TotpEnrollment, error)); ok {
+		return returnFunc(ctx, userID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) *model.TotpEnrollment); ok {
+		r0 = returnFunc(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.TotpEnrollment)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_EnrollTotp_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'EnrollTotp'
+type MockAPI_EnrollTotp_Call struct {
+	*mock.Call
+}
+
+// EnrollTotp is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID string
+func (_e *MockAPI_Expecter) EnrollTotp(ctx interface{}, userID interface{}) *MockAPI_EnrollTotp_Call {
+	return &MockAPI_EnrollTotp_Call{Call: _e.mock.On("EnrollTotp", ctx, userID)}
+}
+
+func (_c *MockAPI_EnrollTotp_Call) Run(run func(ctx context.Context, userID string)) *MockAPI_EnrollTotp_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_EnrollTotp_Call) Return(totpEnrollment *model.TotpEnrollment, err error) *MockAPI_EnrollTotp_Call {
+	_c.Call.Return(totpEnrollment, err)
+	return _c
+}
+
+func (_c *MockAPI_EnrollTotp_Call) RunAndReturn(run func(ctx context.Context, userID string) (*model.TotpEnrollment, error)) *MockAPI_EnrollTotp_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// FinishPasskeyRegistration provides a mock function for the type MockAPI
+func (_mock *MockAPI) FinishPasskeyRegistration(ctx context.Context, userID string, response string, name *string) (*model.Passkey, error) {
+	ret := _mock.Called(ctx, userID, response, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FinishPasskeyRegistration")
+	}
+
+	var r0 *model.Passkey
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string, *string) (*model.Passkey, error)); ok {
+		return returnFunc(ctx, userID, response, name)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string, *string) *model.Passkey); ok {
+		r0 = returnFunc(ctx, userID, response, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Passkey)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string, *string) error); ok {
+		r1 = returnFunc(ctx, userID, response, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_FinishPasskeyRegistration_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'FinishPasskeyRegistration'
+type MockAPI_FinishPasskeyRegistration_Call struct {
+	*mock.Call
+}
+
+// FinishPasskeyRegistration is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID string
+//   - response string
+//   - name *string
+func (_e *MockAPI_Expecter) FinishPasskeyRegistration(ctx interface{}, userID interface{}, response interface{}, name interface{}) *MockAPI_FinishPasskeyRegistration_Call {
+	return &MockAPI_FinishPasskeyRegistration_Call{Call: _e.mock.On("FinishPasskeyRegistration", ctx, userID, response, name)}
+}
+
+func (_c *MockAPI_FinishPasskeyRegistration_Call) Run(run func(ctx context.Context, userID string, response string, name *string)) *MockAPI_FinishPasskeyRegistration_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		var arg3 *string
+		if args[3] != nil {
+			arg3 = args[3].(*string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_FinishPasskeyRegistration_Call) Return(passkey *model.Passkey, err error) *MockAPI_FinishPasskeyRegistration_Call {
+	_c.Call.Return(passkey, err)
+	return _c
+}
+
+func (_c *MockAPI_FinishPasskeyRegistration_Call) RunAndReturn(run func(ctx context.Context, userID string, response string, name *string) (*model.Passkey, error)) *MockAPI_FinishPasskeyRegistration_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ForcePasswordReset provides a mock function for the type MockAPI
+func (_mock *MockAPI) ForcePasswordReset(ctx context.Context, userID string) (bool, error) {
+	ret := _mock.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ForcePasswordReset")
+	}
+
+	var r0 bool
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (bool, error)); ok {
+		return returnFunc(ctx, userID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = returnFunc(ctx, userID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_ForcePasswordReset_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ForcePasswordReset'
+type MockAPI_ForcePasswordReset_Call struct {
+	*mock.Call
+}
+
+// ForcePasswordReset is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID string
+func (_e *MockAPI_Expecter) ForcePasswordReset(ctx interface{}, userID interface{}) *MockAPI_ForcePasswordReset_Call {
+	return &MockAPI_ForcePasswordReset_Call{Call: _e.mock.On("ForcePasswordReset", ctx, userID)}
+}
+
+func (_c *MockAPI_ForcePasswordReset_Call) Run(run func(ctx context.Context, userID string)) *MockAPI_ForcePasswordReset_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_ForcePasswordReset_Call) Return(b bool, err error) *MockAPI_ForcePasswordReset_Call {
+	_c.Call.Return(b, err)
+	return _c
+}
+
+func (_c *MockAPI_ForcePasswordReset_Call) RunAndReturn(run func(ctx context.Context, userID string) (bool, error)) *MockAPI_ForcePasswordReset_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetUserByID provides a mock function for the type MockAPI
+func (_mock *MockAPI) GetUserByID(ctx context.Context, userID string) (*model.User, error) {
+	ret := _mock.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUserByID")
+	}
+
+	var r0 *model.User
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (*model.User, error)); ok {
+		return returnFunc(ctx, userID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) *model.User); ok {
+		r0 = returnFunc(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.User)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_GetUserByID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetUserByID'
+type MockAPI_GetUserByID_Call struct {
+	*mock.Call
+}
+
+// GetUserByID is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID string
+func (_e *MockAPI_Expecter) GetUserByID(ctx interface{}, userID interface{}) *MockAPI_GetUserByID_Call {
+	return &MockAPI_GetUserByID_Call{Call: _e.mock.On("GetUserByID", ctx, userID)}
+}
+
+func (_c *MockAPI_GetUserByID_Call) Run(run func(ctx context.Context, userID string)) *MockAPI_GetUserByID_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_GetUserByID_Call) Return(user *model.User, err error) *MockAPI_GetUserByID_Call {
+	_c.Call.Return(user, err)
+	return _c
+}
+
+func (_c *MockAPI_GetUserByID_Call) RunAndReturn(run func(ctx context.Context, userID string) (*model.User, error)) *MockAPI_GetUserByID_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListPasskeys provides a mock function for the type MockAPI
+func (_mock *MockAPI) ListPasskeys(ctx context.Context, userID string) ([]*model.Passkey, error) {
+	ret := _mock.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListPasskeys")
+	}
+
+	var r0 []*model.Passkey
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) ([]*model.Passkey, error)); ok {
+		return returnFunc(ctx, userID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) []*model.Passkey); ok {
+		r0 = returnFunc(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Passkey)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_ListPasskeys_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListPasskeys'
+type MockAPI_ListPasskeys_Call struct {
+	*mock.Call
+}
+
+// ListPasskeys is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID string
+func (_e *MockAPI_Expecter) ListPasskeys(ctx interface{}, userID interface{}) *MockAPI_ListPasskeys_Call {
+	return &MockAPI_ListPasskeys_Call{Call: _e.mock.On("ListPasskeys", ctx, userID)}
+}
+
+func (_c *MockAPI_ListPasskeys_Call) Run(run func(ctx context.Context, userID string)) *MockAPI_ListPasskeys_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_ListPasskeys_Call) Return(passkeys []*model.Passkey, err error) *MockAPI_ListPasskeys_Call {
+	_c.Call.Return(passkeys, err)
+	return _c
+}
+
+func (_c *MockAPI_ListPasskeys_Call) RunAndReturn(run func(ctx context.Context, userID string) ([]*model.Passkey, error)) *MockAPI_ListPasskeys_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListUsers provides a mock function for the type MockAPI
+func (_mock *MockAPI) ListUsers(ctx context.Context, filter *model.UserFilter, sort *model.UserSort, first int, after *string) (*model.UserConnection, error) {
+	ret := _mock.Called(ctx, filter, sort, first, after)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListUsers")
+	}
+
+	var r0 *model.UserConnection
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *model.UserFilter, *model.UserSort, int, *string) (*model.UserConnection, error)); ok {
+		return returnFunc(ctx, filter, sort, first, after)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *model.UserFilter, *model.UserSort, int, *string) *model.UserConnection); ok {
+		r0 = returnFunc(ctx, filter, sort, first, after)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UserConnection)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, *model.UserFilter, *model.UserSort, int, *string) error); ok {
+		r1 = returnFunc(ctx, filter, sort, first, after)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_ListUsers_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListUsers'
+type MockAPI_ListUsers_Call struct {
+	*mock.Call
+}
+
+// ListUsers is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filter *model.UserFilter
+//   - sort *model.UserSort
+//   - first int
+//   - after *string
+func (_e *MockAPI_Expecter) ListUsers(ctx interface{}, filter interface{}, sort interface{}, first interface{}, after interface{}) *MockAPI_ListUsers_Call {
+	return &MockAPI_ListUsers_Call{Call: _e.mock.On("ListUsers", ctx, filter, sort, first, after)}
+}
+
+func (_c *MockAPI_ListUsers_Call) Run(run func(ctx context.Context, filter *model.UserFilter, sort *model.UserSort, first int, after *string)) *MockAPI_ListUsers_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 *model.UserFilter
+		if args[1] != nil {
+			arg1 = args[1].(*model.UserFilter)
+		}
+		var arg2 *model.UserSort
+		if args[2] != nil {
+			arg2 = args[2].(*model.UserSort)
+		}
+		var arg3 int
+		if args[3] != nil {
+			arg3 = args[3].(int)
+		}
+		var arg4 *string
+		if args[4] != nil {
+			arg4 = args[4].(*string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+			arg4,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_ListUsers_Call) Return(userConnection *model.UserConnection, err error) *MockAPI_ListUsers_Call {
+	_c.Call.Return(userConnection, err)
+	return _c
+}
+
+func (_c *MockAPI_ListUsers_Call) RunAndReturn(run func(ctx context.Context, filter *model.UserFilter, sort *model.UserSort, first int, after *string) (*model.UserConnection, error)) *MockAPI_ListUsers_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Login provides a mock function for the type MockAPI
+func (_mock *MockAPI) Login(ctx context.Context, usernameOrEmail string, password string) (*model.AuthPayload, error) {
+	ret := _mock.Called(ctx, usernameOrEmail, password)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Login")
+	}
+
+	var r0 *model.AuthPayload
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) (*model.AuthPayload, error)); ok {
+		return returnFunc(ctx, usernameOrEmail, password)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) *model.AuthPayload); ok {
+		r0 = returnFunc(ctx, usernameOrEmail, password)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.AuthPayload)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = returnFunc(ctx, usernameOrEmail, password)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_Login_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Login'
+type MockAPI_Login_Call struct {
+	*mock.Call
+}
+
+// Login is a helper method to define mock.On call
+//   - ctx context.Context
+//   - usernameOrEmail string
+//   - password string
+func (_e *MockAPI_Expecter) Login(ctx interface{}, usernameOrEmail interface{}, password interface{}) *MockAPI_Login_Call {
+	return &MockAPI_Login_Call{Call: _e.mock.On("Login", ctx, usernameOrEmail, password)}
+}
+
+func (_c *MockAPI_Login_Call) Run(run func(ctx context.Context, usernameOrEmail string, password string)) *MockAPI_Login_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_Login_Call) Return(authPayload *model.AuthPayload, err error) *MockAPI_Login_Call {
+	_c.Call.Return(authPayload, err)
+	return _c
+}
+
+func (_c *MockAPI_Login_Call) RunAndReturn(run func(ctx context.Context, usernameOrEmail string, password string) (*model.AuthPayload, error)) *MockAPI_Login_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// LoginWithPasskey provides a mock function for the type MockAPI
+func (_mock *MockAPI) LoginWithPasskey(ctx context.Context, response string) (*model.AuthPayload, error) {
+	ret := _mock.Called(ctx, response)
+
+	if len(ret) == 0 {
+		panic("no return value specified for LoginWithPasskey")
+	}
+
+	var r0 *model.AuthPayload
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (*model.AuthPayload, error)); ok {
+		return returnFunc(ctx, response)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) *model.AuthPayload); ok {
+		r0 = returnFunc(ctx, response)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.AuthPayload)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, response)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_LoginWithPasskey_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'LoginWithPasskey'
+type MockAPI_LoginWithPasskey_Call struct {
+	*mock.Call
+}
+
+// LoginWithPasskey is a helper method to define mock.On call
+//   - ctx context.Context
+//   - response string
+func (_e *MockAPI_Expecter) LoginWithPasskey(ctx interface{}, response interface{}) *MockAPI_LoginWithPasskey_Call {
+	return &MockAPI_LoginWithPasskey_Call{Call: _e.mock.On("LoginWithPasskey", ctx, response)}
+}
+
+func (_c *MockAPI_LoginWithPasskey_Call) Run(run func(ctx context.Context, response string)) *MockAPI_LoginWithPasskey_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_LoginWithPasskey_Call) Return(authPayload *model.AuthPayload, err error) *MockAPI_LoginWithPasskey_Call {
+	_c.Call.Return(authPayload, err)
+	return _c
+}
+
+func (_c *MockAPI_LoginWithPasskey_Call) RunAndReturn(run func(ctx context.Context, response string) (*model.AuthPayload, error)) *MockAPI_LoginWithPasskey_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Logout provides a mock function for the type MockAPI
+func (_mock *MockAPI) Logout(ctx context.Context, claims *token.JwtCustomClaim) (bool, error) {
+	ret := _mock.Called(ctx, claims)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Logout")
+	}
+
+	var r0 bool
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *token.JwtCustomClaim) (bool, error)); ok {
+		return returnFunc(ctx, claims)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *token.JwtCustomClaim) bool); ok {
+		r0 = returnFunc(ctx, claims)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, *token.JwtCustomClaim) error); ok {
+		r1 = returnFunc(ctx, claims)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_Logout_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Logout'
+type MockAPI_Logout_Call struct {
+	*mock.Call
+}
+
+// Logout is a helper method to define mock.On call
+//   - ctx context.Context
+//   - claims *token.JwtCustomClaim
+func (_e *MockAPI_Expecter) Logout(ctx interface{}, claims interface{}) *MockAPI_Logout_Call {
+	return &MockAPI_Logout_Call{Call: _e.mock.On("Logout", ctx, claims)}
+}
+
+func (_c *MockAPI_Logout_Call) Run(run func(ctx context.Context, claims *token.JwtCustomClaim)) *MockAPI_Logout_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 *token.JwtCustomClaim
+		if args[1] != nil {
+			arg1 = args[1].(*token.JwtCustomClaim)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_Logout_Call) Return(b bool, err error) *MockAPI_Logout_Call {
+	_c.Call.Return(b, err)
+	return _c
+}
+
+func (_c *MockAPI_Logout_Call) RunAndReturn(run func(ctx context.Context, claims *token.JwtCustomClaim) (bool, error)) *MockAPI_Logout_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RefreshToken provides a mock function for the type MockAPI
+func (_mock *MockAPI) RefreshToken(ctx context.Context, refreshToken string) (*model.AuthPayload, error) {
+	ret := _mock.Called(ctx, refreshToken)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RefreshToken")
+	}
+
+	var r0 *model.AuthPayload
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (*model.AuthPayload, error)); ok {
+		return returnFunc(ctx, refreshToken)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) *model.AuthPayload); ok {
+		r0 = returnFunc(ctx, refreshToken)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.AuthPayload)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, refreshToken)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_RefreshToken_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RefreshToken'
+type MockAPI_RefreshToken_Call struct {
+	*mock.Call
+}
+
+// RefreshToken is a helper method to define mock.On call
+//   - ctx context.Context
+//   - refreshToken string
+func (_e *MockAPI_Expecter) RefreshToken(ctx interface{}, refreshToken interface{}) *MockAPI_RefreshToken_Call {
+	return &MockAPI_RefreshToken_Call{Call: _e.mock.On("RefreshToken", ctx, refreshToken)}
+}
+
+func (_c *MockAPI_RefreshToken_Call) Run(run func(ctx context.Context, refreshToken string)) *MockAPI_RefreshToken_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_RefreshToken_Call) Return(authPayload *model.AuthPayload, err error) *MockAPI_RefreshToken_Call {
+	_c.Call.Return(authPayload, err)
+	return _c
+}
+
+func (_c *MockAPI_RefreshToken_Call) RunAndReturn(run func(ctx context.Context, refreshToken string) (*model.AuthPayload, error)) *MockAPI_RefreshToken_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RegenerateRecoveryCodes provides a mock function for the type MockAPI
+func (_mock *MockAPI) RegenerateRecoveryCodes(ctx context.Context, userID string, code string) ([]string, error) {
+	ret := _mock.Called(ctx, userID, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RegenerateRecoveryCodes")
+	}
+
+	var r0 []string
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) ([]string, error)); ok {
+		return returnFunc(ctx, userID, code)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) []string); ok {
+		r0 = returnFunc(ctx, userID, code)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = returnFunc(ctx, userID, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_RegenerateRecoveryCodes_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RegenerateRecoveryCodes'
+type MockAPI_RegenerateRecoveryCodes_Call struct {
+	*mock.Call
+}
+
+// RegenerateRecoveryCodes is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID string
+//   - code string
+func (_e *MockAPI_Expecter) RegenerateRecoveryCodes(ctx interface{}, userID interface{}, code interface{}) *MockAPI_RegenerateRecoveryCodes_Call {
+	return &MockAPI_RegenerateRecoveryCodes_Call{Call: _e.mock.On("RegenerateRecoveryCodes", ctx, userID, code)}
+}
+
+func (_c *MockAPI_RegenerateRecoveryCodes_Call) Run(run func(ctx context.Context, userID string, code string)) *MockAPI_RegenerateRecoveryCodes_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_RegenerateRecoveryCodes_Call) Return(ss []string, err error) *MockAPI_RegenerateRecoveryCodes_Call {
+	_c.Call.Return(ss, err)
+	return _c
+}
+
+func (_c *MockAPI_RegenerateRecoveryCodes_Call) RunAndReturn(run func(ctx context.Context, userID string, code string) ([]string, error)) *MockAPI_RegenerateRecoveryCodes_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RequestPasswordReset provides a mock function for the type MockAPI
+func (_mock *MockAPI) RequestPasswordReset(ctx context.Context, email string) (bool, error) {
+	ret := _mock.Called(ctx, email)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RequestPasswordReset")
+	}
+
+	var r0 bool
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (bool, error)); ok {
+		return returnFunc(ctx, email)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = returnFunc(ctx, email)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, email)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_RequestPasswordReset_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RequestPasswordReset'
+type MockAPI_RequestPasswordReset_Call struct {
+	*mock.Call
+}
+
+// RequestPasswordReset is a helper method to define mock.On call
+//   - ctx context.Context
+//   - email string
+func (_e *MockAPI_Expecter) RequestPasswordReset(ctx interface{}, email interface{}) *MockAPI_RequestPasswordReset_Call {
+	return &MockAPI_RequestPasswordReset_Call{Call: _e.mock.On("RequestPasswordReset", ctx, email)}
+}
+
+func (_c *MockAPI_RequestPasswordReset_Call) Run(run func(ctx context.Context, email string)) *MockAPI_RequestPasswordReset_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_RequestPasswordReset_Call) Return(b bool, err error) *MockAPI_RequestPasswordReset_Call {
+	_c.Call.Return(b, err)
+	return _c
+}
+
+func (_c *MockAPI_RequestPasswordReset_Call) RunAndReturn(run func(ctx context.Context, email string) (bool, error)) *MockAPI_RequestPasswordReset_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ResendVerification provides a mock function for the type MockAPI
+func (_mock *MockAPI) ResendVerification(ctx context.Context, email string) (bool, error) {
+	ret := _mock.Called(ctx, email)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResendVerification")
+	}
+
+	var r0 bool
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (bool, error)); ok {
+		return returnFunc(ctx, email)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = returnFunc(ctx, email)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, email)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_ResendVerification_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ResendVerification'
+type MockAPI_ResendVerification_Call struct {
+	*mock.Call
+}
+
+// ResendVerification is a helper method to define mock.On call
+//   - ctx context.Context
+//   - email string
+func (_e *MockAPI_Expecter) ResendVerification(ctx interface{}, email interface{}) *MockAPI_ResendVerification_Call {
+	return &MockAPI_ResendVerification_Call{Call: _e.mock.On("ResendVerification", ctx, email)}
+}
+
+func (_c *MockAPI_ResendVerification_Call) Run(run func(ctx context.Context, email string)) *MockAPI_ResendVerification_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_ResendVerification_Call) Return(b bool, err error) *MockAPI_ResendVerification_Call {
+	_c.Call.Return(b, err)
+	return _c
+}
+
+func (_c *MockAPI_ResendVerification_Call) RunAndReturn(run func(ctx context.Context, email string) (bool, error)) *MockAPI_ResendVerification_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ResetPassword provides a mock function for the type MockAPI
+func (_mock *MockAPI) ResetPassword(ctx context.Context, resetToken string, newPassword string) (bool, error) {
+	ret := _mock.Called(ctx, resetToken, newPassword)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResetPassword")
 	}
 
-	var r0 *model.UserObject
+	var r0 bool
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, model.NewUserInput) (*model.UserObject, error)); ok {
-		return returnFunc(ctx, params)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) (bool, error)); ok {
+		return returnFunc(ctx, resetToken, newPassword)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, model.NewUserInput) *model.UserObject); ok {
-		r0 = returnFunc(ctx, params)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) bool); ok {
+		r0 = returnFunc(ctx, resetToken, newPassword)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.UserObject)
+		r0 = ret.Get(0).(bool)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = returnFunc(ctx, resetToken, newPassword)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_ResetPassword_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ResetPassword'
+type MockAPI_ResetPassword_Call struct {
+	*mock.Call
+}
+
+// ResetPassword is a helper method to define mock.On call
+//   - ctx context.Context
+//   - resetToken string
+//   - newPassword string
+func (_e *MockAPI_Expecter) ResetPassword(ctx interface{}, resetToken interface{}, newPassword interface{}) *MockAPI_ResetPassword_Call {
+	return &MockAPI_ResetPassword_Call{Call: _e.mock.On("ResetPassword", ctx, resetToken, newPassword)}
+}
+
+func (_c *MockAPI_ResetPassword_Call) Run(run func(ctx context.Context, resetToken string, newPassword string)) *MockAPI_ResetPassword_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
 		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_ResetPassword_Call) Return(b bool, err error) *MockAPI_ResetPassword_Call {
+	_c.Call.Return(b, err)
+	return _c
+}
+
+func (_c *MockAPI_ResetPassword_Call) RunAndReturn(run func(ctx context.Context, resetToken string, newPassword string) (bool, error)) *MockAPI_ResetPassword_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RevokeAllSessions provides a mock function for the type MockAPI
+func (_mock *MockAPI) RevokeAllSessions(ctx context.Context, userID string) (bool, error) {
+	ret := _mock.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeAllSessions")
 	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, model.NewUserInput) error); ok {
-		r1 = returnFunc(ctx, params)
+
+	var r0 bool
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (bool, error)); ok {
+		return returnFunc(ctx, userID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = returnFunc(ctx, userID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, userID)
 	} else {
 		r1 = ret.Error(1)
 	}
 	return r0, r1
 }
 
-// MockAPI_CreateUser_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateUser'
-type MockAPI_CreateUser_Call struct {
+// MockAPI_RevokeAllSessions_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RevokeAllSessions'
+type MockAPI_RevokeAllSessions_Call struct {
 	*mock.Call
 }
 
-// CreateUser is a helper method to define mock.On call
+// RevokeAllSessions is a helper method to define mock.On call
 //   - ctx context.Context
-//   - params model.NewUserInput
-func (_e *MockAPI_Expecter) CreateUser(ctx interface{}, params interface{}) *MockAPI_CreateUser_Call {
-	return &MockAPI_CreateUser_Call{Call: _e.mock.On("CreateUser", ctx, params)}
+//   - userID string
+func (_e *MockAPI_Expecter) RevokeAllSessions(ctx interface{}, userID interface{}) *MockAPI_RevokeAllSessions_Call {
+	return &MockAPI_RevokeAllSessions_Call{Call: _e.mock.On("RevokeAllSessions", ctx, userID)}
 }
 
-func (_c *MockAPI_CreateUser_Call) Run(run func(ctx context.Context, params model.NewUserInput)) *MockAPI_CreateUser_Call {
+func (_c *MockAPI_RevokeAllSessions_Call) Run(run func(ctx context.Context, userID string)) *MockAPI_RevokeAllSessions_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
 			arg0 = args[0].(context.Context)
 		}
-		var arg1 model.NewUserInput
+		var arg1 string
 		if args[1] != nil {
-			arg1 = args[1].(model.NewUserInput)
+			arg1 = args[1].(string)
 		}
 		run(
 			arg0,
@@ -96,22 +1579,22 @@ func (_c *MockAPI_CreateUser_Call) Run(run func(ctx context.Context, params mode
 	return _c
 }
 
-func (_c *MockAPI_CreateUser_Call) Return(userObject *model.UserObject, err error) *MockAPI_CreateUser_Call {
-	_c.Call.Return(userObject, err)
+func (_c *MockAPI_RevokeAllSessions_Call) Return(b bool, err error) *MockAPI_RevokeAllSessions_Call {
+	_c.Call.Return(b, err)
 	return _c
 }
 
-func (_c *MockAPI_CreateUser_Call) RunAndReturn(run func(ctx context.Context, params model.NewUserInput) (*model.UserObject, error)) *MockAPI_CreateUser_Call {
+func (_c *MockAPI_RevokeAllSessions_Call) RunAndReturn(run func(ctx context.Context, userID string) (bool, error)) *MockAPI_RevokeAllSessions_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// DeleteUser provides a mock function for the type MockAPI
-func (_mock *MockAPI) DeleteUser(ctx context.Context, userID string) (bool, error) {
+// UnlockUser provides a mock function for the type MockAPI
+func (_mock *MockAPI) UnlockUser(ctx context.Context, userID string) (bool, error) {
 	ret := _mock.Called(ctx, userID)
 
 	if len(ret) == 0 {
-		panic("no return value specified for DeleteUser")
+		panic("no return value specified for UnlockUser")
 	}
 
 	var r0 bool
@@ -132,19 +1615,19 @@ func (_mock *MockAPI) DeleteUser(ctx context.Context, userID string) (bool, erro
 	return r0, r1
 }
 
-// MockAPI_DeleteUser_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteUser'
-type MockAPI_DeleteUser_Call struct {
+// MockAPI_UnlockUser_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UnlockUser'
+type MockAPI_UnlockUser_Call struct {
 	*mock.Call
 }
 
-// DeleteUser is a helper method to define mock.On call
+// UnlockUser is a helper method to define mock.On call
 //   - ctx context.Context
 //   - userID string
-func (_e *MockAPI_Expecter) DeleteUser(ctx interface{}, userID interface{}) *MockAPI_DeleteUser_Call {
-	return &MockAPI_DeleteUser_Call{Call: _e.mock.On("DeleteUser", ctx, userID)}
+func (_e *MockAPI_Expecter) UnlockUser(ctx interface{}, userID interface{}) *MockAPI_UnlockUser_Call {
+	return &MockAPI_UnlockUser_Call{Call: _e.mock.On("UnlockUser", ctx, userID)}
 }
 
-func (_c *MockAPI_DeleteUser_Call) Run(run func(ctx context.Context, userID string)) *MockAPI_DeleteUser_Call {
+func (_c *MockAPI_UnlockUser_Call) Run(run func(ctx context.Context, userID string)) *MockAPI_UnlockUser_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
@@ -162,58 +1645,198 @@ func (_c *MockAPI_DeleteUser_Call) Run(run func(ctx context.Context, userID stri
 	return _c
 }
 
-func (_c *MockAPI_DeleteUser_Call) Return(b bool, err error) *MockAPI_DeleteUser_Call {
+func (_c *MockAPI_UnlockUser_Call) Return(b bool, err error) *MockAPI_UnlockUser_Call {
 	_c.Call.Return(b, err)
 	return _c
 }
 
-func (_c *MockAPI_DeleteUser_Call) RunAndReturn(run func(ctx context.Context, userID string) (bool, error)) *MockAPI_DeleteUser_Call {
+func (_c *MockAPI_UnlockUser_Call) RunAndReturn(run func(ctx context.Context, userID string) (bool, error)) *MockAPI_UnlockUser_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// Login provides a mock function for the type MockAPI
-func (_mock *MockAPI) Login(ctx context.Context, usernameOrEmail string, password string) (*model.UserObject, error) {
-	ret := _mock.Called(ctx, usernameOrEmail, password)
+// UpdateUser provides a mock function for the type MockAPI
+func (_mock *MockAPI) UpdateUser(ctx context.Context, userID string, params model.UpdateUserInput) (*model.User, error) {
+	ret := _mock.Called(ctx, userID, params)
 
 	if len(ret) == 0 {
-		panic("no return value specified for Login")
+		panic("no return value specified for UpdateUser")
 	}
 
-	var r0 *model.UserObject
+	var r0 *model.User
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) (*model.UserObject, error)); ok {
-		return returnFunc(ctx, usernameOrEmail, password)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, model.UpdateUserInput) (*model.User, error)); ok {
+		return returnFunc(ctx, userID, params)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) *model.UserObject); ok {
-		r0 = returnFunc(ctx, usernameOrEmail, password)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, model.UpdateUserInput) *model.User); ok {
+		r0 = returnFunc(ctx, userID, params)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.UserObject)
+			r0 = ret.Get(0).(*model.User)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, model.UpdateUserInput) error); ok {
+		r1 = returnFunc(ctx, userID, params)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_UpdateUser_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdateUser'
+type MockAPI_UpdateUser_Call struct {
+	*mock.Call
+}
+
+// UpdateUser is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID string
+//   - params model.UpdateUserInput
+func (_e *MockAPI_Expecter) UpdateUser(ctx interface{}, userID interface{}, params interface{}) *MockAPI_UpdateUser_Call {
+	return &MockAPI_UpdateUser_Call{Call: _e.mock.On("UpdateUser", ctx, userID, params)}
+}
+
+func (_c *MockAPI_UpdateUser_Call) Run(run func(ctx context.Context, userID string, params model.UpdateUserInput)) *MockAPI_UpdateUser_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 model.UpdateUserInput
+		if args[2] != nil {
+			arg2 = args[2].(model.UpdateUserInput)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_UpdateUser_Call) Return(user *model.User, err error) *MockAPI_UpdateUser_Call {
+	_c.Call.Return(user, err)
+	return _c
+}
+
+func (_c *MockAPI_UpdateUser_Call) RunAndReturn(run func(ctx context.Context, userID string, params model.UpdateUserInput) (*model.User, error)) *MockAPI_UpdateUser_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// VerifyEmail provides a mock function for the type MockAPI
+func (_mock *MockAPI) VerifyEmail(ctx context.Context, verificationToken string) (bool, error) {
+	ret := _mock.Called(ctx, verificationToken)
+
+	if len(ret) == 0 {
+		panic("no return value specified for VerifyEmail")
+	}
+
+	var r0 bool
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (bool, error)); ok {
+		return returnFunc(ctx, verificationToken)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = returnFunc(ctx, verificationToken)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, verificationToken)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPI_VerifyEmail_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'VerifyEmail'
+type MockAPI_VerifyEmail_Call struct {
+	*mock.Call
+}
+
+// VerifyEmail is a helper method to define mock.On call
+//   - ctx context.Context
+//   - verificationToken string
+func (_e *MockAPI_Expecter) VerifyEmail(ctx interface{}, verificationToken interface{}) *MockAPI_VerifyEmail_Call {
+	return &MockAPI_VerifyEmail_Call{Call: _e.mock.On("VerifyEmail", ctx, verificationToken)}
+}
+
+func (_c *MockAPI_VerifyEmail_Call) Run(run func(ctx context.Context, verificationToken string)) *MockAPI_VerifyEmail_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPI_VerifyEmail_Call) Return(b bool, err error) *MockAPI_VerifyEmail_Call {
+	_c.Call.Return(b, err)
+	return _c
+}
+
+func (_c *MockAPI_VerifyEmail_Call) RunAndReturn(run func(ctx context.Context, verificationToken string) (bool, error)) *MockAPI_VerifyEmail_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// VerifyMfa provides a mock function for the type MockAPI
+func (_mock *MockAPI) VerifyMfa(ctx context.Context, challenge string, code string) (*model.AuthPayload, error) {
+	ret := _mock.Called(ctx, challenge, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for VerifyMfa")
+	}
+
+	var r0 *model.AuthPayload
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) (*model.AuthPayload, error)); ok {
+		return returnFunc(ctx, challenge, code)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) *model.AuthPayload); ok {
+		r0 = returnFunc(ctx, challenge, code)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.AuthPayload)
 		}
 	}
 	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
-		r1 = returnFunc(ctx, usernameOrEmail, password)
+		r1 = returnFunc(ctx, challenge, code)
 	} else {
 		r1 = ret.Error(1)
 	}
 	return r0, r1
 }
 
-// MockAPI_Login_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Login'
-type MockAPI_Login_Call struct {
+// MockAPI_VerifyMfa_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'VerifyMfa'
+type MockAPI_VerifyMfa_Call struct {
 	*mock.Call
 }
 
-// Login is a helper method to define mock.On call
+// VerifyMfa is a helper method to define mock.On call
 //   - ctx context.Context
-//   - usernameOrEmail string
-//   - password string
-func (_e *MockAPI_Expecter) Login(ctx interface{}, usernameOrEmail interface{}, password interface{}) *MockAPI_Login_Call {
-	return &MockAPI_Login_Call{Call: _e.mock.On("Login", ctx, usernameOrEmail, password)}
+//   - challenge string
+//   - code string
+func (_e *MockAPI_Expecter) VerifyMfa(ctx interface{}, challenge interface{}, code interface{}) *MockAPI_VerifyMfa_Call {
+	return &MockAPI_VerifyMfa_Call{Call: _e.mock.On("VerifyMfa", ctx, challenge, code)}
 }
 
-func (_c *MockAPI_Login_Call) Run(run func(ctx context.Context, usernameOrEmail string, password string)) *MockAPI_Login_Call {
+func (_c *MockAPI_VerifyMfa_Call) Run(run func(ctx context.Context, challenge string, code string)) *MockAPI_VerifyMfa_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
@@ -236,12 +1859,12 @@ func (_c *MockAPI_Login_Call) Run(run func(ctx context.Context, usernameOrEmail
 	return _c
 }
 
-func (_c *MockAPI_Login_Call) Return(userObject *model.UserObject, err error) *MockAPI_Login_Call {
-	_c.Call.Return(userObject, err)
+func (_c *MockAPI_VerifyMfa_Call) Return(authPayload *model.AuthPayload, err error) *MockAPI_VerifyMfa_Call {
+	_c.Call.Return(authPayload, err)
 	return _c
 }
 
-func (_c *MockAPI_Login_Call) RunAndReturn(run func(ctx context.Context, usernameOrEmail string, password string) (*model.UserObject, error)) *MockAPI_Login_Call {
+func (_c *MockAPI_VerifyMfa_Call) RunAndReturn(run func(ctx context.Context, challenge string, code string) (*model.AuthPayload, error)) *MockAPI_VerifyMfa_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -203,6 +203,89 @@ func (_c *MockUserCollection_DeleteOne_Call) RunAndReturn(run func(ctx context.C
 	return _c
 }
 
+// Find provides a mock function for the type MockUserCollection
+func (_mock *MockUserCollection) Find(ctx context.Context, filter interface{}, opts ...options.Lister[options.FindOptions]) (*mongo.Cursor, error) {
+	var tmpRet mock.Arguments
+	if len(opts) > 0 {
+		tmpRet = _mock.Called(ctx, filter, opts)
+	} else {
+		tmpRet = _mock.Called(ctx, filter)
+	}
+	ret := tmpRet
+
+	if len(ret) == 0 {
+		panic("no return value specified for Find")
+	}
+
+	var r0 *mongo.Cursor
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, interface{}, ...options.Lister[options.FindOptions]) (*mongo.Cursor, error)); ok {
+		return returnFunc(ctx, filter, opts...)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, interface{}, ...options.Lister[options.FindOptions]) *mongo.Cursor); ok {
+		r0 = returnFunc(ctx, filter, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*mongo.Cursor)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, interface{}, ...options.Lister[options.FindOptions]) error); ok {
+		r1 = returnFunc(ctx, filter, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockUserCollection_Find_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Find'
+type MockUserCollection_Find_Call struct {
+	*mock.Call
+}
+
+// Find is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filter interface{}
+//   - opts ...options.Lister[options.FindOptions]
+func (_e *MockUserCollection_Expecter) Find(ctx interface{}, filter interface{}, opts ...interface{}) *MockUserCollection_Find_Call {
+	return &MockUserCollection_Find_Call{Call: _e.mock.On("Find",
+		append([]interface{}{ctx, filter}, opts...)...)}
+}
+
+func (_c *MockUserCollection_Find_Call) Run(run func(ctx context.Context, filter interface{}, opts ...options.Lister[options.FindOptions])) *MockUserCollection_Find_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 interface{}
+		if args[1] != nil {
+			arg1 = args[1].(interface{})
+		}
+		var arg2 []options.Lister[options.FindOptions]
+		var variadicArgs []options.Lister[options.FindOptions]
+		if len(args) > 2 {
+			variadicArgs = args[2].([]options.Lister[options.FindOptions])
+		}
+		arg2 = variadicArgs
+		run(
+			arg0,
+			arg1,
+			arg2...,
+		)
+	})
+	return _c
+}
+
+func (_c *MockUserCollection_Find_Call) Return(cursor *mongo.Cursor, err error) *MockUserCollection_Find_Call {
+	_c.Call.Return(cursor, err)
+	return _c
+}
+
+func (_c *MockUserCollection_Find_Call) RunAndReturn(run func(ctx context.Context, filter interface{}, opts ...options.Lister[options.FindOptions]) (*mongo.Cursor, error)) *MockUserCollection_Find_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // FindOne provides a mock function for the type MockUserCollection
 func (_mock *MockUserCollection) FindOne(ctx context.Context, filter interface{}, opts ...options.Lister[options.FindOneOptions]) *mongo.SingleResult {
 	var tmpRet mock.Arguments
@@ -277,6 +360,86 @@ func (_c *MockUserCollection_FindOne_Call) RunAndReturn(run func(ctx context.Con
 	return _c
 }
 
+// FindOneAndUpdate provides a mock function for the type MockUserCollection
+func (_mock *MockUserCollection) FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts ...options.Lister[options.FindOneAndUpdateOptions]) *mongo.SingleResult {
+	var tmpRet mock.Arguments
+	if len(opts) > 0 {
+		tmpRet = _mock.Called(ctx, filter, update, opts)
+	} else {
+		tmpRet = _mock.Called(ctx, filter, update)
+	}
+	ret := tmpRet
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindOneAndUpdate")
+	}
+
+	var r0 *mongo.SingleResult
+	if returnFunc, ok := ret.Get(0).(func(context.Context, interface{}, interface{}, ...options.Lister[options.FindOneAndUpdateOptions]) *mongo.SingleResult); ok {
+		r0 = returnFunc(ctx, filter, update, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*mongo.SingleResult)
+		}
+	}
+	return r0
+}
+
+// MockUserCollection_FindOneAndUpdate_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'FindOneAndUpdate'
+type MockUserCollection_FindOneAndUpdate_Call struct {
+	*mock.Call
+}
+
+// FindOneAndUpdate is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filter interface{}
+//   - update interface{}
+//   - opts ...options.Lister[options.FindOneAndUpdateOptions]
+func (_e *MockUserCollection_Expecter) FindOneAndUpdate(ctx interface{}, filter interface{}, update interface{}, opts ...interface{}) *MockUserCollection_FindOneAndUpdate_Call {
+	return &MockUserCollection_FindOneAndUpdate_Call{Call: _e.mock.On("FindOneAndUpdate",
+		append([]interface{}{ctx, filter, update}, opts...)...)}
+}
+
+func (_c *MockUserCollection_FindOneAndUpdate_Call) Run(run func(ctx context.Context, filter interface{}, update interface{}, opts ...options.Lister[options.FindOneAndUpdateOptions])) *MockUserCollection_FindOneAndUpdate_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 interface{}
+		if args[1] != nil {
+			arg1 = args[1].(interface{})
+		}
+		var arg2 interface{}
+		if args[2] != nil {
+			arg2 = args[2].(interface{})
+		}
+		var arg3 []options.Lister[options.FindOneAndUpdateOptions]
+		var variadicArgs []options.Lister[options.FindOneAndUpdateOptions]
+		if len(args) > 3 {
+			variadicArgs = args[3].([]options.Lister[options.FindOneAndUpdateOptions])
+		}
+		arg3 = variadicArgs
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3...,
+		)
+	})
+	return _c
+}
+
+func (_c *MockUserCollection_FindOneAndUpdate_Call) Return(singleResult *mongo.SingleResult) *MockUserCollection_FindOneAndUpdate_Call {
+	_c.Call.Return(singleResult)
+	return _c
+}
+
+func (_c *MockUserCollection_FindOneAndUpdate_Call) RunAndReturn(run func(ctx context.Context, filter interface{}, update interface{}, opts ...options.Lister[options.FindOneAndUpdateOptions]) *mongo.SingleResult) *MockUserCollection_FindOneAndUpdate_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // InsertOne provides a mock function for the type MockUserCollection
 func (_mock *MockUserCollection) InsertOne(ctx context.Context, document interface{}, opts ...options.Lister[options.InsertOneOptions]) (*mongo.InsertOneResult, error) {
 	var tmpRet mock.Arguments
@@ -360,6 +523,95 @@ func (_c *MockUserCollection_InsertOne_Call) RunAndReturn(run func(ctx context.C
 	return _c
 }
 
+// UpdateMany provides a mock function for the type MockUserCollection
+func (_mock *MockUserCollection) UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...options.Lister[options.UpdateManyOptions]) (*mongo.UpdateResult, error) {
+	var tmpRet mock.Arguments
+	if len(opts) > 0 {
+		tmpRet = _mock.Called(ctx, filter, update, opts)
+	} else {
+		tmpRet = _mock.Called(ctx, filter, update)
+	}
+	ret := tmpRet
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateMany")
+	}
+
+	var r0 *mongo.UpdateResult
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, interface{}, interface{}, ...options.Lister[options.UpdateManyOptions]) (*mongo.UpdateResult, error)); ok {
+		return returnFunc(ctx, filter, update, opts...)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, interface{}, interface{}, ...options.Lister[options.UpdateManyOptions]) *mongo.UpdateResult); ok {
+		r0 = returnFunc(ctx, filter, update, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*mongo.UpdateResult)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, interface{}, interface{}, ...options.Lister[options.UpdateManyOptions]) error); ok {
+		r1 = returnFunc(ctx, filter, update, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockUserCollection_UpdateMany_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdateMany'
+type MockUserCollection_UpdateMany_Call struct {
+	*mock.Call
+}
+
+// UpdateMany is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filter interface{}
+//   - update interface{}
+//   - opts ...options.Lister[options.UpdateManyOptions]
+func (_e *MockUserCollection_Expecter) UpdateMany(ctx interface{}, filter interface{}, update interface{}, opts ...interface{}) *MockUserCollection_UpdateMany_Call {
+	return &MockUserCollection_UpdateMany_Call{Call: _e.mock.On("UpdateMany",
+		append([]interface{}{ctx, filter, update}, opts...)...)}
+}
+
+func (_c *MockUserCollection_UpdateMany_Call) Run(run func(ctx context.Context, filter interface{}, update interface{}, opts ...options.Lister[options.UpdateManyOptions])) *MockUserCollection_UpdateMany_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 interface{}
+		if args[1] != nil {
+			arg1 = args[1].(interface{})
+		}
+		var arg2 interface{}
+		if args[2] != nil {
+			arg2 = args[2].(interface{})
+		}
+		var arg3 []options.Lister[options.UpdateManyOptions]
+		var variadicArgs []options.Lister[options.UpdateManyOptions]
+		if len(args) > 3 {
+			variadicArgs = args[3].([]options.Lister[options.UpdateManyOptions])
+		}
+		arg3 = variadicArgs
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3...,
+		)
+	})
+	return _c
+}
+
+func (_c *MockUserCollection_UpdateMany_Call) Return(updateResult *mongo.UpdateResult, err error) *MockUserCollection_UpdateMany_Call {
+	_c.Call.Return(updateResult, err)
+	return _c
+}
+
+func (_c *MockUserCollection_UpdateMany_Call) RunAndReturn(run func(ctx context.Context, filter interface{}, update interface{}, opts ...options.Lister[options.UpdateManyOptions]) (*mongo.UpdateResult, error)) *MockUserCollection_UpdateMany_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // UpdateOne provides a mock function for the type MockUserCollection
 func (_mock *MockUserCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...options.Lister[options.UpdateOneOptions]) (*mongo.UpdateResult, error) {
 	var tmpRet mock.Arguments
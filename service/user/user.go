@@ -7,13 +7,38 @@ import (
 
 	"github.com/ahummel25/user-auth-api/db/mongo"
 	"github.com/ahummel25/user-auth-api/graphql/model"
+	"github.com/ahummel25/user-auth-api/service/passkey"
+	"github.com/ahummel25/user-auth-api/service/token"
 )
 
 // API is the interface that wraps the methods for user operations.
 type API interface {
-	Login(ctx context.Context, usernameOrEmail string, password string) (*model.UserObject, error)
+	Login(ctx context.Context, usernameOrEmail string, password string) (*model.AuthPayload, error)
+	RefreshToken(ctx context.Context, refreshToken string) (*model.AuthPayload, error)
+	Logout(ctx context.Context, claims *token.JwtCustomClaim) (bool, error)
+	RevokeAllSessions(ctx context.Context, userID string) (bool, error)
 	CreateUser(ctx context.Context, params model.NewUserInput) (*model.UserObject, error)
 	DeleteUser(ctx context.Context, userID string) (bool, error)
+	UnlockUser(ctx context.Context, userID string) (bool, error)
+	ForcePasswordReset(ctx context.Context, userID string) (bool, error)
+	GetUserByID(ctx context.Context, userID string) (*model.User, error)
+	ListUsers(ctx context.Context, filter *model.UserFilter, sort *model.UserSort, first int, after *string) (*model.UserConnection, error)
+	UpdateUser(ctx context.Context, userID string, params model.UpdateUserInput) (*model.User, error)
+	ChangePassword(ctx context.Context, claims *token.JwtCustomClaim, currentPassword string, newPassword string) (bool, error)
+	RequestPasswordReset(ctx context.Context, email string) (bool, error)
+	ResetPassword(ctx context.Context, resetToken string, newPassword string) (bool, error)
+	VerifyEmail(ctx context.Context, verificationToken string) (bool, error)
+	ResendVerification(ctx context.Context, email string) (bool, error)
+	EnrollTotp(ctx context.Context, userID string) (*model.TotpEnrollment, error)
+	ConfirmTotp(ctx context.Context, userID string, code string) (bool, error)
+	VerifyMfa(ctx context.Context, challenge string, code string) (*model.AuthPayload, error)
+	RegenerateRecoveryCodes(ctx context.Context, userID string, code string) ([]string, error)
+	BeginPasskeyRegistration(ctx context.Context, userID string, currentPassword string, code *string) (string, error)
+	FinishPasskeyRegistration(ctx context.Context, userID string, response string, name *string) (*model.Passkey, error)
+	ListPasskeys(ctx context.Context, userID string) ([]*model.Passkey, error)
+	DeletePasskey(ctx context.Context, userID string, passkeyID string) (bool, error)
+	BeginPasskeyLogin(ctx context.Context) (string, error)
+	LoginWithPasskey(ctx context.Context, response string) (*model.AuthPayload, error)
 }
 
 // UserCollection is an interface that wraps the database.Collection interface
@@ -28,14 +53,40 @@ type userSvc struct{}
 type usersCollectionCtxKey struct{}
 
 type userDB struct {
-	UserID        string     `bson:"user_id"`
-	Email         string     `bson:"email"`
-	FirstName     string     `bson:"first_name"`
-	LastName      string     `bson:"last_name"`
-	UserName      string     `bson:"user_name"`
-	Role          model.Role `bson:"role"`
-	Password      string     `bson:"password"`
-	LastLoginDate *time.Time `bson:"last_login_date"`
+	UserID    string     `bson:"user_id"`
+	Email     string     `bson:"email"`
+	FirstName string     `bson:"first_name"`
+	LastName  string     `bson:"last_name"`
+	UserName  string     `bson:"user_name"`
+	Role      model.Role `bson:"role"`
+	Password  string     `bson:"password"`
+	// PasswordChangedAt is when the password was last set, unknown for passwords set before it was
+	// recorded
+	PasswordChangedAt *time.Time `bson:"password_changed_at,omitempty"`
+	// MustChangePassword requires the user to change their password before their next session starts
+	MustChangePassword bool       `bson:"must_change_password"`
+	LastLoginDate      *time.Time `bson:"last_login_date"`
+	EmailVerified      bool       `bson:"email_verified"`
+	Locale             string     `bson:"locale,omitempty"`
+	MFAEnabled         bool       `bson:"mfa_enabled"`
+	// PasswordHistory are the hashes of the previous passwords of the user, most recent first, which
+	// new passwords must not match
+	PasswordHistory []string `bson:"password_history,omitempty"`
+	// TOTPSecret is the encrypted TOTP secret of a user with MFA enabled, and TOTPPendingSecret
+	// the one being enrolled until it is confirmed
+	TOTPSecret        string `bson:"totp_secret,omitempty"`
+	TOTPPendingSecret string `bson:"totp_pending_secret,omitempty"`
+	// TOTPLastStep is the time step of the last code accepted, so that codes cannot be replayed
+	TOTPLastStep int64 `bson:"totp_last_step,omitempty"`
+	// RecoveryCodes are the bcrypt hashes of the unused recovery codes of a user with MFA enabled,
+	// and PendingRecoveryCodes those of the set issued with the enrollment being confirmed
+	RecoveryCodes        []string `bson:"recovery_codes,omitempty"`
+	PendingRecoveryCodes []string `bson:"pending_recovery_codes,omitempty"`
+	// Passkeys are the WebAuthn credentials the user can log in with instead of their password
+	Passkeys []passkey.Credential `bson:"passkeys,omitempty"`
+	// Version is incremented on every update. Users created before versioning have no version
+	// field, which decodes as 0.
+	Version int `bson:"version"`
 }
 
 // New returns a pointer to a new auth service.
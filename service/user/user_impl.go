@@ -2,22 +2,60 @@ package user
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
-	"golang.org/x/crypto/bcrypt"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 
+	"github.com/ahummel25/user-auth-api/config"
+	"github.com/ahummel25/user-auth-api/graphql/errcode"
 	"github.com/ahummel25/user-auth-api/graphql/model"
+	"github.com/ahummel25/user-auth-api/service/lockout"
+	"github.com/ahummel25/user-auth-api/service/mfa"
+	"github.com/ahummel25/user-auth-api/service/notify"
+	"github.com/ahummel25/user-auth-api/service/passkey"
+	"github.com/ahummel25/user-auth-api/service/password"
+	"github.com/ahummel25/user-auth-api/service/token"
+)
+
+const (
+	// DefaultPageSize is the number of users listed when no page size is requested
+	DefaultPageSize = 20
+	maxPageSize     = 100
+	// maxMfaAttempts is the number of codes which may be tried against an MFA challenge
+	maxMfaAttempts = 5
+	// maxPasswordResetAttempts is the number of new passwords which may be tried with a reset token
+	maxPasswordResetAttempts = 5
 )
 
 var (
-	errInvalidPassword   = errors.New("invalid password")
-	errNoUserFound       = errors.New("user not found")
-	errUserAlreadyExists = errors.New("user name or email already exists")
+	errBlankField         = errors.New("first name, last name, email, user name and locale cannot be blank")
+	errEmailNotVerified   = errcode.New(errcode.EmailNotVerified, "email address has not been verified")
+	errInvalidCredentials = errors.New("invalid username, email or password")
+	errInvalidMfaCode     = errors.New("invalid code")
+	errInvalidPageSize    = fmt.Errorf("first must be between 1 and %d", maxPageSize)
+	errInvalidPassword    = errors.New("invalid password")
+	errMfaCodeRequired    = errors.New("a code from the authenticator app or a recovery code is required")
+	errMfaEnabled         = errcode.New(errcode.Conflict, "MFA is already enabled")
+	errMfaNotEnabled      = errors.New("MFA is not enabled")
+	errNoTotpEnrollment   = errors.New("no TOTP enrollment to confirm, call enrollTotp first")
+	errNoUserFound        = errors.New("user not found")
+	errNothingToUpdate    = errors.New("no changes to update")
+	errPasskeyNotFound    = errors.New("passkey not found")
+	errPasskeyRegistered  = errcode.New(errcode.Conflict, "passkey is already registered")
+	errPasswordReused     = errors.New("new password must differ from the recent passwords")
+	errSamePassword       = errors.New("new password must differ from the current password")
+	errStaleUser          = errcode.New(errcode.Conflict, "user has been modified since it was read, reload it and try again")
+	errUserAlreadyExists  = errors.New("user name or email already exists")
 )
 
 // Helper function to get user collection from context
@@ -46,6 +84,75 @@ func findUserByUsernameOrEmail(ctx context.Context, userCollection UserCollectio
 	return &user, nil
 }
 
+// Helper function to find user by user ID
+func findUserByID(ctx context.Context, userCollection UserCollection, userID string) (*userDB, error) {
+	var user userDB
+	if err := userCollection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&user); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errNoUserFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Helper function to map a user document to its GraphQL model
+func toModelUser(user *userDB) *model.User {
+	return &model.User{
+		ID:                 user.UserID,
+		Email:              user.Email,
+		FirstName:          user.FirstName,
+		LastName:           user.LastName,
+		UserName:           user.UserName,
+		Role:               user.Role,
+		LastLoginDate:      user.LastLoginDate,
+		EmailVerified:      user.EmailVerified,
+		MfaEnabled:         user.MFAEnabled,
+		Passkeys:           toModelPasskeys(user.Passkeys),
+		Version:            user.Version,
+		Locale:             optionalString(user.Locale),
+		PasswordChangedAt:  user.PasswordChangedAt,
+		MustChangePassword: user.MustChangePassword,
+	}
+}
+
+// Helper function to map the passkeys of a user to their GraphQL model
+func toModelPasskeys(credentials []passkey.Credential) []*model.Passkey {
+	var passkeys []*model.Passkey
+	for i := range credentials {
+		passkeys = append(passkeys, toModelPasskey(&credentials[i]))
+	}
+	return passkeys
+}
+
+// Helper function to map a passkey to its GraphQL model
+func toModelPasskey(credential *passkey.Credential) *model.Passkey {
+	return &model.Passkey{
+		ID:         credential.EncodedID(),
+		Name:       optionalString(credential.Name),
+		CreatedAt:  credential.CreationDate,
+		LastUsedAt: credential.LastUsedDate,
+	}
+}
+
+// Helper function to describe a user and their passkeys to the WebAuthn ceremonies
+func passkeyAccount(user *userDB) passkey.Account {
+	return passkey.Account{
+		UserID:      user.UserID,
+		Name:        user.Email,
+		DisplayName: strings.TrimSpace(user.FirstName + " " + user.LastName),
+		Credentials: user.Passkeys,
+	}
+}
+
+// Helper function to map an empty string to nil
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
 // Helper function to update last login date
 func updateLastLoginDate(ctx context.Context, userCollection UserCollection, userID string, time time.Time) error {
 	update := bson.M{"$set": bson.M{"last_login_date": time}}
@@ -55,44 +162,506 @@ func updateLastLoginDate(ctx context.Context, userCollection UserCollection, use
 	return nil
 }
 
-// Login authenticates the user.
-func (u *userSvc) Login(ctx context.Context, usernameOrEmail string, password string) (*model.UserObject, error) {
-	userCollection, err := getUserCollection(ctx)
+// Helper function to hash a password with the configured algorithm
+func hashPassword(ctx context.Context, plaintext string) (string, error) {
+	hasher, err := password.New(ctx)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
+	return hasher.Hash(plaintext)
+}
 
-	user, err := findUserByUsernameOrEmail(ctx, userCollection, usernameOrEmail)
+// Helper function to describe a user to the password policy
+func passwordAccount(user *userDB) password.Account {
+	return password.Account{UserName: user.UserName, Email: user.Email, FirstName: user.FirstName, LastName: user.LastName}
+}
+
+// Helper function to check that a new password matches neither the current password of a user nor
+// the configured number of their previous ones
+func checkPasswordReuse(ctx context.Context, hasher password.Hasher, user *userDB, plaintext string) error {
+	configSupplier, err := config.FromContext(ctx)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	cfg, err := configSupplier.GetConfig()
+	if err != nil {
+		return err
+	}
+	history := user.PasswordHistory[:min(len(user.PasswordHistory), cfg.PasswordHistorySize)]
+	for _, hash := range append([]string{user.Password}, history...) {
+		if hash == "" {
+			continue
+		}
+		reused, err := hasher.Verify(hash, plaintext)
+		if err != nil {
+			return err
+		}
+		if reused {
+			return errPasswordReused
+		}
+	}
+	return nil
+}
+
+// Helper function to replace the password of a user with a hash of the given one, moving the hash
+// of the current password to the front of their password history, which keeps the configured
+// number of hashes. With a history size of 0 the history is emptied.
+func setPassword(ctx context.Context, userCollection UserCollection, user *userDB, plaintext string) error {
+	configSupplier, err := config.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	cfg, err := configSupplier.GetConfig()
+	if err != nil {
+		return err
+	}
+	hash, err := hashPassword(ctx, plaintext)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	update := bson.M{
+		"$set": bson.M{
+			"password":             hash,
+			"password_changed_at":  now,
+			"must_change_password": false,
+			"last_update_date":     now,
+		},
+		"$inc": bson.M{"version": 1},
+	}
+	if user.Password != "" {
+		update["$push"] = bson.M{"password_history": bson.M{
+			"$each": []string{user.Password}, "$position": 0, "$slice": cfg.PasswordHistorySize,
+		}}
+	}
+	result, err := userCollection.UpdateOne(ctx, bson.M{"user_id": user.UserID}, update)
+	if err != nil {
+		return err
+	} else if result.MatchedCount == 0 {
+		return errNoUserFound
+	}
+	return nil
+}
+
+// Helper function to replace the hash of the password of a user, which was just verified, with one
+// of the configured algorithm and parameters. The hash is only replaced if the password was not
+// changed in the meantime, and a failure leaves the old hash, which still verifies, in place.
+func rehashPassword(ctx context.Context, userCollection UserCollection, hasher password.Hasher, user *userDB, plaintext string) {
+	hash, err := hasher.Hash(plaintext)
+	if err != nil {
+		slog.Error("Failed to rehash password", "error", err, "user_id", user.UserID)
+		return
+	}
+	filter := bson.M{"user_id": user.UserID, "password": user.Password}
+	if _, err = userCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"password": hash}}); err != nil {
+		slog.Error("Failed to rehash password", "error", err, "user_id", user.UserID)
+		return
+	}
+	slog.Info("Upgraded password hash", "user_id", user.UserID)
+}
+
+// Helper function to append an action token to the page of the client application handling it
+func actionLink(pageURL string, actionToken string) (string, error) {
+	link, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
 	}
+	query := link.Query()
+	query.Set("token", actionToken)
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
 
-	if err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-			return nil, errInvalidPassword
+// Helper function to render a message asking a user to act with an action token, linking to the
+// page of the client application handling it when there is one
+func actionMessage(
+	template string,
+	email string,
+	locale string,
+	pageURL string,
+	actionToken string,
+	ttl time.Duration,
+) (notify.Message, error) {
+	data := notify.ActionData{Token: actionToken, TTL: ttl}
+	if pageURL != "" {
+		link, err := actionLink(pageURL, actionToken)
+		if err != nil {
+			return notify.Message{}, err
 		}
+		data.Link = link
+	}
+	return notify.Render(template, locale, email, data)
+}
+
+// Helper function to issue an email verification token to a user and send it to them. Tokens
+// issued earlier are revoked, as they may have been sent to a previous email address.
+func sendEmailVerification(ctx context.Context, userID string, email string, locale string) error {
+	configSupplier, err := config.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	cfg, err := configSupplier.GetConfig()
+	if err != nil {
+		return err
+	}
+	sender, err := notify.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = token.RevokeActionTokens(ctx, userID, token.PurposeEmailVerification); err != nil {
+		return err
+	}
+	ttl := cfg.EmailVerificationTokenTTL
+	verificationToken, err := token.IssueActionToken(ctx, userID, token.PurposeEmailVerification, ttl)
+	if err != nil {
+		return err
+	}
+	msg, err := actionMessage(
+		notify.TemplateEmailVerification, email, locale, cfg.EmailVerificationURL, verificationToken, ttl,
+	)
+	if err != nil {
+		return err
+	}
+	return sender.Send(ctx, msg)
+}
+
+// Helper function to tell the previous email address of a user that it was replaced, so that its
+// owner notices when someone else moved their account
+func sendEmailChangedNotice(ctx context.Context, previousEmail string, newEmail string, locale string) error {
+	sender, err := notify.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	msg, err := notify.Render(notify.TemplateEmailChanged, locale, previousEmail, notify.EmailChangedData{NewEmail: newEmail})
+	if err != nil {
+		return err
+	}
+	return sender.Send(ctx, msg)
+}
+
+// Helper function to refuse users who have not verified their email when verification is required
+func checkEmailVerified(user *userDB, requireVerifiedEmail bool) error {
+	if requireVerifiedEmail && !user.EmailVerified {
+		return errEmailNotVerified
+	}
+	return nil
+}
+
+// Helper function to issue an access and refresh token pair for the given user and session
+func issueAuthPayload(ctx context.Context, user *model.User, familyID string) (*model.AuthPayload, error) {
+	accessToken, expiresAt, err := token.JwtGenerate(ctx, user.ID, user.Role, familyID)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, refreshTokenExpiresAt, err := token.IssueRefreshToken(ctx, user.ID, familyID)
+	if err != nil {
 		return nil, err
 	}
 
+	authPayload := &model.AuthPayload{
+		Status:                model.AuthStatusAuthenticated,
+		User:                  user,
+		AccessToken:           &accessToken,
+		ExpiresAt:             &expiresAt,
+		RefreshToken:          &refreshToken,
+		RefreshTokenExpiresAt: &refreshTokenExpiresAt,
+	}
+	return authPayload, nil
+}
+
+// Helper function to record a login of the user and start their session, once every factor has
+// been verified
+func completeLogin(ctx context.Context, userCollection UserCollection, user *userDB) (*model.AuthPayload, error) {
+	// Failures are only forgotten once every factor is verified, so that a password alone does not
+	// lift a lock on guessing the second factor
+	resetLoginFailures(ctx, user)
+	loggedInUser := toModelUser(user)
 	// Update last_login_date
 	now := time.Now().UTC()
-	if err = updateLastLoginDate(ctx, userCollection, user.UserID, now); err != nil {
-		// Set last_login_date to the previously fetched value and log the error, but don't fail the login process
-		now = user.LastLoginDate.UTC()
+	if err := updateLastLoginDate(ctx, userCollection, user.UserID, now); err != nil {
+		// Keep the previously fetched last_login_date and log the error, but don't fail the login process
 		slog.Error("Failed to update last_login_date", "error", err, "user_id", user.UserID)
+	} else {
+		loggedInUser.LastLoginDate = &now
+	}
+	// Every login starts a new session, i.e. a new refresh token family
+	return issueAuthPayload(ctx, loggedInUser, token.NewFamilyID())
+}
+
+// Helper function to tell whether a user must change their password before a session is started
+// for them, because an admin required it or because it is older than the given maximum age
+func passwordChangeRequired(user *userDB, maxAge time.Duration) bool {
+	if user.MustChangePassword {
+		return true
+	}
+	// Passwords set before their date was recorded are not known to have expired
+	return maxAge > 0 && user.PasswordChangedAt != nil && time.Since(*user.PasswordChangedAt) > maxAge
+}
+
+// Helper function to issue the access token of a user who must change their password, which only
+// permits changePassword, in place of a session
+func issuePasswordChangeToken(ctx context.Context, user *userDB) (*model.AuthPayload, error) {
+	accessToken, expiresAt, err := token.JwtGenerateRestricted(ctx, user.UserID, user.Role, token.ScopeChangePassword)
+	if err != nil {
+		return nil, err
+	}
+	return &model.AuthPayload{
+		Status:      model.AuthStatusPasswordChangeRequired,
+		User:        toModelUser(user),
+		AccessToken: &accessToken,
+		ExpiresAt:   &expiresAt,
+	}, nil
+}
+
+// Helper function to issue the challenge a user who entered their password completes with their
+// second factor
+func issueMfaChallenge(ctx context.Context, userID string, ttl time.Duration) (*model.AuthPayload, error) {
+	challenge, err := token.IssueActionToken(ctx, userID, token.PurposeMFAChallenge, ttl)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().UTC().Add(ttl)
+	return &model.AuthPayload{
+		Status:                model.AuthStatusMfaRequired,
+		MfaChallenge:          &challenge,
+		MfaChallengeExpiresAt: &expiresAt,
+	}, nil
+}
+
+// Helper function to check a TOTP code of a user and record its time step, so that the same code
+// is refused from then on
+func acceptTotpCode(ctx context.Context, userCollection UserCollection, user *userDB, code string) error {
+	secret, err := mfa.DecryptSecret(ctx, user.UserID, user.TOTPSecret)
+	if err != nil {
+		return err
+	}
+	step, ok := mfa.ValidateCode(secret, code, time.Now())
+	if !ok || step <= user.TOTPLastStep {
+		return errInvalidMfaCode
+	}
+	// Only one of concurrent uses of the same code wins
+	filter := bson.M{"user_id": user.UserID, "totp_last_step": bson.M{"$not": bson.M{"$gte": step}}}
+	result, err := userCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"totp_last_step": step}})
+	if err != nil {
+		return err
+	} else if result.MatchedCount == 0 {
+		return errInvalidMfaCode
+	}
+	return nil
+}
+
+// Helper function to check a recovery code of a user and remove it, so that it cannot be used
+// again
+func acceptRecoveryCode(ctx context.Context, userCollection UserCollection, user *userDB, code string) error {
+	hash, ok := mfa.MatchRecoveryCode(user.RecoveryCodes, code)
+	if !ok {
+		return errInvalidMfaCode
+	}
+	// Only one of concurrent uses of the same code wins
+	filter := bson.M{"user_id": user.UserID, "recovery_codes": hash}
+	result, err := userCollection.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"recovery_codes": hash}})
+	if err != nil {
+		return err
+	} else if result.MatchedCount == 0 {
+		return errInvalidMfaCode
+	}
+	slog.Info("Recovery code used", "user_id", user.UserID, "remaining", len(user.RecoveryCodes)-1)
+	return nil
+}
+
+// Helper function to check a second factor code of a user, which is either a TOTP code or a
+// recovery code
+func acceptMfaCode(ctx context.Context, userCollection UserCollection, user *userDB, code string) error {
+	if mfa.IsTOTPCode(code) {
+		return acceptTotpCode(ctx, userCollection, user, code)
+	}
+	return acceptRecoveryCode(ctx, userCollection, user, code)
+}
+
+// Helper function to log why a login failed, which the caller is not told. The identifier the
+// caller entered is left out, as users sometimes type their password into it.
+func logLoginFailure(ctx context.Context, reason string, userID string) {
+	slog.Info("Login failed", "reason", reason, "user_id", userID, "ip", lockout.ClientIPFromContext(ctx))
+}
+
+// Helper function to count a failed login with the given identifiers. Failing to count it must not
+// hide that the login failed, so the error is only logged.
+func recordLoginFailure(ctx context.Context, userID string, identifiers ...string) {
+	if err := lockout.RecordFailure(ctx, identifiers...); err != nil {
+		slog.Error("Failed to record failed login", "error", err, "user_id", userID)
+	}
+}
+
+// Helper function to verify the current password a signed-in user gave to confirm a sensitive
+// change. Wrong passwords count as failed logins of the user, so that a stolen session cannot be
+// used to guess the password, and the user is refused while locked.
+func verifyCurrentPassword(ctx context.Context, user *userDB, plaintext string) error {
+	if err := lockout.Check(ctx, user.UserName, user.Email); err != nil {
+		return err
+	}
+	hasher, err := password.New(ctx)
+	if err != nil {
+		return err
+	}
+	valid, err := hasher.Verify(user.Password, plaintext)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		recordLoginFailure(ctx, user.UserID, user.UserName, user.Email)
+		return errInvalidPassword
+	}
+	return nil
+}
+
+// Helper function to forget the failed logins with the user name and email of a user, lifting
+// their lock
+func resetLoginFailures(ctx context.Context, user *userDB) {
+	if err := lockout.Reset(ctx, user.UserName, user.Email); err != nil {
+		slog.Error("Failed to reset failed logins", "error", err, "user_id", user.UserID)
+	}
+}
+
+// Login authenticates the user and issues an access and refresh token pair for subsequent requests.
+// Users with MFA enabled get a challenge to complete with VerifyMfa instead, and users who must
+// change their password a token only permitting ChangePassword. Users and clients with too many
+// failed logins are refused for a while. Failures are counted for the identifier the caller
+// entered, and unknown users and wrong passwords fail alike, so that logins do not reveal which
+// users exist.
+func (u *userSvc) Login(ctx context.Context, usernameOrEmail string, plaintext string) (*model.AuthPayload, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hasher, err := password.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	configSupplier, err := config.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := configSupplier.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := findUserByUsernameOrEmail(ctx, userCollection, usernameOrEmail)
+	if err != nil && !errors.Is(err, errNoUserFound) {
+		return nil, err
+	}
+	// Locked identifiers are refused before the password is even checked, whether or not a user
+	// has them
+	if err = lockout.Check(ctx, usernameOrEmail); err != nil {
+		return nil, err
+	}
+	if user == nil {
+		// Take as long as checking the password of an existing user would, so that the response
+		// time does not tell whether the user exists either
+		if dummyHash, err := password.DummyHash(hasher); err == nil {
+			_, _ = hasher.Verify(dummyHash, plaintext)
+		}
+		recordLoginFailure(ctx, "", usernameOrEmail)
+		logLoginFailure(ctx, "user not found", "")
+		return nil, errInvalidCredentials
+	}
+
+	valid, err := hasher.Verify(user.Password, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		recordLoginFailure(ctx, user.UserID, usernameOrEmail)
+		logLoginFailure(ctx, "invalid password", user.UserID)
+		return nil, errInvalidCredentials
+	}
+	// Hashes made under an older policy are upgraded while the plaintext is at hand
+	if hasher.NeedsRehash(user.Password) {
+		rehashPassword(ctx, userCollection, hasher, user, plaintext)
+	}
+	// Only tell whether the email is verified to callers who know the password
+	if err = checkEmailVerified(user, cfg.RequireVerifiedEmail); err != nil {
+		return nil, err
+	}
+
+	// The session only starts once the second factor is verified as well
+	if user.MFAEnabled {
+		return issueMfaChallenge(ctx, user.UserID, cfg.MFAChallengeTTL)
+	}
+	if passwordChangeRequired(user, cfg.PasswordMaxAge) {
+		resetLoginFailures(ctx, user)
+		return issuePasswordChangeToken(ctx, user)
+	}
+	return completeLogin(ctx, userCollection, user)
+}
+
+// RefreshToken exchanges a refresh token for a new access and refresh token pair. Users who must
+// change their password have their session ended instead, and get a token only permitting
+// ChangePassword like on Login.
+func (u *userSvc) RefreshToken(ctx context.Context, refreshToken string) (*model.AuthPayload, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	configSupplier, err := config.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := configSupplier.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := token.RotateRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// Re-read the user so the new access token carries their current role
+	user, err := findUserByID(ctx, userCollection, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	// A session started before the password expired ends with it, leaving a token which only
+	// permits changing the password like on Login
+	if passwordChangeRequired(user, cfg.PasswordMaxAge) {
+		if err = token.RevokeRefreshTokenFamily(ctx, session.FamilyID); err != nil {
+			return nil, err
+		}
+		return issuePasswordChangeToken(ctx, user)
+	}
+	return issueAuthPayload(ctx, toModelUser(user), session.FamilyID)
+}
+
+// Logout ends the session of the given access token by revoking the token and its refresh token family.
+func (u *userSvc) Logout(ctx context.Context, claims *token.JwtCustomClaim) (bool, error) {
+	if err := token.RevokeAccessToken(ctx, claims); err != nil {
+		return false, err
+	}
+	if claims.SessionID != "" {
+		if err := token.RevokeRefreshTokenFamily(ctx, claims.SessionID); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// RevokeAllSessions revokes every access and refresh token issued to an existing user.
+func (u *userSvc) RevokeAllSessions(ctx context.Context, userID string) (bool, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return false, err
+	}
+	if _, err = findUserByID(ctx, userCollection, userID); err != nil {
+		return false, err
 	}
 
-	loggedInUser := &model.User{
-		ID:            user.UserID,
-		Email:         user.Email,
-		FirstName:     user.FirstName,
-		LastName:      user.LastName,
-		UserName:      user.UserName,
-		Role:          user.Role,
-		LastLoginDate: &now,
+	if err = token.RevokeUserTokens(ctx, userID); err != nil {
+		return false, err
 	}
-	userObject := &model.UserObject{User: loggedInUser}
-	return userObject, nil
+	return true, nil
 }
 
 // CreateUser creates a new user.
@@ -102,6 +671,13 @@ func (u *userSvc) CreateUser(ctx context.Context, params model.NewUserInput) (*m
 		return nil, err
 	}
 
+	err = password.Validate(ctx, params.Password, password.Account{
+		UserName: params.UserName, Email: params.Email, FirstName: params.FirstName, LastName: params.LastName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	// Verify if the user name or email already exists
 	filter := bson.M{
 		"$or": []bson.M{
@@ -116,7 +692,7 @@ func (u *userSvc) CreateUser(ctx context.Context, params model.NewUserInput) (*m
 	}
 
 	// Generate a hash from the password to store in the DB
-	hash, err := bcrypt.GenerateFromPassword([]byte(params.Password), bcrypt.DefaultCost)
+	hash, err := hashPassword(ctx, params.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -126,23 +702,42 @@ func (u *userSvc) CreateUser(ctx context.Context, params model.NewUserInput) (*m
 	if params.Role != nil {
 		role = *params.Role
 	}
+	var locale string
+	if params.Locale != nil {
+		locale = *params.Locale
+	}
 	now := time.Now().UTC()
 	newUserInput := bson.D{
 		{Key: "user_id", Value: newUserID},
 		{Key: "email", Value: params.Email},
 		{Key: "user_name", Value: params.UserName},
-		{Key: "password", Value: string(hash)},
+		{Key: "password", Value: hash},
 		{Key: "first_name", Value: params.FirstName},
 		{Key: "last_name", Value: params.LastName},
 		{Key: "role", Value: role},
 		{Key: "creation_date", Value: now},
 		{Key: "last_update_date", Value: now},
 		{Key: "last_login_date", Value: nil}, // Initialize last_login_date as nil
+		{Key: "email_verified", Value: false},
+		{Key: "password_changed_at", Value: now},
+		{Key: "must_change_password", Value: false},
+		{Key: "version", Value: 0},
+	}
+	if locale != "" {
+		newUserInput = append(newUserInput, bson.E{Key: "locale", Value: locale})
 	}
 
 	if _, err = userCollection.InsertOne(ctx, newUserInput); err != nil {
+		// Another user took the user name or email since it was checked
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errUserAlreadyExists
+		}
 		return nil, err
 	}
+	// The user exists either way; a failed delivery can be retried with resendVerification
+	if err = sendEmailVerification(ctx, newUserID, params.Email, locale); err != nil {
+		slog.Error("Failed to send email verification", "error", err, "user_id", newUserID)
+	}
 
 	newUser := &model.User{
 		ID:        newUserID,
@@ -151,21 +746,351 @@ func (u *userSvc) CreateUser(ctx context.Context, params model.NewUserInput) (*m
 		LastName:  params.LastName,
 		UserName:  params.UserName,
 		Role:      role,
+		Locale:    params.Locale,
 	}
 	user := &model.UserObject{User: newUser}
 	return user, nil
 }
 
-// DeleteUser deletes an existing user.
-func (u *userSvc) DeleteUser(ctx context.Context, userID string) (bool, error) {
+// UpdateUser applies the given changes to an existing user, provided the user is still at the
+// version the changes are based on.
+func (u *userSvc) UpdateUser(ctx context.Context, userID string, params model.UpdateUserInput) (*model.User, error) {
 	userCollection, err := getUserCollection(ctx)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	filter := bson.M{"user_id": userID}
-	deleteResult, err := userCollection.DeleteOne(ctx, filter)
-	if err != nil {
-		return false, err
+
+	changes := bson.M{}
+	for field, value := range map[string]*string{
+		"email":      params.Email,
+		"first_name": params.FirstName,
+		"last_name":  params.LastName,
+		"user_name":  params.UserName,
+		"locale":     params.Locale,
+	} {
+		if value == nil {
+			continue
+		} else if *value == "" {
+			return nil, errBlankField
+		}
+		changes[field] = *value
+	}
+	if params.Role != nil {
+		changes["role"] = *params.Role
+	}
+	if len(changes) == 0 {
+		return nil, errNothingToUpdate
+	}
+
+	// A new email address must be verified again, and the previous one is told about the change
+	var current *userDB
+	emailChanged := false
+	if params.Email != nil || params.CurrentPassword != nil {
+		if current, err = findUserByID(ctx, userCollection, userID); err != nil {
+			return nil, err
+		} else if current.Version != params.Version {
+			return nil, errStaleUser
+		}
+		if params.CurrentPassword != nil {
+			if err = verifyCurrentPassword(ctx, current, *params.CurrentPassword); err != nil {
+				return nil, err
+			}
+		}
+		if params.Email != nil {
+			if emailChanged = current.Email != *params.Email; emailChanged {
+				changes["email_verified"] = false
+			}
+		}
+	}
+
+	changes["last_update_date"] = time.Now().UTC()
+	filter := bson.M{"user_id": userID, "version": params.Version}
+	if params.Version == 0 {
+		// Users created before versioning have no version field
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	}
+	update := bson.M{"$set": changes, "$inc": bson.M{"version": 1}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var updated userDB
+	if err = userCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated); err != nil {
+		// The unique indexes refuse a user name or email which belongs to another user
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errUserAlreadyExists
+		} else if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, err
+		}
+		// Either the user does not exist or someone else updated it first
+		if _, err = findUserByID(ctx, userCollection, userID); err != nil {
+			return nil, err
+		}
+		return nil, errStaleUser
+	}
+	if emailChanged {
+		if err = sendEmailVerification(ctx, userID, updated.Email, updated.Locale); err != nil {
+			slog.Error("Failed to send email verification", "error", err, "user_id", userID)
+		}
+		if err = sendEmailChangedNotice(ctx, current.Email, updated.Email, updated.Locale); err != nil {
+			slog.Error("Failed to send email changed notice", "error", err, "user_id", userID)
+		}
+	}
+	return toModelUser(&updated), nil
+}
+
+// ChangePassword replaces the password of the caller after verifying their current one, and signs
+// out every other session of the caller. Wrong current passwords count as failed logins of the
+// caller, so that a stolen session cannot be used to guess the password either.
+func (u *userSvc) ChangePassword(
+	ctx context.Context,
+	claims *token.JwtCustomClaim,
+	currentPassword string,
+	newPassword string,
+) (bool, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	user, err := findUserByID(ctx, userCollection, claims.UserID)
+	if err != nil {
+		return false, err
+	}
+	if err = verifyCurrentPassword(ctx, user, currentPassword); err != nil {
+		return false, err
+	}
+	if newPassword == currentPassword {
+		return false, errSamePassword
+	}
+	if err = password.Validate(ctx, newPassword, passwordAccount(user)); err != nil {
+		return false, err
+	}
+	hasher, err := password.New(ctx)
+	if err != nil {
+		return false, err
+	}
+	if err = checkPasswordReuse(ctx, hasher, user, newPassword); err != nil {
+		return false, err
+	}
+
+	if err = setPassword(ctx, userCollection, user, newPassword); err != nil {
+		return false, err
+	}
+
+	// Anyone holding a session opened with the old password is signed out. Tokens issued without
+	// a session, such as those of users who had to change their password, cannot be told apart, so
+	// the caller is signed out as well and logs in again with the new password.
+	if claims.SessionID == "" {
+		err = token.RevokeUserTokens(ctx, user.UserID)
+	} else {
+		err = token.RevokeOtherSessions(ctx, user.UserID, claims.SessionID)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RequestPasswordReset sends a single-use password reset token to the user with the given email.
+// The outcome is the same whether or not such a user exists, so that it cannot be used to find
+// out which emails are registered.
+func (u *userSvc) RequestPasswordReset(ctx context.Context, email string) (bool, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return false, err
+	}
+	configSupplier, err := config.FromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	cfg, err := configSupplier.GetConfig()
+	if err != nil {
+		return false, err
+	}
+
+	var user userDB
+	if err = userCollection.FindOne(ctx, bson.M{"email": email}).Decode(&user); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			slog.Info("Password reset requested for unknown email")
+			return true, nil
+		}
+		return false, err
+	}
+
+	// Failures past this point are only logged, as reporting them would reveal the user exists
+	if err = sendPasswordReset(ctx, &user, cfg.PasswordResetURL, cfg.PasswordResetTokenTTL); err != nil {
+		slog.Error("Failed to send password reset", "error", err, "user_id", user.UserID)
+	}
+	return true, nil
+}
+
+// Helper function to issue a password reset token to a user and send it to them
+func sendPasswordReset(ctx context.Context, user *userDB, resetURL string, ttl time.Duration) error {
+	sender, err := notify.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	resetToken, err := token.IssueActionToken(ctx, user.UserID, token.PurposePasswordReset, ttl)
+	if err != nil {
+		return err
+	}
+	msg, err := actionMessage(notify.TemplatePasswordReset, user.Email, user.Locale, resetURL, resetToken, ttl)
+	if err != nil {
+		return err
+	}
+	return sender.Send(ctx, msg)
+}
+
+// ResetPassword replaces the password of the user a password reset token was issued to, and signs
+// out every session of the user. The token is only used up once the new password follows the
+// password policy and differs from the recent passwords of the user, so that the user can try
+// another one.
+func (u *userSvc) ResetPassword(ctx context.Context, resetToken string, newPassword string) (bool, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	userID, err := token.AttemptActionToken(ctx, resetToken, token.PurposePasswordReset, maxPasswordResetAttempts)
+	if err != nil {
+		return false, err
+	}
+	user, err := findUserByID(ctx, userCollection, userID)
+	if err != nil {
+		return false, err
+	}
+	if err = password.Validate(ctx, newPassword, passwordAccount(user)); err != nil {
+		return false, err
+	}
+	hasher, err := password.New(ctx)
+	if err != nil {
+		return false, err
+	}
+	if err = checkPasswordReuse(ctx, hasher, user, newPassword); err != nil {
+		return false, err
+	}
+	if _, err = token.ConsumeActionToken(ctx, resetToken, token.PurposePasswordReset); err != nil {
+		return false, err
+	}
+	if err = setPassword(ctx, userCollection, user, newPassword); err != nil {
+		return false, err
+	}
+
+	// Whoever may have learned the old password is signed out
+	if err = token.RevokeUserTokens(ctx, userID); err != nil {
+		return false, err
+	}
+	// A user locked by someone guessing their password can log in with the new one right away
+	resetLoginFailures(ctx, user)
+	return true, nil
+}
+
+// VerifyEmail marks the email address of the user an email verification token was issued to as
+// verified.
+func (u *userSvc) VerifyEmail(ctx context.Context, verificationToken string) (bool, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	userID, err := token.ConsumeActionToken(ctx, verificationToken, token.PurposeEmailVerification)
+	if err != nil {
+		return false, err
+	}
+	update := bson.M{
+		"$set": bson.M{"email_verified": true, "last_update_date": time.Now().UTC()},
+		"$inc": bson.M{"version": 1},
+	}
+	result, err := userCollection.UpdateOne(ctx, bson.M{"user_id": userID}, update)
+	if err != nil {
+		return false, err
+	} else if result.MatchedCount == 0 {
+		return false, errNoUserFound
+	}
+	return true, nil
+}
+
+// ResendVerification sends a new email verification token to the user with the given email if
+// they have not verified it yet. Like RequestPasswordReset, the outcome does not reveal whether
+// such a user exists.
+func (u *userSvc) ResendVerification(ctx context.Context, email string) (bool, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var user userDB
+	if err = userCollection.FindOne(ctx, bson.M{"email": email}).Decode(&user); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			slog.Info("Email verification requested for unknown email")
+			return true, nil
+		}
+		return false, err
+	}
+	if user.EmailVerified {
+		return true, nil
+	}
+
+	if err = sendEmailVerification(ctx, user.UserID, user.Email, user.Locale); err != nil {
+		slog.Error("Failed to send email verification", "error", err, "user_id", user.UserID)
+	}
+	return true, nil
+}
+
+// UnlockUser lifts the lock of a user locked after too many failed logins, and forgets their failed
+// logins.
+func (u *userSvc) UnlockUser(ctx context.Context, userID string) (bool, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return false, err
+	}
+	user, err := findUserByID(ctx, userCollection, userID)
+	if err != nil {
+		return false, err
+	}
+	if err = lockout.Reset(ctx, user.UserName, user.Email); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ForcePasswordReset requires a user to change their password on their next login, and signs out
+// every session of the user so that they log in again right away. It is meant for passwords which
+// may have leaked, so it also removes every passkey of the user, as whoever holds the password may
+// have registered one, and the user has to register theirs again. Routine rotation is left to
+// PASSWORD_MAX_AGE, which keeps the passkeys.
+func (u *userSvc) ForcePasswordReset(ctx context.Context, userID string) (bool, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	update := bson.M{
+		"$set":   bson.M{"must_change_password": true, "last_update_date": time.Now().UTC()},
+		"$unset": bson.M{"passkeys": ""},
+		"$inc":   bson.M{"version": 1},
+	}
+	result, err := userCollection.UpdateOne(ctx, bson.M{"user_id": userID}, update)
+	if err != nil {
+		return false, err
+	} else if result.MatchedCount == 0 {
+		return false, errNoUserFound
+	}
+	if err = token.RevokeUserTokens(ctx, userID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteUser deletes an existing user.
+func (u *userSvc) DeleteUser(ctx context.Context, userID string) (bool, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return false, err
+	}
+	filter := bson.M{"user_id": userID}
+	deleteResult, err := userCollection.DeleteOne(ctx, filter)
+	if err != nil {
+		return false, err
 	}
 	if deleteResult.DeletedCount == 0 {
 		return false, errNoUserFound
@@ -173,3 +1098,525 @@ func (u *userSvc) DeleteUser(ctx context.Context, userID string) (bool, error) {
 
 	return true, nil
 }
+
+// GetUserByID fetches an existing user by their user ID.
+func (u *userSvc) GetUserByID(ctx context.Context, userID string) (*model.User, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := findUserByID(ctx, userCollection, userID)
+	if err != nil {
+		return nil, err
+	}
+	return toModelUser(user), nil
+}
+
+// ListUsers lists one page of the users matching the filter in the given order, starting after
+// the given cursor.
+func (u *userSvc) ListUsers(
+	ctx context.Context,
+	filter *model.UserFilter,
+	sort *model.UserSort,
+	first int,
+	after *string,
+) (*model.UserConnection, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if first < 1 || first > maxPageSize {
+		return nil, errInvalidPageSize
+	}
+	userSort, err := normalizeUserSort(sort)
+	if err != nil {
+		return nil, err
+	}
+
+	query := userListFilter(filter)
+	totalCount, err := userCollection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	pageQuery := query
+	if after != nil && *after != "" {
+		cursor, err := decodeUserCursor(userSort, *after)
+		if err != nil {
+			return nil, err
+		}
+		pageQuery = bson.M{"$and": []bson.M{query, afterCursorFilter(userSort, cursor)}}
+	}
+
+	// Sort on the user ID as well so that the order is stable across pages, and fetch one extra
+	// user to tell whether there is a next page
+	direction := 1
+	if *userSort.Direction == model.SortDirectionDesc {
+		direction = -1
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: userSortFields[userSort.Field], Value: direction}, {Key: "user_id", Value: direction}}).
+		SetLimit(int64(first + 1))
+	results, err := userCollection.Find(ctx, pageQuery, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = results.Close(ctx) }()
+
+	connection := &model.UserConnection{
+		Edges:      []*model.UserEdge{},
+		PageInfo:   &model.PageInfo{HasPreviousPage: after != nil && *after != ""},
+		TotalCount: int(totalCount),
+	}
+	for results.Next(ctx) {
+		if len(connection.Edges) == first {
+			connection.PageInfo.HasNextPage = true
+			break
+		}
+		var user userDB
+		if err = results.Decode(&user); err != nil {
+			return nil, err
+		}
+		cursor, err := encodeUserCursor(userSort, results.Current)
+		if err != nil {
+			return nil, err
+		}
+		connection.Edges = append(connection.Edges, &model.UserEdge{Cursor: cursor, Node: toModelUser(&user)})
+	}
+	if err = results.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(connection.Edges) > 0 {
+		connection.PageInfo.StartCursor = &connection.Edges[0].Cursor
+		connection.PageInfo.EndCursor = &connection.Edges[len(connection.Edges)-1].Cursor
+	}
+	return connection, nil
+}
+
+// Helper function to apply the default order of users, by creation date ascending
+func normalizeUserSort(sort *model.UserSort) (model.UserSort, error) {
+	userSort := model.UserSort{Field: model.UserSortFieldCreationDate}
+	if sort != nil {
+		userSort.Field = sort.Field
+		userSort.Direction = sort.Direction
+	}
+	if userSort.Direction == nil {
+		direction := model.SortDirectionAsc
+		userSort.Direction = &direction
+	}
+	if _, ok := userSortFields[userSort.Field]; !ok || !userSort.Direction.IsValid() {
+		return model.UserSort{}, fmt.Errorf("invalid sort %s %s", userSort.Field, *userSort.Direction)
+	}
+	return userSort, nil
+}
+
+// Helper function to build the users query matching every criteria of the filter
+func userListFilter(filter *model.UserFilter) bson.M {
+	query := bson.M{}
+	if filter == nil {
+		return query
+	}
+	if filter.Role != nil {
+		query["role"] = *filter.Role
+	}
+	if filter.EmailPrefix != nil && *filter.EmailPrefix != "" {
+		query["email"] = prefixPattern(*filter.EmailPrefix)
+	}
+	if filter.UserNamePrefix != nil && *filter.UserNamePrefix != "" {
+		query["user_name"] = prefixPattern(*filter.UserNamePrefix)
+	}
+	lastLogin := bson.M{}
+	if filter.LastLoginFrom != nil {
+		lastLogin["$gte"] = filter.LastLoginFrom.UTC()
+	}
+	if filter.LastLoginTo != nil {
+		lastLogin["$lt"] = filter.LastLoginTo.UTC()
+	}
+	if len(lastLogin) > 0 {
+		query["last_login_date"] = lastLogin
+	}
+	return query
+}
+
+// Helper function to match values starting with the given prefix, ignoring case
+func prefixPattern(prefix string) bson.Regex {
+	return bson.Regex{Pattern: "^" + regexp.QuoteMeta(prefix), Options: "i"}
+}
+
+// IsNotFound reports whether err indicates that the requested user does not exist.
+func IsNotFound(err error) bool {
+	return errors.Is(err, errNoUserFound)
+}
+
+// EnrollTotp generates a new TOTP secret for the user, to be confirmed with ConfirmTotp before it
+// is required on login.
+func (u *userSvc) EnrollTotp(ctx context.Context, userID string) (*model.TotpEnrollment, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	configSupplier, err := config.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := configSupplier.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := findUserByID(ctx, userCollection, userID)
+	if err != nil {
+		return nil, err
+	} else if user.MFAEnabled {
+		return nil, errMfaEnabled
+	}
+
+	secret, err := mfa.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := mfa.EncryptSecret(ctx, userID, secret)
+	if err != nil {
+		return nil, err
+	}
+	recoveryCodes, recoveryHashes, err := mfa.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	update := bson.M{"$set": bson.M{
+		"totp_pending_secret":    encrypted,
+		"pending_recovery_codes": recoveryHashes,
+		"last_update_date":       time.Now().UTC(),
+	}}
+	result, err := userCollection.UpdateOne(ctx, bson.M{"user_id": userID, "mfa_enabled": bson.M{"$ne": true}}, update)
+	if err != nil {
+		return nil, err
+	} else if result.MatchedCount == 0 {
+		return nil, errMfaEnabled
+	}
+
+	return &model.TotpEnrollment{
+		Secret:        secret,
+		URI:           mfa.KeyURI(cfg.TOTPIssuer, user.Email, secret),
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+// ConfirmTotp enables MFA for the user once they prove their authenticator app generates codes
+// for the secret being enrolled.
+func (u *userSvc) ConfirmTotp(ctx context.Context, userID string, code string) (bool, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	user, err := findUserByID(ctx, userCollection, userID)
+	if err != nil {
+		return false, err
+	} else if user.MFAEnabled {
+		return false, errMfaEnabled
+	} else if user.TOTPPendingSecret == "" {
+		return false, errNoTotpEnrollment
+	}
+
+	secret, err := mfa.DecryptSecret(ctx, userID, user.TOTPPendingSecret)
+	if err != nil {
+		return false, err
+	}
+	step, ok := mfa.ValidateCode(secret, code, time.Now())
+	if !ok {
+		return false, errInvalidMfaCode
+	}
+
+	// The secret must still be the one the code was checked against
+	filter := bson.M{"user_id": userID, "totp_pending_secret": user.TOTPPendingSecret}
+	update := bson.M{
+		"$set": bson.M{
+			"mfa_enabled":      true,
+			"totp_secret":      user.TOTPPendingSecret,
+			"totp_last_step":   step,
+			"recovery_codes":   user.PendingRecoveryCodes,
+			"last_update_date": time.Now().UTC(),
+		},
+		"$unset": bson.M{"totp_pending_secret": "", "pending_recovery_codes": ""},
+		"$inc":   bson.M{"version": 1},
+	}
+	result, err := userCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	} else if result.MatchedCount == 0 {
+		return false, errNoTotpEnrollment
+	}
+	return true, nil
+}
+
+// VerifyMfa completes a login which returned an MFA challenge, issuing an access and refresh token
+// pair once the code is verified. A challenge allows a few attempts, and can only be completed once.
+// Wrong codes count as failed logins with the user name and email of the user, so that guessing
+// codes across challenges locks the user like guessing passwords does.
+func (u *userSvc) VerifyMfa(ctx context.Context, challenge string, code string) (*model.AuthPayload, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	configSupplier, err := config.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := configSupplier.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := token.AttemptActionToken(ctx, challenge, token.PurposeMFAChallenge, maxMfaAttempts)
+	if err != nil {
+		return nil, err
+	}
+	user, err := findUserByID(ctx, userCollection, userID)
+	if err != nil {
+		return nil, err
+	} else if !user.MFAEnabled {
+		// Challenges are only issued to users with MFA enabled. Should that no longer hold, the
+		// challenge is refused rather than completed without a second factor.
+		return nil, token.ErrInvalidActionToken
+	}
+
+	// Wrong codes count like wrong passwords, so a challenge is refused while the user is locked
+	if err = lockout.Check(ctx, user.UserName, user.Email); err != nil {
+		return nil, err
+	}
+	if err = acceptMfaCode(ctx, userCollection, user, code); err != nil {
+		if errors.Is(err, errInvalidMfaCode) {
+			recordLoginFailure(ctx, user.UserID, user.UserName, user.Email)
+			logLoginFailure(ctx, "invalid MFA code", user.UserID)
+		}
+		return nil, err
+	}
+	if _, err = token.ConsumeActionToken(ctx, challenge, token.PurposeMFAChallenge); err != nil {
+		return nil, err
+	}
+	if passwordChangeRequired(user, cfg.PasswordMaxAge) {
+		resetLoginFailures(ctx, user)
+		return issuePasswordChangeToken(ctx, user)
+	}
+	return completeLogin(ctx, userCollection, user)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of a user with MFA enabled with a new set,
+// once the user proves they still hold a second factor. Wrong codes count as failed logins of the
+// user like on VerifyMfa, so that a stolen session cannot be used to guess codes either.
+func (u *userSvc) RegenerateRecoveryCodes(ctx context.Context, userID string, code string) ([]string, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := findUserByID(ctx, userCollection, userID)
+	if err != nil {
+		return nil, err
+	} else if !user.MFAEnabled {
+		return nil, errMfaNotEnabled
+	}
+	if err = lockout.Check(ctx, user.UserName, user.Email); err != nil {
+		return nil, err
+	}
+	if err = acceptMfaCode(ctx, userCollection, user, code); err != nil {
+		if errors.Is(err, errInvalidMfaCode) {
+			recordLoginFailure(ctx, user.UserID, user.UserName, user.Email)
+		}
+		return nil, err
+	}
+
+	codes, hashes, err := mfa.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	update := bson.M{
+		"$set": bson.M{"recovery_codes": hashes, "last_update_date": time.Now().UTC()},
+		"$inc": bson.M{"version": 1},
+	}
+	result, err := userCollection.UpdateOne(ctx, bson.M{"user_id": userID, "mfa_enabled": true}, update)
+	if err != nil {
+		return nil, err
+	} else if result.MatchedCount == 0 {
+		return nil, errMfaNotEnabled
+	}
+	return codes, nil
+}
+
+// BeginPasskeyRegistration starts registering a passkey for the user, returning the options for
+// the browser to create it with. A passkey logs the user in without their password or second
+// factor, so the user proves they know their current password, and users with MFA also give a
+// code. Wrong passwords and codes count as failed logins of the user, like on ChangePassword.
+func (u *userSvc) BeginPasskeyRegistration(
+	ctx context.Context, userID string, currentPassword string, code *string,
+) (string, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	user, err := findUserByID(ctx, userCollection, userID)
+	if err != nil {
+		return "", err
+	}
+	if err = verifyCurrentPassword(ctx, user, currentPassword); err != nil {
+		return "", err
+	}
+	if user.MFAEnabled {
+		if code == nil || *code == "" {
+			return "", errMfaCodeRequired
+		}
+		if err = acceptMfaCode(ctx, userCollection, user, *code); err != nil {
+			if errors.Is(err, errInvalidMfaCode) {
+				recordLoginFailure(ctx, user.UserID, user.UserName, user.Email)
+			}
+			return "", err
+		}
+	}
+	return passkey.BeginRegistration(ctx, passkeyAccount(user))
+}
+
+// FinishPasskeyRegistration verifies the passkey the browser created for a registration begun with
+// BeginPasskeyRegistration and adds it to the user. The registration can only be finished once,
+// within WEBAUTHN_CHALLENGE_TTL of the user proving their password when beginning it.
+func (u *userSvc) FinishPasskeyRegistration(
+	ctx context.Context, userID string, response string, name *string,
+) (*model.Passkey, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := findUserByID(ctx, userCollection, userID)
+	if err != nil {
+		return nil, err
+	}
+	credential, err := passkey.FinishRegistration(ctx, passkeyAccount(user), response)
+	if err != nil {
+		return nil, err
+	}
+	if name != nil {
+		credential.Name = *name
+	}
+
+	// The unique index only keeps a credential from belonging to two users, so the filter keeps the
+	// user from holding it twice
+	filter := bson.M{"user_id": userID, "passkeys.credential_id": bson.M{"$ne": credential.ID}}
+	update := bson.M{
+		"$push": bson.M{"passkeys": credential},
+		"$set":  bson.M{"last_update_date": time.Now().UTC()},
+		"$inc":  bson.M{"version": 1},
+	}
+	result, err := userCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		// Credential IDs are unique across users
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errPasskeyRegistered
+		}
+		return nil, err
+	} else if result.MatchedCount == 0 {
+		return nil, errPasskeyRegistered
+	}
+	return toModelPasskey(credential), nil
+}
+
+// ListPasskeys returns the passkeys the user can log in with.
+func (u *userSvc) ListPasskeys(ctx context.Context, userID string) ([]*model.Passkey, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := findUserByID(ctx, userCollection, userID)
+	if err != nil {
+		return nil, err
+	}
+	return toModelPasskeys(user.Passkeys), nil
+}
+
+// DeletePasskey removes the passkey with the given ID from the user, so that it can no longer be
+// used to log in.
+func (u *userSvc) DeletePasskey(ctx context.Context, userID string, passkeyID string) (bool, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	credentialID, err := base64.RawURLEncoding.DecodeString(passkeyID)
+	if err != nil || len(credentialID) == 0 {
+		return false, errPasskeyNotFound
+	}
+	filter := bson.M{"user_id": userID, "passkeys.credential_id": credentialID}
+	update := bson.M{
+		"$pull": bson.M{"passkeys": bson.M{"credential_id": credentialID}},
+		"$set":  bson.M{"last_update_date": time.Now().UTC()},
+		"$inc":  bson.M{"version": 1},
+	}
+	result, err := userCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	} else if result.MatchedCount == 0 {
+		return false, errPasskeyNotFound
+	}
+	return true, nil
+}
+
+// BeginPasskeyLogin starts a passkey login, returning the options for the browser to sign it with.
+func (u *userSvc) BeginPasskeyLogin(ctx context.Context) (string, error) {
+	return passkey.BeginLogin(ctx)
+}
+
+// LoginWithPasskey authenticates the user whose passkey signed a login begun with
+// BeginPasskeyLogin, and issues an access and refresh token pair. Passkeys verify the user on the
+// authenticator, so they stand in for both factors and users with MFA are not asked for a code.
+// Users who must change their password get a token only permitting ChangePassword, like on Login.
+func (u *userSvc) LoginWithPasskey(ctx context.Context, response string) (*model.AuthPayload, error) {
+	userCollection, err := getUserCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	configSupplier, err := config.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := configSupplier.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var user *userDB
+	userID, credential, err := passkey.FinishLogin(ctx, response, func(userID string) (passkey.Account, error) {
+		found, err := findUserByID(ctx, userCollection, userID)
+		if err != nil {
+			return passkey.Account{}, err
+		}
+		user = found
+		return passkeyAccount(user), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = checkEmailVerified(user, cfg.RequireVerifiedEmail); err != nil {
+		return nil, err
+	}
+
+	// Keep the signature counter, so that a clone of the passkey is detected when it is used
+	filter := bson.M{"user_id": userID, "passkeys.credential_id": credential.ID}
+	update := bson.M{"$set": bson.M{
+		"passkeys.$.sign_count":     credential.SignCount,
+		"passkeys.$.backup_state":   credential.BackupState,
+		"passkeys.$.last_used_date": time.Now().UTC(),
+	}}
+	if _, err = userCollection.UpdateOne(ctx, filter, update); err != nil {
+		return nil, err
+	}
+	// A passkey does not replace changing a password an admin or its age requires to change
+	if passwordChangeRequired(user, cfg.PasswordMaxAge) {
+		resetLoginFailures(ctx, user)
+		return issuePasswordChangeToken(ctx, user)
+	}
+	return completeLogin(ctx, userCollection, user)
+}
@@ -1,19 +1,39 @@
 package user
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"log/slog"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/ahummel25/user-auth-api/graphql/errcode"
 	"github.com/ahummel25/user-auth-api/graphql/model"
+	"github.com/ahummel25/user-auth-api/service/lockout"
+	lockoutMocks "github.com/ahummel25/user-auth-api/service/lockout/mocks"
+	"github.com/ahummel25/user-auth-api/service/mfa"
+	"github.com/ahummel25/user-auth-api/service/notify"
+	notifyMocks "github.com/ahummel25/user-auth-api/service/notify/mocks"
+	"github.com/ahummel25/user-auth-api/service/passkey"
+	passkeyMocks "github.com/ahummel25/user-auth-api/service/passkey/mocks"
+	"github.com/ahummel25/user-auth-api/service/passkey/passkeytest"
+	"github.com/ahummel25/user-auth-api/service/password"
+	"github.com/ahummel25/user-auth-api/service/token"
+	tokenMocks "github.com/ahummel25/user-auth-api/service/token/mocks"
 	userMocks "github.com/ahummel25/user-auth-api/service/user/mocks"
 	"github.com/ahummel25/user-auth-api/testutils"
 )
@@ -22,6 +42,9 @@ func TestMain(m *testing.M) {
 	// Set a fixed time for all tests
 	fixedTime := time.Date(2024, 8, 30, 23, 41, 18, 0, time.UTC)
 	testutils.SetFixedTime(fixedTime)
+	// Passwords expire after 90 days, so that users whose password was changed longer ago must
+	// change it. Users without a passwordChangedAt are not affected.
+	_ = os.Setenv("PASSWORD_MAX_AGE", "2160h")
 
 	// Run the tests
 	code := m.Run()
@@ -33,16 +56,22 @@ func TestMain(m *testing.M) {
 }
 
 func TestLogin(t *testing.T) {
+	userAttempts := mock.MatchedBy(func(filter bson.M) bool { return filter["key"] == loginAttemptsKey("testuser") })
+	noAttempts := mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil)
+
 	t.Run("successful authentication", func(t *testing.T) {
 		mockColl := userMocks.NewMockUserCollection(t)
-		ctx := createContextWithMockCollection(mockColl)
+		mockRefreshColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		mockAttemptsColl := lockoutMocks.NewMockAttemptsCollection(t)
+		ctx := withMockRefreshTokenCollection(createContextWithMockCollection(mockColl), mockRefreshColl)
+		ctx = withMockLoginAttempts(ctx, mockAttemptsColl)
 
-		hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.DefaultCost)
+		hashedPassword := mustHashPassword(t, "password")
 		user := userDB{
 			UserID:    "test-id",
 			Email:     "test@example.com",
 			UserName:  "testuser",
-			Password:  string(hashedPassword),
+			Password:  hashedPassword,
 			FirstName: "Test",
 			LastName:  "User",
 			Role:      model.RoleUser,
@@ -63,6 +92,10 @@ func TestLogin(t *testing.T) {
 		// Add expectation for UpdateOne
 		updateFilter := bson.M{"user_id": user.UserID}
 		mockColl.On("UpdateOne", ctx, updateFilter, mock.AnythingOfType("bson.M")).Return(&mongo.UpdateResult{}, nil)
+		mockRefreshColl.On("InsertOne", ctx, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+		// Earlier failed logins of the user are forgotten
+		mockAttemptsColl.On("FindOne", ctx, userAttempts).Return(noAttempts)
+		expectLoginReset(ctx, mockAttemptsColl, user)
 
 		userSvc := &userSvc{}
 		result, err := userSvc.Login(ctx, "testuser", "password")
@@ -72,12 +105,29 @@ func TestLogin(t *testing.T) {
 		assert.Equal(t, user.UserID, result.User.ID)
 		assert.Equal(t, user.Email, result.User.Email)
 		assert.NotNil(t, result.User.LastLoginDate)
+		assert.Equal(t, model.AuthStatusAuthenticated, result.Status)
+		require.NotNil(t, result.AccessToken)
+		assert.True(t, result.ExpiresAt.After(time.Now()))
+
+		// The issued token should validate and carry the user's ID and role
+		parsed, err := token.JwtValidate(withUnrevokedTokens(t, ctx), *result.AccessToken)
+		assert.NoError(t, err)
+		claims, ok := parsed.Claims.(*token.JwtCustomClaim)
+		assert.True(t, ok)
+		assert.Equal(t, user.UserID, claims.UserID)
+		assert.Equal(t, user.Role, claims.Role)
+		assert.NotEmpty(t, claims.SessionID)
+		assert.NotEmpty(t, result.RefreshToken)
+		assert.True(t, result.RefreshTokenExpiresAt.After(*result.ExpiresAt))
 		mockColl.AssertExpectations(t)
+		mockRefreshColl.AssertExpectations(t)
 	})
 
 	t.Run("user not found", func(t *testing.T) {
 		mockColl := userMocks.NewMockUserCollection(t)
-		ctx := createContextWithMockCollection(mockColl)
+		mockAttemptsColl := lockoutMocks.NewMockAttemptsCollection(t)
+		ctx := withMockLoginAttempts(createContextWithMockCollection(mockColl), mockAttemptsColl)
+		ctx = lockout.NewClientIPContext(ctx, "192.0.2.1")
 
 		expectedFilter := bson.M{
 			"$or": []bson.M{
@@ -88,13 +138,26 @@ func TestLogin(t *testing.T) {
 
 		mockResult := mongo.NewSingleResultFromDocument(userDB{}, mongo.ErrNoDocuments, nil)
 		mockColl.On("FindOne", ctx, expectedFilter).Return(mockResult)
+		// The failure counts against the identifier like for known users, and against the client
+		for _, key := range []string{"ip:192.0.2.1", loginAttemptsKey("nonexistent@example.com")} {
+			mockAttemptsColl.On("FindOne", ctx, mock.MatchedBy(func(filter bson.M) bool { return filter["key"] == key })).
+				Return(noAttempts)
+			mockAttemptsColl.On("FindOneAndUpdate", ctx, bson.M{"key": key}, mock.Anything, mock.Anything).
+				Return(mongo.NewSingleResultFromDocument(bson.M{"failures": 1}, nil, nil))
+		}
+
+		logs := captureLogs(t)
 
 		userSvc := &userSvc{}
 		result, err := userSvc.Login(ctx, "nonexistent@example.com", "password")
 
+		// Unknown users fail like wrong passwords, and only the logs tell why
 		assert.Error(t, err)
 		assert.Nil(t, result)
-		assert.Equal(t, errNoUserFound, err)
+		assert.Equal(t, errInvalidCredentials, err)
+		assert.Contains(t, logs.String(), `"reason":"user not found"`)
+		assert.Contains(t, logs.String(), `"ip":"192.0.2.1"`)
+		assert.NotContains(t, logs.String(), "nonexistent@example.com")
 		mockColl.AssertExpectations(t)
 	})
 
@@ -125,14 +188,15 @@ func TestLogin(t *testing.T) {
 
 	t.Run("invalid password", func(t *testing.T) {
 		mockColl := userMocks.NewMockUserCollection(t)
-		ctx := createContextWithMockCollection(mockColl)
+		mockAttemptsColl := lockoutMocks.NewMockAttemptsCollection(t)
+		ctx := withMockLoginAttempts(createContextWithMockCollection(mockColl), mockAttemptsColl)
 
-		hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("correctpassword"), bcrypt.DefaultCost)
+		hashedPassword := mustHashPassword(t, "correctpassword")
 		user := userDB{
 			UserID:   "test-id",
 			Email:    "test@example.com",
 			UserName: "testuser",
-			Password: string(hashedPassword),
+			Password: hashedPassword,
 		}
 
 		expectedFilter := bson.M{
@@ -144,27 +208,123 @@ func TestLogin(t *testing.T) {
 
 		mockResult := mongo.NewSingleResultFromDocument(user, nil, nil)
 		mockColl.On("FindOne", ctx, expectedFilter).Return(mockResult)
+		mockAttemptsColl.On("FindOne", ctx, userAttempts).Return(noAttempts)
+		mockAttemptsColl.On("FindOneAndUpdate", ctx, bson.M{"key": loginAttemptsKey("testuser")}, mock.Anything, mock.Anything).
+			Return(mongo.NewSingleResultFromDocument(bson.M{"failures": 1}, nil, nil))
+
+		logs := captureLogs(t)
 
 		userSvc := &userSvc{}
 		result, err := userSvc.Login(ctx, "testuser", "wrongpassword")
 
 		assert.Error(t, err)
 		assert.Nil(t, result)
-		assert.Equal(t, errInvalidPassword, err)
+		assert.Equal(t, errInvalidCredentials, err)
+		assert.Contains(t, logs.String(), `"reason":"invalid password","user_id":"test-id"`)
 		mockColl.AssertExpectations(t)
 	})
 
+	t.Run("locked user", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockAttemptsColl := lockoutMocks.NewMockAttemptsCollection(t)
+		ctx := withMockLoginAttempts(createContextWithMockCollection(mockColl), mockAttemptsColl)
+
+		hashedPassword := mustHashPassword(t, "password")
+		user := userDB{UserID: "test-id", UserName: "testuser", Password: hashedPassword}
+		mockColl.On("FindOne", ctx, mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+		mockAttemptsColl.On("FindOne", ctx, userAttempts).Return(mongo.NewSingleResultFromDocument(
+			bson.M{"key": loginAttemptsKey("testuser"), "failures": 5, "last_failure_date": time.Now().UTC()}, nil, nil))
+
+		userSvc := &userSvc{}
+		// Even the right password is refused until the lock is lifted
+		result, err := userSvc.Login(ctx, "testuser", "password")
+
+		assert.Equal(t, lockout.ErrLocked, err)
+		assert.Nil(t, result)
+		mockAttemptsColl.AssertNotCalled(t, "FindOneAndUpdate", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("outdated password hash", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockRefreshColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		mockAttemptsColl := lockoutMocks.NewMockAttemptsCollection(t)
+		ctx := withMockRefreshTokenCollection(createContextWithMockCollection(mockColl), mockRefreshColl)
+		ctx = withMockLoginAttempts(ctx, mockAttemptsColl)
+
+		// A hash made before argon2id became the policy
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+		require.NoError(t, err)
+		user := userDB{UserID: "test-id", UserName: "testuser", Password: string(hashedPassword), Role: model.RoleUser}
+		mockColl.On("FindOne", ctx, mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+		var rehash bson.M
+		mockColl.On("UpdateOne", ctx, bson.M{"user_id": user.UserID, "password": user.Password}, mock.AnythingOfType("bson.M")).
+			Run(func(args mock.Arguments) { rehash = args.Get(2).(bson.M) }).
+			Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+		mockColl.On("UpdateOne", ctx, bson.M{"user_id": user.UserID}, mock.AnythingOfType("bson.M")).Return(&mongo.UpdateResult{}, nil)
+		mockRefreshColl.On("InsertOne", ctx, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+		mockAttemptsColl.On("FindOne", ctx, userAttempts).Return(noAttempts)
+		expectLoginReset(ctx, mockAttemptsColl, user)
+
+		userSvc := &userSvc{}
+		result, err := userSvc.Login(ctx, "testuser", "password")
+
+		require.NoError(t, err)
+		assert.NotNil(t, result.AccessToken)
+		set := rehash["$set"].(bson.M)
+		assert.True(t, strings.HasPrefix(set["password"].(string), "$argon2id$"))
+		assertPasswordHash(t, set["password"].(string), "password")
+		// Upgrading the hash is not a change of the user
+		assert.NotContains(t, rehash, "$inc")
+	})
+
+	t.Run("password change required", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockAttemptsColl := lockoutMocks.NewMockAttemptsCollection(t)
+		ctx := withMockLoginAttempts(createContextWithMockCollection(mockColl), mockAttemptsColl)
+
+		user := userDB{
+			UserID: "test-id", UserName: "testuser", Password: mustHashPassword(t, "password"), Role: model.RoleAdmin,
+			MustChangePassword: true,
+		}
+		mockColl.On("FindOne", ctx, mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+		mockAttemptsColl.On("FindOne", ctx, userAttempts).Return(noAttempts)
+		expectLoginReset(ctx, mockAttemptsColl, user)
+
+		userSvc := &userSvc{}
+		result, err := userSvc.Login(ctx, "testuser", "password")
+
+		require.NoError(t, err)
+		assert.Equal(t, model.AuthStatusPasswordChangeRequired, result.Status)
+		assert.True(t, result.User.MustChangePassword)
+		// No session is started, only a token restricted to changing the password is issued
+		assert.Nil(t, result.RefreshToken)
+		require.NotNil(t, result.AccessToken)
+		parsed, err := token.JwtValidate(withUnrevokedTokens(t, ctx), *result.AccessToken)
+		require.NoError(t, err)
+		claims := parsed.Claims.(*token.JwtCustomClaim)
+		assert.Equal(t, token.ScopeChangePassword, claims.Scope)
+		assert.Equal(t, model.RoleAdmin, claims.Role)
+		assert.Empty(t, claims.SessionID)
+		mockColl.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+	})
+
 	t.Run("update last login date fails", func(t *testing.T) {
 		mockColl := userMocks.NewMockUserCollection(t)
-		ctx := createContextWithMockCollection(mockColl)
+		mockRefreshColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		mockAttemptsColl := lockoutMocks.NewMockAttemptsCollection(t)
+		ctx := withMockRefreshTokenCollection(createContextWithMockCollection(mockColl), mockRefreshColl)
+		ctx = withMockLoginAttempts(ctx, mockAttemptsColl)
 
 		oldLoginDate := testutils.CurrentTime.Now().Add(-24 * time.Hour)
-		hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.DefaultCost)
+		hashedPassword := mustHashPassword(t, "password")
 		user := userDB{
 			UserID:        "test-id",
 			Email:         "test@example.com",
 			UserName:      "testuser",
-			Password:      string(hashedPassword),
+			Password:      hashedPassword,
 			FirstName:     "Test",
 			LastName:      "User",
 			Role:          model.RoleUser,
@@ -184,6 +344,9 @@ func TestLogin(t *testing.T) {
 		// Add expectation for UpdateOne to fail
 		updateFilter := bson.M{"user_id": user.UserID}
 		mockColl.On("UpdateOne", ctx, updateFilter, mock.AnythingOfType("bson.M")).Return(nil, errors.New("update failed"))
+		mockRefreshColl.On("InsertOne", ctx, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+		mockAttemptsColl.On("FindOne", ctx, userAttempts).Return(noAttempts)
+		expectLoginReset(ctx, mockAttemptsColl, user)
 
 		userSvc := &userSvc{}
 		result, err := userSvc.Login(ctx, "testuser", "password")
@@ -196,6 +359,78 @@ func TestLogin(t *testing.T) {
 		assert.Equal(t, oldLoginDate, *result.User.LastLoginDate) // Check that the old login date is used
 		mockColl.AssertExpectations(t)
 	})
+
+	t.Run("MFA required", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockActionColl := tokenMocks.NewMockActionTokenCollection(t)
+		mockAttemptsColl := lockoutMocks.NewMockAttemptsCollection(t)
+		ctx := withMockLoginAttempts(createContextWithMockCollection(mockColl), mockAttemptsColl)
+		ctx = token.NewContext(ctx, token.GetActionTokensCollectionKey(), mockActionColl)
+
+		hashedPassword := mustHashPassword(t, "password")
+		user := userDB{UserID: "test-id", UserName: "testuser", Password: hashedPassword, MFAEnabled: true}
+		mockColl.On("FindOne", ctx, mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+		mockAttemptsColl.On("FindOne", ctx, userAttempts).Return(noAttempts)
+		mockActionColl.On("InsertOne", ctx, mock.MatchedBy(func(doc any) bool {
+			raw, _ := bson.Marshal(doc)
+			return bson.Raw(raw).Lookup("purpose").StringValue() == string(token.PurposeMFAChallenge)
+		})).Return(&mongo.InsertOneResult{}, nil)
+
+		userSvc := &userSvc{}
+		result, err := userSvc.Login(ctx, "testuser", "password")
+
+		// No session is started and nothing about the user is returned until the code is verified
+		require.NoError(t, err)
+		assert.Equal(t, model.AuthStatusMfaRequired, result.Status)
+		require.NotNil(t, result.MfaChallenge)
+		assert.NotEmpty(t, *result.MfaChallenge)
+		assert.True(t, result.MfaChallengeExpiresAt.After(time.Now()))
+		assert.Nil(t, result.User)
+		assert.Nil(t, result.AccessToken)
+		assert.Nil(t, result.RefreshToken)
+		mockColl.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestLogin_UnknownUserLockout(t *testing.T) {
+	// Logs in repeatedly with a wrong password, right after the previous attempt and once its
+	// delay has passed, against attempts kept in memory
+	loginResponses := func(t *testing.T, identifier string, user *userDB) []error {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx, waitForDelay := withLoginAttemptsInMemory(t, createContextWithMockCollection(mockColl))
+
+		mockColl.On("FindOne", ctx, mock.AnythingOfType("bson.M")).Return(
+			func(context.Context, any, ...options.Lister[options.FindOneOptions]) *mongo.SingleResult {
+				if user == nil {
+					return mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil)
+				}
+				return mongo.NewSingleResultFromDocument(user, nil, nil)
+			})
+
+		userSvc := &userSvc{}
+		var responses []error
+		// One more round than the default lockout threshold
+		for range 6 {
+			for range 2 {
+				_, err := userSvc.Login(ctx, identifier, "wrongpassword")
+				responses = append(responses, err)
+			}
+			waitForDelay()
+		}
+		return responses
+	}
+
+	user := &userDB{UserID: "test-id", UserName: "testuser", Email: "test@example.com", Password: mustHashPassword(t, "password")}
+	known := loginResponses(t, "testuser", user)
+	unknown := loginResponses(t, "nobody", nil)
+
+	// Guessing the password of an existing user is throttled and locked exactly like guessing that
+	// of a user who does not exist, so the responses do not tell them apart
+	assert.Equal(t, known, unknown)
+	assert.Equal(t, errInvalidCredentials, unknown[0])
+	assert.Equal(t, lockout.ErrThrottled, unknown[1])
+	assert.Equal(t, lockout.ErrLocked, unknown[len(unknown)-1])
 }
 
 func TestCreateUser(t *testing.T) {
@@ -215,6 +450,7 @@ func TestCreateUser(t *testing.T) {
 			"role",
 			"creation_date",
 			"last_update_date",
+			"email_verified",
 		}
 		for _, field := range requiredFields {
 			if !containsKey(bsonDoc, field) {
@@ -225,12 +461,12 @@ func TestCreateUser(t *testing.T) {
 	})
 	t.Run("successful user creation", func(t *testing.T) {
 		mockColl := userMocks.NewMockUserCollection(t)
-		ctx := createContextWithMockCollection(mockColl)
+		ctx, mockSender := withMockEmailVerification(t, createContextWithMockCollection(mockColl))
 
 		newUser := model.NewUserInput{
 			Email:     "test@example.com",
 			UserName:  "testuser",
-			Password:  "password",
+			Password:  "Glacier-Orbit-42",
 			FirstName: "Test",
 			LastName:  "User",
 		}
@@ -252,9 +488,68 @@ func TestCreateUser(t *testing.T) {
 		assert.NotNil(t, result)
 		assert.Equal(t, newUser.Email, result.User.Email)
 		assert.Equal(t, newUser.UserName, result.User.UserName)
+		assert.False(t, result.User.EmailVerified)
+		mockSender.AssertCalled(t, "Send", ctx, mock.MatchedBy(func(msg notify.Message) bool {
+			return msg.To == newUser.Email && msg.Subject == "Confirm your email address"
+		}))
 		mockColl.AssertExpectations(t)
 	})
 
+	t.Run("with locale", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx, mockSender := withMockEmailVerification(t, createContextWithMockCollection(mockColl))
+
+		locale := "es"
+		newUser := model.NewUserInput{
+			Email:     "test@example.com",
+			UserName:  "testuser",
+			Password:  "Glacier-Orbit-42",
+			FirstName: "Test",
+			LastName:  "User",
+			Locale:    &locale,
+		}
+
+		mockColl.On("CountDocuments", ctx, mock.AnythingOfType("bson.M")).Return(int64(0), nil)
+		mockColl.On("InsertOne", ctx, mock.MatchedBy(func(doc bson.D) bool {
+			return containsKey(doc, "locale")
+		})).Return(&mongo.InsertOneResult{}, nil)
+
+		userSvc := &userSvc{}
+		result, err := userSvc.CreateUser(ctx, newUser)
+
+		require.NoError(t, err)
+		assert.Equal(t, &locale, result.User.Locale)
+		// The verification message is sent in the language of the user
+		mockSender.AssertCalled(t, "Send", ctx, mock.MatchedBy(func(msg notify.Message) bool {
+			return msg.Subject == "Confirma tu dirección de correo"
+		}))
+	})
+
+	t.Run("password breaking the policy", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		newUser := model.NewUserInput{
+			Email:    "test@example.com",
+			UserName: "testuser",
+			Password: "testuser",
+		}
+
+		userSvc := &userSvc{}
+		result, err := userSvc.CreateUser(ctx, newUser)
+
+		var codeErr *errcode.Error
+		require.ErrorAs(t, err, &codeErr)
+		assert.Equal(t, errcode.PasswordPolicyViolation, codeErr.Code)
+		assert.Equal(t, []password.Violation{
+			{Rule: password.RulePersonalInfo, Message: "password must not contain the user name, email or name of the user"},
+			{Rule: password.RuleStrength, Message: "password is too easy to guess, use a longer password or more words"},
+		}, codeErr.Extensions["violations"])
+		assert.Nil(t, result)
+		// Nothing is looked up or stored
+		mockColl.AssertNotCalled(t, "CountDocuments", mock.Anything, mock.Anything)
+	})
+
 	t.Run("user already exists", func(t *testing.T) {
 		mockColl := userMocks.NewMockUserCollection(t)
 		ctx := createContextWithMockCollection(mockColl)
@@ -262,7 +557,7 @@ func TestCreateUser(t *testing.T) {
 		newUser := model.NewUserInput{
 			Email:    "existing@example.com",
 			UserName: "existinguser",
-			Password: "password",
+			Password: "Glacier-Orbit-42",
 		}
 
 		expectedCountFilter := bson.M{
@@ -282,6 +577,27 @@ func TestCreateUser(t *testing.T) {
 		mockColl.AssertExpectations(t)
 	})
 
+	t.Run("user created concurrently", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+		newUser := model.NewUserInput{
+			Email:    "test@example.com",
+			UserName: "testuser",
+			Password: "Glacier-Orbit-42",
+		}
+
+		mockColl.On("CountDocuments", ctx, mock.AnythingOfType("bson.M")).Return(int64(0), nil)
+		// Another user took the email between the check and the insert
+		mockColl.On("InsertOne", ctx, docMatcher).
+			Return(nil, mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}})
+
+		userSvc := &userSvc{}
+		result, err := userSvc.CreateUser(ctx, newUser)
+
+		assert.Nil(t, result)
+		assert.Equal(t, errUserAlreadyExists, err)
+	})
+
 	t.Run("database error", func(t *testing.T) {
 		mockColl := userMocks.NewMockUserCollection(t)
 		ctx := createContextWithMockCollection(mockColl)
@@ -289,7 +605,7 @@ func TestCreateUser(t *testing.T) {
 		newUser := model.NewUserInput{
 			Email:    "test@example.com",
 			UserName: "testuser",
-			Password: "password",
+			Password: "Glacier-Orbit-42",
 		}
 
 		expectedCountFilter := bson.M{
@@ -364,10 +680,2112 @@ func TestDeleteUser(t *testing.T) {
 	})
 }
 
-// Helper function to create a context with mock collection
-func createContextWithMockCollection(collection UserCollection) context.Context {
-	ctx := context.Background()
-	return NewContext(ctx, GetUsersCollectionKey(), collection)
+func TestGetUserByID(t *testing.T) {
+	t.Run("user found", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		lastLoginDate := testutils.CurrentTime.Now()
+		user := userDB{
+			UserID:        "test-id",
+			Email:         "test@example.com",
+			UserName:      "testuser",
+			FirstName:     "Test",
+			LastName:      "User",
+			Role:          model.RoleAdmin,
+			LastLoginDate: &lastLoginDate,
+		}
+		mockResult := mongo.NewSingleResultFromDocument(user, nil, nil)
+		mockColl.On("FindOne", ctx, bson.M{"user_id": user.UserID}).Return(mockResult)
+
+		userSvc := &userSvc{}
+		result, err := userSvc.GetUserByID(ctx, user.UserID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, user.UserID, result.ID)
+		assert.Equal(t, user.Email, result.Email)
+		assert.Equal(t, user.Role, result.Role)
+		assert.Equal(t, lastLoginDate, result.LastLoginDate.UTC())
+		mockColl.AssertExpectations(t)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		mockResult := mongo.NewSingleResultFromDocument(userDB{}, mongo.ErrNoDocuments, nil)
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "nonexistent"}).Return(mockResult)
+
+		userSvc := &userSvc{}
+		result, err := userSvc.GetUserByID(ctx, "nonexistent")
+
+		assert.Nil(t, result)
+		assert.Equal(t, errNoUserFound, err)
+		assert.True(t, IsNotFound(err))
+		mockColl.AssertExpectations(t)
+	})
+}
+
+func TestUpdateUser(t *testing.T) {
+	userID := "test-id"
+	stored := userDB{
+		UserID:    userID,
+		Email:     "new@example.com",
+		UserName:  "testuser",
+		FirstName: "Test",
+		LastName:  "User",
+		Role:      model.RoleUser,
+		Version:   4,
+	}
+	current := stored
+	current.Email = "old@example.com"
+	current.EmailVerified = true
+	current.Version = 3
+	current.Password = mustHashPassword(t, "currentPassword")
+	email := "new@example.com"
+	currentPassword := "currentPassword"
+
+	t.Run("successful update", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx, mockSender := withMockEmailVerification(t, createContextWithMockCollection(mockColl))
+		ctx, _ = withNoLoginFailures(t, ctx)
+
+		mockColl.On("FindOne", ctx, bson.M{"user_id": userID}).
+			Return(mongo.NewSingleResultFromDocument(current, nil, nil))
+		var update bson.M
+		mockColl.On("FindOneAndUpdate", ctx, bson.M{"user_id": userID, "version": 3}, mock.AnythingOfType("bson.M"), mock.Anything).
+			Run(func(args mock.Arguments) { update = args.Get(2).(bson.M) }).
+			Return(mongo.NewSingleResultFromDocument(stored, nil, nil))
+
+		userSvc := &userSvc{}
+		result, err := userSvc.UpdateUser(ctx, userID, model.UpdateUserInput{
+			Email: &email, Version: 3, CurrentPassword: &currentPassword,
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, email, result.Email)
+		assert.Equal(t, 4, result.Version)
+		changes := update["$set"].(bson.M)
+		assert.Equal(t, email, changes["email"])
+		assert.Contains(t, changes, "last_update_date")
+		assert.NotContains(t, changes, "user_name")
+		assert.Equal(t, bson.M{"version": 1}, update["$inc"])
+		// The new email address must be verified again
+		assert.Equal(t, false, changes["email_verified"])
+		mockSender.AssertCalled(t, "Send", ctx, mock.MatchedBy(func(msg notify.Message) bool {
+			return msg.To == email
+		}))
+		// The previous address is told where the account moved
+		mockSender.AssertCalled(t, "Send", ctx, mock.MatchedBy(func(msg notify.Message) bool {
+			return msg.To == current.Email && strings.Contains(msg.Text, email)
+		}))
+	})
+
+	t.Run("wrong current password", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx, mockAttemptsColl := withNoLoginFailures(t, createContextWithMockCollection(mockColl))
+
+		mockColl.On("FindOne", ctx, bson.M{"user_id": userID}).
+			Return(mongo.NewSingleResultFromDocument(current, nil, nil))
+		// The wrong password counts like a failed login of the user
+		for _, identifier := range []string{current.UserName, current.Email} {
+			mockAttemptsColl.On("FindOneAndUpdate", ctx, bson.M{"key": loginAttemptsKey(identifier)}, mock.Anything, mock.Anything).
+				Return(mongo.NewSingleResultFromDocument(bson.M{"failures": 1}, nil, nil)).Once()
+		}
+
+		userSvc := &userSvc{}
+		wrongPassword := "wrongPassword"
+		result, err := userSvc.UpdateUser(ctx, userID, model.UpdateUserInput{
+			Email: &email, Version: 3, CurrentPassword: &wrongPassword,
+		})
+
+		assert.Nil(t, result)
+		assert.Equal(t, errInvalidPassword, err)
+		mockColl.AssertNotCalled(t, "FindOneAndUpdate", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("unchanged email stays verified", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		unchanged := current.Email
+		mockColl.On("FindOne", ctx, bson.M{"user_id": userID}).
+			Return(mongo.NewSingleResultFromDocument(current, nil, nil))
+		var update bson.M
+		mockColl.On("FindOneAndUpdate", ctx, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M"), mock.Anything).
+			Run(func(args mock.Arguments) { update = args.Get(2).(bson.M) }).
+			Return(mongo.NewSingleResultFromDocument(current, nil, nil))
+
+		userSvc := &userSvc{}
+		_, err := userSvc.UpdateUser(ctx, userID, model.UpdateUserInput{Email: &unchanged, Version: 3})
+
+		require.NoError(t, err)
+		assert.NotContains(t, update["$set"], "email_verified")
+	})
+
+	t.Run("stale version of an email change", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		mockColl.On("FindOne", ctx, bson.M{"user_id": userID}).
+			Return(mongo.NewSingleResultFromDocument(current, nil, nil))
+
+		userSvc := &userSvc{}
+		result, err := userSvc.UpdateUser(ctx, userID, model.UpdateUserInput{Email: &email, Version: 2})
+
+		assert.Nil(t, result)
+		assert.Equal(t, errStaleUser, err)
+	})
+
+	t.Run("version zero matches users created before versioning", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		firstName := "Renamed"
+		filter := bson.M{"user_id": userID, "version": bson.M{"$in": bson.A{0, nil}}}
+		mockColl.On("FindOneAndUpdate", ctx, filter, mock.AnythingOfType("bson.M"), mock.Anything).
+			Return(mongo.NewSingleResultFromDocument(stored, nil, nil))
+
+		userSvc := &userSvc{}
+		_, err := userSvc.UpdateUser(ctx, userID, model.UpdateUserInput{FirstName: &firstName})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("stale version", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		role := model.RoleAdmin
+		mockColl.On("FindOneAndUpdate", ctx, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M"), mock.Anything).
+			Return(mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil))
+		mockColl.On("FindOne", ctx, bson.M{"user_id": userID}).
+			Return(mongo.NewSingleResultFromDocument(stored, nil, nil))
+
+		userSvc := &userSvc{}
+		result, err := userSvc.UpdateUser(ctx, userID, model.UpdateUserInput{Role: &role, Version: 2})
+
+		assert.Nil(t, result)
+		assert.Equal(t, errStaleUser, err)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		role := model.RoleAdmin
+		mockColl.On("FindOneAndUpdate", ctx, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M"), mock.Anything).
+			Return(mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil))
+		mockColl.On("FindOne", ctx, bson.M{"user_id": userID}).
+			Return(mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil))
+
+		userSvc := &userSvc{}
+		result, err := userSvc.UpdateUser(ctx, userID, model.UpdateUserInput{Role: &role})
+
+		assert.Nil(t, result)
+		assert.True(t, IsNotFound(err))
+	})
+
+	t.Run("email belongs to another user", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		userName := "taken"
+		mockColl.On("FindOne", ctx, bson.M{"user_id": userID}).
+			Return(mongo.NewSingleResultFromDocument(current, nil, nil))
+		// The unique indexes catch the email even when another update takes it at the same time
+		mockColl.On("FindOneAndUpdate", ctx, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M"), mock.Anything).
+			Return(mongo.NewSingleResultFromDocument(bson.M{}, mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}}, nil))
+
+		userSvc := &userSvc{}
+		result, err := userSvc.UpdateUser(ctx, userID, model.UpdateUserInput{Email: &email, UserName: &userName, Version: 3})
+
+		assert.Nil(t, result)
+		assert.Equal(t, errUserAlreadyExists, err)
+	})
+
+	t.Run("invalid changes", func(t *testing.T) {
+		blank := ""
+		tests := []struct {
+			name  string
+			input model.UpdateUserInput
+			err   error
+		}{
+			{name: "no changes", input: model.UpdateUserInput{Version: 1}, err: errNothingToUpdate},
+			{name: "blank last name", input: model.UpdateUserInput{LastName: &blank}, err: errBlankField},
+			{name: "blank locale", input: model.UpdateUserInput{Locale: &blank}, err: errBlankField},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := createContextWithMockCollection(userMocks.NewMockUserCollection(t))
+
+				userSvc := &userSvc{}
+				result, err := userSvc.UpdateUser(ctx, userID, tt.input)
+
+				assert.Nil(t, result)
+				assert.Equal(t, tt.err, err)
+			})
+		}
+	})
+}
+
+func TestRefreshToken(t *testing.T) {
+	const rawRefreshToken = "raw-refresh-token"
+	user := userDB{
+		UserID:   "test-id",
+		Email:    "test@example.com",
+		UserName: "testuser",
+		Role:     model.RoleAdmin,
+	}
+	storedToken := bson.M{"user_id": user.UserID, "family_id": "family-id"}
+
+	t.Run("successful refresh", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockRefreshColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		ctx := withMockRefreshTokenCollection(createContextWithMockCollection(mockColl), mockRefreshColl)
+
+		mockRefreshColl.On("FindOneAndUpdate", ctx, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(storedToken, nil, nil))
+		mockColl.On("FindOne", ctx, bson.M{"user_id": user.UserID}).
+			Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+		mockRefreshColl.On("InsertOne", ctx, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+
+		userSvc := &userSvc{}
+		result, err := userSvc.RefreshToken(ctx, rawRefreshToken)
+
+		assert.NoError(t, err)
+		assert.Equal(t, user.UserID, result.User.ID)
+		require.NotNil(t, result.AccessToken)
+		require.NotNil(t, result.RefreshToken)
+		assert.NotEqual(t, rawRefreshToken, *result.RefreshToken)
+
+		// The new access token stays in the same session as the rotated refresh token
+		parsed, err := token.JwtValidate(withUnrevokedTokens(t, ctx), *result.AccessToken)
+		assert.NoError(t, err)
+		claims := parsed.Claims.(*token.JwtCustomClaim)
+		assert.Equal(t, "family-id", claims.SessionID)
+		assert.Equal(t, model.RoleAdmin, claims.Role)
+	})
+
+	t.Run("expired password", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockRefreshColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		ctx := withMockRefreshTokenCollection(createContextWithMockCollection(mockColl), mockRefreshColl)
+
+		expired := user
+		changedAt := time.Now().UTC().Add(-91 * 24 * time.Hour)
+		expired.PasswordChangedAt = &changedAt
+		mockRefreshColl.On("FindOneAndUpdate", ctx, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(storedToken, nil, nil))
+		mockColl.On("FindOne", ctx, bson.M{"user_id": user.UserID}).
+			Return(mongo.NewSingleResultFromDocument(expired, nil, nil))
+		// The session ends rather than rotating into a new refresh token
+		mockRefreshColl.On("UpdateMany", ctx, bson.M{"family_id": "family-id"}, bson.M{"$set": bson.M{"revoked": true}}).
+			Return(&mongo.UpdateResult{}, nil)
+
+		userSvc := &userSvc{}
+		result, err := userSvc.RefreshToken(ctx, rawRefreshToken)
+
+		require.NoError(t, err)
+		assert.Equal(t, model.AuthStatusPasswordChangeRequired, result.Status)
+		assert.Nil(t, result.RefreshToken)
+		require.NotNil(t, result.AccessToken)
+		parsed, err := token.JwtValidate(withUnrevokedTokens(t, ctx), *result.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, token.ScopeChangePassword, parsed.Claims.(*token.JwtCustomClaim).Scope)
+		mockRefreshColl.AssertNotCalled(t, "InsertOne", mock.Anything, mock.Anything)
+	})
+
+	t.Run("invalid refresh token", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockRefreshColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		ctx := withMockRefreshTokenCollection(createContextWithMockCollection(mockColl), mockRefreshColl)
+
+		mockRefreshColl.On("FindOneAndUpdate", ctx, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil))
+		mockRefreshColl.On("FindOne", ctx, mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil))
+
+		userSvc := &userSvc{}
+		result, err := userSvc.RefreshToken(ctx, rawRefreshToken)
+
+		assert.Nil(t, result)
+		assert.EqualError(t, err, "invalid refresh token")
+	})
+
+	t.Run("user no longer exists", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockRefreshColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		ctx := withMockRefreshTokenCollection(createContextWithMockCollection(mockColl), mockRefreshColl)
+
+		mockRefreshColl.On("FindOneAndUpdate", ctx, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(storedToken, nil, nil))
+		mockColl.On("FindOne", ctx, bson.M{"user_id": user.UserID}).
+			Return(mongo.NewSingleResultFromDocument(userDB{}, mongo.ErrNoDocuments, nil))
+
+		userSvc := &userSvc{}
+		result, err := userSvc.RefreshToken(ctx, rawRefreshToken)
+
+		assert.Nil(t, result)
+		assert.Equal(t, errNoUserFound, err)
+	})
+}
+
+func TestLogout(t *testing.T) {
+	claims := &token.JwtCustomClaim{UserID: "test-id", SessionID: "family-id"}
+	claims.ID = "token-id"
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(10 * time.Minute))
+
+	t.Run("successful logout", func(t *testing.T) {
+		mockRefreshColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		mockRevokedColl := tokenMocks.NewMockRevokedTokenCollection(t)
+		ctx := withMockRefreshTokenCollection(context.Background(), mockRefreshColl)
+		ctx = token.NewContext(ctx, token.GetRevokedTokensCollectionKey(), mockRevokedColl)
+
+		mockRevokedColl.On("InsertOne", ctx, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+		mockRefreshColl.On("UpdateMany", ctx, bson.M{"family_id": "family-id"}, mock.AnythingOfType("bson.M")).
+			Return(&mongo.UpdateResult{}, nil)
+
+		userSvc := &userSvc{}
+		success, err := userSvc.Logout(ctx, claims)
+
+		assert.NoError(t, err)
+		assert.True(t, success)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mockRevokedColl := tokenMocks.NewMockRevokedTokenCollection(t)
+		ctx := token.NewContext(context.Background(), token.GetRevokedTokensCollectionKey(), mockRevokedColl)
+
+		mockRevokedColl.On("InsertOne", ctx, mock.Anything).Return(nil, errors.New("database error"))
+
+		userSvc := &userSvc{}
+		success, err := userSvc.Logout(ctx, claims)
+
+		assert.Error(t, err)
+		assert.False(t, success)
+	})
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	t.Run("successful revocation", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockRefreshColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		mockRevokedColl := tokenMocks.NewMockRevokedTokenCollection(t)
+		ctx := withMockRefreshTokenCollection(createContextWithMockCollection(mockColl), mockRefreshColl)
+		ctx = token.NewContext(ctx, token.GetRevokedTokensCollectionKey(), mockRevokedColl)
+
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+			Return(mongo.NewSingleResultFromDocument(userDB{UserID: "test-id"}, nil, nil))
+		mockRefreshColl.On("UpdateMany", ctx, bson.M{"user_id": "test-id", "revoked": false}, mock.AnythingOfType("bson.M")).
+			Return(&mongo.UpdateResult{}, nil)
+		mockRevokedColl.On("InsertOne", ctx, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+
+		userSvc := &userSvc{}
+		success, err := userSvc.RevokeAllSessions(ctx, "test-id")
+
+		assert.NoError(t, err)
+		assert.True(t, success)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "nonexistent"}).
+			Return(mongo.NewSingleResultFromDocument(userDB{}, mongo.ErrNoDocuments, nil))
+
+		userSvc := &userSvc{}
+		success, err := userSvc.RevokeAllSessions(ctx, "nonexistent")
+
+		assert.Equal(t, errNoUserFound, err)
+		assert.False(t, success)
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	currentPassword, newPassword := "currentPassword", "newPassword123"
+	hash, err := bcrypt.GenerateFromPassword([]byte(currentPassword), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := userDB{UserID: "test-id", UserName: "testuser", Email: "test@example.com", Password: string(hash)}
+	claims := &token.JwtCustomClaim{UserID: user.UserID, SessionID: "session-id"}
+	noAttempts := mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil)
+
+	// Helper function to add a mock login attempts collection, in which the user has no failures
+	withNoFailures := func(t *testing.T, ctx context.Context) (context.Context, *lockoutMocks.MockAttemptsCollection) {
+		mockAttemptsColl := lockoutMocks.NewMockAttemptsCollection(t)
+		ctx = withMockLoginAttempts(ctx, mockAttemptsColl)
+		mockAttemptsColl.On("FindOne", ctx, mock.AnythingOfType("bson.M")).Return(noAttempts)
+		return ctx, mockAttemptsColl
+	}
+
+	t.Run("successful change", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockRefreshColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		mockRevokedColl := tokenMocks.NewMockRevokedTokenCollection(t)
+		ctx := withMockRefreshTokenCollection(createContextWithMockCollection(mockColl), mockRefreshColl)
+		ctx = token.NewContext(ctx, token.GetRevokedTokensCollectionKey(), mockRevokedColl)
+		ctx, _ = withNoFailures(t, ctx)
+
+		mockColl.On("FindOne", ctx, bson.M{"user_id": user.UserID}).
+			Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+		var update bson.M
+		mockColl.On("UpdateOne", ctx, bson.M{"user_id": user.UserID}, mock.AnythingOfType("bson.M")).
+			Run(func(args mock.Arguments) { update = args.Get(2).(bson.M) }).
+			Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+		// The caller's own session stays signed in
+		mockRefreshColl.On("UpdateMany", ctx, bson.M{
+			"user_id":   user.UserID,
+			"revoked":   false,
+			"family_id": bson.M{"$ne": claims.SessionID},
+		}, mock.AnythingOfType("bson.M")).Return(&mongo.UpdateResult{}, nil)
+		mockRevokedColl.On("InsertOne", ctx, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+
+		userSvc := &userSvc{}
+		success, err := userSvc.ChangePassword(ctx, claims, currentPassword, newPassword)
+
+		require.NoError(t, err)
+		assert.True(t, success)
+		newHash := update["$set"].(bson.M)["password"].(string)
+		assertPasswordHash(t, newHash, newPassword)
+		assert.Equal(t, bson.M{"version": 1}, update["$inc"])
+		// Changing the password fulfils a required change
+		assert.Equal(t, false, update["$set"].(bson.M)["must_change_password"])
+		assert.NotNil(t, update["$set"].(bson.M)["password_changed_at"])
+		// The old hash leads the password history
+		assert.Equal(t, bson.M{"password_history": bson.M{
+			"$each": []string{user.Password}, "$position": 0, "$slice": 5,
+		}}, update["$push"])
+	})
+
+	t.Run("wrong current password", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx, mockAttemptsColl := withNoFailures(t, createContextWithMockCollection(mockColl))
+
+		mockColl.On("FindOne", ctx, bson.M{"user_id": user.UserID}).
+			Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+		// The wrong password counts like a failed login of the user
+		for _, identifier := range []string{user.UserName, user.Email} {
+			mockAttemptsColl.On("FindOneAndUpdate", ctx, bson.M{"key": loginAttemptsKey(identifier)}, mock.Anything, mock.Anything).
+				Return(mongo.NewSingleResultFromDocument(bson.M{"failures": 1}, nil, nil)).Once()
+		}
+
+		userSvc := &userSvc{}
+		success, err := userSvc.ChangePassword(ctx, claims, "wrongPassword", newPassword)
+
+		assert.Equal(t, errInvalidPassword, err)
+		assert.False(t, success)
+	})
+
+	t.Run("locked user", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockAttemptsColl := lockoutMocks.NewMockAttemptsCollection(t)
+		ctx := withMockLoginAttempts(createContextWithMockCollection(mockColl), mockAttemptsColl)
+
+		mockColl.On("FindOne", ctx, bson.M{"user_id": user.UserID}).
+			Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+		mockAttemptsColl.On("FindOne", ctx, mock.AnythingOfType("bson.M")).Return(mongo.NewSingleResultFromDocument(
+			bson.M{"failures": 5, "last_failure_date": time.Now().UTC()}, nil, nil))
+
+		userSvc := &userSvc{}
+		// Even the right current password is refused until the lock is lifted
+		success, err := userSvc.ChangePassword(ctx, claims, currentPassword, newPassword)
+
+		assert.Equal(t, lockout.ErrLocked, err)
+		assert.False(t, success)
+		mockColl.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+		mockAttemptsColl.AssertNotCalled(t, "FindOneAndUpdate", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("new password same as current", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx, _ := withNoFailures(t, createContextWithMockCollection(mockColl))
+
+		mockColl.On("FindOne", ctx, bson.M{"user_id": user.UserID}).
+			Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+
+		userSvc := &userSvc{}
+		success, err := userSvc.ChangePassword(ctx, claims, currentPassword, currentPassword)
+
+		assert.Equal(t, errSamePassword, err)
+		assert.False(t, success)
+	})
+
+	t.Run("new password used before", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx, _ := withNoFailures(t, createContextWithMockCollection(mockColl))
+
+		previous := user
+		previous.PasswordHistory = []string{mustHashPassword(t, "Quartz-Meadow-17"), mustHashPassword(t, newPassword)}
+		mockColl.On("FindOne", ctx, bson.M{"user_id": user.UserID}).
+			Return(mongo.NewSingleResultFromDocument(previous, nil, nil))
+
+		userSvc := &userSvc{}
+		success, err := userSvc.ChangePassword(ctx, claims, currentPassword, newPassword)
+
+		assert.Equal(t, errPasswordReused, err)
+		assert.False(t, success)
+		mockColl.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("new password breaking the policy", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx, _ := withNoFailures(t, createContextWithMockCollection(mockColl))
+
+		mockColl.On("FindOne", ctx, bson.M{"user_id": user.UserID}).
+			Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+
+		userSvc := &userSvc{}
+		success, err := userSvc.ChangePassword(ctx, claims, currentPassword, "qwerty")
+
+		var codeErr *errcode.Error
+		require.ErrorAs(t, err, &codeErr)
+		assert.Equal(t, errcode.PasswordPolicyViolation, codeErr.Code)
+		assert.Equal(t, []password.Violation{
+			{Rule: password.RuleMinLength, Message: "password must be at least 8 characters"},
+			{Rule: password.RuleStrength, Message: "password is too easy to guess, use a longer password or more words"},
+		}, codeErr.Extensions["violations"])
+		assert.False(t, success)
+		mockColl.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("user no longer exists", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		mockColl.On("FindOne", ctx, bson.M{"user_id": user.UserID}).
+			Return(mongo.NewSingleResultFromDocument(userDB{}, mongo.ErrNoDocuments, nil))
+
+		userSvc := &userSvc{}
+		success, err := userSvc.ChangePassword(ctx, claims, currentPassword, newPassword)
+
+		assert.Equal(t, errNoUserFound, err)
+		assert.False(t, success)
+	})
+}
+
+func TestRequestPasswordReset(t *testing.T) {
+	user := userDB{UserID: "test-id", Email: "test@example.com"}
+
+	t.Run("known email", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockActionColl := tokenMocks.NewMockActionTokenCollection(t)
+		mockSender := notifyMocks.NewMockSender(t)
+		ctx := createContextWithMockCollection(mockColl)
+		ctx = token.NewContext(ctx, token.GetActionTokensCollectionKey(), mockActionColl)
+		ctx = notify.NewContext(ctx, mockSender)
+
+		mockColl.On("FindOne", ctx, bson.M{"email": user.Email}).
+			Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+		mockActionColl.On("InsertOne", ctx, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+		var sent notify.Message
+		mockSender.On("Send", ctx, mock.AnythingOfType("notify.Message")).
+			Run(func(args mock.Arguments) { sent = args.Get(1).(notify.Message) }).
+			Return(nil)
+
+		userSvc := &userSvc{}
+		success, err := userSvc.RequestPasswordReset(ctx, user.Email)
+
+		require.NoError(t, err)
+		assert.True(t, success)
+		assert.Equal(t, user.Email, sent.To)
+		assert.Contains(t, sent.Text, "choose a new password")
+	})
+
+	t.Run("unknown email", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockSender := notifyMocks.NewMockSender(t)
+		ctx := notify.NewContext(createContextWithMockCollection(mockColl), mockSender)
+
+		mockColl.On("FindOne", ctx, bson.M{"email": "unknown@example.com"}).
+			Return(mongo.NewSingleResultFromDocument(userDB{}, mongo.ErrNoDocuments, nil))
+
+		userSvc := &userSvc{}
+		success, err := userSvc.RequestPasswordReset(ctx, "unknown@example.com")
+
+		// Indistinguishable from a known email
+		require.NoError(t, err)
+		assert.True(t, success)
+		mockSender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	})
+
+	t.Run("delivery failure is not reported", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockActionColl := tokenMocks.NewMockActionTokenCollection(t)
+		mockSender := notifyMocks.NewMockSender(t)
+		ctx := createContextWithMockCollection(mockColl)
+		ctx = token.NewContext(ctx, token.GetActionTokensCollectionKey(), mockActionColl)
+		ctx = notify.NewContext(ctx, mockSender)
+
+		mockColl.On("FindOne", ctx, bson.M{"email": user.Email}).
+			Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+		mockActionColl.On("InsertOne", ctx, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+		mockSender.On("Send", ctx, mock.Anything).Return(errors.New("connection refused"))
+
+		userSvc := &userSvc{}
+		success, err := userSvc.RequestPasswordReset(ctx, user.Email)
+
+		require.NoError(t, err)
+		assert.True(t, success)
+	})
+}
+
+func TestActionMessage(t *testing.T) {
+	t.Run("with reset page", func(t *testing.T) {
+		msg, err := actionMessage(notify.TemplatePasswordReset, "test@example.com", "",
+			"https://app.example.com/reset?lang=en", "a-b_c", time.Hour)
+
+		require.NoError(t, err)
+		assert.Equal(t, "test@example.com", msg.To)
+		assert.Contains(t, msg.Text, "https://app.example.com/reset?lang=en&token=a-b_c")
+		assert.Contains(t, msg.HTML, `href="https://app.example.com/reset?lang=en&amp;token=a-b_c"`)
+		assert.Contains(t, msg.Text, "It expires in 1h.")
+	})
+
+	t.Run("without reset page", func(t *testing.T) {
+		msg, err := actionMessage(notify.TemplatePasswordReset, "test@example.com", "", "", "a-b_c", time.Hour)
+
+		require.NoError(t, err)
+		assert.Contains(t, msg.Text, "code to choose a new password: a-b_c")
+	})
+
+	t.Run("in the locale of the user", func(t *testing.T) {
+		msg, err := actionMessage(notify.TemplateEmailVerification, "test@example.com", "es-MX", "", "a-b_c", time.Hour)
+
+		require.NoError(t, err)
+		assert.Equal(t, "Confirma tu dirección de correo", msg.Subject)
+	})
+}
+
+func TestResetPassword(t *testing.T) {
+	const resetToken = "reset-token"
+
+	t.Run("valid token", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockActionColl := tokenMocks.NewMockActionTokenCollection(t)
+		mockRefreshColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		mockRevokedColl := tokenMocks.NewMockRevokedTokenCollection(t)
+		mockAttemptsColl := lockoutMocks.NewMockAttemptsCollection(t)
+		user := userDB{UserID: "test-id", UserName: "testuser", Email: "test@example.com"}
+		ctx := withMockRefreshTokenCollection(createContextWithMockCollection(mockColl), mockRefreshColl)
+		ctx = token.NewContext(ctx, token.GetRevokedTokensCollectionKey(), mockRevokedColl)
+		ctx = token.NewContext(ctx, token.GetActionTokensCollectionKey(), mockActionColl)
+		ctx = withMockLoginAttempts(ctx, mockAttemptsColl)
+
+		// The token is attempted, then used up once the new password is accepted
+		mockActionColl.On("FindOneAndUpdate", ctx, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(bson.M{"user_id": "test-id"}, nil, nil)).Twice()
+		mockActionColl.On("UpdateMany", ctx, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M")).
+			Return(&mongo.UpdateResult{}, nil)
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+			Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+		var update bson.M
+		mockColl.On("UpdateOne", ctx, bson.M{"user_id": "test-id"}, mock.AnythingOfType("bson.M")).
+			Run(func(args mock.Arguments) { update = args.Get(2).(bson.M) }).
+			Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+		// Every session of the user is signed out
+		mockRefreshColl.On("UpdateMany", ctx, bson.M{"user_id": "test-id", "revoked": false}, mock.AnythingOfType("bson.M")).
+			Return(&mongo.UpdateResult{}, nil)
+		mockRevokedColl.On("InsertOne", ctx, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+		// A lock left by someone guessing the old password is lifted
+		expectLoginReset(ctx, mockAttemptsColl, user)
+
+		userSvc := &userSvc{}
+		success, err := userSvc.ResetPassword(ctx, resetToken, "newPassword123")
+
+		require.NoError(t, err)
+		assert.True(t, success)
+		newHash := update["$set"].(bson.M)["password"].(string)
+		assertPasswordHash(t, newHash, "newPassword123")
+	})
+
+	t.Run("password breaking the policy", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockActionColl := tokenMocks.NewMockActionTokenCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+		ctx = token.NewContext(ctx, token.GetActionTokensCollectionKey(), mockActionColl)
+
+		mockActionColl.On("FindOneAndUpdate", ctx, mock.MatchedBy(func(filter bson.M) bool {
+			return filter["attempts"] != nil
+		}), mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(bson.M{"user_id": "test-id"}, nil, nil)).Once()
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+			Return(mongo.NewSingleResultFromDocument(userDB{UserID: "test-id", UserName: "testuser"}, nil, nil))
+
+		userSvc := &userSvc{}
+		success, err := userSvc.ResetPassword(ctx, resetToken, "testuser2024")
+
+		var codeErr *errcode.Error
+		require.ErrorAs(t, err, &codeErr)
+		assert.Equal(t, errcode.PasswordPolicyViolation, codeErr.Code)
+		assert.False(t, success)
+		// The token can still be used with another password
+		mockActionColl.AssertNotCalled(t, "UpdateMany", mock.Anything, mock.Anything, mock.Anything)
+		mockColl.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("current password", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockActionColl := tokenMocks.NewMockActionTokenCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+		ctx = token.NewContext(ctx, token.GetActionTokensCollectionKey(), mockActionColl)
+
+		mockActionColl.On("FindOneAndUpdate", ctx, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(bson.M{"user_id": "test-id"}, nil, nil)).Once()
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+			Return(mongo.NewSingleResultFromDocument(userDB{
+				UserID: "test-id", UserName: "testuser", Password: mustHashPassword(t, "newPassword123"),
+			}, nil, nil))
+
+		userSvc := &userSvc{}
+		success, err := userSvc.ResetPassword(ctx, resetToken, "newPassword123")
+
+		assert.Equal(t, errPasswordReused, err)
+		assert.False(t, success)
+		mockColl.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockActionColl := tokenMocks.NewMockActionTokenCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+		ctx = token.NewContext(ctx, token.GetActionTokensCollectionKey(), mockActionColl)
+
+		mockActionColl.On("FindOneAndUpdate", ctx, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil))
+
+		userSvc := &userSvc{}
+		success, err := userSvc.ResetPassword(ctx, resetToken, "newPassword123")
+
+		assert.Equal(t, token.ErrInvalidActionToken, err)
+		assert.False(t, success)
+	})
+}
+
+func TestVerifyEmail(t *testing.T) {
+	const verificationToken = "verification-token"
+
+	t.Run("valid token", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockActionColl := tokenMocks.NewMockActionTokenCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+		ctx = token.NewContext(ctx, token.GetActionTokensCollectionKey(), mockActionColl)
+
+		mockActionColl.On("FindOneAndUpdate", ctx, mock.MatchedBy(func(filter bson.M) bool {
+			return filter["purpose"] == token.PurposeEmailVerification
+		}), mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(bson.M{"user_id": "test-id"}, nil, nil))
+		mockActionColl.On("UpdateMany", ctx, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M")).
+			Return(&mongo.UpdateResult{}, nil)
+		var update bson.M
+		mockColl.On("UpdateOne", ctx, bson.M{"user_id": "test-id"}, mock.AnythingOfType("bson.M")).
+			Run(func(args mock.Arguments) { update = args.Get(2).(bson.M) }).
+			Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+
+		userSvc := &userSvc{}
+		success, err := userSvc.VerifyEmail(ctx, verificationToken)
+
+		require.NoError(t, err)
+		assert.True(t, success)
+		assert.Equal(t, true, update["$set"].(bson.M)["email_verified"])
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockActionColl := tokenMocks.NewMockActionTokenCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+		ctx = token.NewContext(ctx, token.GetActionTokensCollectionKey(), mockActionColl)
+
+		mockActionColl.On("FindOneAndUpdate", ctx, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil))
+
+		userSvc := &userSvc{}
+		success, err := userSvc.VerifyEmail(ctx, verificationToken)
+
+		assert.Equal(t, token.ErrInvalidActionToken, err)
+		assert.False(t, success)
+	})
+}
+
+func TestResendVerification(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     *userDB
+		wantSend bool
+	}{
+		{name: "unverified user", user: &userDB{UserID: "test-id", Email: "test@example.com"}, wantSend: true},
+		{name: "verified user", user: &userDB{UserID: "test-id", Email: "test@example.com", EmailVerified: true}},
+		{name: "unknown email"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockColl := userMocks.NewMockUserCollection(t)
+			mockSender := notifyMocks.NewMockSender(t)
+			ctx := notify.NewContext(createContextWithMockCollection(mockColl), mockSender)
+			if tt.wantSend {
+				ctx, mockSender = withMockEmailVerification(t, ctx)
+			}
+
+			if tt.user != nil {
+				mockColl.On("FindOne", ctx, bson.M{"email": "test@example.com"}).
+					Return(mongo.NewSingleResultFromDocument(tt.user, nil, nil))
+			} else {
+				mockColl.On("FindOne", ctx, bson.M{"email": "test@example.com"}).
+					Return(mongo.NewSingleResultFromDocument(userDB{}, mongo.ErrNoDocuments, nil))
+			}
+
+			userSvc := &userSvc{}
+			success, err := userSvc.ResendVerification(ctx, "test@example.com")
+
+			// Every case looks the same to the caller
+			require.NoError(t, err)
+			assert.True(t, success)
+			if tt.wantSend {
+				mockSender.AssertNumberOfCalls(t, "Send", 1)
+			} else {
+				mockSender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestCheckEmailVerified(t *testing.T) {
+	unverified := &userDB{UserID: "test-id"}
+	verified := &userDB{UserID: "test-id", EmailVerified: true}
+
+	assert.NoError(t, checkEmailVerified(unverified, false))
+	assert.NoError(t, checkEmailVerified(verified, true))
+	assert.Equal(t, errEmailNotVerified, checkEmailVerified(unverified, true))
+}
+
+func TestPasswordChangeRequired(t *testing.T) {
+	recently := time.Now().Add(-24 * time.Hour)
+	longAgo := time.Now().Add(-100 * 24 * time.Hour)
+	maxAge := 90 * 24 * time.Hour
+
+	assert.False(t, passwordChangeRequired(&userDB{PasswordChangedAt: &longAgo}, 0))
+	assert.False(t, passwordChangeRequired(&userDB{PasswordChangedAt: &recently}, maxAge))
+	assert.True(t, passwordChangeRequired(&userDB{PasswordChangedAt: &longAgo}, maxAge))
+	assert.True(t, passwordChangeRequired(&userDB{PasswordChangedAt: &recently, MustChangePassword: true}, 0))
+	// Passwords set before their date was recorded are not known to have expired
+	assert.False(t, passwordChangeRequired(&userDB{}, maxAge))
+}
+
+func TestEnrollTotp(t *testing.T) {
+	t.Run("new enrollment", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+			Return(mongo.NewSingleResultFromDocument(userDB{UserID: "test-id", Email: "test@example.com"}, nil, nil))
+		var update bson.M
+		mockColl.On("UpdateOne", ctx, bson.M{"user_id": "test-id", "mfa_enabled": bson.M{"$ne": true}}, mock.AnythingOfType("bson.M")).
+			Run(func(args mock.Arguments) { update = args.Get(2).(bson.M) }).
+			Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+
+		userSvc := &userSvc{}
+		enrollment, err := userSvc.EnrollTotp(ctx, "test-id")
+
+		require.NoError(t, err)
+		assert.NotEmpty(t, enrollment.Secret)
+		assert.Contains(t, enrollment.URI, "otpauth://totp/")
+		assert.Contains(t, enrollment.URI, "test@example.com")
+		assert.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+
+		// Only the encrypted secret is stored
+		stored := update["$set"].(bson.M)["totp_pending_secret"].(string)
+		assert.NotContains(t, stored, enrollment.Secret)
+		secret, err := mfa.DecryptSecret(ctx, "test-id", stored)
+		require.NoError(t, err)
+		assert.Equal(t, enrollment.Secret, secret)
+
+		// Only the hashes of the recovery codes are stored
+		require.Len(t, enrollment.RecoveryCodes, mfa.RecoveryCodeCount)
+		hashes := update["$set"].(bson.M)["pending_recovery_codes"].([]string)
+		assert.NotContains(t, hashes, enrollment.RecoveryCodes[0])
+		hash, ok := mfa.MatchRecoveryCode(hashes, enrollment.RecoveryCodes[0])
+		assert.True(t, ok)
+		assert.Equal(t, hashes[0], hash)
+	})
+
+	t.Run("MFA already enabled", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+			Return(mongo.NewSingleResultFromDocument(userDB{UserID: "test-id", MFAEnabled: true}, nil, nil))
+
+		userSvc := &userSvc{}
+		enrollment, err := userSvc.EnrollTotp(ctx, "test-id")
+
+		assert.Equal(t, errMfaEnabled, err)
+		assert.Nil(t, enrollment)
+	})
+}
+
+// Helper function to create an encrypted TOTP secret of the test user along with a current code
+func newTotpSecret(t *testing.T, ctx context.Context) (encrypted string, code string) {
+	t.Helper()
+	secret, err := mfa.GenerateSecret()
+	require.NoError(t, err)
+	encrypted, err = mfa.EncryptSecret(ctx, "test-id", secret)
+	require.NoError(t, err)
+	code, err = mfa.GenerateCode(secret, time.Now())
+	require.NoError(t, err)
+	return encrypted, code
+}
+
+func TestConfirmTotp(t *testing.T) {
+	t.Run("valid code", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+		pending, code := newTotpSecret(t, ctx)
+
+		pendingRecoveryCodes := []string{"hash-1", "hash-2"}
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+			Return(mongo.NewSingleResultFromDocument(userDB{
+				UserID:               "test-id",
+				TOTPPendingSecret:    pending,
+				PendingRecoveryCodes: pendingRecoveryCodes,
+			}, nil, nil))
+		var update bson.M
+		mockColl.On("UpdateOne", ctx, bson.M{"user_id": "test-id", "totp_pending_secret": pending}, mock.AnythingOfType("bson.M")).
+			Run(func(args mock.Arguments) { update = args.Get(2).(bson.M) }).
+			Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+
+		userSvc := &userSvc{}
+		success, err := userSvc.ConfirmTotp(ctx, "test-id", code)
+
+		require.NoError(t, err)
+		assert.True(t, success)
+		set := update["$set"].(bson.M)
+		assert.Equal(t, true, set["mfa_enabled"])
+		assert.Equal(t, pending, set["totp_secret"])
+		// The code used to confirm cannot be used to log in
+		assert.InDelta(t, time.Now().Unix()/30, set["totp_last_step"], 1)
+		assert.Equal(t, pendingRecoveryCodes, set["recovery_codes"])
+		assert.Contains(t, update["$unset"], "totp_pending_secret")
+		assert.Contains(t, update["$unset"], "pending_recovery_codes")
+	})
+
+	t.Run("invalid code", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+		pending, _ := newTotpSecret(t, ctx)
+
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+			Return(mongo.NewSingleResultFromDocument(userDB{UserID: "test-id", TOTPPendingSecret: pending}, nil, nil))
+
+		userSvc := &userSvc{}
+		success, err := userSvc.ConfirmTotp(ctx, "test-id", "000000")
+
+		assert.Equal(t, errInvalidMfaCode, err)
+		assert.False(t, success)
+	})
+
+	t.Run("no enrollment", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+			Return(mongo.NewSingleResultFromDocument(userDB{UserID: "test-id"}, nil, nil))
+
+		userSvc := &userSvc{}
+		success, err := userSvc.ConfirmTotp(ctx, "test-id", "123456")
+
+		assert.Equal(t, errNoTotpEnrollment, err)
+		assert.False(t, success)
+	})
+}
+
+func TestVerifyMfa(t *testing.T) {
+	const challenge = "mfa-challenge"
+	challengeFilter := mock.MatchedBy(func(filter bson.M) bool {
+		return filter["purpose"] == token.PurposeMFAChallenge
+	})
+
+	noAttempts := mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil)
+	testUser := userDB{UserName: "testuser", Email: "test@example.com"}
+
+	// Helper function to set up a challenge of the test user, whose second factors are given
+	setupChallenge := func(t *testing.T, user userDB) (
+		context.Context, *userMocks.MockUserCollection, *tokenMocks.MockActionTokenCollection, *tokenMocks.MockRefreshTokenCollection,
+		*lockoutMocks.MockAttemptsCollection,
+	) {
+		t.Helper()
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockActionColl := tokenMocks.NewMockActionTokenCollection(t)
+		mockRefreshColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		mockAttemptsColl := lockoutMocks.NewMockAttemptsCollection(t)
+		ctx := withMockRefreshTokenCollection(createContextWithMockCollection(mockColl), mockRefreshColl)
+		ctx = token.NewContext(ctx, token.GetActionTokensCollectionKey(), mockActionColl)
+		ctx = withMockLoginAttempts(ctx, mockAttemptsColl)
+
+		mockActionColl.On("FindOneAndUpdate", ctx, challengeFilter, bson.M{"$inc": bson.M{"attempts": 1}}).
+			Return(mongo.NewSingleResultFromDocument(bson.M{"user_id": "test-id"}, nil, nil)).Once()
+		user.UserID, user.Role, user.MFAEnabled = "test-id", model.RoleUser, true
+		user.UserName, user.Email = testUser.UserName, testUser.Email
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+			Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+		return ctx, mockColl, mockActionColl, mockRefreshColl, mockAttemptsColl
+	}
+
+	// Helper function to expect a wrong code to be counted as a failed login of the test user
+	expectFailure := func(ctx context.Context, mockAttemptsColl *lockoutMocks.MockAttemptsCollection) {
+		for _, identifier := range []string{testUser.UserName, testUser.Email} {
+			mockAttemptsColl.On("FindOneAndUpdate", ctx, bson.M{"key": loginAttemptsKey(identifier)}, mock.Anything, mock.Anything).
+				Return(mongo.NewSingleResultFromDocument(bson.M{"failures": 1}, nil, nil)).Once()
+		}
+	}
+
+	t.Run("valid code", func(t *testing.T) {
+		encrypted, code := newTotpSecret(t, context.Background())
+		ctx, mockColl, mockActionColl, mockRefreshColl, mockAttemptsColl := setupChallenge(t, userDB{TOTPSecret: encrypted})
+		mockAttemptsColl.On("FindOne", ctx, mock.AnythingOfType("bson.M")).Return(noAttempts)
+
+		// The code cannot be used again
+		mockColl.On("UpdateOne", ctx, mock.MatchedBy(func(filter bson.M) bool {
+			return filter["totp_last_step"] != nil
+		}), mock.MatchedBy(func(update bson.M) bool {
+			step := update["$set"].(bson.M)["totp_last_step"].(int64)
+			return step >= time.Now().Unix()/30-1
+		})).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+		// The challenge is used up once the code is verified
+		mockActionColl.On("FindOneAndUpdate", ctx, challengeFilter, mock.MatchedBy(func(update bson.M) bool {
+			return update["$set"] != nil
+		})).Return(mongo.NewSingleResultFromDocument(bson.M{"user_id": "test-id"}, nil, nil))
+		mockActionColl.On("UpdateMany", ctx, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M")).
+			Return(&mongo.UpdateResult{}, nil)
+		mockColl.On("UpdateOne", ctx, bson.M{"user_id": "test-id"}, mock.AnythingOfType("bson.M")).
+			Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+		mockRefreshColl.On("InsertOne", ctx, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+		// Failed logins are only forgotten once the second factor is verified
+		expectLoginReset(ctx, mockAttemptsColl, testUser)
+
+		userSvc := &userSvc{}
+		result, err := userSvc.VerifyMfa(ctx, challenge, code)
+
+		require.NoError(t, err)
+		assert.Equal(t, model.AuthStatusAuthenticated, result.Status)
+		assert.Equal(t, "test-id", result.User.ID)
+		assert.True(t, result.User.MfaEnabled)
+		require.NotNil(t, result.AccessToken)
+		assert.NotNil(t, result.RefreshToken)
+	})
+
+	t.Run("invalid code", func(t *testing.T) {
+		encrypted, _ := newTotpSecret(t, context.Background())
+		ctx, _, _, _, mockAttemptsColl := setupChallenge(t, userDB{TOTPSecret: encrypted})
+		mockAttemptsColl.On("FindOne", ctx, mock.AnythingOfType("bson.M")).Return(noAttempts)
+		// The wrong code counts against the user name and email of the user
+		expectFailure(ctx, mockAttemptsColl)
+
+		userSvc := &userSvc{}
+		result, err := userSvc.VerifyMfa(ctx, challenge, "000000")
+
+		assert.Equal(t, errInvalidMfaCode, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("locked user", func(t *testing.T) {
+		encrypted, code := newTotpSecret(t, context.Background())
+		ctx, mockColl, _, _, mockAttemptsColl := setupChallenge(t, userDB{TOTPSecret: encrypted})
+		mockAttemptsColl.On("FindOne", ctx, mock.AnythingOfType("bson.M")).Return(mongo.NewSingleResultFromDocument(
+			bson.M{"failures": 5, "last_failure_date": time.Now().UTC()}, nil, nil))
+
+		userSvc := &userSvc{}
+		// Even the right code is refused until the lock is lifted
+		result, err := userSvc.VerifyMfa(ctx, challenge, code)
+
+		assert.Equal(t, lockout.ErrLocked, err)
+		assert.Nil(t, result)
+		mockColl.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("replayed code", func(t *testing.T) {
+		encrypted, code := newTotpSecret(t, context.Background())
+		ctx, _, _, _, mockAttemptsColl := setupChallenge(t, userDB{TOTPSecret: encrypted, TOTPLastStep: time.Now().Unix() / 30})
+		mockAttemptsColl.On("FindOne", ctx, mock.AnythingOfType("bson.M")).Return(noAttempts)
+		expectFailure(ctx, mockAttemptsColl)
+
+		userSvc := &userSvc{}
+		result, err := userSvc.VerifyMfa(ctx, challenge, code)
+
+		assert.Equal(t, errInvalidMfaCode, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("recovery code", func(t *testing.T) {
+		codes, hashes, err := mfa.GenerateRecoveryCodes()
+		require.NoError(t, err)
+		ctx, mockColl, mockActionColl, mockRefreshColl, mockAttemptsColl := setupChallenge(t, userDB{RecoveryCodes: hashes})
+		mockAttemptsColl.On("FindOne", ctx, mock.AnythingOfType("bson.M")).Return(noAttempts)
+		expectLoginReset(ctx, mockAttemptsColl, testUser)
+
+		// The recovery code is removed
+		mockColl.On("UpdateOne", ctx, bson.M{"user_id": "test-id", "recovery_codes": hashes[1]},
+			bson.M{"$pull": bson.M{"recovery_codes": hashes[1]}}).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+		mockActionColl.On("FindOneAndUpdate", ctx, challengeFilter, mock.MatchedBy(func(update bson.M) bool {
+			return update["$set"] != nil
+		})).Return(mongo.NewSingleResultFromDocument(bson.M{"user_id": "test-id"}, nil, nil))
+		mockActionColl.On("UpdateMany", ctx, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M")).
+			Return(&mongo.UpdateResult{}, nil)
+		mockColl.On("UpdateOne", ctx, bson.M{"user_id": "test-id"}, mock.AnythingOfType("bson.M")).
+			Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+		mockRefreshColl.On("InsertOne", ctx, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+
+		userSvc := &userSvc{}
+		result, err := userSvc.VerifyMfa(ctx, challenge, codes[1])
+
+		require.NoError(t, err)
+		assert.Equal(t, model.AuthStatusAuthenticated, result.Status)
+		assert.NotNil(t, result.AccessToken)
+	})
+
+	t.Run("used recovery code", func(t *testing.T) {
+		codes, hashes, err := mfa.GenerateRecoveryCodes()
+		require.NoError(t, err)
+		// Another request used the code since the user was read
+		ctx, mockColl, _, _, mockAttemptsColl := setupChallenge(t, userDB{RecoveryCodes: hashes})
+		mockAttemptsColl.On("FindOne", ctx, mock.AnythingOfType("bson.M")).Return(noAttempts)
+		expectFailure(ctx, mockAttemptsColl)
+		mockColl.On("UpdateOne", ctx, bson.M{"user_id": "test-id", "recovery_codes": hashes[0]}, mock.AnythingOfType("bson.M")).
+			Return(&mongo.UpdateResult{MatchedCount: 0}, nil)
+
+		userSvc := &userSvc{}
+		result, err := userSvc.VerifyMfa(ctx, challenge, codes[0])
+
+		assert.Equal(t, errInvalidMfaCode, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("invalid or exhausted challenge", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockActionColl := tokenMocks.NewMockActionTokenCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+		ctx = token.NewContext(ctx, token.GetActionTokensCollectionKey(), mockActionColl)
+
+		mockActionColl.On("FindOneAndUpdate", ctx, challengeFilter, mock.AnythingOfType("bson.M")).
+			Return(mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil))
+
+		userSvc := &userSvc{}
+		result, err := userSvc.VerifyMfa(ctx, challenge, "123456")
+
+		assert.Equal(t, token.ErrInvalidActionToken, err)
+		assert.Nil(t, result)
+	})
+}
+
+func TestRegenerateRecoveryCodes(t *testing.T) {
+	t.Run("valid code", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx, _ := withNoLoginFailures(t, createContextWithMockCollection(mockColl))
+		encrypted, code := newTotpSecret(t, ctx)
+
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+			Return(mongo.NewSingleResultFromDocument(userDB{
+				UserID:        "test-id",
+				MFAEnabled:    true,
+				TOTPSecret:    encrypted,
+				RecoveryCodes: []string{"old-hash"},
+			}, nil, nil))
+		mockColl.On("UpdateOne", ctx, mock.MatchedBy(func(filter bson.M) bool {
+			return filter["totp_last_step"] != nil
+		}), mock.AnythingOfType("bson.M")).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+		var update bson.M
+		mockColl.On("UpdateOne", ctx, bson.M{"user_id": "test-id", "mfa_enabled": true}, mock.AnythingOfType("bson.M")).
+			Run(func(args mock.Arguments) { update = args.Get(2).(bson.M) }).
+			Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+
+		userSvc := &userSvc{}
+		codes, err := userSvc.RegenerateRecoveryCodes(ctx, "test-id", code)
+
+		require.NoError(t, err)
+		require.Len(t, codes, mfa.RecoveryCodeCount)
+		// The old set is replaced
+		hashes := update["$set"].(bson.M)["recovery_codes"].([]string)
+		assert.NotContains(t, hashes, "old-hash")
+		_, ok := mfa.MatchRecoveryCode(hashes[:1], codes[0])
+		assert.True(t, ok)
+		assert.Equal(t, bson.M{"version": 1}, update["$inc"])
+	})
+
+	t.Run("invalid code", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx, waitForDelay := withLoginAttemptsInMemory(t, createContextWithMockCollection(mockColl))
+		encrypted, code := newTotpSecret(t, ctx)
+
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).Return(
+			func(context.Context, any, ...options.Lister[options.FindOneOptions]) *mongo.SingleResult {
+				return mongo.NewSingleResultFromDocument(userDB{
+					UserID: "test-id", UserName: "testuser", Email: "test@example.com", MFAEnabled: true, TOTPSecret: encrypted,
+				}, nil, nil)
+			})
+
+		userSvc := &userSvc{}
+		var responses []error
+		// One more than the default lockout threshold
+		for range 6 {
+			_, err := userSvc.RegenerateRecoveryCodes(ctx, "test-id", "000000")
+			responses = append(responses, err)
+			waitForDelay()
+		}
+		// Wrong codes lock the user like wrong passwords, after which even the right code is refused
+		codes, err := userSvc.RegenerateRecoveryCodes(ctx, "test-id", code)
+
+		assert.Equal(t, []error{
+			errInvalidMfaCode, errInvalidMfaCode, errInvalidMfaCode, errInvalidMfaCode, errInvalidMfaCode, lockout.ErrLocked,
+		}, responses)
+		assert.Equal(t, lockout.ErrLocked, err)
+		assert.Nil(t, codes)
+		mockColl.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("MFA not enabled", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+			Return(mongo.NewSingleResultFromDocument(userDB{UserID: "test-id"}, nil, nil))
+
+		userSvc := &userSvc{}
+		codes, err := userSvc.RegenerateRecoveryCodes(ctx, "test-id", "123456")
+
+		assert.Equal(t, errMfaNotEnabled, err)
+		assert.Nil(t, codes)
+	})
+}
+
+// passkeyOrigin is the origin of the client application passkeys are used from in development
+const passkeyOrigin = "http://localhost:3000"
+
+// Helper function to add a mock login attempts collection to a context, in which nobody has
+// failed logins
+func withNoLoginFailures(t *testing.T, ctx context.Context) (context.Context, *lockoutMocks.MockAttemptsCollection) {
+	mockAttemptsColl := lockoutMocks.NewMockAttemptsCollection(t)
+	ctx = withMockLoginAttempts(ctx, mockAttemptsColl)
+	mockAttemptsColl.On("FindOne", ctx, mock.AnythingOfType("bson.M")).
+		Return(mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil))
+	return ctx, mockAttemptsColl
+}
+
+// Helper function to match the filter a passkey registration of the user is stored with
+func passkeyRegistrationFilter(userID string) any {
+	return mock.MatchedBy(func(filter bson.M) bool {
+		return filter["user_id"] == userID && filter["passkeys.credential_id"] != nil
+	})
+}
+
+// Helper function to register a passkey of the authenticator for the user through the service,
+// returning the stored credential
+func registerPasskey(t *testing.T, authenticator *passkeytest.Authenticator, user userDB) passkey.Credential {
+	t.Helper()
+	// Proving the password and second factor to register is tested by TestPasskeyRegistration
+	user.Password, user.MFAEnabled = mustHashPassword(t, "currentPassword"), false
+	mockColl := userMocks.NewMockUserCollection(t)
+	ctx := withMockPasskeySessions(t, createContextWithMockCollection(mockColl))
+	ctx, _ = withNoLoginFailures(t, ctx)
+	mockColl.On("FindOne", ctx, bson.M{"user_id": user.UserID}).
+		Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+	var credential passkey.Credential
+	mockColl.On("UpdateOne", ctx, passkeyRegistrationFilter(user.UserID), mock.AnythingOfType("bson.M")).
+		Run(func(args mock.Arguments) {
+			credential = *args.Get(2).(bson.M)["$push"].(bson.M)["passkeys"].(*passkey.Credential)
+		}).
+		Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+
+	userSvc := &userSvc{}
+	options, err := userSvc.BeginPasskeyRegistration(ctx, user.UserID, "currentPassword", nil)
+	require.NoError(t, err)
+	response, err := authenticator.Register(options)
+	require.NoError(t, err)
+	_, err = userSvc.FinishPasskeyRegistration(ctx, user.UserID, response, nil)
+	require.NoError(t, err)
+	return credential
+}
+
+func TestPasskeyRegistration(t *testing.T) {
+	const currentPassword = "currentPassword"
+	user := userDB{
+		UserID: "test-id", UserName: "testuser", Email: "test@example.com", FirstName: "Test", LastName: "User",
+		Role: model.RoleUser, Password: mustHashPassword(t, currentPassword),
+	}
+
+	// Helper function to begin a registration of the user and create the passkey for it
+	createPasskey := func(t *testing.T, ctx context.Context, mockColl *userMocks.MockUserCollection) string {
+		t.Helper()
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+			Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+		authenticator, err := passkeytest.NewAuthenticator(passkeyOrigin)
+		require.NoError(t, err)
+
+		userSvc := &userSvc{}
+		options, err := userSvc.BeginPasskeyRegistration(ctx, "test-id", currentPassword, nil)
+		require.NoError(t, err)
+		assert.Contains(t, options, `"name":"test@example.com"`)
+		assert.Contains(t, options, `"displayName":"Test User"`)
+		response, err := authenticator.Register(options)
+		require.NoError(t, err)
+		return response
+	}
+
+	t.Run("new passkey", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := withMockPasskeySessions(t, createContextWithMockCollection(mockColl))
+		ctx, _ = withNoLoginFailures(t, ctx)
+		response := createPasskey(t, ctx, mockColl)
+		var filter, update bson.M
+		mockColl.On("UpdateOne", ctx, passkeyRegistrationFilter("test-id"), mock.AnythingOfType("bson.M")).
+			Run(func(args mock.Arguments) { filter, update = args.Get(1).(bson.M), args.Get(2).(bson.M) }).
+			Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+
+		userSvc := &userSvc{}
+		name := "Laptop"
+		result, err := userSvc.FinishPasskeyRegistration(ctx, "test-id", response, &name)
+
+		require.NoError(t, err)
+		assert.NotEmpty(t, result.ID)
+		assert.Equal(t, &name, result.Name)
+		assert.Nil(t, result.LastUsedAt)
+		credential := update["$push"].(bson.M)["passkeys"].(*passkey.Credential)
+		assert.Equal(t, result.ID, credential.EncodedID())
+		assert.Equal(t, "Laptop", credential.Name)
+		assert.NotEmpty(t, credential.PublicKey)
+		assert.Equal(t, bson.M{"version": 1}, update["$inc"])
+		// The user cannot hold the same passkey twice
+		assert.Equal(t, bson.M{"$ne": credential.ID}, filter["passkeys.credential_id"])
+	})
+
+	t.Run("passkey the user already holds", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := withMockPasskeySessions(t, createContextWithMockCollection(mockColl))
+		ctx, _ = withNoLoginFailures(t, ctx)
+		response := createPasskey(t, ctx, mockColl)
+		mockColl.On("UpdateOne", ctx, passkeyRegistrationFilter("test-id"), mock.AnythingOfType("bson.M")).
+			Return(&mongo.UpdateResult{MatchedCount: 0}, nil)
+
+		userSvc := &userSvc{}
+		result, err := userSvc.FinishPasskeyRegistration(ctx, "test-id", response, nil)
+
+		assert.Equal(t, errPasskeyRegistered, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("passkey of another user", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := withMockPasskeySessions(t, createContextWithMockCollection(mockColl))
+		ctx, _ = withNoLoginFailures(t, ctx)
+		response := createPasskey(t, ctx, mockColl)
+		mockColl.On("UpdateOne", ctx, passkeyRegistrationFilter("test-id"), mock.AnythingOfType("bson.M")).
+			Return(nil, mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}})
+
+		userSvc := &userSvc{}
+		result, err := userSvc.FinishPasskeyRegistration(ctx, "test-id", response, nil)
+
+		assert.Equal(t, errPasskeyRegistered, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("replayed registration", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := withMockPasskeySessions(t, createContextWithMockCollection(mockColl))
+		ctx, _ = withNoLoginFailures(t, ctx)
+		response := createPasskey(t, ctx, mockColl)
+		mockColl.On("UpdateOne", ctx, passkeyRegistrationFilter("test-id"), mock.AnythingOfType("bson.M")).
+			Return(&mongo.UpdateResult{MatchedCount: 1}, nil).Once()
+
+		userSvc := &userSvc{}
+		_, err := userSvc.FinishPasskeyRegistration(ctx, "test-id", response, nil)
+		require.NoError(t, err)
+		result, err := userSvc.FinishPasskeyRegistration(ctx, "test-id", response, nil)
+
+		assert.Equal(t, passkey.ErrInvalidCredential, err)
+		assert.Nil(t, result)
+	})
+
+	// Helper function to expect a wrong password or code to be counted as a failed login of the user
+	expectFailure := func(ctx context.Context, mockAttemptsColl *lockoutMocks.MockAttemptsCollection) {
+		for _, identifier := range []string{user.UserName, user.Email} {
+			mockAttemptsColl.On("FindOneAndUpdate", ctx, bson.M{"key": loginAttemptsKey(identifier)}, mock.Anything, mock.Anything).
+				Return(mongo.NewSingleResultFromDocument(bson.M{"failures": 1}, nil, nil)).Once()
+		}
+	}
+
+	t.Run("wrong current password", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := withMockPasskeySessions(t, createContextWithMockCollection(mockColl))
+		ctx, mockAttemptsColl := withNoLoginFailures(t, ctx)
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+			Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+		expectFailure(ctx, mockAttemptsColl)
+
+		userSvc := &userSvc{}
+		options, err := userSvc.BeginPasskeyRegistration(ctx, "test-id", "wrongPassword", nil)
+
+		assert.Equal(t, errInvalidPassword, err)
+		assert.Empty(t, options)
+	})
+
+	t.Run("locked user", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockAttemptsColl := lockoutMocks.NewMockAttemptsCollection(t)
+		ctx := withMockLoginAttempts(createContextWithMockCollection(mockColl), mockAttemptsColl)
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+			Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+		mockAttemptsColl.On("FindOne", ctx, mock.AnythingOfType("bson.M")).Return(mongo.NewSingleResultFromDocument(
+			bson.M{"failures": 5, "last_failure_date": time.Now().UTC()}, nil, nil))
+
+		userSvc := &userSvc{}
+		// Even the right password is refused until the lock is lifted
+		options, err := userSvc.BeginPasskeyRegistration(ctx, "test-id", currentPassword, nil)
+
+		assert.Equal(t, lockout.ErrLocked, err)
+		assert.Empty(t, options)
+	})
+
+	t.Run("MFA user", func(t *testing.T) {
+		encrypted, code := newTotpSecret(t, context.Background())
+		mfaUser := user
+		mfaUser.MFAEnabled, mfaUser.TOTPSecret = true, encrypted
+
+		t.Run("with code", func(t *testing.T) {
+			mockColl := userMocks.NewMockUserCollection(t)
+			ctx := withMockPasskeySessions(t, createContextWithMockCollection(mockColl))
+			ctx, _ = withNoLoginFailures(t, ctx)
+			mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+				Return(mongo.NewSingleResultFromDocument(mfaUser, nil, nil))
+			// The code cannot be used again
+			mockColl.On("UpdateOne", ctx, mock.MatchedBy(func(filter bson.M) bool {
+				return filter["totp_last_step"] != nil
+			}), mock.AnythingOfType("bson.M")).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+
+			userSvc := &userSvc{}
+			options, err := userSvc.BeginPasskeyRegistration(ctx, "test-id", currentPassword, &code)
+
+			require.NoError(t, err)
+			assert.Contains(t, options, `"name":"test@example.com"`)
+		})
+
+		t.Run("without code", func(t *testing.T) {
+			mockColl := userMocks.NewMockUserCollection(t)
+			ctx := withMockPasskeySessions(t, createContextWithMockCollection(mockColl))
+			ctx, _ = withNoLoginFailures(t, ctx)
+			mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+				Return(mongo.NewSingleResultFromDocument(mfaUser, nil, nil))
+
+			userSvc := &userSvc{}
+			options, err := userSvc.BeginPasskeyRegistration(ctx, "test-id", currentPassword, nil)
+
+			assert.Equal(t, errMfaCodeRequired, err)
+			assert.Empty(t, options)
+		})
+
+		t.Run("wrong code", func(t *testing.T) {
+			mockColl := userMocks.NewMockUserCollection(t)
+			ctx := withMockPasskeySessions(t, createContextWithMockCollection(mockColl))
+			ctx, mockAttemptsColl := withNoLoginFailures(t, ctx)
+			mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+				Return(mongo.NewSingleResultFromDocument(mfaUser, nil, nil))
+			expectFailure(ctx, mockAttemptsColl)
+
+			userSvc := &userSvc{}
+			wrongCode := "AAAAA-BBBBB"
+			options, err := userSvc.BeginPasskeyRegistration(ctx, "test-id", currentPassword, &wrongCode)
+
+			assert.Equal(t, errInvalidMfaCode, err)
+			assert.Empty(t, options)
+		})
+	})
+}
+
+func TestListPasskeys(t *testing.T) {
+	lastUsed := time.Now().UTC().Truncate(time.Millisecond)
+	user := userDB{UserID: "test-id", Passkeys: []passkey.Credential{
+		{ID: []byte{1, 2, 3}, Name: "Laptop", CreationDate: lastUsed.Add(-time.Hour), LastUsedDate: &lastUsed},
+		{ID: []byte{4, 5, 6}, CreationDate: lastUsed},
+	}}
+	mockColl := userMocks.NewMockUserCollection(t)
+	ctx := createContextWithMockCollection(mockColl)
+	mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+		Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+
+	userSvc := &userSvc{}
+	passkeys, err := userSvc.ListPasskeys(ctx, "test-id")
+
+	require.NoError(t, err)
+	name := "Laptop"
+	assert.Equal(t, []*model.Passkey{
+		{ID: "AQID", Name: &name, CreatedAt: lastUsed.Add(-time.Hour), LastUsedAt: &lastUsed},
+		{ID: "BAUG", CreatedAt: lastUsed},
+	}, passkeys)
+}
+
+func TestDeletePasskey(t *testing.T) {
+	filter := bson.M{"user_id": "test-id", "passkeys.credential_id": []byte{1, 2, 3}}
+
+	t.Run("passkey of the user", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+		var update bson.M
+		mockColl.On("UpdateOne", ctx, filter, mock.AnythingOfType("bson.M")).
+			Run(func(args mock.Arguments) { update = args.Get(2).(bson.M) }).
+			Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+
+		userSvc := &userSvc{}
+		success, err := userSvc.DeletePasskey(ctx, "test-id", "AQID")
+
+		require.NoError(t, err)
+		assert.True(t, success)
+		assert.Equal(t, bson.M{"passkeys": bson.M{"credential_id": []byte{1, 2, 3}}}, update["$pull"])
+		assert.Equal(t, bson.M{"version": 1}, update["$inc"])
+	})
+
+	t.Run("passkey not found", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+		// Passkeys of other users do not match either
+		mockColl.On("UpdateOne", ctx, filter, mock.AnythingOfType("bson.M")).
+			Return(&mongo.UpdateResult{MatchedCount: 0}, nil)
+
+		userSvc := &userSvc{}
+		success, err := userSvc.DeletePasskey(ctx, "test-id", "AQID")
+
+		assert.Equal(t, errPasskeyNotFound, err)
+		assert.False(t, success)
+	})
+
+	t.Run("malformed ID", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		userSvc := &userSvc{}
+		success, err := userSvc.DeletePasskey(ctx, "test-id", "not base64!")
+
+		assert.Equal(t, errPasskeyNotFound, err)
+		assert.False(t, success)
+		mockColl.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestLoginWithPasskey(t *testing.T) {
+	// Helper function to begin a login and sign it with the authenticator
+	signLogin := func(t *testing.T, ctx context.Context, authenticator *passkeytest.Authenticator) string {
+		t.Helper()
+		userSvc := &userSvc{}
+		options, err := userSvc.BeginPasskeyLogin(ctx)
+		require.NoError(t, err)
+		response, err := authenticator.Login(options)
+		require.NoError(t, err)
+		return response
+	}
+
+	t.Run("registered passkey", func(t *testing.T) {
+		authenticator, err := passkeytest.NewAuthenticator(passkeyOrigin)
+		require.NoError(t, err)
+		// Users with MFA are not asked for a code either
+		user := userDB{UserID: "test-id", Email: "test@example.com", Role: model.RoleUser, MFAEnabled: true}
+		user.Passkeys = []passkey.Credential{registerPasskey(t, authenticator, user)}
+
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockRefreshColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		mockAttemptsColl := lockoutMocks.NewMockAttemptsCollection(t)
+		ctx := withMockRefreshTokenCollection(createContextWithMockCollection(mockColl), mockRefreshColl)
+		ctx = withMockPasskeySessions(t, ctx)
+		ctx = withMockLoginAttempts(ctx, mockAttemptsColl)
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+			Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+		// A passkey login lifts a lock like a password login does
+		expectLoginReset(ctx, mockAttemptsColl, user)
+		var update bson.M
+		mockColl.On("UpdateOne", ctx, bson.M{"user_id": "test-id", "passkeys.credential_id": authenticator.CredentialID()},
+			mock.AnythingOfType("bson.M")).
+			Run(func(args mock.Arguments) { update = args.Get(2).(bson.M) }).
+			Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+		mockColl.On("UpdateOne", ctx, bson.M{"user_id": "test-id"}, mock.AnythingOfType("bson.M")).
+			Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+		mockRefreshColl.On("InsertOne", ctx, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+
+		userSvc := &userSvc{}
+		result, err := userSvc.LoginWithPasskey(ctx, signLogin(t, ctx, authenticator))
+
+		require.NoError(t, err)
+		assert.Equal(t, model.AuthStatusAuthenticated, result.Status)
+		assert.Equal(t, "test-id", result.User.ID)
+		require.Len(t, result.User.Passkeys, 1)
+		assert.NotNil(t, result.AccessToken)
+		assert.NotNil(t, result.RefreshToken)
+		set := update["$set"].(bson.M)
+		assert.Equal(t, uint32(1), set["passkeys.$.sign_count"])
+		assert.Contains(t, set, "passkeys.$.last_used_date")
+	})
+
+	t.Run("password change required", func(t *testing.T) {
+		authenticator, err := passkeytest.NewAuthenticator(passkeyOrigin)
+		require.NoError(t, err)
+		user := userDB{UserID: "test-id", Email: "test@example.com", Role: model.RoleUser, MustChangePassword: true}
+		user.Passkeys = []passkey.Credential{registerPasskey(t, authenticator, user)}
+
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockAttemptsColl := lockoutMocks.NewMockAttemptsCollection(t)
+		ctx := withMockPasskeySessions(t, createContextWithMockCollection(mockColl))
+		ctx = withMockLoginAttempts(ctx, mockAttemptsColl)
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+			Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+		mockColl.On("UpdateOne", ctx, bson.M{"user_id": "test-id", "passkeys.credential_id": authenticator.CredentialID()},
+			mock.AnythingOfType("bson.M")).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+		expectLoginReset(ctx, mockAttemptsColl, user)
+
+		userSvc := &userSvc{}
+		result, err := userSvc.LoginWithPasskey(ctx, signLogin(t, ctx, authenticator))
+
+		// The passkey does not start a session either, only the password can be changed
+		require.NoError(t, err)
+		assert.Equal(t, model.AuthStatusPasswordChangeRequired, result.Status)
+		assert.Nil(t, result.RefreshToken)
+		require.NotNil(t, result.AccessToken)
+		parsed, err := token.JwtValidate(withUnrevokedTokens(t, ctx), *result.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, token.ScopeChangePassword, parsed.Claims.(*token.JwtCustomClaim).Scope)
+		mockColl.AssertNotCalled(t, "UpdateOne", ctx, bson.M{"user_id": "test-id"}, mock.Anything)
+	})
+
+	t.Run("removed passkey", func(t *testing.T) {
+		authenticator, err := passkeytest.NewAuthenticator(passkeyOrigin)
+		require.NoError(t, err)
+		user := userDB{UserID: "test-id", Email: "test@example.com", Role: model.RoleUser}
+		registerPasskey(t, authenticator, user)
+
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := withMockPasskeySessions(t, createContextWithMockCollection(mockColl))
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+			Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+
+		userSvc := &userSvc{}
+		result, err := userSvc.LoginWithPasskey(ctx, signLogin(t, ctx, authenticator))
+
+		assert.Equal(t, passkey.ErrInvalidCredential, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("deleted user", func(t *testing.T) {
+		authenticator, err := passkeytest.NewAuthenticator(passkeyOrigin)
+		require.NoError(t, err)
+		registerPasskey(t, authenticator, userDB{UserID: "test-id", Email: "test@example.com"})
+
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := withMockPasskeySessions(t, createContextWithMockCollection(mockColl))
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+			Return(mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil))
+
+		userSvc := &userSvc{}
+		result, err := userSvc.LoginWithPasskey(ctx, signLogin(t, ctx, authenticator))
+
+		assert.Equal(t, passkey.ErrInvalidCredential, err)
+		assert.Nil(t, result)
+	})
+}
+
+func TestUnlockUser(t *testing.T) {
+	t.Run("existing user", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockAttemptsColl := lockoutMocks.NewMockAttemptsCollection(t)
+		ctx := withMockLoginAttempts(createContextWithMockCollection(mockColl), mockAttemptsColl)
+		user := userDB{UserID: "test-id", UserName: "testuser", Email: "test@example.com"}
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+			Return(mongo.NewSingleResultFromDocument(user, nil, nil))
+		expectLoginReset(ctx, mockAttemptsColl, user)
+
+		userSvc := &userSvc{}
+		success, err := userSvc.UnlockUser(ctx, "test-id")
+
+		require.NoError(t, err)
+		assert.True(t, success)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockAttemptsColl := lockoutMocks.NewMockAttemptsCollection(t)
+		ctx := withMockLoginAttempts(createContextWithMockCollection(mockColl), mockAttemptsColl)
+		mockColl.On("FindOne", ctx, bson.M{"user_id": "test-id"}).
+			Return(mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil))
+
+		userSvc := &userSvc{}
+		success, err := userSvc.UnlockUser(ctx, "test-id")
+
+		assert.Equal(t, errNoUserFound, err)
+		assert.False(t, success)
+	})
+}
+
+func TestForcePasswordReset(t *testing.T) {
+	t.Run("existing user", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		mockRefreshColl := tokenMocks.NewMockRefreshTokenCollection(t)
+		mockRevokedColl := tokenMocks.NewMockRevokedTokenCollection(t)
+		ctx := withMockRefreshTokenCollection(createContextWithMockCollection(mockColl), mockRefreshColl)
+		ctx = token.NewContext(ctx, token.GetRevokedTokensCollectionKey(), mockRevokedColl)
+
+		var update bson.M
+		mockColl.On("UpdateOne", ctx, bson.M{"user_id": "test-id"}, mock.AnythingOfType("bson.M")).
+			Run(func(args mock.Arguments) { update = args.Get(2).(bson.M) }).
+			Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+		// Every session of the user is signed out
+		mockRefreshColl.On("UpdateMany", ctx, bson.M{"user_id": "test-id", "revoked": false}, mock.AnythingOfType("bson.M")).
+			Return(&mongo.UpdateResult{}, nil)
+		mockRevokedColl.On("InsertOne", ctx, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+
+		userSvc := &userSvc{}
+		success, err := userSvc.ForcePasswordReset(ctx, "test-id")
+
+		require.NoError(t, err)
+		assert.True(t, success)
+		assert.Equal(t, true, update["$set"].(bson.M)["must_change_password"])
+		// Passkeys the user did not register themselves cannot be told apart, so all are removed
+		assert.Equal(t, bson.M{"passkeys": ""}, update["$unset"])
+		assert.Equal(t, bson.M{"version": 1}, update["$inc"])
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+		mockColl.On("UpdateOne", ctx, bson.M{"user_id": "test-id"}, mock.AnythingOfType("bson.M")).
+			Return(&mongo.UpdateResult{MatchedCount: 0}, nil)
+
+		userSvc := &userSvc{}
+		success, err := userSvc.ForcePasswordReset(ctx, "test-id")
+
+		assert.Equal(t, errNoUserFound, err)
+		assert.False(t, success)
+	})
+}
+
+func TestListUsers(t *testing.T) {
+	lastLoginDate := testutils.CurrentTime.Now()
+	userDocs := []any{
+		bson.M{"user_id": "id-1", "email": "a@example.com", "user_name": "a", "role": model.RoleUser, "last_login_date": lastLoginDate},
+		bson.M{"user_id": "id-2", "email": "b@example.com", "user_name": "b", "role": model.RoleUser},
+		bson.M{"user_id": "id-3", "email": "c@example.com", "user_name": "c", "role": model.RoleUser},
+	}
+	emailSort := &model.UserSort{Field: model.UserSortFieldEmail}
+
+	t.Run("first page", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+		role := model.RoleUser
+		emailPrefix := "A.B+"
+		from := lastLoginDate.Add(-time.Hour)
+		filter := &model.UserFilter{Role: &role, EmailPrefix: &emailPrefix, LastLoginFrom: &from}
+		expectedQuery := bson.M{
+			"role":            model.RoleUser,
+			"email":           bson.Regex{Pattern: `^A\.B\+`, Options: "i"},
+			"last_login_date": bson.M{"$gte": from.UTC()},
+		}
+
+		mockColl.On("CountDocuments", ctx, expectedQuery).Return(int64(3), nil)
+		cursor, err := mongo.NewCursorFromDocuments(userDocs, nil, nil)
+		require.NoError(t, err)
+		mockColl.On("Find", ctx, expectedQuery, mock.Anything).Return(cursor, nil)
+
+		userSvc := &userSvc{}
+		result, err := userSvc.ListUsers(ctx, filter, nil, 2, nil)
+
+		require.NoError(t, err)
+		require.Len(t, result.Edges, 2)
+		assert.Equal(t, "id-1", result.Edges[0].Node.ID)
+		assert.Equal(t, lastLoginDate, result.Edges[0].Node.LastLoginDate.UTC())
+		assert.Equal(t, "id-2", result.Edges[1].Node.ID)
+		assert.Equal(t, 3, result.TotalCount)
+		assert.True(t, result.PageInfo.HasNextPage)
+		assert.False(t, result.PageInfo.HasPreviousPage)
+		assert.Equal(t, result.Edges[0].Cursor, *result.PageInfo.StartCursor)
+		assert.Equal(t, result.Edges[1].Cursor, *result.PageInfo.EndCursor)
+	})
+
+	t.Run("next page", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		direction := model.SortDirectionAsc
+		lastDoc := mustMarshal(t, userDocs[1])
+		after, err := encodeUserCursor(model.UserSort{Field: model.UserSortFieldEmail, Direction: &direction}, lastDoc)
+		require.NoError(t, err)
+		lastEmail := lastDoc.Lookup("email")
+		expectedQuery := bson.M{"$and": []bson.M{{}, {"$or": []bson.M{
+			{"email": bson.M{"$gt": lastEmail}},
+			{"email": lastEmail, "user_id": bson.M{"$gt": "id-2"}},
+		}}}}
+
+		mockColl.On("CountDocuments", ctx, bson.M{}).Return(int64(3), nil)
+		cursor, err := mongo.NewCursorFromDocuments(userDocs[2:], nil, nil)
+		require.NoError(t, err)
+		mockColl.On("Find", ctx, expectedQuery, mock.Anything).Return(cursor, nil)
+
+		userSvc := &userSvc{}
+		result, err := userSvc.ListUsers(ctx, nil, emailSort, 2, &after)
+
+		require.NoError(t, err)
+		require.Len(t, result.Edges, 1)
+		assert.Equal(t, "id-3", result.Edges[0].Node.ID)
+		assert.False(t, result.PageInfo.HasNextPage)
+		assert.True(t, result.PageInfo.HasPreviousPage)
+	})
+
+	t.Run("empty page", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		mockColl.On("CountDocuments", ctx, bson.M{}).Return(int64(0), nil)
+		cursor, err := mongo.NewCursorFromDocuments(nil, nil, nil)
+		require.NoError(t, err)
+		mockColl.On("Find", ctx, bson.M{}, mock.Anything).Return(cursor, nil)
+
+		userSvc := &userSvc{}
+		result, err := userSvc.ListUsers(ctx, nil, nil, DefaultPageSize, nil)
+
+		require.NoError(t, err)
+		assert.Empty(t, result.Edges)
+		assert.Nil(t, result.PageInfo.StartCursor)
+		assert.Nil(t, result.PageInfo.EndCursor)
+	})
+
+	t.Run("cursor from a different sort", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+
+		direction := model.SortDirectionDesc
+		after, err := encodeUserCursor(model.UserSort{Field: model.UserSortFieldEmail, Direction: &direction},
+			mustMarshal(t, userDocs[0]))
+		require.NoError(t, err)
+		mockColl.On("CountDocuments", ctx, bson.M{}).Return(int64(3), nil)
+
+		userSvc := &userSvc{}
+		result, err := userSvc.ListUsers(ctx, nil, emailSort, 2, &after)
+
+		assert.Nil(t, result)
+		assert.Equal(t, errInvalidCursor, err)
+	})
+
+	t.Run("malformed cursor", func(t *testing.T) {
+		mockColl := userMocks.NewMockUserCollection(t)
+		ctx := createContextWithMockCollection(mockColl)
+		mockColl.On("CountDocuments", ctx, bson.M{}).Return(int64(3), nil)
+
+		after := "not-a-cursor"
+		userSvc := &userSvc{}
+		result, err := userSvc.ListUsers(ctx, nil, nil, 2, &after)
+
+		assert.Nil(t, result)
+		assert.Equal(t, errInvalidCursor, err)
+	})
+
+	t.Run("invalid page size", func(t *testing.T) {
+		ctx := createContextWithMockCollection(userMocks.NewMockUserCollection(t))
+
+		userSvc := &userSvc{}
+		for _, first := range []int{0, maxPageSize + 1} {
+			result, err := userSvc.ListUsers(ctx, nil, nil, first, nil)
+			assert.Nil(t, result)
+			assert.Equal(t, errInvalidPageSize, err)
+		}
+	})
+}
+
+func TestAfterCursorFilter(t *testing.T) {
+	asc, desc := model.SortDirectionAsc, model.SortDirectionDesc
+	null := bson.RawValue{Type: bson.TypeNull}
+	date := mustMarshal(t, bson.M{"v": testutils.CurrentTime.Now()}).Lookup("v")
+
+	tests := []struct {
+		name      string
+		direction model.SortDirection
+		value     bson.RawValue
+		expected  bson.M
+	}{
+		{
+			name:      "null ascending continues with non-null values",
+			direction: asc,
+			value:     null,
+			expected: bson.M{"$or": []bson.M{
+				{"last_login_date": nil, "user_id": bson.M{"$gt": "id-1"}},
+				{"last_login_date": bson.M{"$ne": nil}},
+			}},
+		},
+		{
+			name:      "null descending is the end of the list",
+			direction: desc,
+			value:     null,
+			expected:  bson.M{"last_login_date": nil, "user_id": bson.M{"$lt": "id-1"}},
+		},
+		{
+			name:      "value ascending",
+			direction: asc,
+			value:     date,
+			expected: bson.M{"$or": []bson.M{
+				{"last_login_date": bson.M{"$gt": date}},
+				{"last_login_date": date, "user_id": bson.M{"$gt": "id-1"}},
+			}},
+		},
+		{
+			name:      "value descending continues with null values",
+			direction: desc,
+			value:     date,
+			expected: bson.M{"$or": []bson.M{
+				{"last_login_date": bson.M{"$lt": date}},
+				{"last_login_date": date, "user_id": bson.M{"$lt": "id-1"}},
+				{"last_login_date": nil},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sort := model.UserSort{Field: model.UserSortFieldLastLoginDate, Direction: &tt.direction}
+			filter := afterCursorFilter(sort, &userCursor{Value: tt.value, UserID: "id-1"})
+			assert.Equal(t, tt.expected, filter)
+		})
+	}
+}
+
+// Helper function to marshal a document as it would be read from a cursor
+func mustMarshal(t *testing.T, doc any) bson.Raw {
+	raw, err := bson.Marshal(doc)
+	require.NoError(t, err)
+	return raw
+}
+
+// Helper function to hash a password as the configured policy does
+func mustHashPassword(t *testing.T, plaintext string) string {
+	t.Helper()
+	hasher, err := password.New(context.Background())
+	require.NoError(t, err)
+	hash, err := hasher.Hash(plaintext)
+	require.NoError(t, err)
+	return hash
+}
+
+// Helper function to check that a hash is of the configured policy and matches the password
+func assertPasswordHash(t *testing.T, hash string, plaintext string) {
+	t.Helper()
+	hasher, err := password.New(context.Background())
+	require.NoError(t, err)
+	assert.False(t, hasher.NeedsRehash(hash))
+	valid, err := hasher.Verify(hash, plaintext)
+	require.NoError(t, err)
+	assert.True(t, valid)
+}
+
+// Helper function to create a context with mock collection
+func createContextWithMockCollection(collection UserCollection) context.Context {
+	ctx := context.Background()
+	return NewContext(ctx, GetUsersCollectionKey(), collection)
+}
+
+// Helper function to add the mocks needed to send an email verification to a context
+func withMockEmailVerification(t *testing.T, ctx context.Context) (context.Context, *notifyMocks.MockSender) {
+	mockActionColl := tokenMocks.NewMockActionTokenCollection(t)
+	mockActionColl.On("UpdateMany", mock.Anything, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M")).
+		Return(&mongo.UpdateResult{}, nil)
+	mockActionColl.On("InsertOne", mock.Anything, mock.AnythingOfType("token.actionTokenDB")).
+		Return(&mongo.InsertOneResult{}, nil)
+	mockSender := notifyMocks.NewMockSender(t)
+	mockSender.On("Send", mock.Anything, mock.AnythingOfType("notify.Message")).Return(nil)
+
+	ctx = token.NewContext(ctx, token.GetActionTokensCollectionKey(), mockActionColl)
+	return notify.NewContext(ctx, mockSender), mockSender
+}
+
+// Helper function to add a mock refresh token collection to a context
+func withMockRefreshTokenCollection(ctx context.Context, collection token.RefreshTokenCollection) context.Context {
+	return token.NewContext(ctx, token.GetRefreshTokensCollectionKey(), collection)
+}
+
+// Helper function to capture what is logged for the rest of the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &logs
+}
+
+// Helper function to add a mock login attempts collection to a context
+func withMockLoginAttempts(ctx context.Context, collection lockout.AttemptsCollection) context.Context {
+	return lockout.NewContext(ctx, lockout.GetAttemptsCollectionKey(), collection)
+}
+
+// Helper function to add a login attempts collection kept in memory to a context, returning a
+// function which lets the delay after the last failures pass
+func withLoginAttemptsInMemory(t *testing.T, ctx context.Context) (context.Context, func()) {
+	mockAttemptsColl := lockoutMocks.NewMockAttemptsCollection(t)
+	ctx = withMockLoginAttempts(ctx, mockAttemptsColl)
+	failures := map[string]int{}
+	lastFailure := map[string]time.Time{}
+	mockAttemptsColl.On("FindOne", ctx, mock.AnythingOfType("bson.M")).Return(
+		func(_ context.Context, filter any, _ ...options.Lister[options.FindOneOptions]) *mongo.SingleResult {
+			key := filter.(bson.M)["key"].(string)
+			if failures[key] == 0 {
+				return mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil)
+			}
+			return mongo.NewSingleResultFromDocument(
+				bson.M{"key": key, "failures": failures[key], "last_failure_date": lastFailure[key]}, nil, nil)
+		}).Maybe()
+	mockAttemptsColl.On("FindOneAndUpdate", ctx, mock.AnythingOfType("bson.M"), mock.Anything, mock.Anything).Return(
+		func(_ context.Context, filter any, _ any, _ ...options.Lister[options.FindOneAndUpdateOptions]) *mongo.SingleResult {
+			key := filter.(bson.M)["key"].(string)
+			failures[key]++
+			lastFailure[key] = time.Now().UTC()
+			return mongo.NewSingleResultFromDocument(bson.M{"key": key, "failures": failures[key]}, nil, nil)
+		}).Maybe()
+	return ctx, func() {
+		for key := range lastFailure {
+			lastFailure[key] = lastFailure[key].Add(-2 * time.Minute)
+		}
+	}
+}
+
+// Helper function to build the login attempts key of an identifier, like the lockout package does
+func loginAttemptsKey(identifier string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(identifier))))
+	return "login:" + hex.EncodeToString(sum[:])
+}
+
+// Helper function to expect the failed logins with the user name and email of a user to be
+// forgotten
+func expectLoginReset(ctx context.Context, mockAttemptsColl *lockoutMocks.MockAttemptsCollection, user userDB) {
+	for _, identifier := range []string{user.UserName, user.Email} {
+		mockAttemptsColl.On("DeleteOne", ctx, bson.M{"key": loginAttemptsKey(identifier)}).
+			Return(&mongo.DeleteResult{}, nil)
+	}
+}
+
+// Helper function to add a mock WebAuthn sessions collection to a context, which hands each session
+// inserted into it out once
+func withMockPasskeySessions(t *testing.T, ctx context.Context) context.Context {
+	mockSessionColl := passkeyMocks.NewMockSessionCollection(t)
+	sessions := map[string]bson.Raw{}
+	mockSessionColl.On("InsertOne", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			session := mustMarshal(t, args.Get(1))
+			sessions[session.Lookup("challenge").StringValue()] = session
+		}).
+		Return(&mongo.InsertOneResult{}, nil).Maybe()
+	mockSessionColl.On("FindOneAndUpdate", mock.Anything, mock.AnythingOfType("bson.M"), mock.AnythingOfType("bson.M")).
+		Return(func(_ context.Context, filter any, _ any, _ ...options.Lister[options.FindOneAndUpdateOptions]) *mongo.SingleResult {
+			challenge := filter.(bson.M)["challenge"].(string)
+			session, ok := sessions[challenge]
+			if !ok {
+				return mongo.NewSingleResultFromDocument(bson.M{}, mongo.ErrNoDocuments, nil)
+			}
+			delete(sessions, challenge)
+			return mongo.NewSingleResultFromDocument(session, nil, nil)
+		}).Maybe()
+	return passkey.NewContext(ctx, passkey.GetSessionsCollectionKey(), mockSessionColl)
+}
+
+// Helper function to add a mock revoked token collection to a context which reports every token as active
+func withUnrevokedTokens(t *testing.T, ctx context.Context) context.Context {
+	mockRevokedColl := tokenMocks.NewMockRevokedTokenCollection(t)
+	mockRevokedColl.On("CountDocuments", mock.Anything, mock.AnythingOfType("bson.M")).Return(int64(0), nil)
+	return token.NewContext(ctx, token.GetRevokedTokensCollectionKey(), mockRevokedColl)
 }
 
 // Helper function to check if a bson.D contains a key